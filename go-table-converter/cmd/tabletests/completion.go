@@ -0,0 +1,245 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// commandFlags returns the flags of a command, sorted by name
+func commandFlags(cmd command) []*flag.Flag {
+	if cmd.flags == nil {
+		return nil
+	}
+	set := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	cmd.flags(set)
+	var flags []*flag.Flag
+	set.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f)
+	})
+	return flags
+}
+
+// isBoolFlag reports whether a flag takes no value, like -l as opposed to -format json
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// completionShells are the shells completion scripts can be generated for
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// runCompletion runs the completion subcommand, which writes a script completing the
+// commands, flags, and path arguments of tabletests in a shell
+func runCompletion(args []string) int {
+	flags := flag.NewFlagSet("completion", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tabletests completion <bash|zsh|fish>")
+		fmt.Fprintln(flags.Output(), "Load the script with:")
+		fmt.Fprintln(flags.Output(), "  bash: source <(tabletests completion bash)")
+		fmt.Fprintln(flags.Output(), "  zsh:  tabletests completion zsh > \"${fpath[1]}/_tabletests\"")
+		fmt.Fprintln(flags.Output(), "  fish: tabletests completion fish > ~/.config/fish/completions/tabletests.fish")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	var write func(io.Writer) error
+	switch shell := flags.Arg(0); shell {
+	case "bash":
+		write = writeBashCompletion
+	case "zsh":
+		write = writeZshCompletion
+	case "fish":
+		write = writeFishCompletion
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown shell %q: use %s\n", shell, strings.Join(completionShells, ", "))
+		return 2
+	}
+	if err := write(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// commandNames returns the names of the commands, help included
+func commandNames() []string {
+	names := make([]string, 0, len(commands)+1)
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+	return append(names, "help")
+}
+
+// shellQuote quotes s in single quotes for bash and zsh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeBashCompletion writes the completion script for bash. Flags taking a value
+// complete file names for it, and path arguments complete directories and Go files.
+func writeBashCompletion(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("# bash completion for tabletests, generated by 'tabletests completion bash'\n")
+	sb.WriteString("_tabletests() {\n")
+	sb.WriteString("    local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}\n")
+	fmt.Fprintf(&sb, "    local commands=%s\n", shellQuote(strings.Join(commandNames(), " ")))
+	sb.WriteString("    if [[ $COMP_CWORD -eq 1 ]]; then\n")
+	sb.WriteString("        COMPREPLY=($(compgen -W \"$commands\" -- \"$cur\"))\n")
+	sb.WriteString("        return\n")
+	sb.WriteString("    fi\n\n")
+	sb.WriteString("    local flags= valueFlags= args=\n")
+	sb.WriteString("    case ${COMP_WORDS[1]} in\n")
+	sb.WriteString("    help)\n")
+	sb.WriteString("        [[ $COMP_CWORD -eq 2 ]] && COMPREPLY=($(compgen -W \"$commands\" -- \"$cur\"))\n")
+	sb.WriteString("        return\n")
+	sb.WriteString("        ;;\n")
+	for _, cmd := range commands {
+		var names, values []string
+		for _, f := range commandFlags(cmd) {
+			names = append(names, "-"+f.Name)
+			if !isBoolFlag(f) {
+				values = append(values, "-"+f.Name)
+			}
+		}
+		args := ""
+		switch {
+		case cmd.name == "completion":
+			args = strings.Join(completionShells, " ")
+		case cmd.paths:
+			args = "paths"
+		}
+		fmt.Fprintf(&sb, "    %s)\n", cmd.name)
+		fmt.Fprintf(&sb, "        flags=%s\n", shellQuote(strings.Join(names, " ")))
+		fmt.Fprintf(&sb, "        valueFlags=%s\n", shellQuote(strings.Join(values, " ")))
+		fmt.Fprintf(&sb, "        args=%s\n", shellQuote(args))
+		sb.WriteString("        ;;\n")
+	}
+	sb.WriteString("    *)\n")
+	sb.WriteString("        return\n")
+	sb.WriteString("        ;;\n")
+	sb.WriteString("    esac\n\n")
+	sb.WriteString("    if [[ \" $valueFlags \" == *\" $prev \"* ]]; then\n")
+	sb.WriteString("        COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	sb.WriteString("        return\n")
+	sb.WriteString("    fi\n")
+	sb.WriteString("    if [[ $cur == -* ]]; then\n")
+	sb.WriteString("        COMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n")
+	sb.WriteString("        return\n")
+	sb.WriteString("    fi\n")
+	sb.WriteString("    case $args in\n")
+	sb.WriteString("    '')\n")
+	sb.WriteString("        ;;\n")
+	sb.WriteString("    paths)\n")
+	sb.WriteString("        COMPREPLY=($(compgen -d -- \"$cur\") $(compgen -f -X '!*.go' -- \"$cur\") $(compgen -W './...' -- \"$cur\"))\n")
+	sb.WriteString("        ;;\n")
+	sb.WriteString("    *)\n")
+	sb.WriteString("        COMPREPLY=($(compgen -W \"$args\" -- \"$cur\"))\n")
+	sb.WriteString("        ;;\n")
+	sb.WriteString("    esac\n")
+	sb.WriteString("}\n")
+	sb.WriteString("complete -o filenames -F _tabletests tabletests\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// zshDescription escapes a description for the brackets of an _arguments spec
+func zshDescription(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+	return strings.ReplaceAll(s, "'", `'\''`)
+}
+
+// writeZshCompletion writes the completion script for zsh, to be installed as _tabletests
+// in a directory of $fpath
+func writeZshCompletion(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("#compdef tabletests\n")
+	sb.WriteString("# zsh completion for tabletests, generated by 'tabletests completion zsh'\n\n")
+	sb.WriteString("_tabletests() {\n")
+	sb.WriteString("    local -a commands\n")
+	sb.WriteString("    commands=(\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&sb, "        '%s:%s'\n", cmd.name, zshDescription(cmd.summary))
+	}
+	sb.WriteString("        'help:show the flags of a command'\n")
+	sb.WriteString("    )\n")
+	sb.WriteString("    if (( CURRENT == 2 )); then\n")
+	sb.WriteString("        _describe 'command' commands\n")
+	sb.WriteString("        return\n")
+	sb.WriteString("    fi\n\n")
+	sb.WriteString("    shift words\n")
+	sb.WriteString("    (( CURRENT-- ))\n")
+	sb.WriteString("    case $words[1] in\n")
+	sb.WriteString("    help)\n")
+	sb.WriteString("        (( CURRENT == 2 )) && _describe 'command' commands\n")
+	sb.WriteString("        ;;\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&sb, "    %s)\n", cmd.name)
+		sb.WriteString("        _arguments -S")
+		for _, f := range commandFlags(cmd) {
+			spec := "-" + f.Name + "[" + zshDescription(f.Usage) + "]"
+			if !isBoolFlag(f) {
+				spec += ":" + f.Name + ":_files"
+			}
+			fmt.Fprintf(&sb, " \\\n            '%s'", spec)
+		}
+		switch {
+		case cmd.name == "completion":
+			fmt.Fprintf(&sb, " \\\n            '1:shell:(%s)'", strings.Join(completionShells, " "))
+		case cmd.paths:
+			sb.WriteString(" \\\n            '*:path:_files'")
+		}
+		sb.WriteString("\n        ;;\n")
+	}
+	sb.WriteString("    esac\n")
+	sb.WriteString("}\n\n")
+	sb.WriteString("if [[ $zsh_eval_context[-1] == loadautofunc ]]; then\n")
+	sb.WriteString("    _tabletests \"$@\"\n")
+	sb.WriteString("else\n")
+	sb.WriteString("    compdef _tabletests tabletests\n")
+	sb.WriteString("fi\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// fishQuote quotes s in single quotes for fish
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// writeFishCompletion writes the completion script for fish
+func writeFishCompletion(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("# fish completion for tabletests, generated by 'tabletests completion fish'\n")
+	sb.WriteString("complete -c tabletests -f\n")
+	all := strings.Join(commandNames(), " ")
+	for _, cmd := range commands {
+		fmt.Fprintf(&sb, "complete -c tabletests -n '__fish_use_subcommand' -a %s -d %s\n", cmd.name, fishQuote(cmd.summary))
+	}
+	sb.WriteString("complete -c tabletests -n '__fish_use_subcommand' -a help -d 'show the flags of a command'\n")
+	fmt.Fprintf(&sb, "complete -c tabletests -n '__fish_seen_subcommand_from help' -a %s\n", fishQuote(all))
+	for _, cmd := range commands {
+		cond := fishQuote("__fish_seen_subcommand_from " + cmd.name)
+		for _, f := range commandFlags(cmd) {
+			value := ""
+			if !isBoolFlag(f) {
+				value = " -r -F"
+			}
+			fmt.Fprintf(&sb, "complete -c tabletests -n %s -o %s%s -d %s\n", cond, f.Name, value, fishQuote(f.Usage))
+		}
+		switch {
+		case cmd.name == "completion":
+			fmt.Fprintf(&sb, "complete -c tabletests -n %s -a %s\n", cond, fishQuote(strings.Join(completionShells, " ")))
+		case cmd.paths:
+			fmt.Fprintf(&sb, "complete -c tabletests -n %s -F -a './...'\n", cond)
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/khalilchatoo/claude-playground/go-table-converter/tableconv"
+)
+
+// ANSI escape sequences of the colors of diffs, the ones git uses
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// isTerminal reports whether a file is a terminal rather than a pipe or a regular file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// useColor reports whether diffs written to a file are colored under a -color mode:
+// always, never, or auto, which colors them for terminals unless NO_COLOR is set
+// (https://no-color.org) or the terminal is dumb
+func useColor(mode string, f *os.File) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && isTerminal(f)
+}
+
+// colorizeDiff colors the lines of a unified diff: file headers bold, hunk headers cyan,
+// removed lines red, and added lines green
+func colorizeDiff(patch string) string {
+	var sb strings.Builder
+	header := false
+	for _, line := range strings.SplitAfter(patch, "\n") {
+		text := strings.TrimSuffix(line, "\n")
+		newline := line[len(text):]
+		switch {
+		case strings.HasPrefix(text, "diff --git "):
+			header = true
+			sb.WriteString(colorBold + text + colorReset + newline)
+		case header && (strings.HasPrefix(text, "--- ") || strings.HasPrefix(text, "+++ ")):
+			sb.WriteString(colorBold + text + colorReset + newline)
+		case strings.HasPrefix(text, "@@ "):
+			// Only the ranges are colored, not the explanations following them
+			header = false
+			end := strings.Index(text[3:], " @@")
+			if end < 0 {
+				sb.WriteString(colorCyan + text + colorReset + newline)
+				break
+			}
+			end += 6
+			sb.WriteString(colorCyan + text[:end] + colorReset + text[end:] + newline)
+		case !header && strings.HasPrefix(text, "-"):
+			sb.WriteString(colorRed + text + colorReset + newline)
+		case !header && strings.HasPrefix(text, "+"):
+			sb.WriteString(colorGreen + text + colorReset + newline)
+		default:
+			sb.WriteString(line)
+		}
+	}
+	return sb.String()
+}
+
+// diffText returns the changes of a dry run as a unified diff, colored if asked to
+func diffText(result tableconv.ConversionResult, color bool) (string, error) {
+	var sb strings.Builder
+	if err := tableconv.WritePatch(&sb, result); err != nil {
+		return "", err
+	}
+	if color {
+		return colorizeDiff(sb.String()), nil
+	}
+	return sb.String(), nil
+}
+
+// writePaged writes text to standard output, through the pager when page is set and
+// standard output is a terminal. The pager is $PAGER, less by default, run like git runs
+// it: with LESS=FRX unless LESS is set, so that text fitting on one screen is printed as
+// it is. A PAGER of cat, or an empty one, disables paging.
+func writePaged(text string, page bool) error {
+	pager, set := os.LookupEnv("PAGER")
+	if !set {
+		pager = "less"
+	}
+	if !page || pager == "" || pager == "cat" || !isTerminal(os.Stdout) {
+		_, err := io.WriteString(os.Stdout, text)
+		return err
+	}
+
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() != 127 {
+			// The pager ran, and quitting it early isn't an error; the shell exits with
+			// 127 when it can't find the pager
+			return nil
+		}
+		// Without a working pager, print the text directly
+		fmt.Fprintf(os.Stderr, "Warning: can't run pager %q: %v\n", pager, err)
+		_, err := io.WriteString(os.Stdout, text)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,1584 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/khalilchatoo/claude-playground/go-table-converter/tableconv"
+)
+
+// command is a subcommand of tabletests
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+	// paths is set for commands taking files, directories, or package patterns as
+	// arguments, which shell completion completes
+	paths bool
+	// flags defines the flags of the command on a flag set, for shell completion
+	flags func(flags *flag.FlagSet)
+}
+
+// flagsOf adapts a function defining the flags of a command for command.flags
+func flagsOf[T any](add func(flags *flag.FlagSet) T) func(flags *flag.FlagSet) {
+	return func(flags *flag.FlagSet) { add(flags) }
+}
+
+// commands are the subcommands of tabletests, in the order the usage lists them
+var commands = []command{
+	{"convert", "convert slice-based table tests to map-based tables", runConvert, true, flagsOf(addConvertFlags)},
+	{"check", "report slice-based table tests without modifying files", runCheck, true, flagsOf(addCheckFlags)},
+	{"analyze", "take stock of the table tests of a tree", runAnalyze, true, flagsOf(addAnalyzeFlags)},
+	{"generate", "add a map-based table test for a function", runGenerate, true, flagsOf(addGenerateFlags)},
+	{"export", "export the cases of a table test to a fixture", runExport, true, flagsOf(addExportFlags)},
+	{"import", "inline the cases of a fixture into a table test", runImport, true, flagsOf(addImportFlags)},
+	{"plan", "record the edits of a conversion for review", runPlan, true, flagsOf(addPlanFlags)},
+	{"apply", "apply a recorded plan to unchanged files", runApply, true, flagsOf(addApplyFlags)},
+	{"revert", "restore the files written by a run", runRevert, true, flagsOf(addRevertFlags)},
+	{"remote", "convert git repositories into pull requests", runRemote, false, flagsOf(addRemoteFlags)},
+	{"serve", "serve an HTTP API converting posted sources", runServe, false, flagsOf(addServeFlags)},
+	{"lsp", "serve the Language Server Protocol for editors", runLSP, false, flagsOf(addLSPFlags)},
+	{"mcp", "serve the Model Context Protocol for AI assistants", runMCP, false, flagsOf(addMCPFlags)},
+}
+
+func init() {
+	// completion lists the commands, so it can't be part of their initializer
+	commands = append(commands, command{"completion", "generate a shell completion script", runCompletion, false, nil})
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	name, args := os.Args[1], os.Args[2:]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		// 'help convert' shows the flags of convert
+		if len(args) > 0 {
+			if cmd, ok := findCommand(args[0]); ok {
+				os.Exit(cmd.run([]string{"-h"}))
+			}
+			fmt.Fprintf(os.Stderr, "Unknown command %q\n", args[0])
+			os.Exit(2)
+		}
+		usage(os.Stdout)
+		return
+	}
+
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", name)
+		if _, err := os.Stat(name); err == nil || strings.HasPrefix(name, "-") {
+			// Flags and paths used to be given without a command
+			fmt.Fprintf(os.Stderr, "Run instead: tabletests %s\n", legacyCommand(os.Args[1:]))
+		}
+		fmt.Fprintln(os.Stderr)
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	os.Exit(cmd.run(args))
+}
+
+// legacyCommand returns the subcommand and arguments doing what the arguments did when
+// they were given without a subcommand
+func legacyCommand(args []string) string {
+	name := "convert"
+	var rest []string
+	for _, arg := range args {
+		switch arg {
+		case "-check", "--check":
+			name = "check"
+			continue
+		case "-l", "--l", "-why-not", "--why-not":
+			name = "check"
+		}
+		rest = append(rest, arg)
+	}
+	return name + " " + strings.Join(rest, " ")
+}
+
+// findCommand returns the subcommand of the given name
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// usage writes the list of subcommands
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: tabletests <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'tabletests help <command>' for the flags of a command.")
+}
+
+// optionFlags are the flags selecting and configuring the tables to convert, shared by
+// the convert and check subcommands
+type optionFlags struct {
+	allFiles         *bool
+	includeVendor    *bool
+	includeTestdata  *bool
+	includeHidden    *bool
+	followSymlinks   *bool
+	noTypeCheck      *bool
+	cacheDir         *string
+	noCache          *bool
+	run              *string
+	since            *string
+	staged           *bool
+	tabulate         *bool
+	subtests         *bool
+	parallel         *bool
+	ignoreOrder      *bool
+	suffixDuplicates *bool
+	dedupe           *bool
+	keys             *string
+	extractTypes     *bool
+	keyedFields      *bool
+	names            *string
+	deadFields       *string
+	cmpDiff          *bool
+	assertions       *string
+	fromGinkgo       *bool
+	fromGocheck      *bool
+	golden           *int
+	configFile       *string
+	quiet            *bool
+	logFormat        *string
+	cpuProfile       *string
+	memProfile       *string
+	timings          *bool
+}
+
+// addOptionFlags defines the flags configuring conversions on a flag set
+func addOptionFlags(flags *flag.FlagSet) *optionFlags {
+	defaultCacheDir, _ := tableconv.DefaultCacheDir()
+	return &optionFlags{
+		allFiles:         flags.Bool("all-files", false, "process every .go file instead of only _test.go files"),
+		includeVendor:    flags.Bool("include-vendor", false, "descend into vendor and node_modules directories"),
+		includeTestdata:  flags.Bool("include-testdata", false, "descend into testdata directories"),
+		includeHidden:    flags.Bool("include-hidden", false, "descend into directories starting with \".\" or \"_\""),
+		followSymlinks:   flags.Bool("follow-symlinks", false, "descend into symbolic links to directories, converting each directory once"),
+		noTypeCheck:      flags.Bool("no-typecheck", false, "convert files without checking that they still compile"),
+		cacheDir:         flags.String("cache-dir", defaultCacheDir, "directory for remembering unchanged files between runs"),
+		noCache:          flags.Bool("no-cache", false, "process every file even if it is unchanged since an earlier run"),
+		run:              flags.String("run", "", "only convert the tables of test functions matching this regular expression, like go test -run"),
+		since:            flags.String("since", "", "only convert files changed since the merge base of this git ref and HEAD (e.g. origin/main)"),
+		staged:           flags.Bool("staged", false, "convert the versions of files staged in the git index and stage the result, for use as a pre-commit hook"),
+		tabulate:         flags.Bool("tabulate", false, "rewrite runs of repeated assertions that only differ in their literals into table tests"),
+		subtests:         flags.Bool("subtests", false, "wrap the bodies of loops over converted tables in t.Run subtests"),
+		parallel:         flags.Bool("parallel", false, "add t.Parallel() calls to the subtests of converted tables and their test"),
+		ignoreOrder:      flags.Bool("ignore-order", false, "convert tables whose loops depend on the order of their cases, reporting them instead of skipping them"),
+		suffixDuplicates: flags.Bool("suffix-duplicates", false, "number cases that share a name (\"name #2\") instead of skipping their table"),
+		dedupe:           flags.Bool("dedupe", false, "remove cases of converted tables that repeat the values of an earlier case under another name"),
+		keys:             flags.String("keys", "", "convert tables without a name field, naming their cases by index (\"case_01\") or by fields (\"a=2,b=3\")"),
+		extractTypes:     flags.Bool("extract-types", false, "lift the anonymous case structs of converted tables into named types declared above their test (type TestAddCase struct{...})"),
+		keyedFields:      flags.Bool("keyed-fields", false, "rewrite positional cases of converted tables into keyed literals naming each field ({a: 2, b: 3, expected: 5})"),
+		names:            flags.String("names", "", "report case names that are awkward to select with go test -run (warn), or replace their spaces and strip their slashes (sanitize)"),
+		deadFields:       flags.String("dead-fields", "", "report fields of converted case structs that no loop reads (warn), or remove them from the struct and every case (remove)"),
+		cmpDiff:          flags.Bool("cmp-diff", false, "replace reflect.DeepEqual assertions in loops over converted tables with cmp.Diff, in modules requiring github.com/google/go-cmp"),
+		assertions:       flags.String("assertions", "", "migrate assertions in loops over converted tables to testify's assert/require.Equal (testify), or back to if blocks (std)"),
+		fromGinkgo:       flags.Bool("from-ginkgo", false, "rewrite Ginkgo DescribeTable specs into map-based table tests keyed by entry description, with t.Run subtests"),
+		fromGocheck:      flags.Bool("from-gocheck", false, "rewrite the test methods of gopkg.in/check.v1 suites into test functions, turning c.Assert and c.Check into if blocks"),
+		golden:           flags.Int("golden", 0, "move string and []byte case values of at least this many bytes into testdata/<test>/<case>.golden files read back by the tests (0 disables)"),
+		configFile:       flags.String("config", "", "run the pipeline of built-in transforms and the rename-field and replace-call rules of this YAML file in the same pass as the conversion"),
+		quiet:            flags.Bool("quiet", false, "don't report progress or the files processed, for CI logs"),
+		logFormat:        flags.String("log-format", "text", "format of the log of the files processed: text, or ndjson for a JSON event per file on standard error"),
+		cpuProfile:       flags.String("cpuprofile", "", "write a CPU profile of the run to this file, for go tool pprof"),
+		memProfile:       flags.String("memprofile", "", "write a profile of the memory in use at the end of the run to this file, for go tool pprof"),
+		timings:          flags.Bool("timings", false, "print the time spent parsing, transforming, formatting, type-checking, writing, and testing on standard error"),
+	}
+}
+
+// options checks the flags and returns the options they configure for converting the
+// given paths
+func (f *optionFlags) options(paths []string) (tableconv.Options, error) {
+	switch tableconv.KeyStrategy(*f.keys) {
+	case tableconv.KeysNone, tableconv.KeysIndex, tableconv.KeysFields:
+	default:
+		return tableconv.Options{}, fmt.Errorf("unknown key strategy %q: use index or fields", *f.keys)
+	}
+
+	switch tableconv.NameCheck(*f.names) {
+	case tableconv.NameCheckNone, tableconv.NameCheckWarn, tableconv.NameCheckSanitize:
+	default:
+		return tableconv.Options{}, fmt.Errorf("unknown name check %q: use warn or sanitize", *f.names)
+	}
+
+	switch tableconv.AssertionStyle(*f.assertions) {
+	case tableconv.AssertionsUnchanged, tableconv.AssertionsTestify, tableconv.AssertionsStd:
+	default:
+		return tableconv.Options{}, fmt.Errorf("unknown assertion style %q: use testify or std", *f.assertions)
+	}
+
+	switch tableconv.DeadFields(*f.deadFields) {
+	case tableconv.DeadFieldsNone, tableconv.DeadFieldsWarn, tableconv.DeadFieldsRemove:
+	default:
+		return tableconv.Options{}, fmt.Errorf("unknown dead field check %q: use warn or remove", *f.deadFields)
+	}
+
+	switch *f.logFormat {
+	case "text", "ndjson":
+	default:
+		return tableconv.Options{}, fmt.Errorf("unknown log format %q: use text or ndjson", *f.logFormat)
+	}
+
+	opts := tableconv.Options{
+		AllFiles:         *f.allFiles,
+		IncludeVendor:    *f.includeVendor,
+		IncludeTestdata:  *f.includeTestdata,
+		IncludeHidden:    *f.includeHidden,
+		FollowSymlinks:   *f.followSymlinks,
+		SkipTypeCheck:    *f.noTypeCheck,
+		SuffixDuplicates: *f.suffixDuplicates,
+		Dedupe:           *f.dedupe,
+		IgnoreOrder:      *f.ignoreOrder,
+		Subtests:         *f.subtests,
+		Tabulate:         *f.tabulate,
+		Parallel:         *f.parallel,
+		Keys:             tableconv.KeyStrategy(*f.keys),
+		NameCheck:        tableconv.NameCheck(*f.names),
+		ExtractTypes:     *f.extractTypes,
+		KeyedFields:      *f.keyedFields,
+		DeadFields:       tableconv.DeadFields(*f.deadFields),
+		CmpDiff:          *f.cmpDiff,
+		Assertions:       tableconv.AssertionStyle(*f.assertions),
+		FromGinkgo:       *f.fromGinkgo,
+		FromGocheck:      *f.fromGocheck,
+		Golden:           *f.golden,
+	}
+	if !*f.noCache {
+		opts.CacheDir = *f.cacheDir
+	}
+	if *f.configFile != "" {
+		cfg, err := tableconv.LoadConfig(*f.configFile)
+		if err != nil {
+			return tableconv.Options{}, err
+		}
+		cfg.Apply(&opts)
+	}
+	if *f.staged && len(paths) > 1 {
+		return tableconv.Options{}, fmt.Errorf("-staged takes a single directory")
+	}
+	if *f.run != "" {
+		filter, err := tableconv.FuncFilter(*f.run)
+		if err != nil {
+			return tableconv.Options{}, fmt.Errorf("invalid -run pattern: %v", err)
+		}
+		opts.TableFilter = filter
+	}
+	if *f.since != "" {
+		changed, err := tableconv.ChangedFiles(gitDir(paths[0]), *f.since)
+		if err != nil {
+			return tableconv.Options{}, err
+		}
+		opts.FileFilter = tableconv.FileSet(changed)
+	}
+	if *f.logFormat == "ndjson" && !*f.quiet {
+		enc := json.NewEncoder(os.Stderr)
+		opts.Events = func(event tableconv.FileEvent) {
+			enc.Encode(event)
+		}
+	}
+	return opts, nil
+}
+
+// progress returns the reporter showing the progress of a run with the given options on
+// standard error, nil with -quiet or when the standard error holds the NDJSON log
+func (f *optionFlags) progress(opts *tableconv.Options) *progressReporter {
+	if *f.quiet || *f.logFormat != "text" {
+		return nil
+	}
+	progress := newProgressReporter()
+	opts.Progress = progress.update
+	return progress
+}
+
+// convertFlags are the flags of the convert subcommand
+type convertFlags struct {
+	optFlags    *optionFlags
+	journal     *string
+	verifyTests *bool
+	format      *string
+	reportFile  *string
+	patchFile   *string
+	diff        *bool
+	color       *string
+	noPager     *bool
+	explain     *bool
+	interactive *bool
+	fuzz        *string
+}
+
+// addConvertFlags defines the flags of the convert subcommand on a flag set
+func addConvertFlags(flags *flag.FlagSet) *convertFlags {
+	defaultJournal, _ := tableconv.DefaultJournal()
+	return &convertFlags{
+		optFlags:    addOptionFlags(flags),
+		journal:     flags.String("journal", defaultJournal, "record the files each run writes in this file so that 'revert' can restore them (empty disables)"),
+		verifyTests: flags.Bool("verify-tests", false, "run go test in each modified package before and after writing it, and revert packages whose tests newly fail"),
+		format:      flags.String("format", "text", "output format: text, json, or markdown (a summary for pull requests)"),
+		reportFile:  flags.String("report", "", "write an HTML page summarizing the proposed changes with before/after diffs to this file instead of modifying files"),
+		patchFile:   flags.String("patch", "", "write the proposed changes to this file as a unified patch instead of modifying files"),
+		diff:        flags.Bool("diff", false, "print the proposed changes as a unified diff instead of modifying files, through $PAGER on terminals"),
+		color:       flags.String("color", "auto", "color -diff and -interactive diffs: auto (on terminals, unless NO_COLOR is set), always, or never"),
+		noPager:     flags.Bool("no-pager", false, "print -diff output directly instead of through $PAGER"),
+		explain:     flags.Bool("explain", false, "annotate each hunk of -patch, -diff, and -interactive diffs with what was changed and why"),
+		interactive: flags.Bool("interactive", false, "show the diff of each table and ask whether to convert it"),
+		fuzz:        flags.String("fuzz", "", "add a FuzzXxx function seeded with the cases of the table test TestXxx to the given file"),
+	}
+}
+
+// runConvert runs the convert subcommand, which converts the slice-based table tests of
+// files, directories, and packages in place
+func runConvert(args []string) int {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	f := addConvertFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tabletests convert [flags] <path or package pattern>...")
+		fmt.Fprintln(flags.Output(), "       tabletests convert [flags] - (convert standard input to standard output)")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	paths := flags.Args()
+	switch *f.format {
+	case "text", "json", "markdown":
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown output format %q: use text, json, or markdown\n", *f.format)
+		return 2
+	}
+	switch *f.color {
+	case "auto", "always", "never":
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown color mode %q: use auto, always, or never\n", *f.color)
+		return 2
+	}
+	if *f.diff && *f.format != "text" {
+		fmt.Fprintln(os.Stderr, "-diff prints the diff as the output and can't be combined with -format")
+		return 2
+	}
+	opts, err := f.optFlags.options(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	opts.Explain = *f.explain
+	opts.Journal = *f.journal
+	opts.VerifyTests = *f.verifyTests
+
+	if *f.fuzz != "" {
+		return runFuzz(paths, *f.fuzz)
+	}
+	if len(paths) == 1 && paths[0] == "-" {
+		return runStdin(opts, false)
+	}
+	if *f.explain && *f.patchFile == "" && !*f.diff && !*f.interactive {
+		fmt.Fprintln(os.Stderr, "-explain annotates diffs and requires -patch, -diff, or -interactive")
+		return 2
+	}
+
+	if *f.interactive {
+		filter, err := selectTables(paths, opts, *f.optFlags.staged, useColor(*f.color, os.Stdout))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		opts.TableFilter = filter
+	}
+
+	// Progress messages would corrupt machine-readable output and diffs
+	progress := f.optFlags.progress(&opts)
+	if *f.format == "text" && !*f.diff && !*f.optFlags.quiet && *f.optFlags.logFormat == "text" {
+		opts.Log = progress.log(os.Stdout)
+	}
+	opts.DryRun = *f.patchFile != "" || *f.reportFile != "" || *f.diff
+
+	stopProfile, err := f.optFlags.startProfile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	result, err := convert(tableconv.NewConverter(opts), paths, *f.optFlags.staged)
+	progress.done()
+	stopProfile(result)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	if *f.patchFile != "" {
+		if err := writePatchFile(*f.patchFile, result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	if *f.reportFile != "" {
+		if err := writeReportFile(*f.reportFile, result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	if *f.diff {
+		text, err := diffText(result, useColor(*f.color, os.Stdout))
+		if err == nil {
+			err = writePaged(text, !*f.noPager)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		for _, err := range result.Errors {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		}
+		return exitStatus(result)
+	}
+
+	switch *f.format {
+	case "json":
+		if err := tableconv.WriteJSON(os.Stdout, result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return exitStatus(result)
+	case "markdown":
+		if err := tableconv.WriteMarkdown(os.Stdout, result); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return exitStatus(result)
+	}
+
+	fmt.Printf("Conversion complete:\n")
+	fmt.Printf("  Files processed: %d\n", result.FilesProcessed)
+	fmt.Printf("  Files modified: %d\n", result.FilesModified)
+	fmt.Printf("  Files unchanged since last run: %d\n", result.FilesCached)
+	fmt.Printf("  Generated files skipped: %d\n", result.FilesGenerated)
+	fmt.Printf("  Tables converted: %d\n", result.TablesConverted)
+	fmt.Printf("  Tables skipped: %d\n", result.TablesSkipped)
+	fmt.Printf("  Tables suppressed: %d\n", result.TablesSuppressed)
+	if *f.patchFile != "" {
+		fmt.Printf("  Patch written to: %s\n", *f.patchFile)
+	}
+	if *f.reportFile != "" {
+		fmt.Printf("  Report written to: %s\n", *f.reportFile)
+	}
+
+	printPackages(result)
+	printDiagnostics(result)
+	printErrors(result)
+	return exitStatus(result)
+}
+
+// checkFlags are the flags of the check subcommand
+type checkFlags struct {
+	optFlags *optionFlags
+	format   *string
+	list     *bool
+	whyNot   *bool
+}
+
+// addCheckFlags defines the flags of the check subcommand on a flag set
+func addCheckFlags(flags *flag.FlagSet) *checkFlags {
+	return &checkFlags{
+		optFlags: addOptionFlags(flags),
+		format:   flags.String("format", "text", "output format: text, json, markdown, sarif, rdjson, or rdjsonl"),
+		list:     flags.Bool("l", false, "list the files that would be modified, one per line"),
+		whyNot:   flags.Bool("why-not", false, "list the slice-based table tests that would be left unconverted, with the reason for each"),
+	}
+}
+
+// runCheck runs the check subcommand, which lists slice-based table tests without
+// modifying files and returns the exit code: 0 when none are found, 1 when any are found,
+// and 2 when the paths could not be checked
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	f := addCheckFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tabletests check [flags] <path or package pattern>...")
+		fmt.Fprintln(flags.Output(), "       tabletests check [flags] - (check standard input)")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	paths := flags.Args()
+	switch *f.format {
+	case "text", "json", "markdown", "sarif", "rdjson", "rdjsonl":
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown output format %q\n", *f.format)
+		return 2
+	}
+	opts, err := f.optFlags.options(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	if len(paths) == 1 && paths[0] == "-" {
+		return runStdin(opts, true)
+	}
+	if *f.list {
+		return runList(paths, opts, *f.optFlags.staged)
+	}
+	if *f.whyNot {
+		return runWhyNot(paths, opts, *f.format, *f.optFlags.staged)
+	}
+
+	opts.DryRun = true
+	progress := f.optFlags.progress(&opts)
+	stopProfile, err := f.optFlags.startProfile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	result, err := convert(tableconv.NewConverter(opts), paths, *f.optFlags.staged)
+	progress.done()
+	stopProfile(result)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 2
+	}
+
+	switch *f.format {
+	case "json":
+		err = tableconv.WriteJSON(os.Stdout, result)
+	case "markdown":
+		err = tableconv.WriteMarkdown(os.Stdout, result)
+	case "sarif":
+		err = tableconv.WriteSARIF(os.Stdout, result)
+	case "rdjson":
+		err = tableconv.WriteRDJSON(os.Stdout, result)
+	case "rdjsonl":
+		err = tableconv.WriteRDJSONL(os.Stdout, result)
+	default:
+		printCheckResult(result)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	switch {
+	case len(result.Errors) > 0:
+		return 2
+	case len(result.Tables) > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// runWhyNot lists the tables that would be left unconverted with the reason for each,
+// as text or as JSON, and returns the exit code: 0 unless the paths could not be checked
+func runWhyNot(paths []string, opts tableconv.Options, format string, staged bool) int {
+	// Files remembered as clean may still hold tables that were skipped
+	opts.DryRun = true
+	opts.CacheDir = ""
+	result, err := convert(tableconv.NewConverter(opts), paths, staged)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	switch format {
+	case "json":
+		err = tableconv.WriteSkippedJSON(os.Stdout, result)
+	case "text":
+		for _, skipped := range result.Skipped {
+			fmt.Printf("%s: table %s not converted (%s): %s\n", skipped.Table.Pos, skipped.Table.Name, skipped.Reason, skipped.Message)
+		}
+		printErrors(result)
+	default:
+		fmt.Fprintf(os.Stderr, "The %s format can't be used with -why-not: use text or json\n", format)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	if len(result.Errors) > 0 {
+		return 2
+	}
+	return 0
+}
+
+// exitStatus returns the exit status of a conversion: 1 when files failed to convert,
+// such as packages reverted by -verify-tests, and 0 otherwise
+func exitStatus(result tableconv.ConversionResult) int {
+	if len(result.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// convert converts the given files and directories, or the staged versions of the files
+// under a directory when staged is set
+func convert(converter *tableconv.Converter, paths []string, staged bool) (tableconv.ConversionResult, error) {
+	if staged {
+		return converter.ConvertStaged(paths[0])
+	}
+	return converter.ConvertPaths(paths)
+}
+
+// runStdin converts the source read from standard input and writes the result to standard
+// output, like gofmt. In check mode nothing is written; the exit code is 1 when the source
+// has tables to convert.
+func runStdin(opts tableconv.Options, check bool) int {
+	src, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: error reading standard input: %v\n", err)
+		return 2
+	}
+
+	out, err := tableconv.NewConverter(opts).ConvertSource(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	if check {
+		if !bytes.Equal(src, out) {
+			fmt.Fprintln(os.Stderr, "<standard input>: slice-based table tests should be map-based")
+			return 1
+		}
+		return 0
+	}
+
+	if _, err := os.Stdout.Write(out); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runFuzz adds a fuzz function seeded with the cases of a table test to a single file,
+// or to standard input written to standard output for -
+func runFuzz(paths []string, testName string) int {
+	if len(paths) != 1 {
+		fmt.Fprintln(os.Stderr, "-fuzz takes a single file")
+		return 2
+	}
+
+	path := paths[0]
+	var src []byte
+	var err error
+	if path == "-" {
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		src, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: error reading file: %v\n", err)
+		return 2
+	}
+
+	out, err := tableconv.GenerateFuzz(src, testName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if path == "-" {
+		_, err = os.Stdout.Write(out)
+	} else if _, err = os.Stat(path); err == nil {
+		err = tableconv.WriteFile(path, out, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// generateFlags are the flags of the generate subcommand
+type generateFlags struct {
+	parallel     *bool
+	extractTypes *bool
+	cmpDiff      *bool
+	assertions   *string
+	print        *bool
+}
+
+// addGenerateFlags defines the flags of the generate subcommand on a flag set
+func addGenerateFlags(flags *flag.FlagSet) *generateFlags {
+	return &generateFlags{
+		parallel:     flags.Bool("parallel", false, "call t.Parallel() in the test and its subtests"),
+		extractTypes: flags.Bool("extract-types", false, "declare the case struct as a named type above the test"),
+		cmpDiff:      flags.Bool("cmp-diff", false, "compare results with cmp.Diff, in modules requiring github.com/google/go-cmp"),
+		assertions:   flags.String("assertions", "", "assert with testify's assert and require (testify), in modules requiring it"),
+		print:        flags.Bool("print", false, "print the test file instead of writing it"),
+	}
+}
+
+// runGenerate adds a map-based table test skeleton for a function to the _test.go file next
+// to it, shaped by the style flags the conversion takes
+func runGenerate(args []string) int {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	f := addGenerateFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tabletests generate [flags] <package dir> <Func|Type.Method>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	switch tableconv.AssertionStyle(*f.assertions) {
+	case tableconv.AssertionsUnchanged, tableconv.AssertionsTestify, tableconv.AssertionsStd:
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown assertion style %q: use testify or std\n", *f.assertions)
+		return 2
+	}
+
+	opts := tableconv.Options{
+		Log:          os.Stderr,
+		Parallel:     *f.parallel,
+		ExtractTypes: *f.extractTypes,
+		CmpDiff:      *f.cmpDiff,
+		Assertions:   tableconv.AssertionStyle(*f.assertions),
+	}
+	path, out, err := tableconv.NewConverter(opts).GenerateTest(flags.Arg(0), flags.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *f.print {
+		_, err = os.Stdout.Write(out)
+	} else {
+		err = tableconv.WriteFile(path, out, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// analyzeFlags are the flags of the analyze subcommand
+type analyzeFlags struct {
+	coverProfile   *string
+	threshold      *float64
+	scaffold       *bool
+	format         *string
+	maxCases       *int
+	maxBytes       *int
+	followSymlinks *bool
+}
+
+// addAnalyzeFlags defines the flags of the analyze subcommand on a flag set
+func addAnalyzeFlags(flags *flag.FlagSet) *analyzeFlags {
+	return &analyzeFlags{
+		coverProfile:   flags.String("coverprofile", "", "coverage profile written by go test -coverprofile, to suggest table tests for poorly covered functions instead of listing table tests"),
+		threshold:      flags.Float64("threshold", 80, "report functions covered less than this percentage"),
+		scaffold:       flags.Bool("scaffold", false, "generate a table test skeleton for each reported function"),
+		format:         flags.String("format", "text", "output format: text or json, or csv for the list of table tests"),
+		maxCases:       flags.Int("max-cases", 50, "flag tables with more cases than this as large (0 disables)"),
+		maxBytes:       flags.Int("max-bytes", 8192, "flag tables whose literal takes more bytes of source than this as large (0 disables)"),
+		followSymlinks: flags.Bool("follow-symlinks", false, "descend into symbolic links to directories, analyzing each directory once"),
+	}
+}
+
+// runAnalyze lists the table tests under the given directories, or with -coverprofile
+// reports the exported functions the profile shows to be poorly covered and that have no
+// table test, suggesting a generated test for each, and scaffolds those tests with -scaffold
+func runAnalyze(args []string) int {
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	f := addAnalyzeFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tabletests analyze [flags] <directory>...")
+		fmt.Fprintln(flags.Output(), "       tabletests analyze -coverprofile <file> [flags] <directory>...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	switch *f.format {
+	case "text", "json":
+	case "csv":
+		if *f.coverProfile != "" {
+			fmt.Fprintln(os.Stderr, "The csv format lists table tests and can't be used with -coverprofile")
+			return 2
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown format %q: use text, json, or csv\n", *f.format)
+		return 2
+	}
+
+	// Without a coverage profile, list the table tests found
+	converter := tableconv.NewConverter(tableconv.Options{Log: os.Stderr, MaxCases: *f.maxCases, MaxTableBytes: *f.maxBytes, FollowSymlinks: *f.followSymlinks})
+	if *f.coverProfile == "" {
+		tables, err := converter.Inventory(flags.Args())
+		if err == nil {
+			switch *f.format {
+			case "json":
+				err = tableconv.WriteInventoryJSON(os.Stdout, tables)
+			case "csv":
+				err = tableconv.WriteInventoryCSV(os.Stdout, tables)
+			default:
+				err = tableconv.WriteInventory(os.Stdout, tables)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+		return 0
+	}
+
+	suggestions, err := converter.SuggestTests(*f.coverProfile, flags.Args(), *f.threshold)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	if *f.format == "json" {
+		err = tableconv.WriteSuggestionsJSON(os.Stdout, suggestions)
+	} else {
+		for _, s := range suggestions {
+			fmt.Printf("%s: %s is %.1f%% covered (%d of %d statements uncovered) and has no table test: tabletests generate %s %s\n",
+				s.Pos, s.Name, s.Coverage, s.Uncovered, s.Statements, s.Dir, s.Name)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	// Tests are generated one at a time, since several may go to the same file
+	exit := 0
+	if *f.scaffold {
+		for _, s := range suggestions {
+			path, out, err := converter.GenerateTest(s.Dir, s.Name)
+			if err == nil {
+				err = tableconv.WriteFile(path, out, 0o644)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %v\n", s.Name, err)
+				exit = 1
+			}
+		}
+	}
+	return exit
+}
+
+// exportFlags are the flags of the export subcommand
+type exportFlags struct {
+	format *string
+}
+
+// addExportFlags defines the flags of the export subcommand on a flag set
+func addExportFlags(flags *flag.FlagSet) *exportFlags {
+	return &exportFlags{
+		format: flags.String("format", "json", "data file format: json, csv, or yaml (needs gopkg.in/yaml.v3)"),
+	}
+}
+
+// runExport moves the cases of a table test to a data file under testdata and rewrites
+// the test to load them
+func runExport(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	f := addExportFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tabletests export [flags] <test file> <TestName>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	fixture := tableconv.FixtureFormat(*f.format)
+	switch fixture {
+	case tableconv.FixtureJSON, tableconv.FixtureCSV, tableconv.FixtureYAML:
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown format %q: use json, csv, or yaml\n", *f.format)
+		return 2
+	}
+
+	converter := tableconv.NewConverter(tableconv.Options{Log: os.Stderr})
+	export, err := converter.ExportTable(flags.Arg(0), flags.Arg(1), fixture)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(export.DataPath), 0o755)
+	}
+	if err == nil {
+		err = tableconv.WriteFile(export.DataPath, export.Data, 0o644)
+	}
+	if err == nil {
+		if _, err = os.Stat(export.TestPath); err == nil {
+			err = tableconv.WriteFile(export.TestPath, export.Test, 0o644)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// lspFlags are the flags of the lsp subcommand
+type lspFlags struct {
+	configFile  *string
+	noTypeCheck *bool
+}
+
+// addLSPFlags defines the flags of the lsp subcommand on a flag set
+func addLSPFlags(flags *flag.FlagSet) *lspFlags {
+	return &lspFlags{
+		configFile:  flags.String("config", "", "run the pipeline and rules of this YAML file with each conversion"),
+		noTypeCheck: flags.Bool("no-typecheck", false, "offer conversions without checking that the package still compiles"),
+	}
+}
+
+// runLSP runs the lsp subcommand, a language server offering the conversion of the table
+// at the cursor as a code action to editors talking to it over standard input and output
+func runLSP(args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ExitOnError)
+	f := addLSPFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tabletests lsp [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	opts := tableconv.Options{SkipTypeCheck: *f.noTypeCheck}
+	if *f.configFile != "" {
+		cfg, err := tableconv.LoadConfig(*f.configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+		cfg.Apply(&opts)
+	}
+	if err := tableconv.NewConverter(opts).ServeLSP(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// mcpFlags are the flags of the mcp subcommand
+type mcpFlags struct {
+	configFile *string
+	dryRun     *bool
+}
+
+// addMCPFlags defines the flags of the mcp subcommand on a flag set
+func addMCPFlags(flags *flag.FlagSet) *mcpFlags {
+	return &mcpFlags{
+		configFile: flags.String("config", "", "run the pipeline and rules of this YAML file with each conversion"),
+		dryRun:     flags.Bool("dry-run", false, "make convert_file report what it would convert without modifying files"),
+	}
+}
+
+// runMCP runs the mcp subcommand, a Model Context Protocol server giving AI assistants
+// tools to detect, preview, and convert table tests over standard input and output
+func runMCP(args []string) int {
+	flags := flag.NewFlagSet("mcp", flag.ExitOnError)
+	f := addMCPFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tabletests mcp [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	opts := tableconv.Options{DryRun: *f.dryRun}
+	if *f.configFile != "" {
+		cfg, err := tableconv.LoadConfig(*f.configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+		cfg.Apply(&opts)
+	}
+	if err := tableconv.NewConverter(opts).ServeMCP(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// Timeouts of the connections of the serve subcommand. Writing covers the conversion, which
+// may load the packages a source imports the first time.
+const (
+	serveReadTimeout  = 30 * time.Second
+	serveWriteTimeout = 2 * time.Minute
+	serveIdleTimeout  = 2 * time.Minute
+)
+
+// serveFlags are the flags of the serve subcommand
+type serveFlags struct {
+	addr        *string
+	configFile  *string
+	noTypeCheck *bool
+}
+
+// addServeFlags defines the flags of the serve subcommand on a flag set
+func addServeFlags(flags *flag.FlagSet) *serveFlags {
+	return &serveFlags{
+		addr:        flags.String("addr", "localhost:8080", "address to listen on"),
+		configFile:  flags.String("config", "", "run the pipeline and rules of this YAML file with each conversion"),
+		noTypeCheck: flags.Bool("no-typecheck", false, "return converted sources without checking that they still compile"),
+	}
+}
+
+// runServe runs the serve subcommand, an HTTP API converting the Go sources posted to it
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	f := addServeFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tabletests serve [flags]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	opts := tableconv.Options{SkipTypeCheck: *f.noTypeCheck}
+	if *f.configFile != "" {
+		cfg, err := tableconv.LoadConfig(*f.configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+		cfg.Apply(&opts)
+	}
+	// Slow clients can't hold connections open for good
+	server := &http.Server{
+		Addr:         *f.addr,
+		Handler:      tableconv.NewConverter(opts).Handler(),
+		ReadTimeout:  serveReadTimeout,
+		WriteTimeout: serveWriteTimeout,
+		IdleTimeout:  serveIdleTimeout,
+	}
+	fmt.Fprintf(os.Stderr, "Serving on http://%s\n", *f.addr)
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// remoteFlags are the flags of the remote subcommand
+type remoteFlags struct {
+	branch           *string
+	base             *string
+	title            *string
+	provider         *string
+	apiURL           *string
+	noPush           *bool
+	configFile       *string
+	ignoreOrder      *bool
+	suffixDuplicates *bool
+}
+
+// addRemoteFlags defines the flags of the remote subcommand on a flag set
+func addRemoteFlags(flags *flag.FlagSet) *remoteFlags {
+	return &remoteFlags{
+		branch:           flags.String("branch", tableconv.DefaultRemoteBranch, "branch to commit the conversion to and push"),
+		base:             flags.String("base", "", "branch to branch off and open the pull request against (default: the default branch)"),
+		title:            flags.String("title", "", "commit subject and pull request title"),
+		provider:         flags.String("provider", "", "github or gitlab (default: told by the host of the URL)"),
+		apiURL:           flags.String("api-url", "", "root of the API of the provider, for self-hosted instances (default: told by the host of the URL)"),
+		noPush:           flags.Bool("no-push", false, "commit the conversion in a clone left in a temporary directory without pushing or opening a pull request"),
+		configFile:       flags.String("config", "", "run the pipeline and rules of this YAML file with each conversion"),
+		ignoreOrder:      flags.Bool("ignore-order", false, "convert tables whose loops depend on the order of their cases"),
+		suffixDuplicates: flags.Bool("suffix-duplicates", false, "number cases that share a name instead of skipping their table"),
+	}
+}
+
+// runRemote runs the remote subcommand, which converts each repository given by git URL on
+// a branch and opens a pull request for it, for migrating many repositories at once
+func runRemote(args []string) int {
+	flags := flag.NewFlagSet("remote", flag.ExitOnError)
+	f := addRemoteFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tabletests remote [flags] <git URL>...")
+		fmt.Fprintln(flags.Output(), "The token opening pull requests is read from GITHUB_TOKEN or GITLAB_TOKEN.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	opts := tableconv.Options{IgnoreOrder: *f.ignoreOrder, SuffixDuplicates: *f.suffixDuplicates, Log: os.Stderr}
+	if *f.configFile != "" {
+		cfg, err := tableconv.LoadConfig(*f.configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+		cfg.Apply(&opts)
+	}
+	conv := tableconv.NewConverter(opts)
+
+	exit := 0
+	for _, repoURL := range flags.Args() {
+		// A provider that can't be told fails the conversion below
+		token := os.Getenv("GITHUB_TOKEN")
+		if p, err := tableconv.RemoteProvider(repoURL, *f.provider, *f.apiURL); err == nil && p == tableconv.ProviderGitLab {
+			token = os.Getenv("GITLAB_TOKEN")
+		}
+		res, err := conv.ConvertRemote(tableconv.RemoteOptions{
+			URL:      repoURL,
+			Branch:   *f.branch,
+			Base:     *f.base,
+			Title:    *f.title,
+			Provider: *f.provider,
+			APIURL:   *f.apiURL,
+			Token:    token,
+			NoPush:   *f.noPush,
+		})
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", repoURL, err)
+			exit = 1
+		case res.Result.FilesModified == 0:
+			fmt.Printf("%s: nothing to convert\n", repoURL)
+		case *f.noPush:
+			fmt.Printf("%s: converted %d tables in %d files, committed in %s\n", repoURL, len(res.Result.Tables), res.Result.FilesModified, res.Dir)
+		default:
+			fmt.Printf("%s: %s\n", repoURL, res.PullRequest)
+		}
+	}
+	return exit
+}
+
+// planFlags are the flags of the plan subcommand
+type planFlags struct {
+	optFlags *optionFlags
+	out      *string
+}
+
+// addPlanFlags defines the flags of the plan subcommand on a flag set
+func addPlanFlags(flags *flag.FlagSet) *planFlags {
+	return &planFlags{
+		optFlags: addOptionFlags(flags),
+		out:      flags.String("out", "", "write the plan to this file instead of standard output"),
+	}
+}
+
+// runPlan runs the plan subcommand, which records the edits of a conversion with the
+// hashes of the files they apply to, without modifying files
+func runPlan(args []string) int {
+	flags := flag.NewFlagSet("plan", flag.ExitOnError)
+	f := addPlanFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tabletests plan [flags] <path or package pattern>...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	// Plans hold edits of existing files, not new golden files or the git index
+	if *f.optFlags.golden > 0 || *f.optFlags.staged {
+		fmt.Fprintln(os.Stderr, "-golden and -staged can't be planned: use convert")
+		return 2
+	}
+	opts, err := f.optFlags.options(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	opts.DryRun = true
+	progress := f.optFlags.progress(&opts)
+	stopProfile, err := f.optFlags.startProfile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	result, err := tableconv.NewConverter(opts).ConvertPaths(flags.Args())
+	progress.done()
+	stopProfile(result)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	for _, e := range result.Errors {
+		fmt.Fprintf(os.Stderr, "Error: %s: %s\n", e.Path, e.Message)
+	}
+
+	w := os.Stdout
+	if *f.out != "" {
+		file, err := os.Create(*f.out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := tableconv.WritePlan(w, result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing plan: %v\n", err)
+		return 1
+	}
+	if *f.out != "" {
+		fmt.Printf("Planned %d tables in %d files; apply with: tabletests apply %s\n", result.TablesConverted, result.FilesModified, *f.out)
+	}
+	if len(result.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// applyFlags are the flags of the apply subcommand
+type applyFlags struct {
+	journal *string
+}
+
+// addApplyFlags defines the flags of the apply subcommand on a flag set
+func addApplyFlags(flags *flag.FlagSet) *applyFlags {
+	defaultJournal, _ := tableconv.DefaultJournal()
+	return &applyFlags{
+		journal: flags.String("journal", defaultJournal, "record the files written in this file so that 'revert' can restore them (empty disables)"),
+	}
+}
+
+// runApply runs the apply subcommand, which applies a plan made by the plan subcommand
+// if none of its files changed since
+func runApply(args []string) int {
+	flags := flag.NewFlagSet("apply", flag.ExitOnError)
+	f := addApplyFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tabletests apply [flags] <plan file>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	plan, err := tableconv.LoadPlan(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	written, err := tableconv.ApplyPlan(plan, *f.journal)
+	for _, path := range written {
+		fmt.Printf("Modified file: %s\n", path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Applied plan to %d files\n", len(written))
+	return 0
+}
+
+// revertFlags are the flags of the revert subcommand
+type revertFlags struct {
+	journal *string
+	run     *string
+	list    *bool
+}
+
+// addRevertFlags defines the flags of the revert subcommand on a flag set
+func addRevertFlags(flags *flag.FlagSet) *revertFlags {
+	defaultJournal, _ := tableconv.DefaultJournal()
+	return &revertFlags{
+		journal: flags.String("journal", defaultJournal, "journal the run was recorded in"),
+		run:     flags.String("run", "", "run to revert (default: the last run)"),
+		list:    flags.Bool("list", false, "list the runs recorded in the journal instead of reverting"),
+	}
+}
+
+// runRevert runs the revert subcommand, which restores the files written by a run recorded
+// in the journal, for undoing conversions outside of version control
+func runRevert(args []string) int {
+	flags := flag.NewFlagSet("revert", flag.ExitOnError)
+	f := addRevertFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tabletests revert [flags] [file]...")
+		fmt.Fprintln(flags.Output(), "Restores every file written by the run unless files are given.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *f.list {
+		entries, err := tableconv.LoadJournal(*f.journal)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		for _, r := range tableconv.JournalRuns(entries) {
+			fmt.Printf("%s  %s  %d files\n", r.Run, r.Time.Local().Format("2006-01-02 15:04:05"), r.Files)
+		}
+		return 0
+	}
+
+	restored, err := tableconv.Revert(*f.journal, *f.run, flags.Args())
+	for _, path := range restored {
+		fmt.Printf("Restored file: %s\n", path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// importFlags are the flags of the import subcommand
+type importFlags struct {
+	caseType *string
+}
+
+// addImportFlags defines the flags of the import subcommand on a flag set
+func addImportFlags(flags *flag.FlagSet) *importFlags {
+	return &importFlags{
+		caseType: flags.String("type", "", "case struct of the test to generate when the file has none of the given name"),
+	}
+}
+
+// runImport runs the import subcommand, which inlines the cases of a testdata fixture
+// into a map-based table of a test
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	f := addImportFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tabletests import [flags] <fixture> <test file> <TestName>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 3 {
+		flags.Usage()
+		return 2
+	}
+
+	converter := tableconv.NewConverter(tableconv.Options{Log: os.Stderr})
+	out, err := converter.ImportFixture(flags.Arg(0), flags.Arg(1), flags.Arg(2), *f.caseType)
+	if err == nil {
+		if _, err = os.Stat(flags.Arg(1)); err == nil {
+			err = tableconv.WriteFile(flags.Arg(1), out, 0o644)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// gitDir returns the directory to run git in for a path given on the command line
+func gitDir(path string) string {
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		// Package patterns are resolved from the working directory
+		return "."
+	case !info.IsDir():
+		return filepath.Dir(path)
+	}
+	return path
+}
+
+// runList prints the paths of the files that would be modified, one per line, and
+// returns the exit code: 0 unless the paths could not be listed
+func runList(paths []string, opts tableconv.Options, staged bool) int {
+	opts.DryRun = true
+	result, err := convert(tableconv.NewConverter(opts), paths, staged)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	for _, file := range result.Files {
+		if file.Modified {
+			fmt.Println(file.Path)
+		}
+	}
+
+	for _, err := range result.Errors {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+	if len(result.Errors) > 0 {
+		return 2
+	}
+	return 0
+}
+
+// selectTables shows the changes converting each table would make and asks whether to
+// convert it, returning a filter for the accepted tables
+func selectTables(paths []string, opts tableconv.Options, staged, color bool) (func(tableconv.Table) bool, error) {
+	opts.DryRun = true
+	result, err := convert(tableconv.NewConverter(opts), paths, staged)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted := make(map[token.Position]bool)
+	filter := func(table tableconv.Table) bool {
+		return accepted[table.Pos]
+	}
+
+	answers := bufio.NewReader(os.Stdin)
+	for _, table := range result.Tables {
+		// Convert the table on its own to show just its changes
+		single := opts
+		single.TableFilter = func(t tableconv.Table) bool {
+			return t.Pos == table.Pos
+		}
+		preview, err := convert(tableconv.NewConverter(single), paths, staged)
+		if err != nil {
+			return nil, err
+		}
+		text, err := diffText(preview, color)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Print(text)
+		printErrors(preview)
+
+		for {
+			fmt.Printf("Convert table %s (%s)? [y,n,q] ", table.Name, table.Pos)
+			answer, err := answers.ReadString('\n')
+			if err != nil && answer == "" {
+				// Stop asking once input runs out, keeping the answers given so far
+				fmt.Println()
+				return filter, nil
+			}
+
+			switch strings.TrimSpace(strings.ToLower(answer)) {
+			case "y", "yes":
+				accepted[table.Pos] = true
+			case "n", "no":
+			case "q", "quit":
+				return filter, nil
+			default:
+				fmt.Println("y - convert this table, n - skip this table, q - skip this and all remaining tables")
+				continue
+			}
+			break
+		}
+	}
+
+	return filter, nil
+}
+
+// printCheckResult lists the slice-based table tests found in check mode
+func printCheckResult(result tableconv.ConversionResult) {
+	for _, table := range result.Tables {
+		fmt.Printf("%s: slice-based table test %q should be map-based\n", table.Pos, table.Name)
+	}
+
+	printDiagnostics(result)
+	printErrors(result)
+}
+
+// writePatchFile writes the changes of a dry run to a patch file
+func writePatchFile(path string, result tableconv.ConversionResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating patch: %v", err)
+	}
+
+	if err := tableconv.WritePatch(f, result); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing patch: %v", err)
+	}
+
+	return f.Close()
+}
+
+// writeReportFile writes the HTML report of a run to the given file
+func writeReportFile(path string, result tableconv.ConversionResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating report: %v", err)
+	}
+
+	if err := tableconv.WriteHTML(f, result); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing report: %v", err)
+	}
+
+	return f.Close()
+}
+
+// printPackages prints the statistics of a run per package directory as a table, when
+// the run spans more than one
+func printPackages(result tableconv.ConversionResult) {
+	packages := result.Packages()
+	if len(packages) < 2 {
+		return
+	}
+
+	fmt.Println("Packages:")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "  PACKAGE\tFILES\tCONVERTED\tSKIPPED\tERRORS")
+	for _, p := range packages {
+		fmt.Fprintf(tw, "  %s\t%d\t%d\t%d\t%d\n", p.Dir, p.Files, p.TablesConverted, p.TablesSkipped, p.Errors)
+	}
+	tw.Flush()
+}
+
+// printDiagnostics prints the problems found with table tests during a run
+func printDiagnostics(result tableconv.ConversionResult) {
+	if len(result.Diagnostics) > 0 {
+		fmt.Println("Warnings:")
+		for _, diag := range result.Diagnostics {
+			fmt.Printf("  - %s: %s: %s\n", diag.Pos, diag.Table, diag.Message)
+		}
+	}
+}
+
+// printErrors prints the errors collected during a run
+func printErrors(result tableconv.ConversionResult) {
+	if len(result.Errors) > 0 {
+		fmt.Println("Errors:")
+		for _, err := range result.Errors {
+			fmt.Printf("  - %s\n", err.Error())
+		}
+	}
+}
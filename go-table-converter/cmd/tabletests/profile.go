@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"text/tabwriter"
+	"time"
+
+	"github.com/khalilchatoo/claude-playground/go-table-converter/tableconv"
+)
+
+// startProfile starts the CPU profile of -cpuprofile and returns the function to call
+// once the conversion is done, which stops it, writes the heap profile of -memprofile,
+// and prints the -timings breakdown of the result
+func (f *optionFlags) startProfile() (func(tableconv.ConversionResult), error) {
+	var cpu *os.File
+	if *f.cpuProfile != "" {
+		var err error
+		if cpu, err = os.Create(*f.cpuProfile); err != nil {
+			return nil, fmt.Errorf("error creating CPU profile: %v", err)
+		}
+		if err := pprof.StartCPUProfile(cpu); err != nil {
+			cpu.Close()
+			return nil, fmt.Errorf("error starting CPU profile: %v", err)
+		}
+	}
+	start := time.Now()
+
+	return func(result tableconv.ConversionResult) {
+		elapsed := time.Since(start)
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			if err := cpu.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: error writing CPU profile: %v\n", err)
+			}
+		}
+		if *f.memProfile != "" {
+			if err := writeHeapProfile(*f.memProfile); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+		}
+		if *f.timings {
+			printTimings(result.Timings, elapsed)
+		}
+	}, nil
+}
+
+// writeHeapProfile writes a profile of the memory in use to a file
+func writeHeapProfile(path string) error {
+	mem, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating memory profile: %v", err)
+	}
+	// Only count memory still in use after the conversion
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(mem); err != nil {
+		mem.Close()
+		return fmt.Errorf("error writing memory profile: %v", err)
+	}
+	return mem.Close()
+}
+
+// printTimings prints the time spent in each phase of a run on standard error, and the
+// time spent outside of them, such as walking directories and resolving packages
+func printTimings(timings tableconv.Timings, elapsed time.Duration) {
+	phases := []struct {
+		name string
+		time time.Duration
+	}{
+		{"parse", timings.Parse},
+		{"transform", timings.Transform},
+		{"format", timings.Format},
+		{"type check", timings.TypeCheck},
+		{"write", timings.Write},
+		{"tests", timings.Tests},
+		{"other", max(elapsed-timings.Total(), 0)},
+	}
+
+	fmt.Fprintln(os.Stderr, "Timings:")
+	tw := tabwriter.NewWriter(os.Stderr, 0, 8, 2, ' ', 0)
+	for _, phase := range phases {
+		share := 0.0
+		if elapsed > 0 {
+			share = 100 * float64(phase.time) / float64(elapsed)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%.1f%%\n", phase.name, phase.time.Round(time.Microsecond), share)
+	}
+	fmt.Fprintf(tw, "  total\t%s\n", elapsed.Round(time.Microsecond))
+	tw.Flush()
+}
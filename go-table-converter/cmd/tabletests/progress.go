@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/khalilchatoo/claude-playground/go-table-converter/tableconv"
+)
+
+const (
+	// progressBarWidth is the number of cells of the progress bar
+	progressBarWidth = 24
+	// progressRedraw is how often the progress bar is redrawn at most
+	progressRedraw = 100 * time.Millisecond
+	// progressInterval is how often progress lines are printed when the progress can't
+	// be redrawn in place, as in CI logs
+	progressInterval = 10 * time.Second
+)
+
+// progressReporter shows the progress of a run on standard error: as a bar redrawn in
+// place on terminals, and otherwise as a line every progressInterval, so that runs too
+// short to take one print nothing
+type progressReporter struct {
+	tty bool
+	// shown is set while the bar is on the screen
+	shown bool
+	// last is when the progress was last shown
+	last time.Time
+}
+
+// newProgressReporter returns a reporter writing to standard error
+func newProgressReporter() *progressReporter {
+	return &progressReporter{tty: isTerminal(os.Stderr) && os.Getenv("TERM") != "dumb", last: time.Now()}
+}
+
+// update shows the progress if it is time to
+func (p *progressReporter) update(progress tableconv.Progress) {
+	finished := progress.Remaining() == 0
+	if p.tty {
+		if time.Since(p.last) < progressRedraw && !finished {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "\r\x1b[K%s %s", progressBar(progress), progressLine(progress))
+		p.shown = true
+	} else {
+		if time.Since(p.last) < progressInterval || finished {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Progress: %s\n", progressLine(progress))
+	}
+	p.last = time.Now()
+}
+
+// clear removes the bar from the screen, until the next update
+func (p *progressReporter) clear() {
+	if p != nil && p.shown {
+		fmt.Fprint(os.Stderr, "\r\x1b[K")
+		p.shown = false
+	}
+}
+
+// done removes the bar once the run is over
+func (p *progressReporter) done() {
+	p.clear()
+}
+
+// log returns a writer for the log of the run that clears the bar before each message,
+// so messages don't run into it
+func (p *progressReporter) log(w io.Writer) io.Writer {
+	if p == nil || !p.tty {
+		return w
+	}
+	return progressLog{p, w}
+}
+
+// progressLog is a log writer clearing the progress bar before writing
+type progressLog struct {
+	progress *progressReporter
+	w        io.Writer
+}
+
+func (l progressLog) Write(b []byte) (int, error) {
+	l.progress.clear()
+	return l.w.Write(b)
+}
+
+// progressBar draws the share of files scanned
+func progressBar(progress tableconv.Progress) string {
+	filled := progressBarWidth
+	if progress.FilesTotal > 0 {
+		filled = progressBarWidth * progress.FilesScanned / progress.FilesTotal
+	}
+	return "[" + strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled) + "]"
+}
+
+// progressLine describes the progress in words
+func progressLine(progress tableconv.Progress) string {
+	line := fmt.Sprintf("%d/%d files scanned, %d modified, %d remaining",
+		progress.FilesScanned, progress.FilesTotal, progress.FilesModified, progress.Remaining())
+	if eta := progress.ETA(); eta > 0 {
+		line += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+	return line
+}
@@ -0,0 +1,3 @@
+module github.com/khalilchatoo/claude-playground/go-table-converter
+
+go 1.24
@@ -0,0 +1,12 @@
+module github.com/khalilchatoo/claude-playground/go-table-converter/golangci
+
+go 1.24
+
+require (
+	github.com/golangci/plugin-module-register v0.1.2
+	github.com/khalilchatoo/claude-playground/go-table-converter v0.0.0
+	golang.org/x/tools v0.32.0
+)
+
+// The converter is developed alongside the plugin
+replace github.com/khalilchatoo/claude-playground/go-table-converter => ../
@@ -0,0 +1,140 @@
+// Package golangci is the tabletest linter of golangci-lint, built into golangci-lint as a
+// module plugin. It reports slice-based table tests with the conversion of each table to
+// a map-based table as a suggested fix, which golangci-lint --fix applies. Findings are
+// suppressed with //nolint:tabletest like those of any other linter.
+package golangci
+
+import (
+	"go/token"
+	"strings"
+
+	"github.com/golangci/plugin-module-register/register"
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/khalilchatoo/claude-playground/go-table-converter/tableconv"
+)
+
+func init() {
+	register.Plugin("tabletest", New)
+}
+
+// Settings are the settings of the linter in .golangci.yml, named like the flags of the
+// same meaning
+type Settings struct {
+	IgnoreOrder      bool   `json:"ignore-order"`
+	SuffixDuplicates bool   `json:"suffix-duplicates"`
+	Keys             string `json:"keys"`
+	// TypeCheck checks that packages still compile with a table converted before
+	// suggesting the fix, which is slower since imports are type-checked from source
+	TypeCheck bool `json:"typecheck"`
+	// Config is the path of a config file with a pipeline and rules to run along with
+	// the conversion
+	Config string `json:"config"`
+}
+
+// plugin is the linter configured with its settings
+type plugin struct {
+	opts tableconv.Options
+}
+
+// New returns the linter configured with its settings from .golangci.yml
+func New(settings any) (register.LinterPlugin, error) {
+	s, err := register.DecodeSettings[Settings](settings)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := tableconv.Options{
+		IgnoreOrder:      s.IgnoreOrder,
+		SuffixDuplicates: s.SuffixDuplicates,
+		Keys:             tableconv.KeyStrategy(s.Keys),
+		SkipTypeCheck:    !s.TypeCheck,
+	}
+	if s.Config != "" {
+		cfg, err := tableconv.LoadConfig(s.Config)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Apply(&opts)
+	}
+	return &plugin{opts: opts}, nil
+}
+
+// BuildAnalyzers returns the analyzer of the linter
+func (p *plugin) BuildAnalyzers() ([]*analysis.Analyzer, error) {
+	return []*analysis.Analyzer{{
+		Name: "tabletest",
+		Doc:  "reports slice-based table tests that should be map-based",
+		Run:  p.run,
+	}}, nil
+}
+
+// GetLoadMode returns the load mode of the linter, which only needs the syntax of files
+func (p *plugin) GetLoadMode() string {
+	return register.LoadModeSyntax
+}
+
+// run reports the slice-based table tests of the test files of a package
+func (p *plugin) run(pass *analysis.Pass) (any, error) {
+	files := make(map[string]*token.File)
+	var paths []string
+	var srcs [][]byte
+	for _, f := range pass.Files {
+		file := pass.Fset.File(f.Pos())
+		if file == nil || !strings.HasSuffix(file.Name(), "_test.go") {
+			continue
+		}
+		src, err := pass.ReadFile(file.Name())
+		if err != nil {
+			return nil, err
+		}
+		files[file.Name()] = file
+		paths = append(paths, file.Name())
+		srcs = append(srcs, src)
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	_, fixes := tableconv.NewConverter(p.opts).FixTables(paths, srcs)
+	for _, fix := range fixes {
+		file := files[fix.Table.Pos.Filename]
+		if file == nil {
+			continue
+		}
+
+		diag := analysis.Diagnostic{
+			Pos:     file.Pos(fix.Table.Pos.Offset),
+			Message: "slice-based table test " + fix.Table.Name + " should be map-based",
+		}
+
+		// A fix editing files outside the package would be applied in part
+		suggested := analysis.SuggestedFix{Message: "Convert " + fix.Table.Name + " to a map-based table test"}
+		for _, edit := range fix.Edits {
+			editFile := files[edit.Path]
+			if editFile == nil {
+				suggested.TextEdits = nil
+				break
+			}
+			suggested.TextEdits = append(suggested.TextEdits, analysis.TextEdit{
+				Pos:     lineStart(editFile, edit.StartLine),
+				End:     lineStart(editFile, edit.EndLine),
+				NewText: []byte(edit.NewText),
+			})
+		}
+		if len(suggested.TextEdits) > 0 {
+			diag.SuggestedFixes = []analysis.SuggestedFix{suggested}
+		}
+		pass.Report(diag)
+	}
+	return nil, nil
+}
+
+// lineStart returns the position of the start of a line of a file, counted from 1, or of
+// the end of the file for the line after its last
+func lineStart(file *token.File, line int) token.Pos {
+	if line > file.LineCount() {
+		return token.Pos(file.Base() + file.Size())
+	}
+	return file.LineStart(line)
+}
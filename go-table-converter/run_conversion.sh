@@ -1,18 +1,16 @@
 #!/bin/bash
 
 # Run the table test converter on a directory
-# Usage: ./run_conversion.sh <directory_path>
+# Usage: ./run_conversion.sh [flags] <path>...
 
-if [ $# -ne 1 ]; then
-    echo "Usage: ./run_conversion.sh <directory_path>"
+if [ $# -lt 1 ]; then
+    echo "Usage: ./run_conversion.sh [flags] <path>..."
     exit 1
 fi
 
-DIR_PATH=$1
-
 # Compile the converter
 echo "Compiling table test converter..."
-go build -o table_converter tabletests.go
+go build -o table_converter ./cmd/tabletests
 
 if [ $? -ne 0 ]; then
     echo "Failed to compile the converter"
@@ -20,10 +18,12 @@ if [ $? -ne 0 ]; then
 fi
 
 # Run the converter
-echo "Running conversion on $DIR_PATH..."
-./table_converter "$DIR_PATH"
+echo "Running conversion..."
+./table_converter convert "$@"
+STATUS=$?
 
 # Cleanup
 rm table_converter
 
-echo "Done!"
\ No newline at end of file
+echo "Done!"
+exit $STATUS
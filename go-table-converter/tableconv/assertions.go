@@ -0,0 +1,69 @@
+package tableconv
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// failureMethods are the methods of testing.T that fail a test
+var failureMethods = map[string]bool{"Error": true, "Errorf": true, "Fatal": true, "Fatalf": true, "Fail": true, "FailNow": true}
+
+// unassertedBodies returns the positions of the subtests a table loop runs whose bodies
+// assert nothing, or of the loop itself when it runs no subtests and asserts nothing.
+// Such tests pass whatever the code under test does.
+func unassertedBodies(fset *token.FileSet, node *ast.File, fn *ast.FuncDecl, rangeStmt *ast.RangeStmt) []token.Position {
+	paths := importPaths(node)
+	var positions []token.Position
+	subtests := false
+	ast.Inspect(rangeStmt.Body, func(n ast.Node) bool {
+		stmt, ok := n.(ast.Stmt)
+		if !ok {
+			return true
+		}
+		lit := subtestFunc(stmt)
+		if lit == nil {
+			return true
+		}
+		subtests = true
+		if param := testingParam(lit.Type, "T"); param != "" && !asserts(lit.Body, param, paths) {
+			positions = append(positions, fset.Position(stmt.Pos()))
+		}
+		return false
+	})
+	if subtests {
+		return positions
+	}
+
+	if param := testingParam(fn.Type, "T"); param != "" && !asserts(rangeStmt.Body, param, paths) {
+		positions = append(positions, fset.Position(rangeStmt.Pos()))
+	}
+	return positions
+}
+
+// asserts reports whether a block may fail the test through the given *testing.T: by
+// calling t.Error, t.Errorf, t.Fatal, t.Fatalf, t.Fail, or t.FailNow, a function of
+// testify's assert or require packages, or a helper the test is passed to
+func asserts(body *ast.BlockStmt, param string, paths map[string]string) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || found {
+			return !found
+		}
+		if pkg, _ := calledFunc(call, paths); pkg == testifyPackages["assert"] || pkg == testifyPackages["require"] {
+			found = true
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && failureMethods[sel.Sel.Name] {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Name == param {
+				found = true
+			}
+		}
+		for _, arg := range call.Args {
+			if ident, ok := arg.(*ast.Ident); ok && ident.Name == param {
+				found = true
+			}
+		}
+		return true
+	})
+	return found
+}
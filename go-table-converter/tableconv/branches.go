@@ -0,0 +1,558 @@
+package tableconv
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// branchCase is a case of a generated table meant to take a branch of the tested function
+type branchCase struct {
+	// name describes the branch by its condition ('b == 0')
+	name string
+	// pos is the position of the statement holding the branch
+	pos token.Position
+	// wantErr is set for branches returning a non-nil error
+	wantErr bool
+	// determined is set for branches that inputs pins down along with their outcome:
+	// results holds the values the branch returns besides a trailing error, unless it
+	// returns a non-nil error
+	determined bool
+	inputs     []branchInput
+	results    []string
+}
+
+// branchInput is the value the condition of a branch pins a parameter to ('b == 0')
+type branchInput struct {
+	param, value string
+}
+
+// branchFields names the fields of a generated table that cases set
+type branchFields struct {
+	// params maps the names of the function's parameters to the fields holding them
+	params map[string]string
+	// results are the fields of the results besides a trailing error, in order
+	results []string
+	wantErr string
+}
+
+// branchCases returns a case for each branch of the if and switch statements of a
+// function, in source order, named after the condition taking it. Branches of function
+// literals are left out, since whether they run depends on their callers.
+// For: if b == 0 { return 0, errors.New("division by zero") } else { ... }
+// It returns: "b == 0" (wantErr when the function returns an error) and "!(b == 0)"
+//
+// A branch is determined when nothing before it in the function can return or change the
+// parameters, its condition pins parameters to literals, and it returns literals or a
+// non-nil error: the case taking it can then be filled in. Here "b == 0" is determined.
+func branchCases(fset *token.FileSet, fn *ast.FuncDecl) []branchCase {
+	var cases []branchCase
+	used := make(map[string]bool)
+	returnsErr := returnsError(fn)
+	add := func(name string, pos token.Pos, body []ast.Stmt, inputs []branchInput, pinned bool) {
+		name = strings.Join(strings.Fields(name), " ")
+		unique := name
+		for n := 2; used[unique]; n++ {
+			unique = fmt.Sprintf("%s (%d)", name, n)
+		}
+		used[unique] = true
+		bc := branchCase{name: unique, pos: fset.Position(pos), wantErr: returnsErr && returnsNonNilError(body)}
+		if pinned {
+			bc.results, bc.determined = branchResults(fset, fn, body, bc.wantErr)
+			bc.inputs = inputs
+		}
+		cases = append(cases, bc)
+	}
+	text := func(expr ast.Expr) string {
+		return nodeString(fset, expr)
+	}
+
+	// Only the branches of the statements reached by every call can be determined
+	params := paramObjects(fn)
+	reached := make(map[ast.Stmt]bool)
+	for _, stmt := range fn.Body.List {
+		reached[stmt] = true
+		if returnsOrAssigns(stmt, params) {
+			break
+		}
+	}
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch stmt := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.IfStmt:
+			inputs, pinned := pinnedInputs(fset, stmt.Cond, params)
+			add(text(stmt.Cond), stmt.Pos(), stmt.Body.List, inputs, pinned && reached[stmt] && stmt.Init == nil)
+			if block, ok := stmt.Else.(*ast.BlockStmt); ok {
+				add(negation(fset, stmt.Cond), block.Pos(), block.List, nil, false)
+			}
+		case *ast.SwitchStmt:
+			for i, clause := range stmt.Body.List {
+				clause := clause.(*ast.CaseClause)
+				var conds []string
+				for _, expr := range clause.List {
+					if stmt.Tag != nil {
+						conds = append(conds, text(stmt.Tag)+" == "+text(expr))
+					} else {
+						conds = append(conds, text(expr))
+					}
+				}
+				// The constant cases of a switch on a parameter are distinct, so each
+				// pins the parameter; without a tag, earlier clauses may be taken instead
+				var inputs []branchInput
+				pinned := false
+				if len(clause.List) > 0 {
+					if stmt.Tag != nil {
+						inputs, pinned = pinnedInputs(fset, &ast.BinaryExpr{X: stmt.Tag, Op: token.EQL, Y: clause.List[0]}, params)
+					} else if i == 0 {
+						inputs, pinned = pinnedInputs(fset, clause.List[0], params)
+					}
+				}
+				add(clauseName(conds, " || ", stmt.Tag, fset), clause.Pos(), clause.Body, inputs, pinned && reached[stmt] && stmt.Init == nil)
+			}
+		case *ast.TypeSwitchStmt:
+			var x ast.Expr
+			switch assign := stmt.Assign.(type) {
+			case *ast.AssignStmt:
+				x = assign.Rhs[0].(*ast.TypeAssertExpr).X
+			case *ast.ExprStmt:
+				x = assign.X.(*ast.TypeAssertExpr).X
+			}
+			for _, clause := range stmt.Body.List {
+				clause := clause.(*ast.CaseClause)
+				var conds []string
+				for _, expr := range clause.List {
+					conds = append(conds, text(x)+" is "+text(expr))
+				}
+				add(clauseName(conds, " or ", x, fset), clause.Pos(), clause.Body, nil, false)
+			}
+		}
+		return true
+	})
+	return cases
+}
+
+// paramObjects returns the names of the parameters of a function by their objects
+func paramObjects(fn *ast.FuncDecl) map[*ast.Object]string {
+	params := make(map[*ast.Object]string)
+	for _, field := range fn.Type.Params.List {
+		if _, variadic := field.Type.(*ast.Ellipsis); variadic {
+			continue
+		}
+		for _, name := range field.Names {
+			if name.Obj != nil && name.Name != "_" {
+				params[name.Obj] = name.Name
+			}
+		}
+	}
+	return params
+}
+
+// returnsOrAssigns reports whether a statement may return, or change a parameter
+func returnsOrAssigns(stmt ast.Stmt, params map[*ast.Object]string) bool {
+	found := false
+	assigned := func(expr ast.Expr) {
+		if ident, ok := ast.Unparen(expr).(*ast.Ident); ok && params[ident.Obj] != "" {
+			found = true
+		}
+	}
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// Closures can change the parameters they capture
+			ast.Inspect(n.Body, func(n ast.Node) bool {
+				if assign, ok := n.(*ast.AssignStmt); ok {
+					for _, lhs := range assign.Lhs {
+						assigned(lhs)
+					}
+				}
+				return !found
+			})
+			return false
+		case *ast.ReturnStmt:
+			found = true
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				assigned(lhs)
+			}
+		case *ast.IncDecStmt:
+			assigned(n.X)
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				assigned(n.X)
+			}
+		case *ast.CallExpr:
+			if ident, ok := n.Fun.(*ast.Ident); ok && ident.Name == "panic" && ident.Obj == nil {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// pinnedInputs returns the values a condition pins parameters to, reporting false unless
+// it only compares parameters with literals ('a == 1 && b == ""')
+func pinnedInputs(fset *token.FileSet, cond ast.Expr, params map[*ast.Object]string) ([]branchInput, bool) {
+	expr, ok := ast.Unparen(cond).(*ast.BinaryExpr)
+	if !ok {
+		return nil, false
+	}
+	switch expr.Op {
+	case token.LAND:
+		x, ok := pinnedInputs(fset, expr.X, params)
+		if !ok {
+			return nil, false
+		}
+		y, ok := pinnedInputs(fset, expr.Y, params)
+		if !ok {
+			return nil, false
+		}
+		for _, input := range y {
+			if slices.ContainsFunc(x, func(other branchInput) bool { return other.param == input.param }) {
+				return nil, false
+			}
+		}
+		return append(x, y...), true
+	case token.EQL:
+		param, value := expr.X, expr.Y
+		if pinnedLiteral(param) {
+			param, value = value, param
+		}
+		ident, ok := ast.Unparen(param).(*ast.Ident)
+		if !ok || params[ident.Obj] == "" || !pinnedLiteral(value) {
+			return nil, false
+		}
+		return []branchInput{{params[ident.Obj], nodeString(fset, value)}}, true
+	}
+	return nil, false
+}
+
+// pinnedLiteral reports whether an expression is a literal: a basic literal, maybe negated,
+// or nil, true, or false
+func pinnedLiteral(expr ast.Expr) bool {
+	switch expr := ast.Unparen(expr).(type) {
+	case *ast.BasicLit:
+		return true
+	case *ast.UnaryExpr:
+		_, ok := expr.X.(*ast.BasicLit)
+		return ok && (expr.Op == token.SUB || expr.Op == token.ADD)
+	case *ast.Ident:
+		return expr.Obj == nil && (expr.Name == "nil" || expr.Name == "true" || expr.Name == "false")
+	}
+	return false
+}
+
+// branchResults returns the values a branch returns besides a trailing error, reporting
+// whether they are known: the branch ends in its only return statement, which returns
+// literals and a nil error, or a non-nil error when wantErr is set. Branches of functions
+// without results are known as they are.
+func branchResults(fset *token.FileSet, fn *ast.FuncDecl, body []ast.Stmt, wantErr bool) ([]string, bool) {
+	if fn.Type.Results == nil || len(fn.Type.Results.List) == 0 {
+		return nil, true
+	}
+	if len(body) == 0 {
+		return nil, false
+	}
+	ret, ok := body[len(body)-1].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) == 0 || returnsOrAssigns(&ast.BlockStmt{List: body[:len(body)-1]}, nil) {
+		return nil, false
+	}
+	if wantErr {
+		return nil, true
+	}
+
+	values := ret.Results
+	if returnsError(fn) {
+		if last, ok := values[len(values)-1].(*ast.Ident); !ok || last.Name != "nil" || last.Obj != nil {
+			return nil, false
+		}
+		values = values[:len(values)-1]
+	}
+	var results []string
+	for _, value := range values {
+		if !pinnedLiteral(value) {
+			return nil, false
+		}
+		results = append(results, nodeString(fset, value))
+	}
+	return results, true
+}
+
+// clauseName names the case taking a switch clause after its conditions, or the default
+// clause after the switched expression ('op default')
+func clauseName(conds []string, sep string, tag ast.Expr, fset *token.FileSet) string {
+	if len(conds) > 0 {
+		return strings.Join(conds, sep)
+	}
+	if tag != nil {
+		return nodeString(fset, tag) + " default"
+	}
+	return "default"
+}
+
+// negation returns the text of a condition negated, dropping a leading ! and wrapping
+// anything but identifiers, selectors, and calls in parentheses
+func negation(fset *token.FileSet, cond ast.Expr) string {
+	switch cond := ast.Unparen(cond).(type) {
+	case *ast.UnaryExpr:
+		if cond.Op == token.NOT {
+			return nodeString(fset, cond.X)
+		}
+	case *ast.Ident, *ast.SelectorExpr, *ast.CallExpr:
+		return "!" + nodeString(fset, cond)
+	}
+	return "!(" + nodeString(fset, cond) + ")"
+}
+
+// resultCount counts the results of a function besides a trailing error
+func resultCount(fn *ast.FuncDecl) int {
+	n := 0
+	if fn.Type.Results != nil {
+		for _, result := range fn.Type.Results.List {
+			n += max(len(result.Names), 1)
+		}
+	}
+	if returnsError(fn) {
+		n--
+	}
+	return n
+}
+
+// returnsError reports whether the last result of a function is an error
+func returnsError(fn *ast.FuncDecl) bool {
+	if fn.Type.Results == nil || len(fn.Type.Results.List) == 0 {
+		return false
+	}
+	results := fn.Type.Results.List
+	ident, ok := results[len(results)-1].Type.(*ast.Ident)
+	return ok && ident.Name == "error"
+}
+
+// returnsNonNilError reports whether the statements of a branch return something other
+// than nil as the last result
+func returnsNonNilError(body []ast.Stmt) bool {
+	for _, stmt := range body {
+		ret, ok := stmt.(*ast.ReturnStmt)
+		if !ok || len(ret.Results) == 0 {
+			continue
+		}
+		if ident, ok := ret.Results[len(ret.Results)-1].(*ast.Ident); !ok || ident.Name != "nil" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeBranchCases writes the cases of a generated table. Cases of determined branches
+// are filled in when the table has the fields they set. The others get a comment pointing
+// at the branch they should take and, for branches returning an error, the wantErr field
+// set when the table has one; they are commented out, since with their inputs left to fill
+// in they would fail as soon as they run.
+func writeBranchCases(buf *bytes.Buffer, cases []branchCase, fields branchFields) {
+	for _, bc := range cases {
+		if values, ok := bc.fieldValues(fields); ok {
+			fmt.Fprintf(buf, "%s: {%s},\n", strconv.Quote(bc.name), strings.Join(values, ", "))
+			continue
+		}
+		fmt.Fprintf(buf, "// %s: {\n//\t// TODO: Take the branch at %s:%d.\n", strconv.Quote(bc.name), filepath.Base(bc.pos.Filename), bc.pos.Line)
+		if bc.wantErr && fields.wantErr != "" {
+			fmt.Fprintf(buf, "//\t%s: true,\n", fields.wantErr)
+		}
+		buf.WriteString("// },\n")
+	}
+}
+
+// fieldValues returns the keyed elements of the case of a determined branch, reporting
+// false when the branch isn't determined or the table lacks a field the case sets
+func (bc branchCase) fieldValues(fields branchFields) ([]string, bool) {
+	if !bc.determined {
+		return nil, false
+	}
+	var values []string
+	for _, input := range bc.inputs {
+		field, ok := fields.params[input.param]
+		if !ok {
+			return nil, false
+		}
+		values = append(values, field+": "+input.value)
+	}
+	if bc.wantErr {
+		if fields.wantErr == "" {
+			return nil, false
+		}
+		return append(values, fields.wantErr+": true"), true
+	}
+	if len(bc.results) != len(fields.results) {
+		return nil, false
+	}
+	for i, result := range bc.results {
+		values = append(values, fields.results[i]+": "+result)
+	}
+	return values, true
+}
+
+// commentedCaseNames returns the names of the cases writeBranchCases commented out in a
+// table, so that running generate again doesn't add them twice
+func commentedCaseNames(file *ast.File, lit *ast.CompositeLit) []string {
+	var names []string
+	for _, group := range file.Comments {
+		if group.Pos() < lit.Lbrace || group.End() > lit.Rbrace {
+			continue
+		}
+		for _, comment := range group.List {
+			text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+			if key, ok := strings.CutSuffix(text, ": {"); ok {
+				if name, err := strconv.Unquote(key); err == nil {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// extendTest adds a case for each branch of a function that has none yet to the map-based
+// table its existing test ranges over, returning the path and new contents of the test's
+// file
+func (c *Converter) extendTest(fset *token.FileSet, files []*sourceFile, fn *ast.FuncDecl, testName string) (string, []byte, error) {
+	for _, file := range files {
+		if !strings.HasSuffix(file.path, "_test.go") {
+			continue
+		}
+		for _, decl := range file.node.Decls {
+			test, ok := decl.(*ast.FuncDecl)
+			if !ok || test.Recv != nil || test.Name.Name != testName || test.Body == nil {
+				continue
+			}
+
+			lit := rangedMapTable(test)
+			if lit == nil {
+				return "", nil, fmt.Errorf("%s already exists and doesn't range over a map-based table", testName)
+			}
+			keys := make(map[string]bool)
+			for _, elt := range lit.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*ast.BasicLit); ok && key.Kind == token.STRING {
+						name, _ := strconv.Unquote(key.Value)
+						keys[name] = true
+					}
+				}
+			}
+			for _, name := range commentedCaseNames(file.node, lit) {
+				keys[name] = true
+			}
+
+			var missing []branchCase
+			for _, bc := range branchCases(fset, fn) {
+				if !keys[bc.name] {
+					missing = append(missing, bc)
+				}
+			}
+			if len(missing) == 0 {
+				return "", nil, fmt.Errorf("%s already has a case for every branch of %s", testName, fn.Name.Name)
+			}
+
+			// The fields generate names after the parameters and results are the ones cases set
+			caseType := lit.Type.(*ast.MapType).Value
+			fields := branchFields{params: make(map[string]string)}
+			for _, name := range paramObjects(fn) {
+				if hasCaseField(caseType, files, name) {
+					fields.params[name] = name
+				}
+			}
+			for i := range resultCount(fn) {
+				want := "want"
+				if i > 0 {
+					want = fmt.Sprintf("want%d", i)
+				}
+				if !hasCaseField(caseType, files, want) {
+					break
+				}
+				fields.results = append(fields.results, want)
+			}
+			if hasCaseField(caseType, files, "wantErr") {
+				fields.wantErr = "wantErr"
+			}
+			var buf bytes.Buffer
+			writeBranchCases(&buf, missing, fields)
+
+			// A last case on the line of the closing brace may lack its comma
+			rbrace := fset.Position(lit.Rbrace).Offset
+			var edits []sourceEdit
+			if len(lit.Elts) > 0 {
+				end := fset.Position(lit.Elts[len(lit.Elts)-1].End()).Offset
+				if !bytes.Contains(file.src[end:rbrace], []byte(",")) {
+					edits = append(edits, sourceEdit{start: end, end: end, text: ","})
+				}
+			}
+			edits = append(edits, sourceEdit{start: rbrace, end: rbrace, text: "\n" + buf.String()})
+			src := applyEdits(file.src, edits)
+			out, err := format.Source(src)
+			if err != nil {
+				return "", nil, fmt.Errorf("error formatting extended test: %v", err)
+			}
+			c.logf("Added %d cases to %s in %s\n", len(missing), testName, file.path)
+			return file.path, out, nil
+		}
+	}
+	return "", nil, fmt.Errorf("%s already exists", testName)
+}
+
+// rangedMapTable returns the first map literal of test cases a test ranges over, inline or
+// held by a variable, or nil when there is none
+func rangedMapTable(test *ast.FuncDecl) *ast.CompositeLit {
+	var table *ast.CompositeLit
+	ast.Inspect(test.Body, func(n ast.Node) bool {
+		if table != nil {
+			return false
+		}
+		if rangeStmt, ok := n.(*ast.RangeStmt); ok {
+			if lit, ok := tableValue(rangeStmt.X).(*ast.CompositeLit); ok {
+				if _, ok := lit.Type.(*ast.MapType); ok {
+					table = lit
+				}
+			}
+		}
+		return true
+	})
+	return table
+}
+
+// hasCaseField reports whether the case type of a table, a struct type or the name of one
+// declared by the package, has a field of the given name
+func hasCaseField(typ ast.Expr, files []*sourceFile, name string) bool {
+	if ident, ok := typ.(*ast.Ident); ok {
+		for _, file := range files {
+			for _, decl := range file.node.Decls {
+				if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+					for _, spec := range gen.Specs {
+						if spec := spec.(*ast.TypeSpec); spec.Name.Name == ident.Name {
+							typ = spec.Type
+						}
+					}
+				}
+			}
+		}
+	}
+
+	structType, ok := typ.(*ast.StructType)
+	if !ok {
+		return false
+	}
+	for _, field := range structType.Fields.List {
+		for _, fieldName := range field.Names {
+			if fieldName.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package tableconv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Version is the version of the converter. It is part of every cache key, together with
+// a hash of the running executable, so cached results never outlive a change to the tool.
+const Version = "0.2.0"
+
+// fileCache remembers the content hashes of files an earlier run left without anything
+// to convert, so unchanged files can be skipped without parsing them. Entries are empty
+// files named after the hash, spread over subdirectories like the go build cache.
+type fileCache struct {
+	dir string
+	// salt identifies the tool build and the options affecting conversion
+	salt []byte
+}
+
+// newFileCache returns a cache stored in dir, or nil when dir is empty
+func newFileCache(dir string, opts Options) *fileCache {
+	// Tables left out by a table filter stay unconverted, so a filtered run
+	// cannot tell that a file has nothing left to convert
+	if dir == "" || opts.TableFilter != nil {
+		return nil
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "tabletests %s\n%s\n", Version, executableID())
+
+	// Only options that change the outcome of a conversion belong in the key, by value:
+	// options deciding which files are read or where results go don't
+	options := []struct {
+		name  string
+		value any
+	}{
+		{"SkipTypeCheck", opts.SkipTypeCheck},
+		{"Subtests", opts.Subtests},
+		{"Tabulate", opts.Tabulate},
+		{"Parallel", opts.Parallel},
+		{"SuffixDuplicates", opts.SuffixDuplicates},
+		{"Dedupe", opts.Dedupe},
+		{"IgnoreOrder", opts.IgnoreOrder},
+		{"Keys", opts.Keys},
+		{"NameCheck", opts.NameCheck},
+		{"ExtractTypes", opts.ExtractTypes},
+		{"KeyedFields", opts.KeyedFields},
+		{"DeadFields", opts.DeadFields},
+		{"CmpDiff", opts.CmpDiff},
+		{"Assertions", opts.Assertions},
+		{"FromGinkgo", opts.FromGinkgo},
+		{"FromGocheck", opts.FromGocheck},
+		{"Golden", opts.Golden},
+		{"MaxCases", opts.MaxCases},
+		{"MaxTableBytes", opts.MaxTableBytes},
+	}
+	for _, opt := range options {
+		fmt.Fprintf(h, "%s=%v\n", opt.name, opt.value)
+	}
+
+	// Rules read from a config file are known by what they do; what other rules would
+	// change can't be told
+	for _, rule := range opts.Rules {
+		if rule.key == "" {
+			return nil
+		}
+		fmt.Fprintf(h, "rule %s\n", rule.key)
+	}
+	return &fileCache{dir: dir, salt: h.Sum(nil)}
+}
+
+// entry returns the path of the cache entry for a file's content
+func (fc *fileCache) entry(src []byte) string {
+	h := sha256.New()
+	h.Write(fc.salt)
+	h.Write(src)
+	sum := hex.EncodeToString(h.Sum(nil))
+	return filepath.Join(fc.dir, sum[:2], sum)
+}
+
+// isClean reports whether a file with this content was left clean by an earlier run
+func (fc *fileCache) isClean(src []byte) bool {
+	if fc == nil {
+		return false
+	}
+	_, err := os.Stat(fc.entry(src))
+	return err == nil
+}
+
+// markClean records that a file with this content has nothing left to convert.
+// Failing to write the cache only costs time on the next run, so errors are ignored.
+func (fc *fileCache) markClean(src []byte) {
+	if fc == nil {
+		return
+	}
+
+	path := fc.entry(src)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	if f, err := os.Create(path); err == nil {
+		f.Close()
+	}
+}
+
+// DefaultCacheDir returns the directory the command-line tool caches results in
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tabletests"), nil
+}
+
+var (
+	executableIDOnce sync.Once
+	executableIDHash string
+)
+
+// executableID hashes the running executable, so builds from modified sources don't
+// reuse results cached by another build of the same version
+func executableID() string {
+	executableIDOnce.Do(func() {
+		path, err := os.Executable()
+		if err != nil {
+			return
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err == nil {
+			executableIDHash = hex.EncodeToString(h.Sum(nil))
+		}
+	})
+	return executableIDHash
+}
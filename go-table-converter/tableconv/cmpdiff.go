@@ -0,0 +1,327 @@
+package tableconv
+
+import (
+	"bufio"
+	"bytes"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cmpModule is the module providing cmp.Diff, and cmpPackage its package
+const (
+	cmpModule  = "github.com/google/go-cmp"
+	cmpPackage = cmpModule + "/cmp"
+)
+
+// useCmpDiff replaces the reflect.DeepEqual assertions in the loops over converted tables
+// of a file with cmp.Diff, which reports what differs, and imports go-cmp. Files whose
+// module doesn't require go-cmp, or that refer to another package or variable as cmp, are
+// left alone, and so are the assertions in opaque, which cmp.Diff would panic on. It
+// reports whether any assertion was replaced.
+func (c *Converter) useCmpDiff(fset *token.FileSet, file *sourceFile, vars tableVars, opaque map[callSite]bool) bool {
+	if len(vars) == 0 {
+		return false
+	}
+	if !moduleRequires(filepath.Dir(file.path), cmpModule) {
+		c.logf("Skipping cmp.Diff in %s: the module doesn't require %s\n", file.path, cmpModule)
+		return false
+	}
+
+	imported := false
+	for _, spec := range file.node.Imports {
+		if importName(spec) != "cmp" {
+			continue
+		}
+		if spec.Path.Value != strconv.Quote(cmpPackage) {
+			c.logf("Skipping cmp.Diff in %s: cmp refers to %s\n", file.path, spec.Path.Value)
+			return false
+		}
+		imported = true
+	}
+
+	modified := false
+	for _, decl := range file.node.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil || usesName(fn.Body, "cmp") && !imported {
+			continue
+		}
+		if replaceDeepEqual(fset, fn, vars, opaque) {
+			c.logf("Replaced reflect.DeepEqual with cmp.Diff in %s\n", fn.Name.Name)
+			modified = true
+		}
+	}
+
+	if modified && !imported {
+		addImport(fset, file.node, cmpPackage)
+	}
+	return modified
+}
+
+// moduleRequires reports whether the go.mod file governing a directory requires a module
+func moduleRequires(dir, module string) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(readModFile(dir)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && fields[0] == "require" {
+			fields = fields[1:]
+		}
+		if len(fields) >= 2 && fields[0] == module {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceDeepEqual replaces the reflect.DeepEqual assertions in the loops over converted
+// tables of a function. An assertion whose body only reports the failure reports the diff
+// instead, prefixed with the case name unless it runs in a subtest already.
+// Change from: if !reflect.DeepEqual(got, tc.want) { t.Errorf("got %v, want %v", got, tc.want) }
+// To:          if diff := cmp.Diff(tc.want, got); diff != "" { t.Errorf("mismatch (-want +got):\n%s", diff) }
+// Assertions in opaque are left alone. It reports whether any assertion was replaced.
+func replaceDeepEqual(fset *token.FileSet, fn *ast.FuncDecl, vars tableVars, opaque map[callSite]bool) bool {
+	modified := false
+	forRangedLoops(fn, vars, func(rangeStmt *ast.RangeStmt) {
+		inSubtest := subtestStmts(rangeStmt.Body)
+		ast.Inspect(rangeStmt.Body, func(n ast.Node) bool {
+			ifStmt, ok := n.(*ast.IfStmt)
+			if !ok || ifStmt.Init != nil || usesName(ifStmt.Body, "diff") {
+				return true
+			}
+			want, got, ok := deepEqualArgs(ifStmt.Cond)
+			if !ok || opaque[callSiteOf(fset, ifStmt.Cond.(*ast.UnaryExpr).X.(*ast.CallExpr))] {
+				return true
+			}
+
+			pos := ifStmt.Cond.Pos()
+			diff := &ast.Ident{NamePos: pos, Name: "diff"}
+			ifStmt.Init = &ast.AssignStmt{
+				Lhs:    []ast.Expr{diff},
+				TokPos: pos,
+				Tok:    token.DEFINE,
+				Rhs: []ast.Expr{&ast.CallExpr{
+					Fun:    &ast.SelectorExpr{X: &ast.Ident{NamePos: pos, Name: "cmp"}, Sel: &ast.Ident{NamePos: pos, Name: "Diff"}},
+					Lparen: pos,
+					Args:   []ast.Expr{want, got},
+					Rparen: pos,
+				}},
+			}
+			ifStmt.Cond = &ast.BinaryExpr{
+				X:     &ast.Ident{NamePos: pos, Name: "diff"},
+				OpPos: pos,
+				Op:    token.NEQ,
+				Y:     &ast.BasicLit{ValuePos: pos, Kind: token.STRING, Value: `""`},
+			}
+
+			var key *ast.Ident
+			if !inSubtest[ifStmt] {
+				key = rangeKey(rangeStmt)
+			}
+			reportDiff(ifStmt.Body, key, inSubtest[ifStmt])
+			modified = true
+			return true
+		})
+	})
+	return modified
+}
+
+// subtestStmts returns the statements of a loop body that run inside 't.Run' closures, in
+// a subtest named after the case
+func subtestStmts(body *ast.BlockStmt) map[ast.Stmt]bool {
+	inSubtest := make(map[ast.Stmt]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && subtestCall(call) {
+			ast.Inspect(call, func(n ast.Node) bool {
+				if stmt, ok := n.(ast.Stmt); ok {
+					inSubtest[stmt] = true
+				}
+				return true
+			})
+		}
+		return true
+	})
+	return inSubtest
+}
+
+// subtestCall reports whether a call runs a subtest ('t.Run(name, func(t *testing.T) { ... })')
+func subtestCall(call *ast.CallExpr) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Run" || len(call.Args) != 2 {
+		return false
+	}
+	_, ok = call.Args[1].(*ast.FuncLit)
+	return ok
+}
+
+// deepEqualArgs returns the expected and actual values compared by a condition such as
+// '!reflect.DeepEqual(got, want)'. Arguments named like expected values ('want',
+// 'tc.expected') are the expected ones; otherwise the actual value is assumed to come first.
+func deepEqualArgs(cond ast.Expr) (want, got ast.Expr, ok bool) {
+	not, ok := cond.(*ast.UnaryExpr)
+	if !ok || not.Op != token.NOT {
+		return nil, nil, false
+	}
+	call, ok := not.X.(*ast.CallExpr)
+	if !ok || len(call.Args) != 2 || call.Ellipsis.IsValid() {
+		return nil, nil, false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "DeepEqual" {
+		return nil, nil, false
+	}
+	if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "reflect" || pkg.Obj != nil {
+		return nil, nil, false
+	}
+
+	if expectedValue(call.Args[0]) && !expectedValue(call.Args[1]) {
+		return call.Args[0], call.Args[1], true
+	}
+	return call.Args[1], call.Args[0], true
+}
+
+// expectedValue reports whether an expression is named like an expected value
+func expectedValue(expr ast.Expr) bool {
+	var name string
+	switch x := expr.(type) {
+	case *ast.Ident:
+		name = x.Name
+	case *ast.SelectorExpr:
+		name = x.Sel.Name
+	default:
+		return false
+	}
+	name = strings.ToLower(name)
+	return strings.HasPrefix(name, "want") || strings.HasPrefix(name, "exp")
+}
+
+// reportDiff rewrites the body of a replaced assertion that only reports the failure with
+// t.Error, t.Errorf, t.Fatal, or t.Fatalf into reporting the diff, prefixed with the case
+// name held by key unless the assertion runs in a subtest. Other bodies are left alone.
+func reportDiff(body *ast.BlockStmt, key *ast.Ident, inSubtest bool) {
+	call, sel, ok := failureBlock(body)
+	if !ok {
+		return
+	}
+	method := "Errorf"
+	if strings.HasPrefix(sel.Sel.Name, "Fatal") {
+		method = "Fatalf"
+	}
+	sel.Sel = &ast.Ident{NamePos: sel.Sel.Pos(), Name: method}
+
+	pos := call.Lparen
+	format := "mismatch (-want +got):\n%s"
+	var args []ast.Expr
+	if !inSubtest && key != nil {
+		format = "%s: " + format
+		args = append(args, &ast.Ident{NamePos: pos, Name: key.Name})
+	}
+	args = append([]ast.Expr{&ast.BasicLit{ValuePos: pos, Kind: token.STRING, Value: strconv.Quote(format)}}, args...)
+	call.Args = append(args, &ast.Ident{NamePos: pos, Name: "diff"})
+	call.Ellipsis = token.NoPos
+}
+
+// callSite identifies a call by the file and offset of its parenthesis, which stay the
+// same across file sets and line merges
+type callSite struct {
+	path   string
+	offset int
+}
+
+// callSiteOf returns the site of a call
+func callSiteOf(fset *token.FileSet, call *ast.CallExpr) callSite {
+	pos := fset.Position(call.Lparen)
+	return callSite{pos.Filename, pos.Offset}
+}
+
+// opaqueDeepEquals type-checks the files of a package and returns the reflect.DeepEqual
+// calls comparing values that cmp.Diff panics on: values whose types reach unexported
+// fields without an Equal method, and interfaces, whose dynamic values may. Calls whose
+// arguments can't be typed count as well.
+func (c *Converter) opaqueDeepEquals(files []*sourceFile) map[callSite]bool {
+	srcs := make([]namedSource, 0, len(files))
+	for _, file := range files {
+		srcs = append(srcs, namedSource{file.path, file.parsed})
+	}
+	srcs = append(srcs, c.siblingSources(files)...)
+
+	fset := token.NewFileSet()
+	info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	c.typeCheck(fset, srcs, info)
+
+	opaque := make(map[callSite]bool)
+	for expr := range info.Types {
+		call, ok := expr.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 || !isSelector(call.Fun, "reflect", "DeepEqual") {
+			continue
+		}
+		for _, arg := range call.Args {
+			typ := info.Types[arg].Type
+			if typ == nil || reachesUnexported(typ, make(map[types.Type]bool)) {
+				opaque[callSiteOf(fset, call)] = true
+			}
+		}
+	}
+	return opaque
+}
+
+// isSelector reports whether an expression selects name from an identifier ('reflect.DeepEqual')
+func isSelector(expr ast.Expr, x, name string) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != name {
+		return false
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	return ok && ident.Name == x
+}
+
+// reachesUnexported reports whether cmp.Diff would have to compare an unexported field, or
+// a value of unknown type, to compare values of a type. Types with an Equal method are
+// compared with it instead.
+func reachesUnexported(typ types.Type, seen map[types.Type]bool) bool {
+	if seen[typ] {
+		return false
+	}
+	seen[typ] = true
+	if hasEqualMethod(typ) {
+		return false
+	}
+
+	switch t := typ.Underlying().(type) {
+	case *types.Struct:
+		for field := range t.Fields() {
+			if !field.Exported() || reachesUnexported(field.Type(), seen) {
+				return true
+			}
+		}
+	case *types.Pointer:
+		return reachesUnexported(t.Elem(), seen)
+	case *types.Slice:
+		return reachesUnexported(t.Elem(), seen)
+	case *types.Array:
+		return reachesUnexported(t.Elem(), seen)
+	case *types.Map:
+		return reachesUnexported(t.Key(), seen) || reachesUnexported(t.Elem(), seen)
+	case *types.Interface:
+		return true
+	case *types.Basic:
+		return t.Kind() == types.Invalid
+	}
+	return false
+}
+
+// hasEqualMethod reports whether cmp.Diff compares values of a type with their Equal
+// method: one taking a value of the type and returning a bool
+func hasEqualMethod(typ types.Type) bool {
+	obj, _, _ := types.LookupFieldOrMethod(typ, true, nil, "Equal")
+	method, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	sig := method.Type().(*types.Signature)
+	return sig.Params().Len() == 1 && sig.Results().Len() == 1 &&
+		types.AssignableTo(typ, sig.Params().At(0).Type()) &&
+		types.Identical(sig.Results().At(0).Type(), types.Typ[types.Bool])
+}
@@ -0,0 +1,190 @@
+package tableconv
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// configPipeline is the section of a config file listing the built-in transforms to run
+const configPipeline = "pipeline"
+
+// Built-in transforms a pipeline can list
+const (
+	transformConvert  = "convert-to-map"
+	transformSubtests = "wrap-subtests"
+	transformParallel = "add-parallel"
+	transformKeyed    = "keyed-literals"
+	transformFormat   = "format"
+)
+
+// transformsBefore lists, for each built-in transform, the transforms that have to run
+// before it because it rewrites what they produce
+var transformsBefore = map[string][]string{
+	transformConvert:  nil,
+	transformSubtests: {transformConvert},
+	transformParallel: {transformConvert, transformSubtests},
+	transformKeyed:    {transformConvert},
+	transformFormat:   {transformConvert, transformSubtests, transformParallel, transformKeyed},
+}
+
+// Config is what a config file asks of a run: the built-in transforms of its pipeline,
+// in order, and the rules to run along with them
+type Config struct {
+	Pipeline []string
+	Rules    []Rule
+}
+
+// LoadConfig reads a config file, a YAML mapping in the subset of YAML that fixtures are
+// read in, with block or flow sequences allowed for the pipeline:
+//
+//	pipeline:
+//	  - convert-to-map
+//	  - wrap-subtests
+//	  - add-parallel
+//	  - keyed-literals
+//	  - format
+//	rename-field:
+//	  exp: expected
+//	replace-call:
+//	  checkEq: require.Equal
+//	  testutil.Diff: github.com/acme/check.Diff
+//
+// The pipeline starts with convert-to-map, and transforms rewriting what others produce
+// come after them: add-parallel after wrap-subtests, and format last. rename-field renames
+// a field of anonymous case structs, along with the keys of keyed cases and the selectors
+// of the field in the loops over the tables, leaving tables that already have a field of
+// the new name alone. replace-call makes calls of a function or package function call
+// another, keeping their arguments; the package of the replacement is imported when it is
+// a standard library or testify package or is given by import path.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("error reading config file: %v", err)
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext != ".yaml" && ext != ".yml" {
+		return Config{}, fmt.Errorf("unknown config file format %q: use .yaml", ext)
+	}
+	pipeline, rest, err := readPipeline(data)
+	if err != nil {
+		return Config{}, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	sections, err := readYAMLFixture(rest)
+	if err != nil {
+		return Config{}, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if err := checkPipeline(pipeline); err != nil {
+		return Config{}, fmt.Errorf("%s: %v", path, err)
+	}
+
+	cfg := Config{Pipeline: pipeline}
+	for _, section := range sections {
+		for i, from := range section.fields {
+			to := section.values[i]
+			if to.null || to.text == "" {
+				return Config{}, fmt.Errorf("%s: %s %s has no replacement", path, section.name, from)
+			}
+			rule, err := ruleFromConfig(section.name, from, to.text)
+			if err != nil {
+				return Config{}, fmt.Errorf("%s: %v", path, err)
+			}
+			cfg.Rules = append(cfg.Rules, rule)
+		}
+	}
+	return cfg, nil
+}
+
+// Apply turns on the options of the transforms of the pipeline and adds the rules to the
+// options. Converting and formatting always run, and the conversion runs the transforms
+// in an order satisfying every pipeline LoadConfig accepts, all in one pass over each file.
+func (cfg Config) Apply(opts *Options) {
+	for _, transform := range cfg.Pipeline {
+		switch transform {
+		case transformSubtests:
+			opts.Subtests = true
+		case transformParallel:
+			opts.Parallel = true
+		case transformKeyed:
+			opts.KeyedFields = true
+		}
+	}
+	opts.Rules = append(opts.Rules, cfg.Rules...)
+}
+
+// readPipeline reads the pipeline section out of a config file, returning its transforms
+// and the file with the lines of the section blanked, so that the rest reads as a fixture
+// with its line numbers intact
+func readPipeline(data []byte) ([]string, []byte, error) {
+	var pipeline []string
+	var rest bytes.Buffer
+	inPipeline := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+		case inPipeline && strings.HasPrefix(trimmed, "-"):
+			value, after, err := yamlScalar(strings.TrimSpace(trimmed[1:]), false)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			if after = strings.TrimSpace(after); after != "" && !strings.HasPrefix(after, "#") {
+				return nil, nil, fmt.Errorf("line %d: unexpected %q after the transform", line, after)
+			}
+			pipeline = append(pipeline, value)
+			text = ""
+		case text[0] != ' ' && text[0] != '\t':
+			inPipeline = false
+			key, value, err := yamlPair(trimmed)
+			if err != nil || key != configPipeline {
+				break
+			}
+			if pipeline != nil {
+				return nil, nil, fmt.Errorf("line %d: pipeline listed twice", line)
+			}
+			pipeline = []string{}
+			if value.text == "" && !value.quoted {
+				inPipeline = true
+			} else if items, ok := strings.CutPrefix(value.text, "["); ok && !value.quoted && strings.HasSuffix(items, "]") {
+				for _, item := range strings.Split(strings.TrimSuffix(items, "]"), ",") {
+					if item = strings.TrimSpace(item); item != "" {
+						pipeline = append(pipeline, item)
+					}
+				}
+			} else {
+				return nil, nil, fmt.Errorf("line %d: pipeline isn't a sequence", line)
+			}
+			text = ""
+		case inPipeline:
+			return nil, nil, fmt.Errorf("line %d: pipeline isn't a sequence", line)
+		}
+		rest.WriteString(text)
+		rest.WriteByte('\n')
+	}
+	return pipeline, rest.Bytes(), scanner.Err()
+}
+
+// checkPipeline checks that a pipeline lists known transforms once each, after the
+// transforms they depend on
+func checkPipeline(pipeline []string) error {
+	for i, transform := range pipeline {
+		before, known := transformsBefore[transform]
+		if !known {
+			return fmt.Errorf("unknown transform %q in pipeline", transform)
+		}
+		if slices.Contains(pipeline[:i], transform) {
+			return fmt.Errorf("transform %s listed twice in pipeline", transform)
+		}
+		for _, other := range before {
+			if j := slices.Index(pipeline, other); j > i || (j < 0 && other == transformConvert) {
+				return fmt.Errorf("transform %s has to come after %s in pipeline", transform, other)
+			}
+		}
+	}
+	return nil
+}
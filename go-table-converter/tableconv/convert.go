@@ -0,0 +1,998 @@
+package tableconv
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+)
+
+// tableCandidate is a slice-based table test found in a file
+type tableCandidate struct {
+	// ident is the variable holding the table, nil for a table ranged over inline
+	// ('for _, tc := range []struct{ ... }{ ... }')
+	ident *ast.Ident
+	// lit is the composite literal holding the test cases
+	lit *ast.CompositeLit
+	// spec is the var declaration of the table when it declares the slice type itself
+	spec *ast.ValueSpec
+	// table is the type of the table and its map-based replacement
+	table tableType
+	// funcName is the function declaring the table, empty for package-level tables
+	funcName string
+	// skip tells why the table was left unconverted once it was found
+	skip SkipReason
+	// converter converts the table when its directives override options, nil otherwise
+	converter *Converter
+}
+
+// inlineTableName names tables ranged over inline, which have no variable
+const inlineTableName = "(inline)"
+
+// findTables finds the slice-based table tests in a parsed file without modifying it
+func findTables(node *ast.File, types *namedTypes) []*tableCandidate {
+	var candidates []*tableCandidate
+
+	for _, decl := range node.Decls {
+		funcName := ""
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+			funcName = funcDecl.Name.Name
+		}
+
+		for _, candidate := range findDeclTables(decl, types) {
+			candidate.funcName = funcName
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	return candidates
+}
+
+// findDeclTables finds the slice-based table tests in a top-level declaration
+func findDeclTables(decl ast.Decl, types *namedTypes) []*tableCandidate {
+	var candidates []*tableCandidate
+
+	ast.Inspect(decl, func(n ast.Node) bool {
+		// Look for loops over inline tables like 'for _, tc := range []struct{ ... }{ ... }'
+		if rangeStmt, ok := n.(*ast.RangeStmt); ok {
+			if lit, table, ok := literalTable(rangeStmt.X, types); ok {
+				candidates = append(candidates, &tableCandidate{lit: lit, table: table})
+			}
+			return true
+		}
+
+		// Look for var declarations like 'var tests = []struct{ ... }{ ... }'
+		if spec, ok := n.(*ast.ValueSpec); ok {
+			for i, ident := range spec.Names {
+				if isBlankIdent(ident) {
+					continue
+				}
+
+				if candidate, ok := specTable(spec, i, types); ok {
+					candidates = append(candidates, candidate)
+				}
+			}
+
+			return true
+		}
+
+		// Look for short variable declarations and assignments like 'tests := []struct{ ... }{ ... }'
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok {
+			return true
+		}
+
+		// Only interested in := or = assignments
+		if assign.Tok != token.DEFINE && assign.Tok != token.ASSIGN {
+			return true
+		}
+
+		// Check each right-hand side value against the variable it is assigned to
+		for i, rhs := range assign.Rhs {
+			if i >= len(assign.Lhs) {
+				break
+			}
+
+			ident, ok := assign.Lhs[i].(*ast.Ident)
+			if !ok || isBlankIdent(ident) {
+				continue
+			}
+
+			if lit, table, ok := literalTable(rhs, types); ok {
+				candidates = append(candidates, &tableCandidate{ident: ident, lit: lit, table: table})
+			}
+		}
+
+		return true
+	})
+
+	return candidates
+}
+
+// convertTables converts the given tables of a parsed file in place. It returns the tables
+// that were converted; loops ranging over them are updated separately by updateLoops.
+// Tables assigned to the same variable are converted together or not at all, since the
+// variable can only have one type.
+func (c *Converter) convertTables(fset *token.FileSet, candidates []*tableCandidate) (converted []*tableCandidate, diags []Diagnostic) {
+	// Step 1: Check that the cases of each table can become map entries
+	checked := make(map[*tableCandidate][]*tableCase)
+	failed := make(map[any]bool)
+	for _, candidate := range candidates {
+		cases, caseDiags := c.forTable(candidate).checkCases(fset, candidate)
+		diags = append(diags, caseDiags...)
+		if cases == nil {
+			failed[candidate.varKey()] = true
+			continue
+		}
+		checked[candidate] = cases
+	}
+
+	// Step 2: Convert the slice of struct declarations (table tests)
+	for _, candidate := range candidates {
+		cases, ok := checked[candidate]
+		if !ok {
+			continue
+		}
+		if failed[candidate.varKey()] {
+			c.logf("Skipping table test variable %s: another table assigned to it can't be converted\n", candidate.name())
+			candidate.skip = SkipSharedVariable
+			continue
+		}
+
+		convertTableLiteral(fset, candidate.lit, candidate.table, cases)
+		if c.forTable(candidate).opts.KeyedFields {
+			keyCaseFields(candidate.table, cases)
+		}
+		if candidate.spec != nil {
+			candidate.spec.Type = candidate.table.declMapType(candidate.spec.Type)
+		}
+
+		c.logf("Found table test variable: %s\n", candidate.name())
+		converted = append(converted, candidate)
+	}
+
+	return converted, diags
+}
+
+// checkCases finds the cases of a table and names them, returning nil when they can't all
+// become map entries and recording why in the candidate
+func (c *Converter) checkCases(fset *token.FileSet, candidate *tableCandidate) ([]*tableCase, []Diagnostic) {
+	// Cases that aren't literals would be lost
+	if opaque := opaqueCases(fset, candidate); len(opaque) > 0 {
+		c.logf("Skipping table test variable %s: cases that aren't struct literals\n", candidate.name())
+		candidate.skip = SkipNonLiteralCases
+		return nil, opaque
+	}
+
+	cases := tableCases(candidate.lit, candidate.table)
+	diags := c.nameCases(fset, candidate, cases)
+	diags = append(diags, c.checkCaseNames(fset, candidate, cases)...)
+
+	// Cases sharing a name would become duplicate map keys
+	ok, dupDiags := c.resolveDuplicateNames(fset, candidate, cases)
+	diags = append(diags, dupDiags...)
+	if !ok {
+		c.logf("Skipping table test variable %s: duplicate case names\n", candidate.name())
+		candidate.skip = SkipDuplicateNames
+		return nil, diags
+	}
+
+	// Cases repeating the values of another add nothing but run time
+	cases, dupDiags = c.resolveDuplicateValues(fset, candidate, cases)
+	diags = append(diags, dupDiags...)
+
+	return cases, diags
+}
+
+// wholeVariables drops the kept tables assigned to a variable that other found tables are
+// assigned to as well, unless all of them were kept
+func wholeVariables(found, kept []*tableCandidate) []*tableCandidate {
+	isKept := make(map[*tableCandidate]bool)
+	for _, candidate := range kept {
+		isKept[candidate] = true
+	}
+	dropped := make(map[any]bool)
+	for _, candidate := range found {
+		if !isKept[candidate] {
+			dropped[candidate.varKey()] = true
+		}
+	}
+
+	var whole []*tableCandidate
+	for _, candidate := range kept {
+		if !dropped[candidate.varKey()] {
+			whole = append(whole, candidate)
+		}
+	}
+	return whole
+}
+
+// updateLoops updates the loops of a parsed file that range over the converted tables in
+// tableTestVars and reports whether the file was modified. With sharedLoopVars, the file
+// belongs to a module whose loops share their variables between iterations.
+func (c *Converter) updateLoops(fset *token.FileSet, node *ast.File, tableTestVars tableVars, sharedLoopVars bool) (modified bool) {
+	if len(tableTestVars) == 0 {
+		return false
+	}
+
+	// Step 2: Update range loops over table tests and references to the removed name field
+	ast.Inspect(node, func(n ast.Node) bool {
+		rangeStmt, ok := n.(*ast.RangeStmt)
+		if !ok {
+			return true
+		}
+
+		// Check if the range is over a table test
+		nameField, ok := tableTestVars.ranged(rangeStmt)
+		if !ok {
+			return true
+		}
+
+		c.logf("Found range over table test: %s\n", nodeString(fset, rangeStmt.X))
+
+		// Index-based loops get a case variable instead ('tests[i]' becomes 'tc')
+		if loop, _ := findIndexedLoop(rangeStmt); loop != nil && loop.rewrite(fset) {
+			modified = true
+		}
+
+		// Replace tc.name (whatever the case variable is called) with the map key
+		caseVar, ok := rangeStmt.Value.(*ast.Ident)
+		if !ok || isBlankIdent(caseVar) {
+			return true
+		}
+
+		replaceExprs(rangeStmt.Body, func(expr ast.Expr) ast.Expr {
+			sel, ok := expr.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != nameField {
+				return nil
+			}
+
+			if x, ok := sel.X.(*ast.Ident); !ok || x.Name != caseVar.Name {
+				return nil
+			}
+
+			key := rangeKey(rangeStmt)
+			if key == nil {
+				return nil
+			}
+
+			modified = true
+			return &ast.Ident{NamePos: sel.Pos(), Name: key.Name}
+		})
+
+		// A loop that only used the case for its name no longer needs it
+		if !usesIdents(rangeStmt.Body, caseVar.Name) {
+			rangeStmt.Value = nil
+		}
+
+		return true
+	})
+
+	// Step 3: Optionally run the cases of converted tables as (parallel) subtests
+	for _, decl := range node.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+
+		if c.opts.Subtests && wrapSubtests(fn, tableTestVars) {
+			c.logf("Wrapped loop bodies in subtests in %s\n", fn.Name.Name)
+			modified = true
+		}
+		if c.opts.Parallel && addParallel(fn, tableTestVars) {
+			c.logf("Added t.Parallel() calls to %s\n", fn.Name.Name)
+			modified = true
+		}
+		if sharedLoopVars && copyLoopVars(fn, tableTestVars) {
+			c.logf("Copied loop variables captured by parallel subtests in %s\n", fn.Name.Name)
+			modified = true
+		}
+	}
+
+	return modified
+}
+
+// rangeKey returns the key variable of a loop over a converted table, which holds the case
+// name. Loops ignoring the key get one named "name", or another name the loop doesn't use:
+// Change from: for _, tc := range tests
+// To:         for name, tc := range tests
+// Loops assigning the key to something other than a variable have none.
+func rangeKey(rangeStmt *ast.RangeStmt) *ast.Ident {
+	if isBlankIdent(rangeStmt.Key) || rangeStmt.Key == nil {
+		rangeStmt.Key = &ast.Ident{Name: freshKeyName(rangeStmt)}
+		if rangeStmt.Tok == token.ILLEGAL {
+			rangeStmt.Tok = token.DEFINE
+		}
+	}
+
+	key, _ := rangeStmt.Key.(*ast.Ident)
+	return key
+}
+
+// keyNames are the names tried in turn for the key of a loop over a converted table
+var keyNames = []string{"name", "tname", "caseName"}
+
+// freshKeyName returns a name for the key of a loop over a converted table that the loop
+// doesn't use already: the key would shadow a variable of the enclosing function used in
+// the body, or be shadowed by one the body declares. Uses of an existing key don't count,
+// since they are renamed along with it.
+func freshKeyName(rangeStmt *ast.RangeStmt) string {
+	var key *ast.Object
+	if ident, ok := rangeStmt.Key.(*ast.Ident); ok && ident.Obj != nil {
+		key = ident.Obj
+	}
+	free := func(name string) bool {
+		value, _ := rangeStmt.Value.(*ast.Ident)
+		return (value == nil || value.Name != name) && !usesNameExcept(rangeStmt.Body, name, key)
+	}
+	for _, name := range keyNames {
+		if free(name) {
+			return name
+		}
+	}
+	for n := 2; ; n++ {
+		if name := fmt.Sprintf("caseName%d", n); free(name) {
+			return name
+		}
+	}
+}
+
+// tableVars maps the variables holding converted tables to their name field. Variables are
+// identified by their declaration so loops over an unrelated variable of the same name, such
+// as an already map-based table in another test, are left untouched. Tables ranged over
+// inline are identified by their literal.
+type tableVars map[any]string
+
+// addShared records a converted package-level table for the files of the package that
+// don't declare it. Their references to the table can't be resolved within the file,
+// so they are matched by name.
+func (vars tableVars) addShared(candidate *tableCandidate) {
+	vars[candidate.ident.Name] = candidate.table.nameField
+}
+
+// add records a converted table
+func (vars tableVars) add(candidate *tableCandidate) {
+	if candidate.ident == nil {
+		vars[candidate.lit] = candidate.table.nameField
+		return
+	}
+	vars[varKey(candidate.ident)] = candidate.table.nameField
+}
+
+// ranged returns the name field of the converted table a loop ranges over
+func (vars tableVars) ranged(rangeStmt *ast.RangeStmt) (string, bool) {
+	switch x := rangeStmt.X.(type) {
+	case *ast.Ident:
+		return vars.lookup(x)
+	case *ast.CompositeLit:
+		nameField, ok := vars[x]
+		return nameField, ok
+	case *ast.CallExpr:
+		if name, _ := baseName(x.Fun); name != "" && len(x.Args) == 0 {
+			nameField, ok := vars[helperKey(name)]
+			return nameField, ok
+		}
+	}
+	return "", false
+}
+
+// lookup returns the name field of the converted table a variable refers to
+func (vars tableVars) lookup(ident *ast.Ident) (string, bool) {
+	nameField, ok := vars[varKey(ident)]
+	return nameField, ok
+}
+
+// varKey identifies the variable an identifier refers to: its resolved declaration when the
+// parser could resolve it within the file, and otherwise its name
+func varKey(ident *ast.Ident) any {
+	if ident.Obj != nil {
+		return ident.Obj
+	}
+	return ident.Name
+}
+
+// tableType describes the type of a slice-based table test and its map-based replacement
+type tableType struct {
+	// mapType is the map type that replaces the slice type
+	mapType *ast.MapType
+	// nameField is the name of the field that becomes the map key
+	nameField string
+	// nameFieldIndex is the position of the name field in the struct
+	nameFieldIndex int
+	// named is the declared case type when the table uses one instead of an anonymous struct
+	named *namedType
+	// pointer is set for tables of pointers to structs ([]*testCase)
+	pointer bool
+	// structType is the case struct type
+	structType *ast.StructType
+}
+
+// trimsType reports whether converting the table drops the name field from its named
+// case type, which tables without a name field leave alone
+func (table tableType) trimsType() bool {
+	return table.named != nil && table.nameField != ""
+}
+
+// declMapType returns the map type replacing a table type declared on a variable.
+// The declaration gets its own copy so each node appears once in the tree.
+func (table tableType) declMapType(declared ast.Expr) *ast.MapType {
+	mapType := *table.mapType
+	if arrayType, ok := declared.(*ast.ArrayType); ok {
+		mapType.Map = arrayType.Lbrack
+	}
+	if table.named != nil {
+		mapType.Value = table.elemType(&ast.Ident{NamePos: declared.End(), Name: table.named.spec.Name.Name})
+		if arrayType, ok := declared.(*ast.ArrayType); ok && table.named.generic() {
+			elt := arrayType.Elt
+			if star, ok := elt.(*ast.StarExpr); ok {
+				elt = star.X
+			}
+			mapType.Value = table.elemType(elt)
+		}
+	}
+	return &mapType
+}
+
+// tableMapType checks whether a type expression is a slice of structs. The struct may be
+// anonymous or a named case type declared in the package. It returns the equivalent map type
+// and the name field that becomes the map key, if the struct has one; the cases of tables
+// without one are only converted when keys are generated for them.
+func tableMapType(typ ast.Expr, types *namedTypes) (tableType, bool) {
+	// Check if it's a slice of structs
+	arrayType, ok := typ.(*ast.ArrayType)
+	if !ok {
+		return tableType{}, false
+	}
+
+	// Check if it's a slice (no length)
+	if arrayType.Len != nil {
+		return tableType{}, false
+	}
+
+	// Check if element type is a struct, either inline or a named case type, or a
+	// pointer to one
+	elt := arrayType.Elt
+	star, pointer := elt.(*ast.StarExpr)
+	if pointer {
+		elt = star.X
+	}
+
+	var named *namedType
+	structType, ok := elt.(*ast.StructType)
+	if !ok {
+		// Generic case types are instantiated with their type arguments
+		name, instantiated := baseName(elt)
+		if name == "" {
+			return tableType{}, false
+		}
+
+		named = types.lookup(name)
+		if named == nil || named.generic() != instantiated {
+			return tableType{}, false
+		}
+		structType = named.structType
+	}
+
+	// Check for name/description field
+	nameField, nameFieldIndex := findNameField(structType)
+
+	table := tableType{nameField: nameField, nameFieldIndex: nameFieldIndex, named: named, pointer: pointer, structType: structType}
+	table.mapType = &ast.MapType{
+		Map: arrayType.Lbrack,
+		Key: &ast.Ident{Name: "string"},
+	}
+	if named != nil && named.generic() {
+		// The type arguments carry over to the map ('map[string]testCase[int]')
+		table.mapType.Value = table.elemType(elt)
+	} else if named != nil {
+		// The named type itself is trimmed once all of its tables are converted
+		table.mapType.Value = table.elemType(&ast.Ident{NamePos: elt.Pos(), Name: named.spec.Name.Name})
+	} else {
+		table.mapType.Value = table.elemType(createStructTypeWithoutField(structType, nameFieldIndex))
+	}
+
+	return table, true
+}
+
+// elemType returns the map value type for a case struct type, a pointer to it for
+// tables of pointers
+func (table tableType) elemType(structType ast.Expr) ast.Expr {
+	if table.pointer {
+		return &ast.StarExpr{Star: structType.Pos(), X: structType}
+	}
+	return structType
+}
+
+// literalTable checks whether an expression is a slice-based table test literal
+func literalTable(expr ast.Expr, types *namedTypes) (*ast.CompositeLit, tableType, bool) {
+	// Check if it's a composite literal
+	compLit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, tableType{}, false
+	}
+
+	table, ok := tableMapType(compLit.Type, types)
+	if !ok {
+		return nil, tableType{}, false
+	}
+
+	return compLit, table, true
+}
+
+// specTable checks whether a 'var tests = []struct{ ... }{ ... }' declaration holds a table test.
+// The slice type may be declared on the variable, inferred from the composite literal, or both.
+func specTable(spec *ast.ValueSpec, i int, types *namedTypes) (*tableCandidate, bool) {
+	if i >= len(spec.Values) {
+		return nil, false
+	}
+
+	lit, table, ok := literalTable(spec.Values[i], types)
+	if !ok {
+		return nil, false
+	}
+
+	// The value expression carries the type when the declaration omits it
+	if spec.Type == nil {
+		return &tableCandidate{ident: spec.Names[i], lit: lit, table: table}, true
+	}
+
+	// A declared type is shared by every name in the spec, so only rewrite single-name specs
+	if len(spec.Names) != 1 {
+		return nil, false
+	}
+
+	if _, ok := tableMapType(spec.Type, types); !ok {
+		return nil, false
+	}
+
+	return &tableCandidate{ident: spec.Names[i], lit: lit, spec: spec, table: table}, true
+}
+
+// varKey identifies the variable the table is assigned to, or the table itself when it
+// is ranged over inline
+func (candidate *tableCandidate) varKey() any {
+	if candidate.ident == nil {
+		return candidate.lit
+	}
+	return varKey(candidate.ident)
+}
+
+// name returns the variable holding the table, or inlineTableName for inline tables
+func (candidate *tableCandidate) name() string {
+	if candidate.ident == nil {
+		return inlineTableName
+	}
+	return candidate.ident.Name
+}
+
+// info describes the table for reports
+func (candidate *tableCandidate) info(fset *token.FileSet) Table {
+	return Table{
+		Name: candidate.name(),
+		Func: candidate.funcName,
+		Pos:  fset.Position(candidate.pos()),
+	}
+}
+
+// pos returns the position of the table: its variable, or its literal when ranged over
+// inline
+func (candidate *tableCandidate) pos() token.Pos {
+	if candidate.ident != nil {
+		return candidate.ident.Pos()
+	}
+	return candidate.lit.Pos()
+}
+
+// typeRefs counts the references to the table's named case type made by the table itself
+func (candidate *tableCandidate) typeRefs() int {
+	if candidate.table.named == nil {
+		return 0
+	}
+
+	name := candidate.table.named.spec.Name.Name
+
+	// The slice element type of the literal and of the declaration
+	refs := 1
+	if candidate.spec != nil {
+		refs++
+	}
+
+	// Case literals that spell out the type ('testCase{...}' or '&testCase{...}')
+	for _, elt := range candidate.lit.Elts {
+		if caseLit := caseLiteral(elt); caseLit != nil {
+			if typeName, _ := baseName(caseLit.Type); typeName == name {
+				refs++
+			}
+		}
+	}
+
+	return refs
+}
+
+// tableCase is a test case literal of a table
+type tableCase struct {
+	// elt is the case as written in the table, lit itself or &lit for tables of pointers
+	elt ast.Expr
+	lit *ast.CompositeLit
+	// name is the value of the name field: a literal, a constant expression, or an
+	// expression computed at run time
+	name ast.Expr
+	// nameIndex is the index of the name field in the literal, -1 when a keyed literal omits it
+	nameIndex int
+}
+
+// tableCases finds the test case literals of a table and their names.
+// Both positional ({"simple sum", 1, 2}) and keyed ({name: "simple sum", a: 1}) literals are supported.
+func tableCases(compLit *ast.CompositeLit, table tableType) []*tableCase {
+	var cases []*tableCase
+	for _, elt := range compLit.Elts {
+		caseLit := caseLiteral(elt)
+		if caseLit == nil {
+			continue
+		}
+
+		tc := &tableCase{elt: elt, lit: caseLit, nameIndex: -1}
+		cases = append(cases, tc)
+
+		// Cases of tables without a name field get generated names
+		if table.nameField == "" {
+			continue
+		}
+
+		// Keyed literals name their fields, so the name can appear anywhere or be omitted
+		if len(caseLit.Elts) > 0 {
+			if _, keyed := caseLit.Elts[0].(*ast.KeyValueExpr); keyed {
+				for j, elt := range caseLit.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						if key, ok := kv.Key.(*ast.Ident); ok && key.Name == table.nameField {
+							tc.name = kv.Value
+							tc.nameIndex = j
+						}
+					}
+				}
+
+				// An omitted name field holds the zero value
+				if tc.nameIndex < 0 {
+					tc.name = &ast.BasicLit{Kind: token.STRING, Value: `""`}
+				}
+				continue
+			}
+		}
+
+		// An empty literal holds the zero value as well
+		if len(caseLit.Elts) == 0 {
+			tc.name = &ast.BasicLit{Kind: token.STRING, Value: `""`}
+			continue
+		}
+		if table.nameFieldIndex < len(caseLit.Elts) {
+			tc.name = caseLit.Elts[table.nameFieldIndex]
+			tc.nameIndex = table.nameFieldIndex
+		}
+	}
+
+	return cases
+}
+
+// opaqueCases reports the elements of a table that aren't case literals, such as calls to
+// case constructors. Their names can't be moved to the map key, so the table can't be
+// converted without dropping them.
+func opaqueCases(fset *token.FileSet, candidate *tableCandidate) []Diagnostic {
+	var diags []Diagnostic
+	for _, elt := range candidate.lit.Elts {
+		if caseLiteral(elt) == nil {
+			diags = append(diags, Diagnostic{
+				Table:   candidate.name(),
+				Message: fmt.Sprintf("case %s is not a struct literal, so its name can't become the map key", nodeString(fset, elt)),
+				Pos:     fset.Position(elt.Pos()),
+			})
+		}
+	}
+	return diags
+}
+
+// caseLiteral returns the struct literal of a table element, which tables of pointers may
+// write as &testCase{...}, or nil when the element is no literal
+func caseLiteral(elt ast.Expr) *ast.CompositeLit {
+	if unary, ok := elt.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+		elt = unary.X
+	}
+	lit, _ := elt.(*ast.CompositeLit)
+	return lit
+}
+
+// convertTableLiteral converts a slice of structs literal to a map keyed by the name field in place
+func convertTableLiteral(fset *token.FileSet, compLit *ast.CompositeLit, table tableType, cases []*tableCase) {
+	// Create new map entries from the slice elements
+	entries := make([]ast.Expr, 0, len(compLit.Elts))
+	for _, tc := range cases {
+		// Extract name field value for map key and drop it from the struct literal
+		sliceElt := tc.lit
+		nameValue := tc.name
+		newElts := splitCaseName(fset, tc)
+
+		// Reuse the case literal as the map value so its comments stay attached, and
+		// keep positions in source order so the printer places them next to the case.
+		// A name that doesn't lead the literal on its first line is moved to the opening
+		// brace instead.
+		if tc.elt == sliceElt && sliceElt.Type == nil && nameValue.Pos().IsValid() && (len(newElts) == 0 || nameValue.Pos() < newElts[0].Pos()) && fset.Position(nameValue.Pos()).Line == fset.Position(sliceElt.Lbrace).Line {
+			sliceElt.Lbrace = nameValue.End()
+		} else {
+			nameValue = &ast.BasicLit{ValuePos: tc.elt.Pos(), Kind: token.STRING, Value: nodeString(fset, nameValue)}
+		}
+		sliceElt.Elts = newElts
+
+		// A case spelling out the anonymous struct type loses the name field too
+		if structType, ok := sliceElt.Type.(*ast.StructType); ok {
+			collapseRemovedField(fset, structType, table.nameFieldIndex)
+			sliceElt.Type = createStructTypeWithoutField(structType, table.nameFieldIndex)
+		}
+
+		// Create map entry, keeping the & of tables of pointers
+		entry := &ast.KeyValueExpr{
+			Key:   nameValue,
+			Colon: nameValue.End(),
+			Value: tc.elt,
+		}
+
+		entries = append(entries, entry)
+	}
+
+	// Replace the original slice with the new map, whose anonymous struct type lost the
+	// name field
+	if table.named == nil {
+		collapseRemovedField(fset, table.structType, table.nameFieldIndex)
+	}
+	compLit.Type = table.mapType
+	compLit.Elts = entries
+}
+
+// splitCaseName returns the elements of a test case literal without its name field
+func splitCaseName(fset *token.FileSet, tc *tableCase) []ast.Expr {
+	caseLit := tc.lit
+	if tc.nameIndex < 0 {
+		return caseLit.Elts
+	}
+
+	// A name field on a line of its own in a multi-line literal leaves its line behind
+	prevEnd, next := caseLit.Lbrace, caseLit.Rbrace
+	if tc.nameIndex > 0 {
+		prevEnd = caseLit.Elts[tc.nameIndex-1].End()
+	}
+	if tc.nameIndex+1 < len(caseLit.Elts) {
+		next = caseLit.Elts[tc.nameIndex+1].Pos()
+	}
+	collapseRemovedLines(fset, prevEnd, caseLit.Elts[tc.nameIndex], next)
+
+	rest := make([]ast.Expr, 0, len(caseLit.Elts)-1)
+	for j, val := range caseLit.Elts {
+		if j != tc.nameIndex {
+			rest = append(rest, val)
+		}
+	}
+
+	return rest
+}
+
+// keyCaseFields rewrites the positional cases of a converted table into keyed literals
+// naming each field, which the name field no longer takes part in
+// Change from: "simple sum": {2, 3, 5}
+// To:          "simple sum": {a: 2, b: 3, expected: 5}
+func keyCaseFields(table tableType, cases []*tableCase) {
+	var names []string
+	for i, name := range fieldNames(table.structType) {
+		if i != table.nameFieldIndex {
+			names = append(names, name)
+		}
+	}
+
+	for _, tc := range cases {
+		// Positional literals list every field; keyed ones are left as they are
+		if len(tc.lit.Elts) == 0 || len(tc.lit.Elts) != len(names) {
+			continue
+		}
+		if _, keyed := tc.lit.Elts[0].(*ast.KeyValueExpr); keyed {
+			continue
+		}
+
+		for i, value := range tc.lit.Elts {
+			tc.lit.Elts[i] = &ast.KeyValueExpr{
+				Key:   &ast.Ident{NamePos: value.Pos(), Name: names[i]},
+				Colon: value.Pos(),
+				Value: value,
+			}
+		}
+	}
+}
+
+// collapseRemovedLines merges the lines of a removed node that sat on lines of its own into the
+// line that follows, so the printer doesn't leave a blank line where the node used to be
+func collapseRemovedLines(fset *token.FileSet, prevEnd token.Pos, removed ast.Node, next token.Pos) {
+	file := fset.File(removed.Pos())
+	if file == nil || !prevEnd.IsValid() || !next.IsValid() {
+		return
+	}
+
+	start := file.Line(removed.Pos())
+	if file.Line(prevEnd) >= start || file.Line(removed.End()) >= file.Line(next) {
+		return
+	}
+
+	for merges := file.Line(next) - start; merges > 0; merges-- {
+		file.MergeLine(start)
+	}
+}
+
+// findNameField tries to find the name field in a struct type. The index counts fields the
+// way positional literals do: each name of a multi-name field ('name, desc string') and each
+// embedded field is one element.
+func findNameField(structType *ast.StructType) (string, int) {
+	if structType.Fields == nil || structType.Fields.List == nil {
+		return "", -1
+	}
+
+	// Check for name field
+	index := 0
+	for _, field := range structType.Fields.List {
+		// Embedded fields take one element and can't be the name field
+		if len(field.Names) == 0 {
+			index++
+			continue
+		}
+
+		for _, name := range field.Names {
+			fieldName := name.Name
+			if fieldName == "name" || fieldName == "desc" || fieldName == "description" {
+				return fieldName, index
+			}
+			index++
+		}
+	}
+
+	return "", -1
+}
+
+// promotedNameField returns the name field a struct type has through a struct it embeds,
+// at any depth, when the embedded types are declared in the package; "" if it has none
+func promotedNameField(structType *ast.StructType, types *namedTypes) string {
+	seen := make(map[*ast.StructType]bool)
+	var find func(structType *ast.StructType) string
+	find = func(structType *ast.StructType) string {
+		if structType == nil || structType.Fields == nil || seen[structType] {
+			return ""
+		}
+		seen[structType] = true
+		for _, field := range structType.Fields.List {
+			if len(field.Names) > 0 {
+				continue
+			}
+			typ := field.Type
+			if star, ok := typ.(*ast.StarExpr); ok {
+				typ = star.X
+			}
+			name, _ := baseName(typ)
+			named := types.lookup(name)
+			if named == nil {
+				continue
+			}
+			if nameField, _ := findNameField(named.structType); nameField != "" {
+				return nameField
+			}
+			if nameField := find(named.structType); nameField != "" {
+				return nameField
+			}
+		}
+		return ""
+	}
+	return find(structType)
+}
+
+// collapseRemovedField merges the lines of a field that createStructTypeWithoutField drops
+// into the line that follows, so the printer doesn't leave a blank line in the struct type
+func collapseRemovedField(fset *token.FileSet, structType *ast.StructType, fieldIndex int) {
+	if fieldIndex < 0 || structType.Fields == nil {
+		return
+	}
+
+	fields := structType.Fields.List
+	index := 0
+	for i, field := range fields {
+		count := max(1, len(field.Names))
+		if fieldIndex >= index+count {
+			index += count
+			continue
+		}
+
+		// A field sharing its declaration keeps its line
+		if count > 1 {
+			return
+		}
+
+		prevEnd, next := structType.Fields.Opening, structType.Fields.Closing
+		if i > 0 {
+			prevEnd = fields[i-1].End()
+		}
+		if i+1 < len(fields) {
+			next = fields[i+1].Pos()
+		}
+		// The doc comment of the field goes along with it
+		var removed ast.Node = field
+		if field.Doc != nil {
+			removed = span{from: field.Doc.Pos(), to: field.End()}
+		}
+		collapseRemovedLines(fset, prevEnd, removed, next)
+		return
+	}
+}
+
+// span is a node covering a range of source, such as a field along with its doc comment
+type span struct {
+	from, to token.Pos
+}
+
+func (s span) Pos() token.Pos { return s.from }
+func (s span) End() token.Pos { return s.to }
+
+// createStructTypeWithoutField creates a new struct type without the field at the given
+// positional index, as returned by findNameField. A field sharing its declaration with
+// others ('name, desc string') only loses its own name.
+func createStructTypeWithoutField(structType *ast.StructType, fieldIndex int) *ast.StructType {
+	if fieldIndex < 0 {
+		return structType
+	}
+
+	newFields := &ast.FieldList{
+		Opening: structType.Fields.Opening,
+		List:    make([]*ast.Field, 0, len(structType.Fields.List)),
+		Closing: structType.Fields.Closing,
+	}
+
+	index := 0
+	for _, field := range structType.Fields.List {
+		count := max(1, len(field.Names))
+		if fieldIndex < index || fieldIndex >= index+count {
+			newFields.List = append(newFields.List, field)
+			index += count
+			continue
+		}
+
+		if len(field.Names) > 1 {
+			trimmed := *field
+			trimmed.Names = make([]*ast.Ident, 0, len(field.Names)-1)
+			for j, name := range field.Names {
+				if index+j != fieldIndex {
+					trimmed.Names = append(trimmed.Names, name)
+				}
+			}
+			newFields.List = append(newFields.List, &trimmed)
+		}
+		index += count
+	}
+
+	return &ast.StructType{
+		Struct: structType.Struct,
+		Fields: newFields,
+	}
+}
+
+// baseName returns the name of a type or function, which may be instantiated with type
+// arguments ('testCase[int]'), and whether it is. It returns "" for any other expression.
+func baseName(expr ast.Expr) (string, bool) {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		return x.Name, false
+	case *ast.IndexExpr:
+		if ident, ok := x.X.(*ast.Ident); ok {
+			return ident.Name, true
+		}
+	case *ast.IndexListExpr:
+		if ident, ok := x.X.(*ast.Ident); ok {
+			return ident.Name, true
+		}
+	}
+	return "", false
+}
+
+// isBlankIdent checks if an expression is a blank identifier (_)
+func isBlankIdent(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == "_"
+}
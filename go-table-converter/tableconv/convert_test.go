@@ -0,0 +1,152 @@
+package tableconv
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected output of the conversion tests")
+
+// TestConvert converts the module under testdata/convert/<name>/input with the options of
+// each case and compares the files it ends up with, along with a report of the tables
+// converted and skipped and of the diagnostics, with testdata/convert/<name>/want. Run
+// 'go test -update' to rewrite the expected output after changing a transform. A config
+// file in the input sets the options of its pipeline, like -config.
+func TestConvert(t *testing.T) {
+	tests := map[string]Options{
+		"index-loops":   {},
+		"golden":        {Golden: 32},
+		"cmp-diff":      {CmpDiff: true},
+		"tabulate":      {Tabulate: true},
+		"embedded-name": {},
+		"dead-fields":   {DeadFields: DeadFieldsRemove, Parallel: true},
+		"from-gocheck":  {FromGocheck: true},
+		"pipeline":      {},
+	}
+
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			input := filepath.Join("testdata", "convert", name, "input")
+			dir := t.TempDir()
+			if err := os.CopyFS(dir, os.DirFS(input)); err != nil {
+				t.Fatal(err)
+			}
+
+			config := filepath.Join(dir, "tabletests.yaml")
+			if _, err := os.Stat(config); err == nil {
+				cfg, err := LoadConfig(config)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cfg.Apply(&opts)
+			}
+			// The outputs may import modules missing from the module cache
+			opts.SkipTypeCheck = true
+
+			result, err := NewConverter(opts).ConvertDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := readTree(t, dir)
+			got["report.txt"] = conversionReport(dir, result)
+
+			want := filepath.Join("testdata", "convert", name, "want")
+			if *update {
+				writeTree(t, want, got)
+				return
+			}
+			for path, data := range readTree(t, want) {
+				if out, ok := got[path]; !ok {
+					t.Errorf("%s is missing", path)
+				} else if out != data {
+					t.Errorf("%s differs from the expected output:\n%s", path, unifiedDiff(path, []byte(data), []byte(out), nil))
+				}
+				delete(got, path)
+			}
+			for path := range got {
+				t.Errorf("unexpected file %s", path)
+			}
+		})
+	}
+}
+
+// conversionReport lists the tables a conversion converted and skipped and the
+// diagnostics it reported, with paths relative to dir
+func conversionReport(dir string, result ConversionResult) string {
+	position := func(pos fmt.Stringer) string {
+		rel, err := filepath.Rel(dir, pos.String())
+		if err != nil {
+			return pos.String()
+		}
+		return filepath.ToSlash(rel)
+	}
+
+	table := func(table Table) string {
+		if table.Func == "" {
+			return table.Name
+		}
+		return table.Name + " in " + table.Func
+	}
+
+	var lines []string
+	for _, converted := range result.Tables {
+		lines = append(lines, fmt.Sprintf("%s: converted %s", position(converted.Pos), table(converted)))
+	}
+	for _, skipped := range result.Skipped {
+		lines = append(lines, fmt.Sprintf("%s: skipped %s: %s", position(skipped.Table.Pos), table(skipped.Table), skipped.Reason))
+	}
+	for _, diag := range result.Diagnostics {
+		lines = append(lines, fmt.Sprintf("%s: %s: %s", position(diag.Pos), diag.Table, diag.Message))
+	}
+	for _, err := range result.Errors {
+		lines = append(lines, fmt.Sprintf("error: %s", err.Message))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// readTree returns the contents of the files under dir by slash-separated relative path
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// writeTree replaces dir with the given files
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	for path, data := range files {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
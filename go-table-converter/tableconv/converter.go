@@ -0,0 +1,1203 @@
+// Package tableconv converts slice-based table tests in Go to map-based table tests.
+package tableconv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ConversionResult holds statistics about the conversion process
+type ConversionResult struct {
+	FilesProcessed  int
+	FilesModified   int
+	FilesCached     int
+	FilesGenerated  int
+	TablesConverted int
+	TablesSkipped   int
+	// TablesSuppressed counts the tables left alone because of a //tabletests:ignore
+	// directive
+	TablesSuppressed int
+	Tables           []Table
+	Diagnostics      []Diagnostic
+	Skipped          []SkippedTable
+	Files            []FileResult
+	Errors           []FileError
+	// Timings is the time spent in each phase of the conversion
+	Timings Timings
+}
+
+// FileError describes a file that could not be converted
+type FileError struct {
+	Path    string
+	Message string
+}
+
+// Error implements the error interface
+func (e FileError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// FileResult holds information about the conversion of a single file
+type FileResult struct {
+	Path            string
+	Modified        bool
+	Cached          bool
+	Generated       bool
+	TablesConverted int
+	// TablesSkipped counts the slice-based tables of the file that were found but left
+	// unconverted, such as tables whose loops depend on the order of their cases
+	TablesSkipped int
+	// TablesSuppressed counts the tables of the file excluded by a //tabletests:ignore
+	// directive
+	TablesSuppressed int
+	Tables           []Table
+	Diagnostics      []Diagnostic
+	// Skipped lists the tables counted by TablesSkipped, and the tables without a name
+	// field that loops range over, with the reason each was left unconverted
+	Skipped []SkippedTable
+
+	// src and out are the original and converted source of a modified file
+	src, out []byte
+	// explanations tell what the conversion changed and why, with Options.Explain
+	explanations []explanation
+}
+
+// Table describes a slice-based table test found in a file
+type Table struct {
+	// Name is the variable holding the table, "(inline)" for a table ranged over directly
+	Name string
+	// Func is the function declaring the table, empty for package-level tables
+	Func string
+	// Pos is the position of the table variable
+	Pos token.Position
+}
+
+// FuncFilter returns a filter for Options.TableFilter that accepts the tables declared in
+// functions whose names match a regular expression, like 'go test -run' selects tests. As
+// with 'go test -run', only the part of the pattern before the first slash outside
+// brackets and parentheses is used, since the rest selects subtests.
+func FuncFilter(pattern string) (func(table Table) bool, error) {
+	depth := 0
+split:
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++ // Skip the escaped character
+		case '[', '(':
+			depth++
+		case ']', ')':
+			depth--
+		case '/':
+			if depth == 0 {
+				pattern = pattern[:i]
+				break split
+			}
+		}
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return func(table Table) bool {
+		return table.Func != "" && re.MatchString(table.Func)
+	}, nil
+}
+
+// Diagnostic describes a problem found with a table test
+type Diagnostic struct {
+	// Table is the variable holding the table
+	Table string
+	// Pos is the position the problem was found at
+	Pos token.Position
+	// Message describes the problem
+	Message string
+}
+
+// Options configures a Converter
+type Options struct {
+	// Log receives progress messages while converting; nil discards them
+	Log io.Writer
+	// Progress, when set, is called by ConvertPaths once the paths are walked and after
+	// each directory it converts
+	Progress func(Progress)
+	// Events, when set, is told when the conversion of each file starts and once it is
+	// done with the file
+	Events func(FileEvent)
+	// DryRun reports tables that would be converted without modifying any files
+	DryRun bool
+	// AllFiles makes ConvertDir process every .go file instead of only _test.go files
+	AllFiles bool
+	// IncludeVendor makes ConvertDir descend into vendor and node_modules directories,
+	// which hold third-party code and are skipped by default
+	IncludeVendor bool
+	// IncludeTestdata makes ConvertDir descend into testdata directories
+	IncludeTestdata bool
+	// IncludeHidden makes ConvertDir descend into directories whose names start with
+	// "." or "_", which the go tool ignores as well
+	IncludeHidden bool
+	// FollowSymlinks makes ConvertDir descend into symbolic links to directories, which
+	// are skipped by default; each directory is still converted once
+	FollowSymlinks bool
+	// FileFilter, when set, limits ConvertDir to the files for which it returns true
+	FileFilter func(path string) bool
+	// TableFilter, when set, limits conversion to the tables for which it returns true
+	TableFilter func(table Table) bool
+	// SkipTypeCheck writes converted files without first checking that they still compile
+	SkipTypeCheck bool
+	// CacheDir is where content hashes of files with nothing to convert are remembered,
+	// so ConvertDir can skip them on later runs; empty disables the cache
+	CacheDir string
+	// Subtests wraps the bodies of loops over converted tables that do not run subtests
+	// in t.Run calls named by the map key
+	Subtests bool
+	// Tabulate rewrites runs of structurally identical assertions in test functions,
+	// which only differ in their literals, into map-based table tests
+	Tabulate bool
+	// Parallel inserts t.Parallel() calls into the subtests run by loops over converted
+	// tables, and into their test function, when nothing in the test prevents it
+	Parallel bool
+	// SuffixDuplicates converts tables whose cases share a name by numbering the later
+	// cases ("negative values #2") instead of skipping the table
+	SuffixDuplicates bool
+	// Dedupe removes the cases of converted tables whose values, apart from their name,
+	// repeat those of an earlier case; they are reported either way
+	Dedupe bool
+	// Explain annotates the hunks of patches with what the conversion changed and why
+	Explain bool
+	// IgnoreOrder converts tables whose loops depend on the order of their cases, which
+	// are otherwise reported and left alone, since map iteration order is random
+	IgnoreOrder bool
+	// Keys converts tables without a name field, which are left alone by default, by
+	// generating a name for each case
+	Keys KeyStrategy
+	// NameCheck reports, or sanitizes, case names that are awkward to select with
+	// 'go test -run'
+	NameCheck NameCheck
+	// ExtractTypes lifts the anonymous case structs of converted tables into named types
+	// declared above their test function ('type TestAddCase struct{ ... }')
+	ExtractTypes bool
+	// KeyedFields rewrites the positional cases of converted tables into keyed literals
+	// naming each field ('{a: 2, b: 3, expected: 5}')
+	KeyedFields bool
+	// DeadFields reports, or removes, the fields of the anonymous case structs of
+	// converted tables that the loops over them never read
+	DeadFields DeadFields
+	// CmpDiff replaces the reflect.DeepEqual assertions in loops over converted tables
+	// with cmp.Diff from github.com/google/go-cmp, in modules that require it
+	CmpDiff bool
+	// Assertions migrates the assertions in loops over converted tables to testify, in
+	// modules that require it, or back to if blocks
+	Assertions AssertionStyle
+	// FromGinkgo rewrites Ginkgo DescribeTable specs into map-based table tests running
+	// their entries in t.Run subtests
+	FromGinkgo bool
+	// FromGocheck rewrites the test methods of gopkg.in/check.v1 suites into test
+	// functions, turning c.Assert and c.Check calls into if blocks, before converting
+	FromGocheck bool
+	// Golden moves the string and []byte values of at least this many bytes out of the
+	// cases of table tests into golden files under testdata, which the tests read back
+	// and rewrite when run with -update; zero leaves them inline
+	Golden int
+	// MaxCases and MaxTableBytes make Inventory flag tables with more cases, or whose
+	// literal takes more bytes of source, as large; zero disables either limit
+	MaxCases      int
+	MaxTableBytes int
+	// Rules run after the conversion like rules passed to RegisterRule, ahead of them;
+	// LoadConfig reads them from config files
+	Rules []Rule
+	// Journal is the file each written file is recorded in, so that Revert can restore
+	// the files a run modified; empty disables the journal
+	Journal string
+	// VerifyTests runs 'go test' in each package before and after writing its converted
+	// files, and restores the files when tests that passed before fail after
+	VerifyTests bool
+}
+
+// Converter converts slice-based table tests to map-based table tests
+type Converter struct {
+	opts Options
+
+	// importer resolves imports when type-checking converted packages
+	importer types.Importer
+	// overlay holds the contents to read instead of the files on disk at the same clean
+	// paths, such as the documents open in an editor
+	overlay map[string][]byte
+	// cache skips files left clean by earlier runs
+	cache *fileCache
+	// rules are the rewrites registered to run along with the conversion
+	rules []Rule
+	// run identifies the files the converter writes in the journal
+	run string
+	// timings accumulates the time spent in each phase by the directory being converted
+	timings Timings
+}
+
+// NewConverter returns a Converter configured with the given options
+func NewConverter(opts Options) *Converter {
+	if opts.Log == nil {
+		opts.Log = io.Discard
+	}
+	c := &Converter{opts: opts, run: newRunID()}
+	for _, rule := range opts.Rules {
+		c.RegisterRule(rule)
+	}
+	c.cache = newFileCache(opts.CacheDir, opts)
+	return c
+}
+
+// logf writes a progress message to the configured log
+func (c *Converter) logf(format string, args ...any) {
+	fmt.Fprintf(c.opts.Log, format, args...)
+}
+
+// ConvertDir converts all slice-based table tests to map-based tables in a directory
+func (c *Converter) ConvertDir(directory string) (ConversionResult, error) {
+	return c.ConvertPaths([]string{directory})
+}
+
+// ConvertPaths converts the table tests in the given files and directories. Directories
+// are walked like ConvertDir; files named explicitly are converted even when they are not
+// test files. Go package patterns (./internal/..., example.com/mod/pkg) are resolved by
+// the go tool instead, so only the files of matching packages that build with the current
+// build constraints are converted.
+func (c *Converter) ConvertPaths(paths []string) (ConversionResult, error) {
+	result := ConversionResult{}
+
+	// Collect the Go files of each directory so files of the same package are converted together
+	var dirs []string
+	filesByDir := make(map[string][]string)
+	seen := make(map[string]bool)
+	addFile := func(path string) {
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+
+		dir := filepath.Dir(path)
+		if _, ok := filesByDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		filesByDir[dir] = append(filesByDir[dir], path)
+	}
+
+	for _, root := range paths {
+		if isPackagePattern(root) {
+			files, err := c.resolvePattern(root, &result)
+			if err != nil {
+				result.Errors = append(result.Errors, FileError{root, err.Error()})
+			}
+			for _, path := range files {
+				addFile(path)
+			}
+			continue
+		}
+		if info, err := os.Stat(root); err == nil && !info.IsDir() {
+			addFile(root)
+			continue
+		}
+
+		err := c.walkFiles(root, func(path string, err error) error {
+			if err != nil {
+				result.Errors = append(result.Errors, FileError{path, fmt.Sprintf("error accessing file: %v", err)})
+				return nil // Continue processing
+			}
+
+			// Skip files the options leave out
+			if c.wantFile(path) {
+				addFile(path)
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return result, fmt.Errorf("error walking directory: %v", err)
+		}
+	}
+
+	progress := Progress{start: time.Now()}
+	for _, dir := range dirs {
+		progress.FilesTotal += len(filesByDir[dir])
+	}
+	c.reportProgress(progress)
+	for _, dir := range dirs {
+		c.convertDirectory(filesByDir[dir], &result)
+		progress.FilesScanned += len(filesByDir[dir])
+		progress.FilesModified = result.FilesModified
+		c.reportProgress(progress)
+	}
+
+	return result, nil
+}
+
+// skipDir reports whether a directory with the given name should not be descended into
+func (c *Converter) skipDir(name string) bool {
+	switch {
+	case name == "vendor" || name == "node_modules":
+		return !c.opts.IncludeVendor
+	case name == "testdata":
+		return !c.opts.IncludeTestdata
+	case strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_"):
+		return !c.opts.IncludeHidden
+	}
+	return false
+}
+
+// wantFile reports whether the file at path should be converted
+func (c *Converter) wantFile(path string) bool {
+	if !strings.HasSuffix(path, ".go") {
+		return false
+	}
+
+	// Table tests live in test files, so leave production code alone unless asked
+	if !c.opts.AllFiles && !strings.HasSuffix(path, "_test.go") {
+		return false
+	}
+
+	return c.opts.FileFilter == nil || c.opts.FileFilter(path)
+}
+
+// convertDirectory converts the Go files of a single directory and adds them to the result
+func (c *Converter) convertDirectory(paths []string, result *ConversionResult) {
+	start := time.Now()
+	var srcs [][]byte
+	var readable []string
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			err = fmt.Errorf("error reading file: %v", err)
+			c.resultEvent(path, time.Now(), FileResult{}, err)
+			result.Errors = append(result.Errors, FileError{path, err.Error()})
+			continue
+		}
+		srcs = append(srcs, src)
+		readable = append(readable, path)
+	}
+	result.Timings.Parse += time.Since(start)
+
+	c.convertSources(readable, srcs, result)
+}
+
+// convertSources converts the sources of the Go files of a single directory and adds
+// them to the result
+func (c *Converter) convertSources(paths []string, srcs [][]byte, result *ConversionResult) {
+	// Files of a package can affect each other's conversion, so a directory is only
+	// skipped when none of its files can hold a table, or when every one of them is
+	// unchanged since a run left it clean
+	if c.prescan() && !slices.ContainsFunc(srcs, mayHoldTables) {
+		for _, path := range paths {
+			c.logf("Skipping file without tables: %s\n", path)
+			file := FileResult{Path: path}
+			c.resultEvent(path, c.startEvent(path), file, nil)
+			result.Files = append(result.Files, file)
+		}
+		result.FilesProcessed += len(paths)
+		return
+	}
+	clean := len(srcs) > 0
+	for _, src := range srcs {
+		if !c.cache.isClean(src) {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		for _, path := range paths {
+			c.logf("Skipping unchanged file: %s\n", path)
+			file := FileResult{Path: path, Cached: true}
+			c.resultEvent(path, c.startEvent(path), file, nil)
+			result.Files = append(result.Files, file)
+		}
+		result.FilesProcessed += len(paths)
+		result.FilesCached += len(paths)
+		return
+	}
+
+	c.timings = Timings{}
+	defer func() {
+		result.Timings.add(c.timings)
+	}()
+	fset := token.NewFileSet()
+
+	var files []*sourceFile
+	starts := make(map[string]time.Time)
+	for i, path := range paths {
+		c.logf("Processing file: %s\n", path)
+		starts[path] = c.startEvent(path)
+
+		file, err := parseSourceFile(fset, path, srcs[i])
+		c.timings.Parse += time.Since(starts[path])
+		if err != nil {
+			c.resultEvent(path, starts[path], FileResult{}, err)
+			result.Errors = append(result.Errors, FileError{path, err.Error()})
+			continue // Continue with next file
+		}
+		files = append(files, file)
+	}
+
+	for _, pkg := range groupPackages(files) {
+		c.convertPackage(fset, pkg)
+		c.writePackage(pkg)
+	}
+
+	for _, file := range files {
+		c.resultEvent(file.path, starts[file.path], file.result, file.err)
+		if file.err != nil {
+			result.Errors = append(result.Errors, FileError{file.path, file.err.Error()})
+			continue
+		}
+
+		result.FilesProcessed++
+		result.Files = append(result.Files, file.result)
+		result.Diagnostics = append(result.Diagnostics, file.result.Diagnostics...)
+		result.Skipped = append(result.Skipped, file.result.Skipped...)
+		result.TablesSkipped += file.result.TablesSkipped
+		result.TablesSuppressed += file.result.TablesSuppressed
+		if file.result.Generated {
+			result.FilesGenerated++
+		}
+		if file.result.Modified {
+			result.FilesModified++
+			result.TablesConverted += file.result.TablesConverted
+			result.Tables = append(result.Tables, file.result.Tables...)
+			c.logf("Modified file: %s, Tables converted: %d\n", file.path, file.result.TablesConverted)
+		}
+
+		// Converted files are clean too once written, since reruns are no-ops
+		if len(file.result.Diagnostics) == 0 && (!file.result.Modified || !c.opts.DryRun) {
+			c.cache.markClean(file.out)
+		}
+	}
+}
+
+// ConvertFile converts the table tests in a single Go file, rewriting it in place when modified.
+// Only case types declared in the file itself are converted.
+func (c *Converter) ConvertFile(filePath string) (FileResult, error) {
+	fset := token.NewFileSet()
+	file, err := parseSourceFile(fset, filePath, nil)
+	if err != nil {
+		return FileResult{}, err
+	}
+
+	c.convertPackage(fset, []*sourceFile{file})
+	c.writePackage([]*sourceFile{file})
+
+	return file.result, file.err
+}
+
+// ConvertSource converts the table tests in Go source code and returns the converted source.
+// Source without slice-based table tests is returned unchanged.
+func (c *Converter) ConvertSource(src []byte) ([]byte, error) {
+	file, err := c.convertSource(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return file.out, nil
+}
+
+// convertSource converts Go source as a package of its own
+func (c *Converter) convertSource(src []byte) (*sourceFile, error) {
+	fset := token.NewFileSet()
+	file, err := parseSourceFile(fset, "", src)
+	if err != nil {
+		return nil, err
+	}
+
+	c.convertPackage(fset, []*sourceFile{file})
+	if file.err != nil {
+		return nil, file.err
+	}
+
+	return file, nil
+}
+
+// sourceFile is a parsed Go file taking part in a conversion
+type sourceFile struct {
+	path string
+	src  []byte
+	node *ast.File
+
+	// parsed is the source node was parsed from, which differs from src once repeated
+	// assertions or Ginkgo tables were rewritten
+	parsed []byte
+
+	// refs counts the references to imported packages in the original source, so
+	// imports that only the conversion left unused are removed
+	refs map[string]int
+
+	// comments associates comments with the nodes they document so comments belonging
+	// to removed nodes (such as the name field) can be dropped after rewriting
+	comments ast.CommentMap
+
+	// tabulated are the tables generated from repeated assertions or Ginkgo tables before
+	// converting
+	tabulated []Table
+	// migrated is set when gocheck tests were rewritten into test functions, or tests
+	// were rewritten to read golden files
+	migrated bool
+	// golden are the golden files to write along with the file, by path
+	golden map[string][]byte
+	// caseTypes are the case structs extracted into named types, declared once printed
+	caseTypes []caseTypeDecl
+
+	// out is the converted source, set once the file has been converted
+	out    []byte
+	result FileResult
+	err    error
+}
+
+// parseSourceFile parses a Go file; src is read from path when nil
+func parseSourceFile(fset *token.FileSet, path string, src []byte) (*sourceFile, error) {
+	if src == nil {
+		var err error
+		src, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading file: %v", err)
+		}
+	}
+
+	// Parse the Go file
+	node, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing file: %v", err)
+	}
+
+	return &sourceFile{
+		result:   FileResult{Path: path, Generated: ast.IsGenerated(node)},
+		path:     path,
+		src:      src,
+		node:     node,
+		parsed:   src,
+		refs:     packageRefs(node),
+		comments: ast.NewCommentMap(fset, node, node.Comments),
+	}, nil
+}
+
+// groupPackages splits the files of a directory by package clause, since a directory
+// may hold both a package and its external _test package
+func groupPackages(files []*sourceFile) [][]*sourceFile {
+	var names []string
+	byName := make(map[string][]*sourceFile)
+	for _, file := range files {
+		name := file.node.Name.Name
+		if _, ok := byName[name]; !ok {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], file)
+	}
+
+	pkgs := make([][]*sourceFile, 0, len(names))
+	for _, name := range names {
+		pkgs = append(pkgs, byName[name])
+	}
+	return pkgs
+}
+
+// convertPackage converts the table tests in the files of a package. Named case types
+// can be shared between the files, so the files are converted together.
+func (c *Converter) convertPackage(fset *token.FileSet, files []*sourceFile) {
+	start := time.Now()
+	if c.opts.Tabulate {
+		c.tabulatePackage(fset, files)
+	}
+	if c.opts.FromGinkgo {
+		c.migrateGinkgoPackage(fset, files)
+	}
+	if c.opts.FromGocheck {
+		c.migrateGocheckPackage(fset, files)
+	}
+	if c.opts.Golden > 0 {
+		c.externalizeGoldenPackage(fset, files)
+	}
+
+	types := collectNamedTypes(files)
+
+	// Generated files would just be regenerated, so their tables are left alone; their
+	// references still count below so case types they share are left alone too
+	candidates := make([][]*tableCandidate, len(files))
+	skipped := make([][]*tableCandidate, len(files))
+	for i, file := range files {
+		if file.result.Generated {
+			c.logf("Skipping generated file: %s\n", file.path)
+			continue
+		}
+		found := findTables(file.node, types)
+		for _, candidate := range found {
+			directives := tableDirectives(fset, file.node, candidate.pos())
+			if _, ok := directives[ignoreDirective]; ok {
+				c.logf("Skipping table test variable %s: ignored by a %s%s directive\n", candidate.name(), directivePrefix, ignoreDirective)
+				file.result.TablesSuppressed++
+				continue
+			}
+			// A misspelled directive may have been meant to keep the table as it is
+			if diags := c.configureTable(fset, candidate, directives); len(diags) > 0 {
+				c.logf("Skipping table test variable %s: invalid %s directives\n", candidate.name(), directivePrefix)
+				file.result.Diagnostics = append(file.result.Diagnostics, diags...)
+				file.result.TablesSkipped++
+				file.skipTable(candidate.info(fset), SkipInvalidDirective)
+				continue
+			}
+			tc := c.forTable(candidate)
+
+			// Tables without a name field are only converted when keys are generated, and
+			// only reported when they are ranged over, since they may be plain test data
+			if candidate.table.nameField == "" {
+				// A name field promoted from an embedded struct can't become the key
+				// without rewriting the embedded struct too
+				if promoted := promotedNameField(candidate.table.structType, types); promoted != "" {
+					c.logf("Skipping table test variable %s: its name field %s is promoted from an embedded struct\n", candidate.name(), promoted)
+					file.result.TablesSkipped++
+					file.skipTable(candidate.info(fset), SkipPromotedNameField)
+					continue
+				}
+				ranged := onlyRanged(file.node, candidate)
+				switch {
+				case tc.opts.Keys == KeysNone && ranged:
+					file.skipTable(candidate.info(fset), SkipNoNameField)
+					continue
+				case tc.opts.Keys != KeysNone && !ranged:
+					file.skipTable(candidate.info(fset), SkipUsedOutsideLoops)
+					continue
+				case !ranged:
+					continue
+				}
+			}
+			if reason := tc.checkTableOrder(fset, files, file, candidate); reason != "" {
+				file.result.TablesSkipped++
+				file.skipTable(candidate.info(fset), reason)
+				continue
+			}
+			if c.opts.TableFilter == nil || c.opts.TableFilter(candidate.info(fset)) {
+				candidates[i] = append(candidates[i], candidate)
+			}
+		}
+		// Tables dropped from here on are counted once the rest are converted
+		file.result.TablesSkipped += len(candidates[i])
+		whole := wholeVariables(found, candidates[i])
+		for _, candidate := range candidates[i] {
+			if !slices.Contains(whole, candidate) {
+				candidate.skip = SkipSharedVariable
+			}
+		}
+		skipped[i] = candidates[i]
+		candidates[i] = whole
+	}
+
+	// Functions building tables are converted along with the loops over their results
+	var helpers []*helperTable
+	for _, helper := range findHelperTables(files, types) {
+		if helper.file.result.Generated || (helper.table.nameField == "" && c.opts.Keys == KeysNone) {
+			continue
+		}
+		if _, ok := tableDirectives(fset, helper.file.node, helper.fn.Pos())[ignoreDirective]; ok {
+			c.logf("Skipping table helper %s: ignored by a %s%s directive\n", helper.fn.Name.Name, directivePrefix, ignoreDirective)
+			helper.file.result.TablesSuppressed++
+			continue
+		}
+		vars := tableVars{helperKey(helper.fn.Name.Name): helper.table.nameField}
+		if reason := c.checkOrder(fset, files, helper.file, vars, helper.fn.Name.Name+"()"); reason != "" {
+			helper.file.result.TablesSkipped++
+			helper.file.skipTable(helper.info(fset), reason)
+			continue
+		}
+		if c.opts.TableFilter == nil || c.opts.TableFilter(helper.info(fset)) {
+			helpers = append(helpers, helper)
+		}
+	}
+
+	// Trimming a named case type affects every use of it, so only convert its tables
+	// when every reference to the type belongs to a table being converted
+	refs := make(map[*namedType]int)
+	for _, fileCandidates := range candidates {
+		for _, candidate := range fileCandidates {
+			if candidate.table.trimsType() {
+				refs[candidate.table.named] += candidate.typeRefs()
+			}
+		}
+	}
+	for _, helper := range helpers {
+		if helper.table.trimsType() {
+			refs[helper.table.named] += helper.typeRefs()
+		}
+	}
+	typeSkips := make(map[*namedType]SkipReason)
+	for named, count := range refs {
+		if named.file.result.Generated {
+			c.logf("Skipping tables of type %s: the type is declared in a generated file\n", named.spec.Name.Name)
+			typeSkips[named] = SkipCaseTypeGenerated
+			delete(refs, named)
+		} else if count != types.refs[named.spec.Name.Name] {
+			c.logf("Skipping tables of type %s: the type is used outside of table tests\n", named.spec.Name.Name)
+			typeSkips[named] = SkipCaseTypeShared
+			delete(refs, named)
+		}
+	}
+
+	var taken map[string]bool
+	if c.opts.ExtractTypes {
+		taken = packageNames(files)
+	}
+
+	modified := make(map[*sourceFile]bool)
+	vars := make([]tableVars, len(files))
+	converted := make([][]*tableCandidate, len(files))
+	shared := make(tableVars)
+	for i, file := range files {
+		var convertible []*tableCandidate
+		for _, candidate := range candidates[i] {
+			if !candidate.table.trimsType() || refs[candidate.table.named] > 0 {
+				convertible = append(convertible, candidate)
+			} else {
+				candidate.skip = typeSkips[candidate.table.named]
+			}
+		}
+
+		var diags []Diagnostic
+		converted[i], diags = c.convertTables(fset, convertible)
+		if c.opts.Explain {
+			for _, candidate := range converted[i] {
+				explainTable(fset, files, candidate)
+			}
+		}
+		file.result.Diagnostics = append(file.result.Diagnostics, diags...)
+		file.result.TablesSkipped -= len(converted[i])
+		vars[i] = make(tableVars)
+		for _, candidate := range converted[i] {
+			vars[i].add(candidate)
+			if candidate.funcName == "" && candidate.ident != nil {
+				shared.addShared(candidate)
+			}
+			file.result.Tables = append(file.result.Tables, candidate.info(fset))
+		}
+		if len(converted[i]) > 0 {
+			modified[file] = true
+			file.result.TablesConverted = len(converted[i])
+		}
+		for _, candidate := range skipped[i] {
+			if candidate.skip != "" {
+				file.skipTable(candidate.info(fset), candidate.skip)
+			}
+		}
+	}
+	for _, helper := range helpers {
+		if helper.table.trimsType() && refs[helper.table.named] == 0 {
+			helper.file.result.TablesSkipped++
+			helper.file.skipTable(helper.info(fset), typeSkips[helper.table.named])
+			continue
+		}
+
+		reason, diags := c.convertHelper(fset, helper)
+		file := helper.file
+		file.result.Diagnostics = append(file.result.Diagnostics, diags...)
+		if reason != "" {
+			file.result.TablesSkipped++
+			file.skipTable(helper.info(fset), reason)
+			continue
+		}
+
+		shared[helperKey(helper.fn.Name.Name)] = helper.table.nameField
+		file.result.Tables = append(file.result.Tables, helper.info(fset))
+		file.result.TablesConverted++
+		modified[file] = true
+	}
+
+	// Update the loops once every table is converted, since tables declared at package
+	// level can be ranged over by tests in any file of the package
+	var opaque map[callSite]bool
+	if c.opts.CmpDiff && len(modified) > 0 {
+		opaque = c.opaqueDeepEquals(files)
+	}
+	for i, file := range files {
+		for key, nameField := range shared {
+			if _, ok := vars[i][key]; !ok {
+				vars[i][key] = nameField
+			}
+		}
+		if c.opts.Explain {
+			explainLoops(fset, files, file, vars[i])
+		}
+		if c.updateLoops(fset, file.node, vars[i], sharesLoopVars(file.path)) {
+			modified[file] = true
+		}
+		if c.opts.CmpDiff && c.useCmpDiff(fset, file, vars[i], opaque) {
+			modified[file] = true
+		}
+		if c.opts.Assertions != AssertionsUnchanged && c.migrateAssertions(fset, file, vars[i]) {
+			modified[file] = true
+		}
+
+		if file.migrated {
+			modified[file] = true
+		}
+		if len(file.tabulated) > 0 {
+			modified[file] = true
+			file.result.TablesConverted += len(file.tabulated)
+			file.result.Tables = append(file.result.Tables, file.tabulated...)
+		}
+	}
+
+	// Tidy the case structs of converted tables once the loops over them are updated
+	for i, file := range files {
+		if c.opts.DeadFields != DeadFieldsNone {
+			for _, candidate := range converted[i] {
+				file.result.Diagnostics = append(file.result.Diagnostics, c.checkDeadFields(fset, file, candidate)...)
+			}
+		}
+		if c.opts.ExtractTypes {
+			c.extractCaseTypes(fset, file, converted[i], taken)
+		}
+	}
+
+	// Drop the name field from the named case types whose tables were converted
+	for named := range refs {
+		_, nameFieldIndex := findNameField(named.structType)
+		collapseRemovedField(fset, named.structType, nameFieldIndex)
+		named.spec.Type = createStructTypeWithoutField(named.structType, nameFieldIndex)
+		modified[named.file] = true
+	}
+
+	// Registered rules see the files as converted
+	for _, file := range files {
+		if c.applyRules(fset, file) {
+			modified[file] = true
+		}
+	}
+
+	transformed := time.Now()
+	c.timings.Transform += transformed.Sub(start)
+
+	declared := packageNames(files)
+	for _, file := range files {
+		if file.err != nil {
+			continue
+		}
+		if !modified[file] {
+			file.out = file.src
+			continue
+		}
+
+		c.fixImports(fset, file, declared)
+		file.node.Comments = file.comments.Filter(file.node).Comments()
+
+		// Print with gofmt's settings so converted files need no further formatting
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, file.node); err != nil {
+			file.err = fmt.Errorf("error formatting file: %v", err)
+			continue
+		}
+
+		file.out = buf.Bytes()
+		if len(file.caseTypes) > 0 {
+			out, err := insertCaseTypes(file.out, file.caseTypes)
+			if err != nil {
+				file.err = err
+				continue
+			}
+			file.out = out
+		}
+		file.result.Modified = true
+		file.result.src = file.src
+		file.result.out = file.out
+	}
+
+	formatted := time.Now()
+	c.timings.Format += formatted.Sub(transformed)
+	c.checkPackage(files)
+	c.timings.TypeCheck += time.Since(formatted)
+}
+
+// tabulatePackage rewrites repeated assertions in the files of a package into table
+// tests, which are then converted along with the other tables of the files
+func (c *Converter) tabulatePackage(fset *token.FileSet, files []*sourceFile) {
+	// The functions the assertions call, declared in files of the package that aren't being
+	// converted as often as not, name and type the fields of generated tables
+	nodes := make([]*ast.File, 0, len(files))
+	for _, file := range files {
+		nodes = append(nodes, file.node)
+	}
+	for _, sibling := range c.siblingSources(files) {
+		if node, err := parser.ParseFile(fset, sibling.path, sibling.src, 0); err == nil {
+			nodes = append(nodes, node)
+		}
+	}
+	info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	pkg, _ := c.checkFiles(fset, nodes, info)
+
+	for _, file := range files {
+		if file.result.Generated {
+			continue
+		}
+
+		src, tables := c.tabulate(fset, file, info, pkg)
+		if len(tables) == 0 {
+			continue
+		}
+
+		node, err := parser.ParseFile(fset, file.path, src, parser.ParseComments)
+		if err != nil {
+			c.logf("Skipping repeated assertions in %s: %v\n", file.path, err)
+			continue
+		}
+		file.node = node
+		file.parsed = src
+		file.comments = ast.NewCommentMap(fset, node, node.Comments)
+		file.tabulated = tables
+	}
+}
+
+// stagedWrite is a file about to be written, with the contents to restore if the rest of
+// its package can't be written
+type stagedWrite struct {
+	path string
+	// before is nil for files the write creates, and after for files it removes
+	before, after []byte
+}
+
+// writePackage writes the modified files of a package, along with their golden files,
+// and records them in the journal. A failed write restores the files written before it,
+// so a package is written whole or not at all; failures are set on the files. With
+// Options.VerifyTests, the package is restored too when its tests newly fail.
+func (c *Converter) writePackage(files []*sourceFile) {
+	if c.opts.DryRun {
+		return
+	}
+	start, tests := time.Now(), c.timings.Tests
+	defer func() {
+		c.timings.Write += time.Since(start) - (c.timings.Tests - tests)
+	}()
+
+	var staged []stagedWrite
+	var written []*sourceFile
+	rollback := func(err error) error {
+		return rollbackWrites(staged, err)
+	}
+	fail := func(culprit *sourceFile, err error) {
+		culprit.err = rollback(err)
+		culprit.result = FileResult{Path: culprit.path}
+		for _, file := range files {
+			if file != culprit && file.result.Modified && file.err == nil {
+				file.err = fmt.Errorf("not converted: writing %s, in the same package, failed", filepath.Base(culprit.path))
+				file.result = FileResult{Path: file.path}
+			}
+		}
+	}
+
+	// Failing tests only count against the conversion when they passed before it
+	var baseline testRun
+	if c.opts.VerifyTests {
+		for _, file := range files {
+			if file.err == nil && file.result.Modified {
+				c.logf("Running tests of %s before converting\n", filepath.Dir(file.path))
+				var err error
+				if baseline, err = c.runTests(filepath.Dir(file.path)); err != nil {
+					fail(file, err)
+					return
+				}
+				break
+			}
+		}
+	}
+
+	for _, file := range files {
+		if file.err != nil || !file.result.Modified {
+			continue
+		}
+
+		writes := []stagedWrite{{file.path, file.src, file.out}}
+		for _, path := range slices.Sorted(maps.Keys(file.golden)) {
+			prior, err := os.ReadFile(path)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				fail(file, fmt.Errorf("error reading golden file: %v", err))
+				return
+			}
+			writes = append(writes, stagedWrite{path, prior, file.golden[path]})
+		}
+		for _, w := range writes {
+			if err := w.write(); err != nil {
+				fail(file, err)
+				return
+			}
+			staged = append(staged, w)
+		}
+		written = append(written, file)
+	}
+
+	if c.opts.VerifyTests && len(written) > 0 {
+		c.logf("Running tests of %s after converting\n", filepath.Dir(written[0].path))
+		after, err := c.runTests(filepath.Dir(written[0].path))
+		if err != nil {
+			fail(written[0], err)
+			return
+		}
+		if failures := after.newFailures(baseline); len(failures) > 0 {
+			c.revertFailingTests(written, failures, after, rollback)
+			return
+		}
+	}
+
+	if c.opts.Journal == "" || len(staged) == 0 {
+		return
+	}
+	entries := make([]JournalEntry, 0, len(staged))
+	for _, w := range staged {
+		entry, err := newJournalEntry(c.run, w.path, w.before, w.after)
+		if err != nil {
+			c.logf("Warning: %s not recorded in the journal: %v\n", w.path, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := appendJournal(c.opts.Journal, entries); err != nil {
+		// The files are converted all the same, so the failure is no reason to fail them
+		for _, file := range written {
+			file.result.Diagnostics = append(file.result.Diagnostics, Diagnostic{
+				Pos:     token.Position{Filename: file.path},
+				Message: fmt.Sprintf("converted, but not recorded in the journal: %v", err),
+			})
+		}
+	}
+}
+
+// revertFailingTests restores the files of a package whose tests newly fail once
+// converted, and sets the failures, with the output of the tests, on the files declaring
+// the failing tests, or on every file when no file declares them
+func (c *Converter) revertFailingTests(written []*sourceFile, failures []string, after testRun, rollback func(error) error) {
+	err := rollback(fmt.Errorf("tests newly fail once converted: %s", strings.Join(failures, ", ")))
+	output := after.failureOutput(failures)
+
+	var culprits []string
+	for _, file := range written {
+		for _, test := range failures {
+			if file.declaresTest(test) {
+				culprits = append(culprits, filepath.Base(file.path))
+				break
+			}
+		}
+	}
+	for _, file := range written {
+		c.logf("Reverted file: %s\n", file.path)
+		if len(culprits) == 0 || slices.Contains(culprits, filepath.Base(file.path)) {
+			file.err = fmt.Errorf("reverted: %v\n%s", err, output)
+		} else {
+			file.err = fmt.Errorf("reverted: tests of %s, in the same package, newly fail once converted", strings.Join(culprits, ", "))
+		}
+		file.result = FileResult{Path: file.path}
+	}
+}
+
+// rollbackWrites restores the files of writes made in order, newest first, adding the
+// restores that fail to err
+func rollbackWrites(staged []stagedWrite, err error) error {
+	for i := len(staged) - 1; i >= 0; i-- {
+		if restoreErr := staged[i].restore(); restoreErr != nil {
+			err = fmt.Errorf("%v; restoring %s also failed: %v", err, staged[i].path, restoreErr)
+		}
+	}
+	return err
+}
+
+// write writes the new contents of a staged file
+func (w stagedWrite) write() error {
+	if w.after == nil {
+		if err := os.Remove(w.path); err != nil {
+			return fmt.Errorf("error removing file: %v", err)
+		}
+		return nil
+	}
+	if w.before != nil {
+		if err := writeFileAtomic(w.path, w.after); err != nil {
+			return fmt.Errorf("error writing to file: %v", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o755); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+	if err := writeFileMode(w.path, w.after, 0o644); err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+	return nil
+}
+
+// restore restores the contents of a staged file written earlier
+func (w stagedWrite) restore() error {
+	switch {
+	case w.before == nil:
+		return os.Remove(w.path)
+	case w.after == nil:
+		return WriteFile(w.path, w.before, 0o644)
+	}
+	return writeFileAtomic(w.path, w.before)
+}
+
+// WriteFile writes data to a file like os.WriteFile, but the way conversions write files:
+// without ever leaving it partially written. An existing file keeps its mode, and a new
+// one is created with perm.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	info, err := os.Stat(path)
+	switch {
+	case err == nil:
+		perm = info.Mode().Perm()
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+	return writeFileMode(path, data, perm)
+}
+
+// writeFileAtomic replaces the contents of a file without ever leaving it partially written.
+// The data goes to a temporary file in the same directory that is synced and then renamed
+// over the original, keeping the original file mode.
+func writeFileAtomic(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return writeFileMode(path, data, info.Mode().Perm())
+}
+
+// writeFileMode writes a file through a synced temporary file renamed over it, with the
+// given permissions
+func writeFileMode(path string, data []byte, perm os.FileMode) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
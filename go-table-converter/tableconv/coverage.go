@@ -0,0 +1,410 @@
+package tableconv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Suggestion is an exported function with low coverage and no table test, for which a
+// map-based table test is suggested
+type Suggestion struct {
+	// Name is the function ('Parse') or method ('Parser.Parse'), as GenerateTest takes it
+	Name string
+	// Dir is the directory of the function's package
+	Dir string
+	// Pos is the position of the function declaration
+	Pos token.Position
+	// Statements and Uncovered count the statements of the function and those the
+	// profile didn't cover
+	Statements int
+	Uncovered  int
+	// Coverage is the percentage of statements covered
+	Coverage float64
+}
+
+// coverBlock is a block of statements of a coverage profile
+type coverBlock struct {
+	startLine, startCol int
+	endLine, endCol     int
+	stmts               int
+	count               int
+}
+
+// SuggestTests reads a coverage profile ('go test -coverprofile') and finds the exported
+// functions of the packages under paths that are covered less than threshold percent and
+// aren't called from the loop of any table test of their package. The suggestions come
+// most uncovered statements first. Functions of files the profile doesn't cover at all
+// are left out, since the profile says nothing about them.
+func (c *Converter) SuggestTests(profile string, paths []string, threshold float64) ([]Suggestion, error) {
+	blocks, err := readCoverProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Step 1: Collect the package directories under the paths
+	dirs, err := c.packageDirs(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	// Step 2: Measure the exported functions of each package without a table test
+	var suggestions []Suggestion
+	for _, dir := range dirs {
+		fset := token.NewFileSet()
+		files, err := packageFiles(fset, dir)
+		if err != nil {
+			return nil, err
+		}
+		importPath := packageImportPath(dir)
+		tested := tableTested(files)
+
+		for _, file := range files {
+			if strings.HasSuffix(file.path, "_test.go") {
+				continue
+			}
+			fileBlocks, ok := profileBlocks(blocks, file.path, importPath)
+			if !ok {
+				continue
+			}
+
+			for _, decl := range file.node.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Body == nil || !exportedFunc(fn) || tested[fn.Name.Name] {
+					continue
+				}
+				start, end := fset.Position(fn.Pos()), fset.Position(fn.End())
+				stmts, uncovered := funcCoverage(fileBlocks, start, end)
+				if stmts == 0 {
+					continue
+				}
+				coverage := 100 * float64(stmts-uncovered) / float64(stmts)
+				if coverage >= threshold {
+					continue
+				}
+
+				name := fn.Name.Name
+				if recv := receiverType(fn); recv != "" {
+					name = recv + "." + name
+				}
+				suggestions = append(suggestions, Suggestion{
+					Name:       name,
+					Dir:        dir,
+					Pos:        start,
+					Statements: stmts,
+					Uncovered:  uncovered,
+					Coverage:   coverage,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Uncovered > suggestions[j].Uncovered
+	})
+	return suggestions, nil
+}
+
+// packageDirs returns the directories holding Go files under paths, in walk order
+func (c *Converter) packageDirs(paths []string) ([]string, error) {
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, root := range paths {
+		err := c.walkFiles(root, func(path string, err error) error {
+			if err != nil {
+				return err
+			}
+			if dir := filepath.Dir(path); strings.HasSuffix(path, ".go") && !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error walking directory: %v", err)
+		}
+	}
+	return dirs, nil
+}
+
+// readCoverProfile reads the blocks of a coverage profile by file name. Blocks listed more
+// than once, as profiles merged from several runs have them, count as covered if any run
+// covered them.
+func readCoverProfile(path string) (map[string][]coverBlock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading coverage profile: %v", err)
+	}
+
+	blocks := make(map[string][]coverBlock)
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "mode:") {
+			continue
+		}
+
+		// Lines look like 'example.com/pkg/file.go:12.34,15.2 3 1'
+		colon := strings.LastIndex(text, ":")
+		fields := strings.Fields(text[colon+1:])
+		block, ok := parseCoverBlock(fields)
+		if colon < 0 || !ok {
+			return nil, fmt.Errorf("error parsing coverage profile: line %d: %q", line, text)
+		}
+
+		name := text[:colon]
+		key := name + ":" + fields[0]
+		if i, ok := index[key]; ok {
+			blocks[name][i].count += block.count
+			continue
+		}
+		index[key] = len(blocks[name])
+		blocks[name] = append(blocks[name], block)
+	}
+	return blocks, nil
+}
+
+// parseCoverBlock parses the fields of a coverage profile line after the file name
+// ('12.34,15.2', '3', '1')
+func parseCoverBlock(fields []string) (coverBlock, bool) {
+	if len(fields) != 3 {
+		return coverBlock{}, false
+	}
+	start, end, ok := strings.Cut(fields[0], ",")
+	if !ok {
+		return coverBlock{}, false
+	}
+
+	var block coverBlock
+	var nums []int
+	for _, s := range []string{start, end} {
+		line, col, ok := strings.Cut(s, ".")
+		if !ok {
+			return coverBlock{}, false
+		}
+		nums = append(nums, atoi(line), atoi(col))
+	}
+	nums = append(nums, atoi(fields[1]), atoi(fields[2]))
+	for _, n := range nums {
+		if n < 0 {
+			return coverBlock{}, false
+		}
+	}
+	block.startLine, block.startCol, block.endLine, block.endCol = nums[0], nums[1], nums[2], nums[3]
+	block.stmts, block.count = nums[4], nums[5]
+	return block, true
+}
+
+// atoi parses a non-negative number, returning -1 if it isn't one
+func atoi(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
+// profileBlocks returns the blocks of a file from a coverage profile, which names files by
+// import path, by absolute path, or by absolute path prefixed with '_' outside of modules
+func profileBlocks(blocks map[string][]coverBlock, path, importPath string) ([]coverBlock, bool) {
+	var names []string
+	if importPath != "" {
+		names = append(names, importPath+"/"+filepath.Base(path))
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		names = append(names, abs, "_"+filepath.ToSlash(abs))
+	}
+	for _, name := range names {
+		if fileBlocks, ok := blocks[name]; ok {
+			return fileBlocks, true
+		}
+	}
+	return nil, false
+}
+
+// packageImportPath returns the import path of the package in a directory, from the module
+// path of the go.mod file governing it, or "" when there is none
+func packageImportPath(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for root := dir; ; {
+		if data, err := os.ReadFile(filepath.Join(root, "go.mod")); err == nil {
+			module := ""
+			scanner := bufio.NewScanner(bytes.NewReader(data))
+			for scanner.Scan() {
+				fields := strings.Fields(scanner.Text())
+				if len(fields) >= 2 && fields[0] == "module" {
+					module = strings.Trim(fields[1], `"`)
+					break
+				}
+			}
+			rel, err := filepath.Rel(root, dir)
+			if module == "" || err != nil {
+				return ""
+			}
+			if rel == "." {
+				return module
+			}
+			return module + "/" + filepath.ToSlash(rel)
+		}
+
+		parent := filepath.Dir(root)
+		if parent == root {
+			return ""
+		}
+		root = parent
+	}
+}
+
+// funcCoverage counts the statements of the blocks within a function and those of them
+// that weren't covered
+func funcCoverage(blocks []coverBlock, start, end token.Position) (stmts, uncovered int) {
+	before := func(line1, col1, line2, col2 int) bool {
+		return line1 < line2 || (line1 == line2 && col1 <= col2)
+	}
+	for _, block := range blocks {
+		if !before(start.Line, start.Column, block.startLine, block.startCol) ||
+			!before(block.endLine, block.endCol, end.Line, end.Column) {
+			continue
+		}
+		stmts += block.stmts
+		if block.count == 0 {
+			uncovered += block.stmts
+		}
+	}
+	return stmts, uncovered
+}
+
+// exportedFunc reports whether a function is exported, along with its receiver type for
+// methods
+func exportedFunc(fn *ast.FuncDecl) bool {
+	if !fn.Name.IsExported() {
+		return false
+	}
+	if fn.Recv != nil {
+		recv := receiverType(fn)
+		return recv != "" && ast.IsExported(recv)
+	}
+	return true
+}
+
+// tableTested returns the names of the functions and methods called in the loops of the
+// table tests of a package, slice-based or map-based, by name
+func tableTested(files []*sourceFile) map[string]bool {
+	tested := make(map[string]bool)
+	for _, file := range files {
+		if !strings.HasSuffix(file.path, "_test.go") {
+			continue
+		}
+		ast.Inspect(file.node, func(n ast.Node) bool {
+			rangeStmt, ok := n.(*ast.RangeStmt)
+			if !ok || !tableExpr(rangeStmt.X) {
+				return true
+			}
+			ast.Inspect(rangeStmt.Body, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok {
+					switch fun := call.Fun.(type) {
+					case *ast.Ident:
+						tested[fun.Name] = true
+					case *ast.SelectorExpr:
+						tested[fun.Sel.Name] = true
+					}
+				}
+				return true
+			})
+			return true
+		})
+	}
+	return tested
+}
+
+// tableExpr reports whether a ranged expression is a table of test cases: a slice or map
+// literal of composite literals, inline or held by a variable
+func tableExpr(expr ast.Expr) bool {
+	lit, ok := tableValue(expr).(*ast.CompositeLit)
+	if !ok || len(lit.Elts) == 0 {
+		return false
+	}
+	switch typ := lit.Type.(type) {
+	case *ast.ArrayType:
+		if typ.Len != nil {
+			return false
+		}
+	case *ast.MapType:
+	default:
+		return false
+	}
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			elt = kv.Value
+		}
+		if _, ok := elt.(*ast.CompositeLit); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// tableValue returns the value a ranged variable was declared with, or the ranged
+// expression itself
+func tableValue(expr ast.Expr) ast.Expr {
+	if ident, ok := expr.(*ast.Ident); ok && ident.Obj != nil {
+		switch decl := ident.Obj.Decl.(type) {
+		case *ast.ValueSpec:
+			for i, name := range decl.Names {
+				if name.Name == ident.Name && i < len(decl.Values) {
+					expr = decl.Values[i]
+				}
+			}
+		case *ast.AssignStmt:
+			for i, lhs := range decl.Lhs {
+				if lhs, ok := lhs.(*ast.Ident); ok && lhs.Name == ident.Name && i < len(decl.Rhs) {
+					expr = decl.Rhs[i]
+				}
+			}
+		}
+	}
+	return expr
+}
+
+// jsonSuggestion is the machine-readable form of a Suggestion
+type jsonSuggestion struct {
+	Name       string       `json:"name"`
+	Dir        string       `json:"dir"`
+	Position   jsonPosition `json:"position"`
+	Statements int          `json:"statements"`
+	Uncovered  int          `json:"uncovered"`
+	Coverage   float64      `json:"coverage"`
+}
+
+// WriteSuggestionsJSON writes test suggestions as an indented JSON array
+func WriteSuggestionsJSON(w io.Writer, suggestions []Suggestion) error {
+	report := []jsonSuggestion{}
+	for _, s := range suggestions {
+		report = append(report, jsonSuggestion{
+			Name:       s.Name,
+			Dir:        s.Dir,
+			Position:   newJSONPosition(s.Pos),
+			Statements: s.Statements,
+			Uncovered:  s.Uncovered,
+			Coverage:   s.Coverage,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
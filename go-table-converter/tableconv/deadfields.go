@@ -0,0 +1,302 @@
+package tableconv
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+)
+
+// DeadFields checks case structs for fields that no loop over their table reads, which
+// tend to pile up as tests evolve
+type DeadFields string
+
+const (
+	// DeadFieldsNone leaves case fields alone
+	DeadFieldsNone DeadFields = ""
+	// DeadFieldsWarn reports the fields that are never read
+	DeadFieldsWarn DeadFields = "warn"
+	// DeadFieldsRemove drops the fields that are never read from the struct and from every
+	// case, reporting the ones whose values can't be dropped
+	DeadFieldsRemove DeadFields = "remove"
+)
+
+// checkDeadFields reports the fields of the anonymous case struct of a converted table that
+// the loops over the table never read. Only tables declared in a function and used by
+// nothing but loops are checked, and only loops whose case variable, or copies of it
+// ('tc := tc'), is used for its fields alone, since anything else could read every field;
+// tables that can't be checked for that are reported. Embedded fields are assumed to be
+// read, as their fields are promoted.
+func (c *Converter) checkDeadFields(fset *token.FileSet, file *sourceFile, candidate *tableCandidate) []Diagnostic {
+	table := candidate.table
+	if table.named != nil || candidate.funcName == "" {
+		return nil
+	}
+	if !onlyRanged(file.node, candidate) {
+		return []Diagnostic{{
+			Table:   candidate.name(),
+			Pos:     fset.Position(candidate.pos()),
+			Message: fmt.Sprintf("fields of the cases aren't checked for reads: %s is used by more than the loops over it", candidate.name()),
+		}}
+	}
+	_, fn := enclosingFunc(file.node, candidate.lit)
+	if fn == nil {
+		return nil
+	}
+	read, pos, reason := readFields(fn, candidate)
+	if reason != "" {
+		return []Diagnostic{{
+			Table:   candidate.name(),
+			Pos:     fset.Position(pos),
+			Message: "fields of the cases aren't checked for reads: " + reason,
+		}}
+	}
+	if read == nil {
+		return nil
+	}
+
+	structType := caseStruct(table)
+	var diags []Diagnostic
+	var removed []int
+	index := 0
+	for _, field := range structType.Fields.List {
+		if len(field.Names) == 0 {
+			index++
+			continue
+		}
+
+		for _, name := range field.Names {
+			if name.Name == "_" || read[name.Name] {
+				index++
+				continue
+			}
+
+			diag := Diagnostic{Table: candidate.name(), Pos: fset.Position(name.Pos())}
+			switch {
+			case c.opts.DeadFields != DeadFieldsRemove:
+				diag.Message = fmt.Sprintf("field %s of the cases is never read by the loops over %s", name.Name, candidate.name())
+			case callsInField(candidate, name.Name, index):
+				diag.Message = fmt.Sprintf("field %s of the cases is never read by the loops over %s, but cases compute it with calls, so it is kept", name.Name, candidate.name())
+			default:
+				diag.Message = fmt.Sprintf("unread field %s removed from the cases of %s", name.Name, candidate.name())
+				removed = append(removed, index)
+			}
+			diags = append(diags, diag)
+			index++
+		}
+	}
+
+	// Remove from the end so the positions of earlier fields stay valid
+	for i := len(removed) - 1; i >= 0; i-- {
+		structType = removeCaseField(fset, candidate, structType, removed[i])
+	}
+	if len(removed) > 0 {
+		c.logf("Removed %d unread fields from the cases of %s\n", len(removed), candidate.name())
+	}
+
+	return diags
+}
+
+// caseStruct returns the anonymous case struct of a converted table's map type
+func caseStruct(table tableType) *ast.StructType {
+	value := table.mapType.Value
+	if star, ok := value.(*ast.StarExpr); ok {
+		value = star.X
+	}
+	structType, _ := value.(*ast.StructType)
+	return structType
+}
+
+// readFields returns the fields read by the loops over a table in a function, nil when
+// there are no such loops. It gives up when a loop uses its case variable for anything
+// other than selecting a field or copying it into a variable of its own, returning why
+// and where.
+func readFields(fn *ast.FuncDecl, candidate *tableCandidate) (map[string]bool, token.Pos, string) {
+	read := make(map[string]bool)
+	loops := 0
+	var pos token.Pos
+	reason := ""
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		rangeStmt, isRange := n.(*ast.RangeStmt)
+		if !isRange || reason != "" {
+			return reason == ""
+		}
+		switch x := rangeStmt.X.(type) {
+		case *ast.Ident:
+			if candidate.ident == nil || varKey(x) != candidate.varKey() {
+				return true
+			}
+		case *ast.CompositeLit:
+			if x != candidate.lit {
+				return true
+			}
+		default:
+			return true
+		}
+
+		loops++
+		caseVar := caseIdent(rangeStmt)
+		if caseVar == nil {
+			return true
+		}
+		if rangeStmt.Tok != token.DEFINE {
+			pos, reason = caseVar.Pos(), fmt.Sprintf("the loop assigns the cases to %s, declared outside of it", caseVar.Name)
+			return false
+		}
+
+		// Copies and closures refer to the case by name too, so any other use of the
+		// name counts as reading the whole case. Copies declared from it, such as the
+		// 'tc := tc' of parallel subtests, are followed like the case variable itself.
+		names := map[string]bool{caseVar.Name: true}
+		parents := parentMap(rangeStmt.Body)
+		ast.Inspect(rangeStmt.Body, func(n ast.Node) bool {
+			ident, isIdent := n.(*ast.Ident)
+			if !isIdent || !names[ident.Name] {
+				return reason == ""
+			}
+			if copied, ok := caseCopy(parents[ident], ident, names); ok {
+				names[copied] = true
+				return true
+			}
+			sel, isSel := parents[ident].(*ast.SelectorExpr)
+			switch {
+			case isSel && sel.Sel == ident:
+			case isSel && sel.X == ident:
+				read[sel.Sel.Name] = true
+			default:
+				pos, reason = ident.Pos(), fmt.Sprintf("the loop uses its case %s for more than selecting its fields", ident.Name)
+			}
+			return reason == ""
+		})
+		return reason == ""
+	})
+	if reason != "" {
+		return nil, pos, reason
+	}
+	if loops == 0 {
+		return nil, token.NoPos, ""
+	}
+	return read, token.NoPos, ""
+}
+
+// caseCopy reports whether an identifier is either side of a declaration copying a case
+// variable, known by names, into a variable of its own ('tc := tc', 'test := tc'),
+// returning the name of the copy
+func caseCopy(parent ast.Node, ident *ast.Ident, names map[string]bool) (string, bool) {
+	assign, ok := parent.(*ast.AssignStmt)
+	if !ok || assign.Tok != token.DEFINE || len(assign.Lhs) != len(assign.Rhs) {
+		return "", false
+	}
+	for i, rhs := range assign.Rhs {
+		from, ok := rhs.(*ast.Ident)
+		if !ok || !names[from.Name] {
+			continue
+		}
+		to, ok := assign.Lhs[i].(*ast.Ident)
+		if !ok || isBlankIdent(to) {
+			continue
+		}
+		if ident == from || ident == to {
+			return to.Name, true
+		}
+	}
+	return "", false
+}
+
+// callsInField reports whether a case of a table computes the value of a field with a
+// call, which removing the field would no longer make
+func callsInField(candidate *tableCandidate, name string, index int) bool {
+	calls := false
+	for _, tc := range convertedCases(candidate) {
+		if value := caseField(tc, name, index); value != nil {
+			ast.Inspect(value, func(n ast.Node) bool {
+				if _, ok := n.(*ast.CallExpr); ok {
+					calls = true
+				}
+				return !calls
+			})
+		}
+	}
+	return calls
+}
+
+// convertedCases returns the case literals of a converted table
+func convertedCases(candidate *tableCandidate) []*ast.CompositeLit {
+	var cases []*ast.CompositeLit
+	for _, elt := range candidate.lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if caseLit := caseLiteral(kv.Value); caseLit != nil {
+				cases = append(cases, caseLit)
+			}
+		}
+	}
+	return cases
+}
+
+// caseField returns the element of a case literal setting a field, given by its name and
+// positional index: the key-value pair of a keyed literal or the value of a positional
+// one. It returns nil when the literal leaves the field out.
+func caseField(caseLit *ast.CompositeLit, name string, index int) ast.Expr {
+	if len(caseLit.Elts) == 0 {
+		return nil
+	}
+	if _, keyed := caseLit.Elts[0].(*ast.KeyValueExpr); keyed {
+		for _, elt := range caseLit.Elts {
+			kv := elt.(*ast.KeyValueExpr)
+			if key, ok := kv.Key.(*ast.Ident); ok && key.Name == name {
+				return kv
+			}
+		}
+		return nil
+	}
+	if index < len(caseLit.Elts) {
+		return caseLit.Elts[index]
+	}
+	return nil
+}
+
+// removeCaseField drops the field at a positional index from the case struct of a
+// converted table and from each of its cases, returning the trimmed struct
+func removeCaseField(fset *token.FileSet, candidate *tableCandidate, structType *ast.StructType, index int) *ast.StructType {
+	name := fieldNames(structType)[index]
+	for _, caseLit := range convertedCases(candidate) {
+		value := caseField(caseLit, name, index)
+		if value == nil {
+			continue
+		}
+
+		elts := make([]ast.Expr, 0, len(caseLit.Elts)-1)
+		for j, elt := range caseLit.Elts {
+			if elt != value {
+				elts = append(elts, elt)
+				continue
+			}
+
+			prevEnd, next := caseLit.Lbrace, caseLit.Rbrace
+			if j > 0 {
+				prevEnd = caseLit.Elts[j-1].End()
+			}
+			if j+1 < len(caseLit.Elts) {
+				next = caseLit.Elts[j+1].Pos()
+			}
+			collapseRemovedLines(fset, prevEnd, elt, next)
+		}
+		caseLit.Elts = elts
+
+		// A case spelling out the struct type loses the field too
+		if caseType, ok := caseLit.Type.(*ast.StructType); ok {
+			caseLit.Type = createStructTypeWithoutField(caseType, index)
+		}
+	}
+
+	collapseRemovedField(fset, structType, index)
+	trimmed := createStructTypeWithoutField(structType, index)
+
+	table := candidate.table
+	table.mapType.Value = table.elemType(trimmed)
+	if candidate.spec != nil {
+		if mapType, ok := candidate.spec.Type.(*ast.MapType); ok {
+			mapType.Value = table.elemType(trimmed)
+		}
+	}
+	return trimmed
+}
@@ -0,0 +1,129 @@
+package tableconv
+
+import (
+	"strings"
+)
+
+// diffHunk is a run of changed lines: lines [aStart, aEnd) of the original are replaced
+// by lines [bStart, bEnd) of the converted source
+type diffHunk struct {
+	aStart, aEnd int
+	bStart, bEnd int
+}
+
+// splitLines splits source into lines, keeping each line's newline
+func splitLines(src []byte) []string {
+	if len(src) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(src), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes the hunks of changed lines turning a into b using Myers' algorithm
+func diffLines(a, b []string) []diffHunk {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	// v[k+offset] is the furthest x reached on diagonal k; trace keeps v before each step
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+				x = v[k+1+offset]
+			} else {
+				x = v[k-1+offset] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+offset] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace backwards to recover which lines were kept
+	type edit struct {
+		kind byte // '=', '-', '+'
+		a, b int
+	}
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+offset]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{'=', x, y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, edit{'+', x, y})
+			} else {
+				x--
+				edits = append(edits, edit{'-', x, y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	// Group consecutive changes into hunks, walking forwards
+	var hunks []diffHunk
+	var cur *diffHunk
+	ai, bi := 0, 0
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		if e.kind == '=' {
+			if cur != nil {
+				hunks = append(hunks, *cur)
+				cur = nil
+			}
+			ai, bi = e.a+1, e.b+1
+			continue
+		}
+
+		if cur == nil {
+			cur = &diffHunk{aStart: ai, aEnd: ai, bStart: bi, bEnd: bi}
+		}
+		if e.kind == '-' {
+			cur.aEnd++
+			ai++
+		} else {
+			cur.bEnd++
+			bi++
+		}
+	}
+	if cur != nil {
+		hunks = append(hunks, *cur)
+	}
+
+	return hunks
+}
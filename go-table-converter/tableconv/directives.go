@@ -0,0 +1,151 @@
+package tableconv
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// directivePrefix starts the comments configuring the conversion of a table, placed
+// above its declaration or in the doc comment of its function ('//tabletests:ignore')
+const directivePrefix = "//tabletests:"
+
+// ignoreDirective excludes a table, or every table of a function, from conversion
+const ignoreDirective = "ignore"
+
+// tableDirectives returns the directives applying to the table declared at pos, by name
+// with their values ('key=int' is key with the value int, 'ignore' has none). Directives
+// of the comment group ending on the line above the table, or on its line, override
+// those of the doc comment of the function declaring it.
+func tableDirectives(fset *token.FileSet, node *ast.File, pos token.Pos) map[string]string {
+	directives := make(map[string]string)
+	for _, decl := range node.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Doc != nil && fn.Pos() <= pos && pos < fn.End() {
+			parseDirectives(fn.Doc, directives)
+		}
+	}
+
+	line := fset.Position(pos).Line
+	for _, group := range node.Comments {
+		end := fset.Position(group.End()).Line
+		if end == line-1 || end == line && group.Pos() > pos {
+			parseDirectives(group, directives)
+		}
+	}
+	return directives
+}
+
+// parseDirectives adds the directives of a comment group to directives
+func parseDirectives(group *ast.CommentGroup, directives map[string]string) {
+	for _, comment := range group.List {
+		text, ok := strings.CutPrefix(comment.Text, directivePrefix)
+		if !ok {
+			continue
+		}
+		// Anything after the directive explains it
+		if i := strings.IndexAny(text, " \t"); i >= 0 {
+			text = text[:i]
+		}
+		name, value, _ := strings.Cut(text, "=")
+		directives[name] = value
+	}
+}
+
+// configureTable applies the directives of a table that override options of the
+// converter for it alone: namefield=<field> picks the field keying the cases of an
+// anonymous case struct, keys=index|fields names the cases of a table without one, and
+// suffix-duplicates, ignore-order, dedupe, and keyed-fields turn on (or with =false
+// off) the options of the same name. It returns a diagnostic for each directive it
+// doesn't understand, which keeps the table from being converted.
+func (c *Converter) configureTable(fset *token.FileSet, candidate *tableCandidate, directives map[string]string) []Diagnostic {
+	opts := c.opts
+	overridden := false
+	var diags []Diagnostic
+	invalid := func(format string, args ...any) {
+		diags = append(diags, Diagnostic{Table: candidate.name(), Pos: fset.Position(candidate.pos()), Message: fmt.Sprintf(format, args...)})
+	}
+	flags := map[string]*bool{
+		"suffix-duplicates": &opts.SuffixDuplicates,
+		"ignore-order":      &opts.IgnoreOrder,
+		"dedupe":            &opts.Dedupe,
+		"keyed-fields":      &opts.KeyedFields,
+	}
+
+	names := make([]string, 0, len(directives))
+	for name := range directives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := directives[name]
+		switch name {
+		case ignoreDirective:
+		case "namefield":
+			if candidate.table.named != nil {
+				invalid("%snamefield only applies to tables of anonymous structs, %s declares its name field", directivePrefix, candidate.table.named.spec.Name.Name)
+			} else if !candidate.table.setNameField(value) {
+				invalid("%snamefield=%s names no field of the case struct", directivePrefix, value)
+			}
+		case "keys":
+			switch KeyStrategy(value) {
+			case KeysIndex, KeysFields:
+				opts.Keys, overridden = KeyStrategy(value), true
+			default:
+				invalid("unknown key strategy %q in %skeys: use index or fields", value, directivePrefix)
+			}
+		default:
+			flag, ok := flags[name]
+			if !ok {
+				invalid("unknown directive %s%s", directivePrefix, name)
+				continue
+			}
+			on, err := strconv.ParseBool(cmp.Or(value, "true"))
+			if err != nil {
+				invalid("%s%s takes true or false, not %q", directivePrefix, name, value)
+				continue
+			}
+			*flag, overridden = on, true
+		}
+	}
+
+	if overridden {
+		converter := *c
+		converter.opts = opts
+		candidate.converter = &converter
+	}
+	return diags
+}
+
+// forTable returns the converter to convert a table with: the one configured by its
+// directives, or c
+func (c *Converter) forTable(candidate *tableCandidate) *Converter {
+	if candidate.converter != nil {
+		return candidate.converter
+	}
+	return c
+}
+
+// setNameField makes a field of the anonymous case struct the name field keying the
+// cases, reporting false when the struct has no such field
+func (table *tableType) setNameField(name string) bool {
+	index := 0
+	for _, field := range table.structType.Fields.List {
+		if len(field.Names) == 0 {
+			index++
+			continue
+		}
+		for _, ident := range field.Names {
+			if ident.Name == name {
+				table.nameField, table.nameFieldIndex = name, index
+				table.mapType.Value = table.elemType(createStructTypeWithoutField(table.structType, index))
+				return true
+			}
+			index++
+		}
+	}
+	return false
+}
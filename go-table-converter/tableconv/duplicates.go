@@ -0,0 +1,241 @@
+package tableconv
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// caseName identifies the name of a test case: the value of a constant name, or the source
+// of a name computed at run time
+type caseName struct {
+	text     string
+	constant bool
+}
+
+// String formats the name for diagnostics
+func (name caseName) String() string {
+	if name.constant {
+		return strconv.Quote(name.text)
+	}
+	return name.text
+}
+
+// resolveDuplicateNames finds test cases of a table that share a name, which would become
+// duplicate map keys. With SuffixDuplicates the later cases get a numbered suffix; otherwise
+// the table can't be converted. It reports whether the table can be converted, along with
+// a diagnostic for each collision.
+func (c *Converter) resolveDuplicateNames(fset *token.FileSet, candidate *tableCandidate, cases []*tableCase) (bool, []Diagnostic) {
+	names := make(map[caseName]*tableCase)
+	for _, tc := range cases {
+		name := caseNameOf(fset, tc)
+		if _, seen := names[name]; !seen {
+			names[name] = tc
+		}
+		if !name.constant {
+			c.logf("Keeping computed case name %s in table %s as the map key\n", name, candidate.name())
+		}
+	}
+
+	var diags []Diagnostic
+	for _, tc := range cases {
+		name := caseNameOf(fset, tc)
+		if names[name] == tc {
+			continue
+		}
+
+		first := fset.Position(names[name].lit.Pos())
+		diag := Diagnostic{
+			Table: candidate.name(),
+			Pos:   fset.Position(tc.lit.Pos()),
+		}
+
+		if !c.opts.SuffixDuplicates {
+			diag.Message = fmt.Sprintf("duplicate case name %s (first used at %s)", name, first)
+			diags = append(diags, diag)
+			continue
+		}
+
+		// Number the duplicate after the cases already using the name
+		var suffixed caseName
+		var suffix string
+		for n := 2; ; n++ {
+			suffix = fmt.Sprintf(" #%d", n)
+			suffixed = suffixName(name, suffix)
+			if _, taken := names[suffixed]; !taken {
+				break
+			}
+		}
+		names[suffixed] = tc
+
+		// String literals are renamed, other names get the suffix appended
+		if lit, ok := tc.name.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			lit.Value = strconv.Quote(suffixed.text)
+		} else {
+			tc.name = &ast.BinaryExpr{X: tc.name, OpPos: tc.name.End(), Op: token.ADD, Y: &ast.BasicLit{ValuePos: tc.name.End(), Kind: token.STRING, Value: strconv.Quote(suffix)}}
+		}
+
+		diag.Message = fmt.Sprintf("duplicate case name %s (first used at %s) renamed to %s", name, first, suffixed)
+		diags = append(diags, diag)
+	}
+
+	return c.opts.SuffixDuplicates || len(diags) == 0, diags
+}
+
+// suffixName returns a case name with a suffix appended
+func suffixName(name caseName, suffix string) caseName {
+	if name.constant {
+		return caseName{text: name.text + suffix, constant: true}
+	}
+	return caseName{text: name.text + " + " + strconv.Quote(suffix)}
+}
+
+// caseNameOf returns the name of a test case. Names that are constant within the file
+// compare by value, so "a"+"b" and "ab" collide; other names compare by their source.
+func caseNameOf(fset *token.FileSet, tc *tableCase) caseName {
+	if value := constValue(tc.name); value != nil && value.Kind() == constant.String {
+		return caseName{text: constant.StringVal(value), constant: true}
+	}
+	return caseName{text: nodeString(fset, tc.name)}
+}
+
+// constValue evaluates a constant expression built from literals, parentheses, concatenation,
+// and constants declared in the file. It returns nil for anything else.
+func constValue(expr ast.Expr) constant.Value {
+	return evalConst(expr, make(map[*ast.Object]bool))
+}
+
+// evalConst evaluates a constant expression, tracking the constants being evaluated so a
+// malformed cyclic declaration can't recurse forever
+func evalConst(expr ast.Expr, visiting map[*ast.Object]bool) constant.Value {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		value := constant.MakeFromLiteral(e.Value, e.Kind, 0)
+		if value.Kind() == constant.Unknown {
+			return nil
+		}
+		return value
+	case *ast.ParenExpr:
+		return evalConst(e.X, visiting)
+	case *ast.BinaryExpr:
+		x, y := evalConst(e.X, visiting), evalConst(e.Y, visiting)
+		if x == nil || y == nil || x.Kind() != y.Kind() || e.Op != token.ADD {
+			return nil
+		}
+		return constant.BinaryOp(x, e.Op, y)
+	case *ast.Ident:
+		obj := e.Obj
+		if obj == nil || obj.Kind != ast.Con || visiting[obj] {
+			return nil
+		}
+		spec, ok := obj.Decl.(*ast.ValueSpec)
+		if !ok {
+			return nil
+		}
+		for i, name := range spec.Names {
+			// Constants repeating the previous expression implicitly depend on iota
+			if name.Obj == obj && i < len(spec.Values) {
+				visiting[obj] = true
+				defer delete(visiting, obj)
+				return evalConst(spec.Values[i], visiting)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveDuplicateValues finds test cases of a table whose values, apart from their name,
+// repeat those of an earlier case. They add run time but no coverage. With Dedupe the
+// later cases are removed from the cases to convert; otherwise they are only reported.
+// Cases computing a value with a call aren't compared, since calls may differ from one
+// case to the next.
+func (c *Converter) resolveDuplicateValues(fset *token.FileSet, candidate *tableCandidate, cases []*tableCase) ([]*tableCase, []Diagnostic) {
+	first := make(map[string]*tableCase)
+	kept := make([]*tableCase, 0, len(cases))
+	var diags []Diagnostic
+	for _, tc := range cases {
+		values, ok := caseValues(fset, tc)
+		if !ok {
+			kept = append(kept, tc)
+			continue
+		}
+		earlier, seen := first[values]
+		if !seen {
+			first[values] = tc
+			kept = append(kept, tc)
+			continue
+		}
+
+		diag := Diagnostic{Table: candidate.name(), Pos: fset.Position(tc.lit.Pos())}
+		if !c.opts.Dedupe {
+			diag.Message = fmt.Sprintf("case %s repeats the values of case %s (at %s)", caseNameOf(fset, tc), caseNameOf(fset, earlier), fset.Position(earlier.lit.Pos()))
+			kept = append(kept, tc)
+		} else {
+			diag.Message = fmt.Sprintf("case %s removed, it repeats the values of case %s (at %s)", caseNameOf(fset, tc), caseNameOf(fset, earlier), fset.Position(earlier.lit.Pos()))
+			removeTableElt(fset, candidate.lit, tc.elt)
+		}
+		diags = append(diags, diag)
+	}
+
+	if len(kept) < len(cases) {
+		c.logf("Removed %d duplicate cases from table test variable %s\n", len(cases)-len(kept), candidate.name())
+	}
+	return kept, diags
+}
+
+// caseValues returns the source of the values of a case apart from its name, keyed
+// literals sorted by field so the order they are written in doesn't matter. It reports
+// false for cases making calls.
+func caseValues(fset *token.FileSet, tc *tableCase) (string, bool) {
+	calls := false
+	ast.Inspect(tc.lit, func(n ast.Node) bool {
+		if _, ok := n.(*ast.CallExpr); ok {
+			calls = true
+		}
+		return !calls
+	})
+	if calls {
+		return "", false
+	}
+
+	var values []string
+	for i, elt := range tc.lit.Elts {
+		if i != tc.nameIndex {
+			values = append(values, nodeString(fset, elt))
+		}
+	}
+	if len(tc.lit.Elts) > 0 {
+		if _, keyed := tc.lit.Elts[0].(*ast.KeyValueExpr); keyed {
+			sort.Strings(values)
+		}
+	}
+	return strings.Join(values, "\x00"), true
+}
+
+// removeTableElt drops an element from the literal of a table, merging the lines it sat
+// on, along with those of the comments above it, which go with it, into the next element's
+func removeTableElt(fset *token.FileSet, lit *ast.CompositeLit, elt ast.Expr) {
+	for i, e := range lit.Elts {
+		if e != elt {
+			continue
+		}
+		prevEnd, next := lit.Lbrace, lit.Rbrace
+		if i > 0 {
+			prevEnd = lit.Elts[i-1].End()
+		}
+		if i+1 < len(lit.Elts) {
+			next = lit.Elts[i+1].Pos()
+		}
+		removed := span{from: elt.Pos(), to: elt.End()}
+		if file := fset.File(elt.Pos()); file != nil && file.Line(prevEnd)+1 < file.Line(elt.Pos()) {
+			removed.from = file.LineStart(file.Line(prevEnd) + 1)
+		}
+		collapseRemovedLines(fset, prevEnd, removed, next)
+		lit.Elts = append(lit.Elts[:i:i], lit.Elts[i+1:]...)
+		return
+	}
+}
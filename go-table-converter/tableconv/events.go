@@ -0,0 +1,83 @@
+package tableconv
+
+import "time"
+
+// Kinds of FileEvent
+const (
+	// EventStart is reported when a file starts being converted
+	EventStart = "start"
+	// EventResult is reported once a file is converted, skipped, or failed
+	EventResult = "result"
+)
+
+// FileEvent is an event of the conversion of a single file, reported to Options.Events.
+// Its JSON form is one line of the -log-format=ndjson log.
+type FileEvent struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	Path  string    `json:"path"`
+
+	// The rest is only set on result events. Duration runs from the start event of the
+	// file, so it includes the type check and write of the package the file is part of.
+	Duration         time.Duration `json:"durationNs,omitempty"`
+	Modified         bool          `json:"modified,omitempty"`
+	Cached           bool          `json:"cached,omitempty"`
+	Generated        bool          `json:"generated,omitempty"`
+	TablesFound      int           `json:"tablesFound,omitempty"`
+	TablesConverted  int           `json:"tablesConverted,omitempty"`
+	TablesSkipped    int           `json:"tablesSkipped,omitempty"`
+	TablesSuppressed int           `json:"tablesSuppressed,omitempty"`
+	Skipped          []EventSkip   `json:"skipped,omitempty"`
+	Error            string        `json:"error,omitempty"`
+}
+
+// EventSkip is a table a result event reports as left unconverted
+type EventSkip struct {
+	Table   string     `json:"table"`
+	Line    int        `json:"line"`
+	Reason  SkipReason `json:"reason"`
+	Message string     `json:"message"`
+}
+
+// startEvent reports that a file starts being converted and returns the time it did
+func (c *Converter) startEvent(path string) time.Time {
+	now := time.Now()
+	if c.opts.Events != nil {
+		c.opts.Events(FileEvent{Time: now, Event: EventStart, Path: path})
+	}
+	return now
+}
+
+// resultEvent reports the outcome of a file whose conversion started at start, or the
+// error that stopped it
+func (c *Converter) resultEvent(path string, start time.Time, res FileResult, err error) {
+	if c.opts.Events == nil {
+		return
+	}
+	now := time.Now()
+	event := FileEvent{Time: now, Event: EventResult, Path: path, Duration: now.Sub(start)}
+	if err != nil {
+		event.Error = err.Error()
+		c.opts.Events(event)
+		return
+	}
+
+	event.Modified = res.Modified
+	event.Cached = res.Cached
+	event.Generated = res.Generated
+	if res.Modified {
+		event.TablesConverted = res.TablesConverted
+	}
+	event.TablesSkipped = res.TablesSkipped
+	event.TablesSuppressed = res.TablesSuppressed
+	event.TablesFound = event.TablesConverted + res.TablesSkipped + res.TablesSuppressed
+	for _, skipped := range res.Skipped {
+		event.Skipped = append(event.Skipped, EventSkip{
+			Table:   skipped.Table.Name,
+			Line:    skipped.Table.Pos.Line,
+			Reason:  skipped.Reason,
+			Message: skipped.Message,
+		})
+	}
+	c.opts.Events(event)
+}
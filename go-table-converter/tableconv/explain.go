@@ -0,0 +1,114 @@
+package tableconv
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+)
+
+// explanation tells what a conversion changed at an offset of the original source and
+// why, for annotating the hunks of a patch
+type explanation struct {
+	offset  int
+	message string
+}
+
+// explain records an explanation for the file of the package holding pos. Offsets are
+// kept rather than lines, since removing fields merges lines of the file set.
+func explain(fset *token.FileSet, files []*sourceFile, pos token.Pos, format string, args ...any) {
+	tf := fset.File(pos)
+	if tf == nil {
+		return
+	}
+	for _, file := range files {
+		// Sources rewritten before converting no longer match the offsets
+		if file.path == tf.Name() && bytes.Equal(file.parsed, file.src) {
+			file.result.explanations = append(file.result.explanations, explanation{tf.Offset(pos), fmt.Sprintf(format, args...)})
+			return
+		}
+	}
+}
+
+// explainTable explains the conversion of a table's literal and of its name field
+func explainTable(fset *token.FileSet, files []*sourceFile, candidate *tableCandidate) {
+	table := candidate.table
+	if table.nameField == "" {
+		explain(fset, files, candidate.lit.Pos(), "%s becomes a map keyed by generated case names, so cases are selected by name and run in random order", candidate.name())
+		return
+	}
+	explain(fset, files, candidate.lit.Pos(), "%s becomes a map keyed by the %s of each case, so cases are selected by name and run in random order", candidate.name(), table.nameField)
+
+	index := 0
+	for _, field := range table.structType.Fields.List {
+		for _, name := range field.Names {
+			if index == table.nameFieldIndex {
+				explain(fset, files, name.Pos(), "the %s field is removed, the map key holds it now", name.Name)
+			}
+			index++
+		}
+		if len(field.Names) == 0 {
+			index++
+		}
+	}
+}
+
+// explainLoops explains the changes updateLoops is about to make to the loops of a file
+// over converted tables: the key variable they take and the uses of the name field the
+// key replaces
+func explainLoops(fset *token.FileSet, files []*sourceFile, file *sourceFile, vars tableVars) {
+	for _, fn := range funcDecls(file.node) {
+		forRangedLoops(fn, vars, func(rangeStmt *ast.RangeStmt) {
+			nameField, _ := vars.ranged(rangeStmt)
+			key := ""
+			if ident, ok := rangeStmt.Key.(*ast.Ident); ok && !isBlankIdent(ident) {
+				key = ident.Name
+			} else if rangeStmt.Key == nil || isBlankIdent(rangeStmt.Key) {
+				key = freshKeyName(rangeStmt)
+				explain(fset, files, rangeStmt.Pos(), "the loop takes the map key as %s to name the cases", key)
+			}
+			caseVar, ok := rangeStmt.Value.(*ast.Ident)
+			if key == "" || !ok || isBlankIdent(caseVar) {
+				return
+			}
+
+			// Name the uses in t.Run calls apart, since those name the subtests
+			runArgs := make(map[ast.Expr]bool)
+			ast.Inspect(rangeStmt.Body, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok && len(call.Args) == 2 {
+					if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Run" {
+						runArgs[call.Args[0]] = true
+					}
+				}
+				sel, ok := n.(*ast.SelectorExpr)
+				if !ok || sel.Sel.Name != nameField {
+					return true
+				}
+				if x, ok := sel.X.(*ast.Ident); ok && x.Name == caseVar.Name {
+					if runArgs[sel] {
+						explain(fset, files, sel.Pos(), "t.Run names the subtest by the map key %s instead of %s.%s", key, caseVar.Name, nameField)
+					} else {
+						explain(fset, files, sel.Pos(), "%s.%s is replaced by the map key %s", caseVar.Name, nameField, key)
+					}
+				}
+				return true
+			})
+		})
+	}
+}
+
+// explanationNotes returns the explanations of a file by the index of the line of its
+// source they apply to
+func explanationNotes(src []byte, explanations []explanation) map[int][]string {
+	if len(explanations) == 0 {
+		return nil
+	}
+	notes := make(map[int][]string)
+	for _, e := range explanations {
+		if e.offset <= len(src) {
+			line := bytes.Count(src[:e.offset], []byte("\n"))
+			notes[line] = append(notes[line], e.message)
+		}
+	}
+	return notes
+}
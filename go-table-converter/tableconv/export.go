@@ -0,0 +1,545 @@
+package tableconv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FixtureFormat is the format of the data files table cases are exported to
+type FixtureFormat string
+
+const (
+	// FixtureJSON is an object of cases by name, each an object of field values
+	FixtureJSON FixtureFormat = "json"
+	// FixtureCSV is a header row naming the fields after a name column, and a row per case
+	FixtureCSV FixtureFormat = "csv"
+	// FixtureYAML is a mapping of cases by name, each a mapping of field values; tests
+	// load it with gopkg.in/yaml.v3, which the module has to require
+	FixtureYAML FixtureFormat = "yaml"
+)
+
+// yamlModule is the module tests load YAML fixtures with
+const yamlModule = "gopkg.in/yaml.v3"
+
+// fixtureTypes are the types of the fields fixtures can hold, by the strconv function
+// parsing them from CSV and its bit size, zero for the functions taking none
+var fixtureTypes = map[string]struct {
+	parse string
+	bits  int
+}{
+	"string": {"", 0}, "bool": {"ParseBool", 0},
+	"int": {"Atoi", 0}, "int8": {"ParseInt", 8}, "int16": {"ParseInt", 16}, "int32": {"ParseInt", 32}, "int64": {"ParseInt", 64}, "rune": {"ParseInt", 32},
+	"uint": {"ParseUint", 0}, "uint8": {"ParseUint", 8}, "uint16": {"ParseUint", 16}, "uint32": {"ParseUint", 32}, "uint64": {"ParseUint", 64}, "byte": {"ParseUint", 8},
+	"float32": {"ParseFloat", 32}, "float64": {"ParseFloat", 64},
+}
+
+// fixtureField is a field of the case struct of an exported table
+type fixtureField struct {
+	name string
+	typ  string
+}
+
+// Export is a table test exported to a data file, with the test rewritten to load it
+type Export struct {
+	// TestPath and Test are the path and new contents of the test's file
+	TestPath string
+	Test     []byte
+	// DataPath and Data are the path and contents of the data file under testdata
+	DataPath string
+	Data     []byte
+}
+
+// ExportTable exports the cases of the map-based table a test ranges over to a data file
+// named after the test ('testdata/TestAdd.json') and rewrites the test to load them with a
+// generated loader function ('tests := loadTestAddCases(t)'). An anonymous case struct
+// becomes a named type ('TestAddCase') the loader returns. The cases have to set fields of
+// predeclared string, boolean, and numeric types to literals. Nothing is written; the
+// caller writes the returned files.
+func (c *Converter) ExportTable(path, testName string, fixture FixtureFormat) (Export, error) {
+	if fixture == FixtureYAML && !moduleRequires(filepath.Dir(path), yamlModule) {
+		return Export{}, fmt.Errorf("exporting to YAML needs %s, which the module doesn't require", yamlModule)
+	}
+
+	fset := token.NewFileSet()
+	files, err := packageFiles(fset, filepath.Dir(path))
+	if err != nil {
+		return Export{}, err
+	}
+	var file *sourceFile
+	for _, f := range files {
+		if sameFile(f.path, path) {
+			file = f
+		}
+	}
+	if file == nil {
+		return Export{}, fmt.Errorf("%s is not a Go file", path)
+	}
+
+	// Step 1: Find the test and the table it ranges over
+	var test *ast.FuncDecl
+	for _, decl := range file.node.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == testName && fn.Body != nil {
+			test = fn
+		}
+	}
+	if test == nil {
+		return Export{}, fmt.Errorf("test %s not found in %s", testName, path)
+	}
+	t := testingParam(test.Type, "T")
+	lit := rangedMapTable(test)
+	if t == "" || lit == nil {
+		return Export{}, fmt.Errorf("%s doesn't range over a map-based table", testName)
+	}
+	if spec := declaringSpec(test, lit); spec != nil && spec.Type != nil {
+		return Export{}, fmt.Errorf("the table of %s is declared with its type, which the loader can't return", testName)
+	}
+
+	// Step 2: Collect the fields of the case type and the values of each case
+	taken := packageNames(files)
+	mapType := lit.Type.(*ast.MapType)
+	if key, ok := mapType.Key.(*ast.Ident); !ok || key.Name != "string" {
+		return Export{}, fmt.Errorf("the table of %s isn't keyed by strings", testName)
+	}
+	caseType, structType := "", (*ast.StructType)(nil)
+	switch typ := mapType.Value.(type) {
+	case *ast.StructType:
+		structType = typ
+		caseType = testName + "Case"
+		for n := 2; taken[caseType]; n++ {
+			caseType = fmt.Sprintf("%sCase%d", testName, n)
+		}
+	case *ast.Ident:
+		caseType = typ.Name
+		structType = namedStruct(files, typ.Name)
+	}
+	if structType == nil {
+		return Export{}, fmt.Errorf("the cases of %s aren't structs declared in the package", testName)
+	}
+	fields, err := fixtureFields(structType)
+	if err != nil {
+		return Export{}, fmt.Errorf("%s: %v", testName, err)
+	}
+
+	var names []string
+	var rows [][]any
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return Export{}, fmt.Errorf("%s: cases have to be keyed by string literals", testName)
+		}
+		name, ok := stringLit(kv.Key)
+		caseLit, isLit := kv.Value.(*ast.CompositeLit)
+		if !ok || !isLit {
+			return Export{}, fmt.Errorf("%s: cases have to be keyed by string literals", testName)
+		}
+		row, err := caseRow(caseLit, fields)
+		if err != nil {
+			return Export{}, fmt.Errorf("%s: case %q: %v", testName, name, err)
+		}
+		names = append(names, name)
+		rows = append(rows, row)
+	}
+
+	// Step 3: Write the data file
+	dataName := testName + "." + string(fixture)
+	export := Export{
+		TestPath: file.path,
+		DataPath: filepath.Join(filepath.Dir(file.path), "testdata", dataName),
+	}
+	switch fixture {
+	case FixtureJSON:
+		export.Data = jsonFixture(names, fields, rows)
+	case FixtureCSV:
+		export.Data, err = csvFixture(names, fields, rows)
+	case FixtureYAML:
+		export.Data = yamlFixture(names, fields, rows)
+	default:
+		err = fmt.Errorf("unknown fixture format %q", fixture)
+	}
+	if err != nil {
+		return Export{}, err
+	}
+
+	// Step 4: Load the cases in the test, declaring the case type above it
+	loader := "load" + testName + "Cases"
+	for n := 2; taken[loader]; n++ {
+		loader = fmt.Sprintf("load%sCases%d", testName, n)
+	}
+	src := file.src
+	offset := func(pos token.Pos) int {
+		return fset.Position(pos).Offset
+	}
+	var edits []sourceEdit
+	if mapType.Value == structType {
+		start := offset(test.Pos())
+		if test.Doc != nil {
+			start = offset(test.Doc.Pos())
+		}
+		text := fmt.Sprintf("type %s %s\n\n", caseType, src[offset(structType.Pos()):offset(structType.End())])
+		edits = append(edits, sourceEdit{start: start, end: start, text: text})
+	}
+	edits = append(edits, sourceEdit{start: offset(lit.Pos()), end: offset(lit.End()), text: fmt.Sprintf("%s(%s)", loader, t)})
+	end := offset(test.End())
+	edits = append(edits, sourceEdit{start: end, end: end, text: "\n\n" + fixtureLoader(fixture, loader, testName, dataName, caseType, fields)})
+
+	// Step 5: Import what the loader uses
+	imports := []string{"os", "path/filepath"}
+	switch fixture {
+	case FixtureJSON:
+		imports = append(imports, "encoding/json")
+	case FixtureCSV:
+		imports = append(imports, "encoding/csv")
+		if csvParses(fields) {
+			imports = append(imports, "strconv")
+		}
+	case FixtureYAML:
+		imports = append(imports, yamlModule)
+	}
+	edits = append(importEdits(fset, file.node, src, imports), edits...)
+
+	out, err := format.Source(applyEdits(src, edits))
+	if err != nil {
+		return Export{}, fmt.Errorf("error formatting exported test: %v", err)
+	}
+	export.Test = out
+	c.logf("Exported %d cases of %s to %s\n", len(names), testName, export.DataPath)
+	return export, nil
+}
+
+// sameFile reports whether two paths name the same file
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// declaringSpec returns the var declaration of a function holding a table literal, or nil
+// when the literal is assigned or ranged over inline
+func declaringSpec(fn *ast.FuncDecl, lit *ast.CompositeLit) *ast.ValueSpec {
+	var found *ast.ValueSpec
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if spec, ok := n.(*ast.ValueSpec); ok {
+			for _, value := range spec.Values {
+				if value == lit {
+					found = spec
+				}
+			}
+		}
+		return found == nil
+	})
+	return found
+}
+
+// namedStruct returns the struct type declared by the package under a name, or nil
+func namedStruct(files []*sourceFile, name string) *ast.StructType {
+	for _, file := range files {
+		for _, decl := range file.node.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				if spec := spec.(*ast.TypeSpec); spec.Name.Name == name {
+					structType, _ := spec.Type.(*ast.StructType)
+					return structType
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// fixtureFields returns the fields of a case struct in order, failing for fields of types
+// fixtures can't hold
+func fixtureFields(structType *ast.StructType) ([]fixtureField, error) {
+	var fields []fixtureField
+	exported := make(map[string]bool)
+	for _, field := range structType.Fields.List {
+		ident, ok := field.Type.(*ast.Ident)
+		if !ok || len(field.Names) == 0 {
+			return nil, fmt.Errorf("field of type %s can't be exported", typeString(field.Type))
+		}
+		if _, ok := fixtureTypes[ident.Name]; !ok {
+			return nil, fmt.Errorf("field %s of type %s can't be exported", field.Names[0].Name, ident.Name)
+		}
+		for _, name := range field.Names {
+			if name.Name == "_" || exported[exportedName(name.Name)] {
+				return nil, fmt.Errorf("field %s can't be exported", name.Name)
+			}
+			exported[exportedName(name.Name)] = true
+			fields = append(fields, fixtureField{name: name.Name, typ: ident.Name})
+		}
+	}
+	return fields, nil
+}
+
+// typeString returns the source of a type for messages
+func typeString(typ ast.Expr) string {
+	return nodeString(token.NewFileSet(), typ)
+}
+
+// exportedName returns a field name with its first letter upper-cased, for the structs
+// fixtures are decoded into
+func exportedName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
+
+// caseRow returns the values a case sets its fields to, in field order, with the zero
+// value for fields it leaves out
+func caseRow(caseLit *ast.CompositeLit, fields []fixtureField) ([]any, error) {
+	row := make([]any, len(fields))
+	for i, field := range fields {
+		row[i] = zeroValue(field.typ)
+	}
+	for i, elt := range caseLit.Elts {
+		index := i
+		value := elt
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			key, _ := kv.Key.(*ast.Ident)
+			index = -1
+			for j, field := range fields {
+				if key != nil && field.name == key.Name {
+					index = j
+				}
+			}
+			value = kv.Value
+		}
+		if index < 0 || index >= len(fields) {
+			return nil, fmt.Errorf("unknown field")
+		}
+		v, ok := literalValue(value, fields[index].typ)
+		if !ok {
+			return nil, fmt.Errorf("%s isn't set to a literal", fields[index].name)
+		}
+		row[index] = v
+	}
+	return row, nil
+}
+
+// zeroValue returns the zero value of a fixture field type
+func zeroValue(typ string) any {
+	switch fixtureTypes[typ].parse {
+	case "":
+		return ""
+	case "ParseBool":
+		return false
+	case "ParseUint":
+		return uint64(0)
+	case "ParseFloat":
+		return float64(0)
+	}
+	return int64(0)
+}
+
+// literalValue returns the value of a literal for a field of a fixture type: a string, a
+// bool, an int64, a uint64, or a float64
+func literalValue(expr ast.Expr, typ string) (any, bool) {
+	if ident, ok := expr.(*ast.Ident); ok && typ == "bool" {
+		return ident.Name == "true", ident.Name == "true" || ident.Name == "false"
+	}
+	if typ == "string" {
+		return stringLit(expr)
+	}
+
+	text := ""
+	if unary, ok := expr.(*ast.UnaryExpr); ok && (unary.Op == token.SUB || unary.Op == token.ADD) {
+		text = unary.Op.String()
+		expr = unary.X
+	}
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok {
+		return nil, false
+	}
+	if lit.Kind == token.CHAR {
+		r, _, _, err := strconv.UnquoteChar(lit.Value[1:len(lit.Value)-1], '\'')
+		if err != nil {
+			return nil, false
+		}
+		lit = &ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(int(r))}
+	}
+	text += lit.Value
+
+	switch fixtureTypes[typ].parse {
+	case "ParseUint":
+		v, err := strconv.ParseUint(text, 0, 64)
+		return v, err == nil
+	case "ParseFloat":
+		v, err := strconv.ParseFloat(strings.ReplaceAll(text, "_", ""), 64)
+		return v, err == nil
+	case "ParseBool":
+		return nil, false
+	}
+	v, err := strconv.ParseInt(text, 0, 64)
+	return v, err == nil
+}
+
+// fixtureText formats a value of a fixture for CSV and YAML
+func fixtureText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return ""
+}
+
+// jsonFixture writes cases as a JSON object of cases by name, keeping the order of the
+// cases and their fields
+func jsonFixture(names []string, fields []fixtureField, rows [][]any) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("{\n")
+	for i, name := range names {
+		key, _ := json.Marshal(name)
+		fmt.Fprintf(&buf, "  %s: {", key)
+		for j, field := range fields {
+			value, _ := json.Marshal(rows[i][j])
+			sep := ","
+			if j == len(fields)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(&buf, "\n    %q: %s%s", field.name, value, sep)
+		}
+		sep := ","
+		if i == len(names)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(&buf, "\n  }%s\n", sep)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// csvFixture writes cases as CSV: a header row naming the fields after a name column, and a
+// row per case
+func csvFixture(names []string, fields []fixtureField, rows [][]any) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{"name"}
+	for _, field := range fields {
+		header = append(header, field.name)
+	}
+	w.Write(header)
+	for i, name := range names {
+		record := []string{name}
+		for _, v := range rows[i] {
+			record = append(record, fixtureText(v))
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("error writing CSV: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlFixture writes cases as a YAML mapping of cases by name, quoting every string
+func yamlFixture(names []string, fields []fixtureField, rows [][]any) []byte {
+	var buf bytes.Buffer
+	for i, name := range names {
+		fmt.Fprintf(&buf, "%s:\n", strconv.Quote(name))
+		for j, field := range fields {
+			value := fixtureText(rows[i][j])
+			if s, ok := rows[i][j].(string); ok {
+				value = strconv.Quote(s)
+			}
+			fmt.Fprintf(&buf, "  %s: %s\n", field.name, value)
+		}
+	}
+	return buf.Bytes()
+}
+
+// csvParses reports whether the CSV loader of a case type parses any field with strconv
+func csvParses(fields []fixtureField) bool {
+	for _, field := range fields {
+		if field.typ != "string" {
+			return true
+		}
+	}
+	return false
+}
+
+// fixtureLoader returns the source of the function loading the cases of a test from its
+// data file
+func fixtureLoader(fixture FixtureFormat, loader, testName, dataName, caseType string, fields []fixtureField) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s loads the cases of %s from testdata/%s\n", loader, testName, dataName)
+	fmt.Fprintf(&b, "func %s(t *testing.T) map[string]%s {\n", loader, caseType)
+	b.WriteString("t.Helper()\n\n")
+
+	if fixture == FixtureCSV {
+		fmt.Fprintf(&b, "f, err := os.Open(filepath.Join(\"testdata\", %q))\n", dataName)
+		b.WriteString("if err != nil {\nt.Fatalf(\"error opening test cases: %v\", err)\n}\ndefer f.Close()\n\n")
+		b.WriteString("records, err := csv.NewReader(f).ReadAll()\n")
+		b.WriteString("if err != nil {\nt.Fatalf(\"error reading test cases: %v\", err)\n}\n")
+		b.WriteString("if len(records) == 0 {\nt.Fatal(\"error reading test cases: missing header\")\n}\n\n")
+		fmt.Fprintf(&b, "tests := make(map[string]%s, len(records)-1)\n", caseType)
+		b.WriteString("for _, record := range records[1:] {\n")
+		fmt.Fprintf(&b, "var tc %s\n", caseType)
+		for i, field := range fields {
+			column := fmt.Sprintf("record[%d]", i+1)
+			parse := fixtureTypes[field.typ]
+			fail := fmt.Sprintf("t.Fatalf(\"error parsing %s of case %%q: %%v\", record[0], err)", field.name)
+			switch {
+			case parse.parse == "":
+				fmt.Fprintf(&b, "tc.%s = %s\n", field.name, column)
+			case parse.parse == "Atoi" || parse.parse == "ParseBool":
+				fmt.Fprintf(&b, "if tc.%s, err = strconv.%s(%s); err != nil {\n%s\n}\n", field.name, parse.parse, column, fail)
+			case field.typ == "int64" || field.typ == "uint64" || field.typ == "float64":
+				fmt.Fprintf(&b, "if tc.%s, err = strconv.%s(%s); err != nil {\n%s\n}\n", field.name, parse.parse, parseArgs(column, parse.parse, parse.bits), fail)
+			default:
+				fmt.Fprintf(&b, "if v, err := strconv.%s(%s); err != nil {\n%s\n} else {\ntc.%s = %s(v)\n}\n", parse.parse, parseArgs(column, parse.parse, parse.bits), fail, field.name, field.typ)
+			}
+		}
+		b.WriteString("tests[record[0]] = tc\n}\nreturn tests\n}")
+		return b.String()
+	}
+
+	unmarshal := "json.Unmarshal"
+	if fixture == FixtureYAML {
+		unmarshal = "yaml.Unmarshal"
+	}
+	fmt.Fprintf(&b, "data, err := os.ReadFile(filepath.Join(\"testdata\", %q))\n", dataName)
+	b.WriteString("if err != nil {\nt.Fatalf(\"error reading test cases: %v\", err)\n}\n\n")
+	b.WriteString("var cases map[string]struct {\n")
+	for _, field := range fields {
+		fmt.Fprintf(&b, "%s %s `%s:%q`\n", exportedName(field.name), field.typ, fixture, field.name)
+	}
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "if err := %s(data, &cases); err != nil {\nt.Fatalf(\"error parsing test cases: %%v\", err)\n}\n\n", unmarshal)
+	fmt.Fprintf(&b, "tests := make(map[string]%s, len(cases))\n", caseType)
+	b.WriteString("for name, c := range cases {\n")
+	var values []string
+	for _, field := range fields {
+		values = append(values, fmt.Sprintf("%s: c.%s", field.name, exportedName(field.name)))
+	}
+	fmt.Fprintf(&b, "tests[name] = %s{%s}\n", caseType, strings.Join(values, ", "))
+	b.WriteString("}\nreturn tests\n}")
+	return b.String()
+}
+
+// parseArgs returns the arguments of a strconv parse function for a column
+func parseArgs(column, parse string, bits int) string {
+	if parse == "ParseFloat" {
+		return fmt.Sprintf("%s, %d", column, bits)
+	}
+	return fmt.Sprintf("%s, 10, %d", column, bits)
+}
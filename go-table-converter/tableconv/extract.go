@@ -0,0 +1,239 @@
+package tableconv
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+)
+
+// caseTypeDecl is an anonymous case struct lifted into a named type, declared above the
+// function whose tables use it
+type caseTypeDecl struct {
+	// decl is the index of the function in the declarations of its file
+	decl int
+	// text is the type declaration as written, with the comments of its fields
+	text string
+}
+
+// packageNames returns the names declared at the top level of the files of a package
+func packageNames(files []*sourceFile) map[string]bool {
+	names := make(map[string]bool)
+	for _, file := range files {
+		for _, decl := range file.node.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil {
+					names[decl.Name.Name] = true
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						names[spec.Name.Name] = true
+					case *ast.ValueSpec:
+						for _, name := range spec.Names {
+							names[name.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// extractCaseTypes lifts the anonymous case structs of converted tables into named types
+// declared above their function, so 'map[string]struct{ ... }' becomes
+// 'map[string]TestAddCase'. Tables of a function with identical structs share one type,
+// and names already declared in the package are numbered ('TestAddCase2'). Structs that
+// refer to types or constants declared in the function stay anonymous, since a
+// declaration outside of it can't see them.
+func (c *Converter) extractCaseTypes(fset *token.FileSet, file *sourceFile, converted []*tableCandidate, taken map[string]bool) {
+	extracted := make(map[int]map[string]string)
+	for _, candidate := range converted {
+		table := candidate.table
+		if table.named != nil {
+			continue
+		}
+
+		// Package-level tables have no function to name their type after
+		decl, fn := enclosingFunc(file.node, candidate.lit)
+		if fn == nil {
+			continue
+		}
+
+		value := table.mapType.Value
+		if star, ok := value.(*ast.StarExpr); ok {
+			value = star.X
+		}
+		structType, ok := value.(*ast.StructType)
+		if !ok {
+			continue
+		}
+		if refersToLocals(structType, fn) {
+			c.logf("Skipping case type of %s in %s: it refers to declarations in the function\n", candidate.name(), fn.Name.Name)
+			continue
+		}
+
+		// Identical structs of the same function share their type
+		if extracted[decl] == nil {
+			extracted[decl] = make(map[string]string)
+		}
+		key := nodeString(fset, structType)
+		name, ok := extracted[decl][key]
+		if !ok {
+			name = caseTypeName(fn, taken)
+			taken[name] = true
+			extracted[decl][key] = name
+
+			file.caseTypes = append(file.caseTypes, caseTypeDecl{
+				decl: decl,
+				text: "type " + name + " " + commentedString(fset, file, table.structType, structType),
+			})
+			c.logf("Extracted case type %s from %s\n", name, fn.Name.Name)
+		}
+
+		// Replace the struct in the map types and in cases spelling it out
+		table.mapType.Value = table.elemType(&ast.Ident{NamePos: structType.Pos(), Name: name})
+		if candidate.spec != nil {
+			if mapType, ok := candidate.spec.Type.(*ast.MapType); ok {
+				mapType.Value = table.elemType(&ast.Ident{NamePos: mapType.Value.Pos(), Name: name})
+			}
+		}
+		for _, elt := range candidate.lit.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				continue
+			}
+			if caseLit := caseLiteral(kv.Value); caseLit != nil {
+				if _, ok := caseLit.Type.(*ast.StructType); ok {
+					caseLit.Type = &ast.Ident{NamePos: caseLit.Type.Pos(), Name: name}
+				}
+			}
+		}
+	}
+}
+
+// enclosingFunc returns the function declaration containing a node and its index in the
+// declarations of the file, or nil if the node is declared at package level
+func enclosingFunc(node *ast.File, n ast.Node) (int, *ast.FuncDecl) {
+	for i, decl := range node.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Pos() <= n.Pos() && n.End() <= fn.End() {
+			return i, fn
+		}
+	}
+	return -1, nil
+}
+
+// refersToLocals reports whether the field types of a struct refer to types or constants
+// declared inside a function, including its type parameters
+func refersToLocals(structType *ast.StructType, fn *ast.FuncDecl) bool {
+	local := false
+	for _, field := range structType.Fields.List {
+		ast.Inspect(field.Type, func(n ast.Node) bool {
+			ident, ok := n.(*ast.Ident)
+			if ok && ident.Obj != nil && (ident.Obj.Kind == ast.Typ || ident.Obj.Kind == ast.Con) {
+				if pos := ident.Obj.Pos(); pos >= fn.Pos() && pos < fn.End() {
+					local = true
+				}
+			}
+			return !local
+		})
+	}
+	return local
+}
+
+// caseTypeName names the case type of a function's tables after the function
+// ('TestAdd' gets 'TestAddCase'), numbering it when the name is taken in the package or
+// the function uses it
+func caseTypeName(fn *ast.FuncDecl, taken map[string]bool) string {
+	base := fn.Name.Name + "Case"
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = fmt.Sprintf("%s%d", base, n)
+		}
+		if !taken[name] && !usesName(fn.Body, name) {
+			return name
+		}
+	}
+}
+
+// commentedString prints a case struct along with the comments of its fields. The
+// comments are looked up by the nodes of the original struct, so those of a removed name
+// field are left out.
+func commentedString(fset *token.FileSet, file *sourceFile, original, structType *ast.StructType) string {
+	var groups []*ast.CommentGroup
+	seen := make(map[*ast.CommentGroup]bool)
+	collect := func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		for _, group := range file.comments[n] {
+			if !seen[group] && group.Pos() >= original.Pos() && group.End() <= original.End() {
+				seen[group] = true
+				groups = append(groups, group)
+			}
+		}
+		return true
+	}
+	collect(original)
+	collect(original.Fields)
+	for _, field := range structType.Fields.List {
+		ast.Inspect(field, collect)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Pos() < groups[j].Pos() })
+
+	var buf bytes.Buffer
+	config := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	if err := config.Fprint(&buf, fset, &printer.CommentedNode{Node: structType, Comments: groups}); err != nil {
+		return nodeString(fset, structType)
+	}
+	return buf.String()
+}
+
+// insertCaseTypes adds the extracted case type declarations to a formatted file, each
+// above the doc comment of its function
+func insertCaseTypes(src []byte, decls []caseTypeDecl) ([]byte, error) {
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing converted file: %v", err)
+	}
+
+	// Insert from the end so earlier offsets stay valid, keeping the types of a function
+	// in the order they were extracted
+	sorted := make([]caseTypeDecl, len(decls))
+	copy(sorted, decls)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].decl > sorted[j].decl })
+
+	out := src
+	for i := 0; i < len(sorted); {
+		decl := sorted[i].decl
+		var text []byte
+		for ; i < len(sorted) && sorted[i].decl == decl; i++ {
+			text = append(text, sorted[i].text+"\n\n"...)
+		}
+		if decl >= len(node.Decls) {
+			return nil, fmt.Errorf("error inserting case types: declaration %d not found", decl)
+		}
+
+		pos := node.Decls[decl].Pos()
+		if fn, ok := node.Decls[decl].(*ast.FuncDecl); ok && fn.Doc != nil {
+			pos = fn.Doc.Pos()
+		}
+		offset := fset.Position(pos).Offset
+		out = append(out[:offset:offset], append(text, out[offset:]...)...)
+	}
+
+	formatted, err := format.Source(out)
+	if err != nil {
+		return nil, fmt.Errorf("error formatting case types: %v", err)
+	}
+	return formatted, nil
+}
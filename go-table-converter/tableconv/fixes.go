@@ -0,0 +1,69 @@
+package tableconv
+
+import "strings"
+
+// Edit replaces whole lines of a file: the lines from StartLine up to but not including
+// EndLine, counted from 1, are replaced by NewText, which ends in a newline unless empty.
+// StartLine equals EndLine for insertions.
+type Edit struct {
+	Path      string
+	StartLine int
+	EndLine   int
+	NewText   string
+}
+
+// TableFix converts a single table, as the edits of the files its conversion changes
+type TableFix struct {
+	Table Table
+	Edits []Edit
+}
+
+// FixTables converts the Go files of a single package, given by path and source, without
+// reading or writing files. It returns the result of converting every table, and for each
+// converted table the edits converting it alone, for editors and linters to offer as
+// fixes of the table.
+func (c *Converter) FixTables(paths []string, srcs [][]byte) (ConversionResult, []TableFix) {
+	conv := *c
+	conv.opts.DryRun = true
+	conv.cache = nil
+
+	var result ConversionResult
+	conv.convertSources(paths, srcs, &result)
+
+	var fixes []TableFix
+	for _, table := range result.Tables {
+		single := conv
+		single.opts.TableFilter = func(t Table) bool {
+			return t.Pos == table.Pos
+		}
+		var preview ConversionResult
+		single.convertSources(paths, srcs, &preview)
+
+		fix := TableFix{Table: table}
+		for _, file := range preview.Files {
+			if file.Modified {
+				fix.Edits = append(fix.Edits, file.edits()...)
+			}
+		}
+		if len(fix.Edits) > 0 {
+			fixes = append(fixes, fix)
+		}
+	}
+	return result, fixes
+}
+
+// edits returns the line edits turning the original source of a modified file into the
+// converted source
+func (file FileResult) edits() []Edit {
+	a, b := splitLines(file.src), splitLines(file.out)
+	var edits []Edit
+	for _, hunk := range diffLines(a, b) {
+		edits = append(edits, Edit{
+			Path:      file.Path,
+			StartLine: hunk.aStart + 1,
+			EndLine:   hunk.aEnd + 1,
+			NewText:   strings.Join(b[hunk.bStart:hunk.bEnd], ""),
+		})
+	}
+	return edits
+}
@@ -0,0 +1,549 @@
+package tableconv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fixtureCase is a case read from a fixture, with its field values in the order of the
+// fixture
+type fixtureCase struct {
+	name   string
+	fields []string
+	values []fixtureValue
+}
+
+// fixtureValue is a field value read from a fixture
+type fixtureValue struct {
+	text string
+	// quoted is set for values the fixture holds as strings
+	quoted bool
+	// null is set for JSON nulls, which leave the field at its zero value
+	null bool
+}
+
+// loaderImports are the imports of generated fixture loaders, removed along with the
+// loader once nothing else uses them
+var loaderImports = []string{"encoding/csv", "encoding/json", "os", "path/filepath", "strconv", yamlModule}
+
+// ImportFixture writes the cases of a fixture ('testdata/TestAdd.json') into the test of a
+// file as an inline map-based table, the reverse of ExportTable, and returns the new
+// contents of the file without writing it. The format is told by the extension: .json,
+// .csv, or .yaml (.yml). A test loading its cases with a generated loader gets the table
+// in place of the call, and the loader is removed once unused; a test ranging over a map
+// literal gets the cases whose names the table lacks; a missing test is generated with
+// the table and a loop over it, for which caseType names the case struct. Fields are set
+// to the literals of their values, leaving zero values out.
+func (c *Converter) ImportFixture(fixturePath, path, testName, caseType string) ([]byte, error) {
+	data, err := os.ReadFile(fixturePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fixture: %v", err)
+	}
+	var cases []fixtureCase
+	switch ext := strings.ToLower(filepath.Ext(fixturePath)); ext {
+	case ".json":
+		cases, err = readJSONFixture(data)
+	case ".csv":
+		cases, err = readCSVFixture(data)
+	case ".yaml", ".yml":
+		cases, err = readYAMLFixture(data)
+	default:
+		return nil, fmt.Errorf("unknown fixture format %q: use .json, .csv, or .yaml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", fixturePath, err)
+	}
+
+	fset := token.NewFileSet()
+	files, err := packageFiles(fset, filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	var file *sourceFile
+	for _, f := range files {
+		if sameFile(f.path, path) {
+			file = f
+		}
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%s is not a Go file", path)
+	}
+	var test *ast.FuncDecl
+	for _, decl := range file.node.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == testName && fn.Body != nil {
+			test = fn
+		}
+	}
+
+	offset := func(pos token.Pos) int {
+		return fset.Position(pos).Offset
+	}
+	var edits []sourceEdit
+	switch {
+	// Step 1a: Generate a missing test around the table
+	case test == nil:
+		if caseType == "" {
+			return nil, fmt.Errorf("test %s not found in %s; name its case type to generate it", testName, path)
+		}
+		structType := namedStruct(files, caseType)
+		if structType == nil {
+			return nil, fmt.Errorf("struct type %s not found in the package", caseType)
+		}
+		table, err := caseLiterals(cases, structType, nil)
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "\n\nfunc %s(t *testing.T) {\n", testName)
+		fmt.Fprintf(&b, "tests := map[string]%s{\n%s}\n\n", caseType, table)
+		b.WriteString("for name, tc := range tests {\nt.Run(name, func(t *testing.T) {\n")
+		b.WriteString("// TODO: Call the code under test with the fields of tc and check the results.\n_ = tc\n")
+		b.WriteString("})\n}\n}\n")
+		end := len(bytes.TrimRight(file.src, "\n"))
+		edits = append(edits, sourceEdit{start: end, end: len(file.src), text: b.String()})
+		edits = append(edits, importEdits(fset, file.node, file.src, []string{"testing"})...)
+		c.logf("Generated %s with %d cases in %s\n", testName, len(cases), path)
+
+	// Step 1b: Replace the loader call of a test with the table
+	case loaderCall(test, files) != nil:
+		call := loaderCall(test, files)
+		loader := packageFunc(files, call.Fun.(*ast.Ident).Name)
+		elemType := loader.decl.Type.Results.List[0].Type.(*ast.MapType).Value
+		structType, _ := elemType.(*ast.StructType)
+		if ident, ok := elemType.(*ast.Ident); ok {
+			structType = namedStruct(files, ident.Name)
+		}
+		if structType == nil {
+			return nil, fmt.Errorf("the cases %s loads aren't structs declared in the package", loader.decl.Name.Name)
+		}
+		table, err := caseLiterals(cases, structType, nil)
+		if err != nil {
+			return nil, err
+		}
+		typeText := string(file.src[offset(elemType.Pos()):offset(elemType.End())])
+		if loader.file != file {
+			typeText = nodeString(fset, elemType)
+		}
+		text := fmt.Sprintf("map[string]%s{\n%s}", typeText, table)
+		edits = append(edits, sourceEdit{start: offset(call.Pos()), end: offset(call.End()), text: text})
+
+		// The loader goes when this was its only call
+		if calls(files, loader.decl.Name.Name) == 1 {
+			if loader.file == file {
+				edits = append(edits, declRemoval(fset, gocheckDecl{file, loader.decl}))
+			} else {
+				c.logf("Leaving %s in %s, which would have to be removed by hand\n", loader.decl.Name.Name, loader.file.path)
+			}
+		}
+		c.logf("Inlined %d cases into %s in %s\n", len(cases), testName, path)
+
+	// Step 1c: Extend the map literal the test ranges over
+	default:
+		lit := rangedMapTable(test)
+		if lit == nil {
+			return nil, fmt.Errorf("%s neither loads its cases nor ranges over a map-based table", testName)
+		}
+		elemType := lit.Type.(*ast.MapType).Value
+		structType, _ := elemType.(*ast.StructType)
+		if ident, ok := elemType.(*ast.Ident); ok {
+			structType = namedStruct(files, ident.Name)
+		}
+		if structType == nil {
+			return nil, fmt.Errorf("the cases of %s aren't structs declared in the package", testName)
+		}
+		existing := make(map[string]bool)
+		for _, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				if name, ok := stringLit(kv.Key); ok {
+					existing[name] = true
+				}
+			}
+		}
+		table, err := caseLiterals(cases, structType, existing)
+		if err != nil {
+			return nil, err
+		}
+		if table == "" {
+			return nil, fmt.Errorf("%s already has a case for every case of %s", testName, fixturePath)
+		}
+
+		// A last case on the line of the closing brace may lack its comma and a newline
+		rbrace := offset(lit.Rbrace)
+		start := offset(lit.Lbrace) + 1
+		if len(lit.Elts) > 0 {
+			start = offset(lit.Elts[len(lit.Elts)-1].End())
+			if !bytes.Contains(file.src[start:rbrace], []byte(",")) {
+				edits = append(edits, sourceEdit{start: start, end: start, text: ","})
+			}
+		}
+		if !bytes.Contains(file.src[start:rbrace], []byte("\n")) {
+			table = "\n" + table
+		}
+		edits = append(edits, sourceEdit{start: rbrace, end: rbrace, text: table})
+		c.logf("Added the cases of %s to %s in %s\n", fixturePath, testName, path)
+	}
+
+	// Step 2: Drop the imports of the loader nothing uses anymore
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	src := applyEdits(file.src, edits)
+	node, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing rewritten test: %v", err)
+	}
+	refs := packageRefs(node)
+	removeImports(fset, node, func(spec *ast.ImportSpec) bool {
+		for _, path := range loaderImports {
+			if spec.Path.Value == strconv.Quote(path) && refs[importName(spec)] == 0 {
+				return true
+			}
+		}
+		return false
+	})
+
+	var out bytes.Buffer
+	if err := format.Node(&out, fset, node); err != nil {
+		return nil, fmt.Errorf("error formatting rewritten test: %v", err)
+	}
+	return out.Bytes(), nil
+}
+
+// packageFuncDecl is a function declared by a package, with its file
+type packageFuncDecl struct {
+	file *sourceFile
+	decl *ast.FuncDecl
+}
+
+// packageFunc returns the function of a package with the given name
+func packageFunc(files []*sourceFile, name string) packageFuncDecl {
+	for _, file := range files {
+		for _, decl := range file.node.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == name {
+				return packageFuncDecl{file, fn}
+			}
+		}
+	}
+	return packageFuncDecl{}
+}
+
+// loaderCall returns the call in a test to a function of the package that takes the test's
+// *testing.T alone and returns a map of cases by name, as loaders generated by ExportTable
+// do ('tests := loadTestAddCases(t)'), or nil
+func loaderCall(test *ast.FuncDecl, files []*sourceFile) *ast.CallExpr {
+	var found *ast.CallExpr
+	ast.Inspect(test.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || found != nil || len(call.Args) != 1 {
+			return found == nil
+		}
+		ident, ok := call.Fun.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		fn := packageFunc(files, ident.Name).decl
+		if fn == nil || testingParam(fn.Type, "T") == "" || fn.Type.Results == nil || len(fn.Type.Results.List) != 1 {
+			return true
+		}
+		if mapType, ok := fn.Type.Results.List[0].Type.(*ast.MapType); ok {
+			if key, ok := mapType.Key.(*ast.Ident); ok && key.Name == "string" {
+				found = call
+			}
+		}
+		return found == nil
+	})
+	return found
+}
+
+// calls counts the calls to a function of a package
+func calls(files []*sourceFile, name string) int {
+	count := 0
+	for _, file := range files {
+		ast.Inspect(file.node, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if ident, ok := call.Fun.(*ast.Ident); ok && ident.Name == name {
+					count++
+				}
+			}
+			return true
+		})
+	}
+	return count
+}
+
+// caseLiterals returns the elements of a table literal holding the cases of a fixture, one
+// per line ('"one": {a: 1, want: 1},'), leaving out the cases named in skip
+func caseLiterals(cases []fixtureCase, structType *ast.StructType, skip map[string]bool) (string, error) {
+	types := make(map[string]string)
+	for _, field := range structType.Fields.List {
+		typ := ""
+		if ident, ok := field.Type.(*ast.Ident); ok {
+			typ = ident.Name
+		}
+		for _, name := range field.Names {
+			types[name.Name] = typ
+		}
+	}
+
+	var b strings.Builder
+	for _, fc := range cases {
+		if skip[fc.name] {
+			continue
+		}
+		var elts []string
+		for i, field := range fc.fields {
+			typ, ok := types[field]
+			if !ok {
+				return "", fmt.Errorf("case %q: the case struct has no field %s", fc.name, field)
+			}
+			if _, ok := fixtureTypes[typ]; !ok {
+				return "", fmt.Errorf("case %q: field %s isn't of a predeclared string, boolean, or numeric type", fc.name, field)
+			}
+			literal, zero, err := goLiteral(fc.values[i], typ)
+			if err != nil {
+				return "", fmt.Errorf("case %q: field %s: %v", fc.name, field, err)
+			}
+			if !zero {
+				elts = append(elts, field+": "+literal)
+			}
+		}
+		fmt.Fprintf(&b, "%s: {%s},\n", strconv.Quote(fc.name), strings.Join(elts, ", "))
+	}
+	return b.String(), nil
+}
+
+// goLiteral returns the Go literal of a fixture value for a field of the given type, and
+// whether it is the zero value
+func goLiteral(value fixtureValue, typ string) (string, bool, error) {
+	if value.null {
+		return "", true, nil
+	}
+	parse := fixtureTypes[typ]
+	switch parse.parse {
+	case "":
+		return strconv.Quote(value.text), value.text == "", nil
+	case "ParseBool":
+		v, err := strconv.ParseBool(value.text)
+		if err != nil {
+			return "", false, fmt.Errorf("%q isn't a valid bool", value.text)
+		}
+		return strconv.FormatBool(v), !v, nil
+	case "ParseFloat":
+		v, err := strconv.ParseFloat(value.text, max(parse.bits, 32))
+		if err != nil {
+			return "", false, fmt.Errorf("%q isn't a valid %s", value.text, typ)
+		}
+		return strconv.FormatFloat(v, 'g', -1, max(parse.bits, 32)), v == 0, nil
+	case "ParseUint":
+		v, err := strconv.ParseUint(value.text, 10, parse.bits)
+		if err != nil {
+			return "", false, fmt.Errorf("%q isn't a valid %s", value.text, typ)
+		}
+		return strconv.FormatUint(v, 10), v == 0, nil
+	}
+	v, err := strconv.ParseInt(value.text, 10, parse.bits)
+	if err != nil {
+		return "", false, fmt.Errorf("%q isn't a valid %s", value.text, typ)
+	}
+	return strconv.FormatInt(v, 10), v == 0, nil
+}
+
+// readJSONFixture reads an object of cases by name, each an object of scalar field
+// values, keeping the order of the cases and their fields
+func readJSONFixture(data []byte) ([]fixtureCase, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	delim := func(want json.Delim) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if tok != want {
+			return fmt.Errorf("expected %v, found %v", want, tok)
+		}
+		return nil
+	}
+
+	if err := delim('{'); err != nil {
+		return nil, err
+	}
+	var cases []fixtureCase
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		fc := fixtureCase{name: tok.(string)}
+		if err := delim('{'); err != nil {
+			return nil, fmt.Errorf("case %q: %v", fc.name, err)
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			field := tok.(string)
+			if tok, err = dec.Token(); err != nil {
+				return nil, err
+			}
+			var value fixtureValue
+			switch tok := tok.(type) {
+			case string:
+				value = fixtureValue{text: tok, quoted: true}
+			case json.Number:
+				value = fixtureValue{text: tok.String()}
+			case bool:
+				value = fixtureValue{text: strconv.FormatBool(tok)}
+			case nil:
+				value = fixtureValue{null: true}
+			default:
+				return nil, fmt.Errorf("case %q: field %s isn't a string, number, or boolean", fc.name, field)
+			}
+			fc.fields = append(fc.fields, field)
+			fc.values = append(fc.values, value)
+		}
+		if err := delim('}'); err != nil {
+			return nil, err
+		}
+		cases = append(cases, fc)
+	}
+	return cases, delim('}')
+}
+
+// readCSVFixture reads a header row naming the fields after a name column, and a row per
+// case
+func readCSVFixture(data []byte) ([]fixtureCase, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("missing header")
+	}
+
+	var cases []fixtureCase
+	for _, record := range records[1:] {
+		fc := fixtureCase{name: record[0], fields: records[0][1:]}
+		for _, text := range record[1:] {
+			fc.values = append(fc.values, fixtureValue{text: text, quoted: true})
+		}
+		cases = append(cases, fc)
+	}
+	return cases, nil
+}
+
+// readYAMLFixture reads a mapping of cases by name, each a block mapping of scalar field
+// values, as ExportTable writes them. Only that subset of YAML is understood: scalars are
+// plain, single-quoted, or double-quoted, and lines holding only comments are skipped.
+func readYAMLFixture(data []byte) ([]fixtureCase, error) {
+	var cases []fixtureCase
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+
+		key, value, err := yamlPair(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if text[0] != ' ' && text[0] != '\t' {
+			if value.text != "" || value.quoted {
+				return nil, fmt.Errorf("line %d: case %q isn't a mapping", line, key)
+			}
+			cases = append(cases, fixtureCase{name: key})
+			continue
+		}
+		if len(cases) == 0 {
+			return nil, fmt.Errorf("line %d: field %s outside of a case", line, key)
+		}
+		fc := &cases[len(cases)-1]
+		if !value.quoted && (value.text == "~" || value.text == "null") {
+			value = fixtureValue{null: true}
+		}
+		fc.fields = append(fc.fields, key)
+		fc.values = append(fc.values, value)
+	}
+	return cases, scanner.Err()
+}
+
+// yamlPair splits a 'key: value' line of a YAML mapping, unquoting both
+func yamlPair(line string) (string, fixtureValue, error) {
+	key, rest, err := yamlScalar(line, true)
+	if err != nil {
+		return "", fixtureValue{}, err
+	}
+	rest = strings.TrimSpace(rest)
+	if !strings.HasPrefix(rest, ":") {
+		return "", fixtureValue{}, fmt.Errorf("expected a key and a value")
+	}
+	rest = strings.TrimSpace(rest[1:])
+	quoted := strings.HasPrefix(rest, `"`) || strings.HasPrefix(rest, "'")
+	value, rest, err := yamlScalar(rest, false)
+	if err != nil {
+		return "", fixtureValue{}, err
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fixtureValue{}, fmt.Errorf("unexpected %q after the value", rest)
+	}
+	return key, fixtureValue{text: value, quoted: quoted}, nil
+}
+
+// yamlScalar reads a scalar from the start of s, returning it and the rest of s. Plain keys
+// end at ': ' and plain values at ' #'.
+func yamlScalar(s string, isKey bool) (string, string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+				continue
+			}
+			if s[i] == '"' {
+				value, err := strconv.Unquote(s[:i+1])
+				if err != nil {
+					return "", "", fmt.Errorf("invalid double-quoted scalar %s", s[:i+1])
+				}
+				return value, s[i+1:], nil
+			}
+		}
+		return "", "", fmt.Errorf("unterminated double-quoted scalar")
+	case strings.HasPrefix(s, "'"):
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				b.WriteByte(s[i])
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			return b.String(), s[i+1:], nil
+		}
+		return "", "", fmt.Errorf("unterminated single-quoted scalar")
+	}
+
+	end := len(s)
+	if isKey {
+		if i := strings.Index(s, ":"); i >= 0 {
+			end = i
+		}
+	} else if i := strings.Index(s, " #"); i >= 0 {
+		end = i
+	}
+	return strings.TrimSpace(s[:end]), s[end:], nil
+}
@@ -0,0 +1,166 @@
+package tableconv
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// FlakySignal is a call in a table test that makes its outcome depend on more than its
+// cases: the wall clock, real time passing, an unseeded random source, or the network.
+// Such tests are the likeliest to break once their cases run in map order or in parallel.
+type FlakySignal struct {
+	// Pos is the position of the call
+	Pos token.Position
+	// Case is the case whose literal holds the call, empty for calls in the loop, which
+	// every case makes
+	Case string
+	// Call is the function called ('time.Now')
+	Call string
+	// Reason tells why the call makes the test flaky
+	Reason string
+}
+
+// flakyFuncs are the functions signaling flakiness by import path and name, with the
+// reason given for them
+var flakyFuncs = map[string]map[string]string{
+	"time": {
+		"Now":      "reads the wall clock",
+		"Since":    "reads the wall clock",
+		"Until":    "reads the wall clock",
+		"Sleep":    "waits for real time to pass",
+		"After":    "waits for real time to pass",
+		"Tick":     "waits for real time to pass",
+		"NewTimer": "waits for real time to pass",
+	},
+	"net": {
+		"Dial":           "uses the network",
+		"DialTimeout":    "uses the network",
+		"DialTCP":        "uses the network",
+		"DialUDP":        "uses the network",
+		"Listen":         "uses the network",
+		"ListenPacket":   "uses the network",
+		"LookupHost":     "uses the network",
+		"LookupIP":       "uses the network",
+		"LookupAddr":     "uses the network",
+		"ResolveTCPAddr": "uses the network",
+	},
+	"net/http": {
+		"Get":            "uses the network",
+		"Head":           "uses the network",
+		"Post":           "uses the network",
+		"PostForm":       "uses the network",
+		"ListenAndServe": "uses the network",
+	},
+}
+
+// unseededRand are the functions of math/rand and math/rand/v2 that don't draw from the
+// global source
+var unseededRand = map[string]bool{"New": true, "NewSource": true, "NewPCG": true, "NewChaCha8": true, "NewZipf": true, "Seed": true}
+
+// flakySignals finds the calls signaling flakiness in the cases of a table and the loop
+// ranging over it. Functions of math/rand drawing from the global source count unless
+// the test seeds it with rand.Seed; those of math/rand/v2 can't be seeded and always
+// count.
+func flakySignals(fset *token.FileSet, node *ast.File, fn *ast.FuncDecl, lit *ast.CompositeLit, rangeStmt *ast.RangeStmt, structType *ast.StructType) []FlakySignal {
+	// Step 1: Resolve the names the file imports the packages by
+	paths := importPaths(node)
+	seeded := false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if pkg, name := calledFunc(call, paths); pkg == "math/rand" && name == "Seed" {
+				seeded = true
+			}
+		}
+		return true
+	})
+
+	var signals []FlakySignal
+	inspect := func(n ast.Node, caseName string) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			pkg, name := calledFunc(call, paths)
+			reason := flakyFuncs[pkg][name]
+			switch {
+			case pkg == "math/rand" && !unseededRand[name] && !seeded:
+				reason = "draws from the unseeded global random source"
+			case pkg == "math/rand/v2" && !unseededRand[name]:
+				reason = "draws from the global random source, which can't be seeded"
+			}
+			if reason != "" {
+				sel := call.Fun.(*ast.SelectorExpr)
+				signals = append(signals, FlakySignal{
+					Pos:    fset.Position(call.Pos()),
+					Case:   caseName,
+					Call:   sel.X.(*ast.Ident).Name + "." + name,
+					Reason: reason,
+				})
+			}
+			return true
+		})
+	}
+
+	// Step 2: Look in each case, then in the loop
+	nameField, nameIndex := findNameField(structType)
+	for i, elt := range lit.Elts {
+		inspect(elt, tableCaseName(elt, nameField, nameIndex, i))
+	}
+	inspect(rangeStmt.Body, "")
+	return signals
+}
+
+// importPaths maps the names a file imports packages by to their import paths
+func importPaths(node *ast.File) map[string]string {
+	paths := make(map[string]string)
+	for _, spec := range node.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+			paths[importName(spec)] = path
+		}
+	}
+	return paths
+}
+
+// calledFunc returns the import path and name of the package-level function a call calls
+// ('time.Now'), or empty strings for calls of anything else
+func calledFunc(call *ast.CallExpr, paths map[string]string) (string, string) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", ""
+	}
+	x, ok := sel.X.(*ast.Ident)
+	if !ok || x.Obj != nil || paths[x.Name] == "" {
+		return "", ""
+	}
+	return paths[x.Name], sel.Sel.Name
+}
+
+// tableCaseName names a case of a table by its map key or the string literal of its name
+// field, keyed or at nameIndex, or else by its position ('case 3')
+func tableCaseName(elt ast.Expr, nameField string, nameIndex, index int) string {
+	if kv, ok := elt.(*ast.KeyValueExpr); ok {
+		if name, ok := stringLit(kv.Key); ok {
+			return name
+		}
+		elt = kv.Value
+	}
+	if lit := caseLiteral(elt); lit != nil && nameField != "" {
+		for i, field := range lit.Elts {
+			if kv, ok := field.(*ast.KeyValueExpr); ok {
+				if key, ok := kv.Key.(*ast.Ident); ok && key.Name == nameField {
+					field = kv.Value
+				} else {
+					continue
+				}
+			} else if i != nameIndex {
+				continue
+			}
+			if name, ok := stringLit(field); ok {
+				return name
+			}
+		}
+	}
+	return "case " + strconv.Itoa(index+1)
+}
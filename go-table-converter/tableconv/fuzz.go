@@ -0,0 +1,248 @@
+package tableconv
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"strings"
+)
+
+// fuzzTypes are the parameter types native fuzzing supports, besides []byte
+var fuzzTypes = map[string]bool{
+	"string": true, "bool": true, "byte": true, "rune": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true,
+}
+
+// fuzzInput is a table field that becomes a parameter of the fuzz target
+type fuzzInput struct {
+	name string
+	// typ is the type as written in Go source
+	typ string
+	// index is the position of the field in positional case literals
+	index int
+}
+
+// GenerateFuzz adds a FuzzXxx function for the table test TestXxx to Go source. The fuzzer
+// is seeded with the cases of the test's table, slice- or map-based, through the fields
+// of types native fuzzing supports; the case name and the expected results are left out.
+// The body of the fuzz target is left for the developer to fill in.
+func GenerateFuzz(src []byte, testName string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parseSourceFile(fset, "", src)
+	if err != nil {
+		return nil, err
+	}
+
+	var fn *ast.FuncDecl
+	fuzzName := "Fuzz" + strings.TrimPrefix(testName, "Test")
+	for _, decl := range file.node.Decls {
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcDecl.Recv == nil {
+			switch funcDecl.Name.Name {
+			case testName:
+				fn = funcDecl
+			case fuzzName:
+				return nil, fmt.Errorf("%s already exists", fuzzName)
+			}
+		}
+	}
+	if fn == nil || fn.Body == nil {
+		return nil, fmt.Errorf("test %s not found", testName)
+	}
+
+	types := collectNamedTypes([]*sourceFile{file})
+	lit, structType := findCaseTable(fn.Body, types)
+	if lit == nil {
+		return nil, fmt.Errorf("no table of struct cases found in %s", testName)
+	}
+
+	inputs := fuzzInputs(fset, structType)
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("the cases of %s have no input fields of types supported by fuzzing", testName)
+	}
+
+	// Build the fuzz function as source so it can be spliced in after the test
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "\n\n// %s seeds the fuzzer with the cases of %s\n", fuzzName, testName)
+	fmt.Fprintf(&buf, "func %s(f *testing.F) {\n", fuzzName)
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			elt = kv.Value
+		}
+		caseLit, ok := elt.(*ast.CompositeLit)
+		if !ok {
+			continue
+		}
+
+		var args []string
+		for _, input := range inputs {
+			args = append(args, seedValue(fset, caseValue(caseLit, input), input.typ))
+		}
+		fmt.Fprintf(&buf, "\tf.Add(%s)\n", strings.Join(args, ", "))
+	}
+
+	var params []string
+	for _, input := range inputs {
+		// The fuzz target's own parameter is called t
+		name := input.name
+		if name == "t" {
+			name = "tValue"
+		}
+		params = append(params, name+" "+input.typ)
+	}
+	fmt.Fprintf(&buf, "\tf.Fuzz(func(t *testing.T, %s) {\n", strings.Join(params, ", "))
+	fmt.Fprintf(&buf, "\t\t// TODO: exercise the code under test with the fuzzed inputs, as %s does\n", testName)
+	fmt.Fprintf(&buf, "\t})\n}")
+
+	end := fset.Position(fn.End()).Offset
+	out := append(append(append([]byte{}, src[:end]...), buf.Bytes()...), src[end:]...)
+
+	out, err = format.Source(out)
+	if err != nil {
+		return nil, fmt.Errorf("error formatting file: %v", err)
+	}
+	return out, nil
+}
+
+// findCaseTable finds the first slice or map literal of struct cases in a function body
+func findCaseTable(body *ast.BlockStmt, types *namedTypes) (*ast.CompositeLit, *ast.StructType) {
+	var table *ast.CompositeLit
+	var structType *ast.StructType
+	ast.Inspect(body, func(n ast.Node) bool {
+		if table != nil {
+			return false
+		}
+
+		lit, ok := n.(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+
+		var elt ast.Expr
+		switch typ := lit.Type.(type) {
+		case *ast.ArrayType:
+			if typ.Len == nil {
+				elt = typ.Elt
+			}
+		case *ast.MapType:
+			elt = typ.Value
+		}
+
+		switch elt := elt.(type) {
+		case *ast.StructType:
+			table, structType = lit, elt
+		case *ast.Ident:
+			if named := types.lookup(elt.Name); named != nil {
+				table, structType = lit, named.structType
+			}
+		}
+		return table == nil
+	})
+
+	return table, structType
+}
+
+// fuzzInputs returns the fields of a case struct that can seed a fuzzer
+func fuzzInputs(fset *token.FileSet, structType *ast.StructType) []fuzzInput {
+	nameField, _ := findNameField(structType)
+
+	var inputs []fuzzInput
+	index := 0
+	for _, field := range structType.Fields.List {
+		typ := nodeString(fset, field.Type)
+		names := field.Names
+		if len(names) == 0 {
+			// Embedded fields take a position but are never inputs
+			index++
+			continue
+		}
+
+		for _, name := range names {
+			if (fuzzTypes[typ] || typ == "[]byte") && name.Name != nameField && !isExpectation(name.Name) {
+				inputs = append(inputs, fuzzInput{name: name.Name, typ: typ, index: index})
+			}
+			index++
+		}
+	}
+
+	return inputs
+}
+
+// isExpectation reports whether a field name looks like it holds an expected result
+func isExpectation(name string) bool {
+	lower := strings.ToLower(name)
+	switch lower {
+	case "exp", "err", "error", "golden", "result":
+		return true
+	}
+	return strings.HasPrefix(lower, "want") || strings.HasPrefix(lower, "expect")
+}
+
+// caseValue returns the value a case literal gives an input field, nil when omitted
+func caseValue(caseLit *ast.CompositeLit, input fuzzInput) ast.Expr {
+	for i, elt := range caseLit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			if i == input.index {
+				return elt
+			}
+			continue
+		}
+
+		if key, ok := kv.Key.(*ast.Ident); ok && key.Name == input.name {
+			return kv.Value
+		}
+	}
+	return nil
+}
+
+// seedValue returns the source of an f.Add argument for a field value. f.Add needs values
+// of exactly the fuzz parameter types, so values whose untyped default would differ are
+// converted; omitted values become the zero value.
+func seedValue(fset *token.FileSet, value ast.Expr, typ string) string {
+	if value == nil {
+		switch {
+		case typ == "string":
+			return `""`
+		case typ == "bool":
+			return "false"
+		case typ == "[]byte":
+			return "[]byte(nil)"
+		case typ == "int":
+			return "0"
+		default:
+			return typ + "(0)"
+		}
+	}
+
+	text := nodeString(fset, value)
+
+	// Untyped constants default to int, float64, string, and bool
+	lit := value
+	if unary, ok := lit.(*ast.UnaryExpr); ok && unary.Op == token.SUB {
+		lit = unary.X
+	}
+	if basic, ok := lit.(*ast.BasicLit); ok {
+		switch {
+		case typ == "int" && basic.Kind == token.INT,
+			typ == "float64" && basic.Kind == token.FLOAT,
+			typ == "string" && basic.Kind == token.STRING,
+			(typ == "rune" || typ == "int32") && basic.Kind == token.CHAR:
+			return text
+		}
+	}
+	if ident, ok := value.(*ast.Ident); ok && typ == "bool" && (ident.Name == "true" || ident.Name == "false") {
+		return text
+	}
+	if comp, ok := value.(*ast.CompositeLit); ok && typ == "[]byte" && nodeString(fset, comp.Type) == "[]byte" {
+		return text
+	}
+	if call, ok := value.(*ast.CallExpr); ok && nodeString(fset, call.Fun) == typ {
+		return text
+	}
+
+	return typ + "(" + text + ")"
+}
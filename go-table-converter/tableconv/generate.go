@@ -0,0 +1,398 @@
+package tableconv
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// comparableTypes are the predeclared types whose values are compared with != in
+// generated tests
+var comparableTypes = map[string]bool{
+	"string": true, "bool": true, "byte": true, "rune": true, "uintptr": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true, "complex64": true, "complex128": true,
+}
+
+// skeletonField is a field of the case struct of a generated test
+type skeletonField struct {
+	name string
+	typ  string
+}
+
+// GenerateTest generates a map-based table test skeleton for a function of the package in
+// dir, given by name ('Add') or, for methods, by receiver type and name ('Calc.Add'). The
+// cases get a field for the receiver, for each parameter, and for each result, with a
+// wantErr flag for a trailing error, and a case named after the condition of each if and
+// switch branch of the function, its inputs left for the developer to fill in. A test that
+// exists already gets the cases of the branches its map-based table has no case for
+// instead. The test is added to the _test.go file next to the function's file, which is
+// created when missing; GenerateTest returns its path and new contents without writing it.
+// The Parallel, CmpDiff, Assertions, and ExtractTypes options shape the test like
+// converted ones.
+func (c *Converter) GenerateTest(dir, name string) (string, []byte, error) {
+	fset := token.NewFileSet()
+	fn, file, err := findFunc(fset, dir, name)
+	if err != nil {
+		return "", nil, err
+	}
+	if fn.Type.TypeParams != nil {
+		return "", nil, fmt.Errorf("%s is generic, which generated tests don't support", name)
+	}
+
+	path := strings.TrimSuffix(file.path, ".go") + "_test.go"
+	var testFile *sourceFile
+	if _, err := os.Stat(path); err == nil {
+		if testFile, err = parseSourceFile(fset, path, nil); err != nil {
+			return "", nil, err
+		}
+		if testFile.node.Name.Name != file.node.Name.Name {
+			return "", nil, fmt.Errorf("%s belongs to package %s", path, testFile.node.Name.Name)
+		}
+	}
+
+	// Names already declared by the package, its tests included, aren't reused
+	taken := make(map[string]bool)
+	files, err := packageFiles(fset, dir)
+	if err != nil {
+		return "", nil, err
+	}
+	for declared := range packageNames(files) {
+		taken[declared] = true
+	}
+	testName := "Test" + strings.ReplaceAll(name, ".", "_")
+	if taken[testName] {
+		return c.extendTest(fset, files, fn, testName)
+	}
+	taken[testName] = true
+
+	var test bytes.Buffer
+	imports := c.skeleton(&test, fset, file, fn, testName, taken)
+
+	// A new file imports the standard library in a group of its own
+	var buf bytes.Buffer
+	if testFile != nil {
+		buf.Write(bytes.TrimRight(testFile.src, "\n"))
+		buf.WriteString("\n\n")
+	} else {
+		fmt.Fprintf(&buf, "package %s\n\nimport (\n", file.node.Name.Name)
+		for _, std := range []bool{true, false} {
+			for _, spec := range imports {
+				path, _ := strconv.Unquote(spec.Path.Value)
+				if stdPath(path) != std {
+					continue
+				}
+				if spec.Name != nil {
+					fmt.Fprintf(&buf, "%s ", spec.Name.Name)
+				}
+				fmt.Fprintf(&buf, "%s\n", spec.Path.Value)
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(")\n\n")
+	}
+	buf.Write(test.Bytes())
+
+	node, err := parser.ParseFile(fset, path, buf.Bytes(), parser.ParseComments)
+	if err != nil {
+		return "", nil, fmt.Errorf("error parsing generated test: %v", err)
+	}
+	imported := make(map[string]bool)
+	for _, spec := range node.Imports {
+		imported[spec.Path.Value] = true
+	}
+	for _, spec := range imports {
+		if imported[spec.Path.Value] {
+			continue
+		}
+		path, _ := strconv.Unquote(spec.Path.Value)
+		addImport(fset, node, path)
+		if spec.Name != nil {
+			node.Imports[len(node.Imports)-1].Name = &ast.Ident{Name: spec.Name.Name}
+		}
+	}
+
+	var out bytes.Buffer
+	if err := format.Node(&out, fset, node); err != nil {
+		return "", nil, fmt.Errorf("error formatting generated test: %v", err)
+	}
+	c.logf("Generated %s in %s\n", testName, path)
+	return path, out.Bytes(), nil
+}
+
+// findFunc finds a function of the package in dir by name, or a method by receiver type
+// and name ('Calc.Add'), along with its file
+func findFunc(fset *token.FileSet, dir, name string) (*ast.FuncDecl, *sourceFile, error) {
+	recv, funcName, isMethod := strings.Cut(name, ".")
+	if !isMethod {
+		recv, funcName = "", name
+	}
+
+	files, err := packageFiles(fset, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file.path, "_test.go") {
+			continue
+		}
+		for _, decl := range file.node.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Name.Name != funcName || (fn.Recv == nil) != (recv == "") {
+				continue
+			}
+			if recv == "" || receiverType(fn) == recv {
+				return fn, file, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("function %s not found in %s", name, dir)
+}
+
+// packageFiles parses the Go files of a directory
+func packageFiles(fset *token.FileSet, dir string) ([]*sourceFile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing files: %v", err)
+	}
+	sort.Strings(paths)
+
+	var files []*sourceFile
+	for _, path := range paths {
+		file, err := parseSourceFile(fset, path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// skeleton writes the test of a function to buf and returns the imports it needs
+func (c *Converter) skeleton(buf *bytes.Buffer, fset *token.FileSet, file *sourceFile, fn *ast.FuncDecl, testName string, taken map[string]bool) []*ast.ImportSpec {
+	typeText := func(expr ast.Expr) string {
+		return nodeString(fset, expr)
+	}
+
+	// Step 1: Derive the fields from the receiver, the parameters, and the results
+	var inputs, outputs []skeletonField
+	var types []ast.Expr
+	used := make(map[string]bool)
+	field := func(name string) string {
+		for n := 1; used[name]; n++ {
+			name = fmt.Sprintf("%s%d", strings.TrimRight(name, "0123456789"), n)
+		}
+		used[name] = true
+		return name
+	}
+
+	var call string
+	if fn.Recv != nil {
+		recv := fn.Recv.List[0].Type
+		types = append(types, recv)
+		inputs = append(inputs, skeletonField{field("recv"), typeText(recv)})
+		call = "tc.recv."
+	}
+	call += fn.Name.Name
+
+	fields := branchFields{params: make(map[string]string)}
+	var args []string
+	index := 0
+	for _, param := range fn.Type.Params.List {
+		types = append(types, param.Type)
+		names := param.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{nil}
+		}
+		for _, paramName := range names {
+			index++
+			name := fmt.Sprintf("arg%d", index)
+			if paramName != nil && paramName.Name != "_" {
+				name = paramName.Name
+			}
+			name = field(name)
+			if paramName != nil {
+				fields.params[paramName.Name] = name
+			}
+
+			if ellipsis, ok := param.Type.(*ast.Ellipsis); ok {
+				inputs = append(inputs, skeletonField{name, "[]" + typeText(ellipsis.Elt)})
+				args = append(args, "tc."+name+"...")
+				continue
+			}
+			inputs = append(inputs, skeletonField{name, typeText(param.Type)})
+			args = append(args, "tc."+name)
+		}
+	}
+	call += "(" + strings.Join(args, ", ") + ")"
+
+	var results []ast.Expr
+	if fn.Type.Results != nil {
+		for _, result := range fn.Type.Results.List {
+			for range max(len(result.Names), 1) {
+				results = append(results, result.Type)
+			}
+		}
+	}
+	returnsErr := false
+	if len(results) > 0 {
+		if ident, ok := results[len(results)-1].(*ast.Ident); ok && ident.Name == "error" {
+			returnsErr = true
+			results = results[:len(results)-1]
+		}
+	}
+	var gots []string
+	for i, result := range results {
+		types = append(types, result)
+		want, got := "want", "got"
+		if i > 0 {
+			want, got = fmt.Sprintf("want%d", i), fmt.Sprintf("got%d", i)
+		}
+		want = field(want)
+		outputs = append(outputs, skeletonField{want, typeText(result)})
+		fields.results = append(fields.results, want)
+		gots = append(gots, got)
+	}
+	var wantErr string
+	if returnsErr {
+		wantErr = field("wantErr")
+		outputs = append(outputs, skeletonField{wantErr, "bool"})
+		fields.wantErr = wantErr
+	}
+
+	// Step 2: Pick the assertion style the module supports
+	moduleDir := filepath.Dir(file.path)
+	assertions := c.opts.Assertions
+	if assertions == AssertionsTestify && !moduleRequires(moduleDir, testifyModule) {
+		c.logf("Using standard assertions: the module doesn't require %s\n", testifyModule)
+		assertions = AssertionsStd
+	}
+	cmpDiff := c.opts.CmpDiff && assertions != AssertionsTestify
+	if cmpDiff && !moduleRequires(moduleDir, cmpModule) {
+		c.logf("Using reflect.DeepEqual: the module doesn't require %s\n", cmpModule)
+		cmpDiff = false
+	}
+	paths := []string{"testing"}
+
+	// Step 3: Write the table and the loop over it
+	caseType := "struct {\n"
+	for _, f := range append(inputs, outputs...) {
+		caseType += fmt.Sprintf("%s %s\n", f.name, f.typ)
+	}
+	caseType += "}"
+	if c.opts.ExtractTypes {
+		typeName := testName + "Case"
+		for n := 2; taken[typeName]; n++ {
+			typeName = fmt.Sprintf("%sCase%d", testName, n)
+		}
+		taken[typeName] = true
+		fmt.Fprintf(buf, "type %s %s\n\n", typeName, caseType)
+		caseType = typeName
+	}
+
+	fmt.Fprintf(buf, "func %s(t *testing.T) {\n", testName)
+	if c.opts.Parallel {
+		buf.WriteString("t.Parallel()\n\n")
+	}
+	fmt.Fprintf(buf, "tests := map[string]%s{\n", caseType)
+	if cases := branchCases(fset, fn); len(cases) > 0 {
+		writeBranchCases(buf, cases, fields)
+	} else {
+		buf.WriteString("// TODO: Add test cases.\n")
+	}
+	buf.WriteString("}\n\n")
+	buf.WriteString("for name, tc := range tests {\n")
+	buf.WriteString("t.Run(name, func(t *testing.T) {\n")
+	if c.opts.Parallel {
+		buf.WriteString("t.Parallel()\n\n")
+	}
+
+	lhs := append([]string{}, gots...)
+	if returnsErr {
+		lhs = append(lhs, "err")
+	}
+	if len(lhs) > 0 {
+		fmt.Fprintf(buf, "%s := %s\n", strings.Join(lhs, ", "), call)
+	} else {
+		fmt.Fprintf(buf, "%s\n", call)
+	}
+
+	label := fn.Name.Name + "()"
+	if returnsErr {
+		switch assertions {
+		case AssertionsTestify:
+			fmt.Fprintf(buf, "if tc.%s {\nassert.Error(t, err)\nreturn\n}\nrequire.NoError(t, err)\n", wantErr)
+			paths = append(paths, testifyPackages["assert"], testifyPackages["require"])
+		default:
+			fmt.Fprintf(buf, "if (err != nil) != tc.%s {\nt.Fatalf(\"%s error = %%v, wantErr %%v\", err, tc.%s)\n}\n", wantErr, label, wantErr)
+			if len(gots) > 0 {
+				buf.WriteString("if err != nil {\nreturn\n}\n")
+			}
+		}
+	}
+	for i, got := range gots {
+		want := "tc." + outputs[i].name
+		label := label
+		if i > 0 {
+			label += " " + got
+		}
+		switch {
+		case assertions == AssertionsTestify:
+			fmt.Fprintf(buf, "assert.Equal(t, %s, %s)\n", want, got)
+			paths = append(paths, testifyPackages["assert"])
+		case cmpDiff:
+			fmt.Fprintf(buf, "if diff := cmp.Diff(%s, %s); diff != \"\" {\nt.Errorf(\"%s mismatch (-want +got):\\n%%s\", diff)\n}\n", want, got, label)
+			paths = append(paths, cmpPackage)
+		case comparableType(results[i]):
+			fmt.Fprintf(buf, "if %s != %s {\nt.Errorf(\"%s = %%v, want %%v\", %s, %s)\n}\n", got, want, label, got, want)
+		default:
+			fmt.Fprintf(buf, "if !reflect.DeepEqual(%s, %s) {\nt.Errorf(\"%s = %%v, want %%v\", %s, %s)\n}\n", got, want, label, got, want)
+			paths = append(paths, "reflect")
+		}
+	}
+	buf.WriteString("})\n}\n}\n")
+
+	// Step 4: Import the packages of the test and of the types it spells out
+	var imports []*ast.ImportSpec
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if value := strconv.Quote(path); !seen[value] {
+			seen[value] = true
+			imports = append(imports, &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: value}})
+		}
+	}
+	refs := make(map[string]bool)
+	for _, typ := range types {
+		for name := range packageRefs(&ast.File{Decls: []ast.Decl{&ast.GenDecl{Tok: token.TYPE, Specs: []ast.Spec{&ast.TypeSpec{Name: ast.NewIdent("_"), Type: typ}}}}}) {
+			refs[name] = true
+		}
+	}
+	for _, spec := range file.node.Imports {
+		if refs[importName(spec)] && !seen[spec.Path.Value] {
+			seen[spec.Path.Value] = true
+			imports = append(imports, spec)
+		}
+	}
+	return imports
+}
+
+// comparableType reports whether values of a type are compared with != in generated
+// tests: predeclared types other than interfaces, and pointers
+func comparableType(typ ast.Expr) bool {
+	switch typ := typ.(type) {
+	case *ast.Ident:
+		return comparableTypes[typ.Name]
+	case *ast.StarExpr:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,641 @@
+package tableconv
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ginkgoPackages are the import paths of the packages providing Ginkgo's DSL, including
+// the table extension of Ginkgo v1
+var ginkgoPackages = map[string]bool{
+	"github.com/onsi/ginkgo":                  true,
+	"github.com/onsi/ginkgo/v2":               true,
+	"github.com/onsi/ginkgo/extensions/table": true,
+}
+
+// gomegaPackage is the package providing the assertions of Ginkgo specs
+const gomegaPackage = "github.com/onsi/gomega"
+
+// ginkgoDSL are the names Ginkgo's packages declare, which dot imports bring into scope
+var ginkgoDSL = map[string]bool{
+	"Describe": true, "Context": true, "When": true, "It": true, "Specify": true, "By": true,
+	"FDescribe": true, "FContext": true, "FWhen": true, "FIt": true, "FSpecify": true,
+	"PDescribe": true, "PContext": true, "PWhen": true, "PIt": true, "PSpecify": true,
+	"XDescribe": true, "XContext": true, "XWhen": true, "XIt": true, "XSpecify": true,
+	"DescribeTable": true, "FDescribeTable": true, "PDescribeTable": true, "XDescribeTable": true,
+	"Entry": true, "FEntry": true, "PEntry": true, "XEntry": true,
+	"BeforeEach": true, "AfterEach": true, "JustBeforeEach": true, "JustAfterEach": true,
+	"BeforeAll": true, "AfterAll": true, "BeforeSuite": true, "AfterSuite": true,
+	"SynchronizedBeforeSuite": true, "SynchronizedAfterSuite": true, "DeferCleanup": true,
+	"RunSpecs": true, "RegisterFailHandler": true, "Fail": true, "Skip": true, "Label": true,
+	"Ordered": true, "Serial": true, "Focus": true, "Pending": true, "Offset": true,
+	"GinkgoT": true, "GinkgoWriter": true, "GinkgoHelper": true, "GinkgoRecover": true,
+}
+
+// ginkgoContainers are the Ginkgo containers whose tables are migrated
+var ginkgoContainers = map[string]bool{"Describe": true, "Context": true, "When": true}
+
+// ginkgoSetup are the Ginkgo nodes running code around the specs of a container, which
+// the tables in it could depend on
+var ginkgoSetup = map[string]bool{
+	"BeforeEach": true, "AfterEach": true, "JustBeforeEach": true, "JustAfterEach": true,
+	"BeforeAll": true, "AfterAll": true,
+}
+
+// gomegaAssertions are the gomega functions starting an assertion, which testing.T tests
+// call on the Gomega returned by NewWithT instead
+var gomegaAssertions = map[string]bool{
+	"Expect": true, "Ω": true, "Eventually": true, "Consistently": true,
+	"ExpectWithOffset": true, "EventuallyWithOffset": true, "ConsistentlyWithOffset": true,
+}
+
+// ginkgoImports are the names a file refers to the Ginkgo and gomega packages by
+type ginkgoImports struct {
+	// dot is set when a Ginkgo package is dot imported
+	dot bool
+	// names are the names Ginkgo packages are imported by otherwise
+	names map[string]bool
+	// gomega is the name gomega is imported by, "." for a dot import
+	gomega string
+}
+
+// ginkgoImportsOf returns the names a file refers to the Ginkgo and gomega packages by
+func ginkgoImportsOf(node *ast.File) ginkgoImports {
+	imports := ginkgoImports{names: make(map[string]bool)}
+	for _, spec := range node.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := importName(spec)
+		switch {
+		case ginkgoPackages[path] && name == ".":
+			imports.dot = true
+		case ginkgoPackages[path]:
+			imports.names[name] = true
+		case path == gomegaPackage:
+			imports.gomega = name
+		}
+	}
+	return imports
+}
+
+// dsl returns the name of the Ginkgo function an expression refers to, or ""
+func (g ginkgoImports) dsl(expr ast.Expr) string {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		if g.dot && x.Obj == nil && ginkgoDSL[x.Name] {
+			return x.Name
+		}
+	case *ast.SelectorExpr:
+		if pkg, ok := x.X.(*ast.Ident); ok && pkg.Obj == nil && g.names[pkg.Name] {
+			return x.Sel.Name
+		}
+	}
+	return ""
+}
+
+// assertion returns the name of the gomega assertion function an expression refers to,
+// or ""
+func (g ginkgoImports) assertion(expr ast.Expr) string {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		if g.gomega == "." && x.Obj == nil && gomegaAssertions[x.Name] {
+			return x.Name
+		}
+	case *ast.SelectorExpr:
+		if pkg, ok := x.X.(*ast.Ident); ok && pkg.Obj == nil && g.gomega != "" && pkg.Name == g.gomega && gomegaAssertions[x.Sel.Name] {
+			return x.Sel.Name
+		}
+	}
+	return ""
+}
+
+// used reports whether a file still refers to a Ginkgo package
+func (g ginkgoImports) used(node *ast.File) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if expr, ok := n.(ast.Expr); ok && g.dsl(expr) != "" {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// migrateGinkgoPackage rewrites the Ginkgo tables of the files of a package into table
+// tests, removing the Ginkgo imports of files left without specs
+func (c *Converter) migrateGinkgoPackage(fset *token.FileSet, files []*sourceFile) {
+	taken := packageNames(files)
+	for _, file := range files {
+		if file.result.Generated {
+			continue
+		}
+
+		src, tables := c.migrateGinkgo(fset, file, taken)
+		if len(tables) == 0 {
+			continue
+		}
+
+		node, err := parser.ParseFile(fset, file.path, src, parser.ParseComments)
+		if err != nil {
+			c.logf("Skipping Ginkgo tables in %s: %v\n", file.path, err)
+			continue
+		}
+		if imports := ginkgoImportsOf(node); !imports.used(node) {
+			removeImports(fset, node, func(spec *ast.ImportSpec) bool {
+				path, _ := strconv.Unquote(spec.Path.Value)
+				if ginkgoPackages[path] {
+					c.logf("Removed Ginkgo import %s from %s\n", spec.Path.Value, file.path)
+					return true
+				}
+				return false
+			})
+		}
+
+		file.node = node
+		file.parsed = src
+		file.comments = ast.NewCommentMap(fset, node, node.Comments)
+		file.tabulated = append(file.tabulated, tables...)
+	}
+}
+
+// ginkgoMigration holds the rewrite of the Ginkgo tables of a file
+type ginkgoMigration struct {
+	parsedSource
+	c       *Converter
+	node    *ast.File
+	imports ginkgoImports
+	taken   map[string]bool
+
+	// decl is the declaration holding the specs being migrated
+	decl ast.Decl
+	// funcs are the test functions generated from the tables of decl
+	funcs  []string
+	edits  []sourceEdit
+	tables []Table
+}
+
+// migrateGinkgo rewrites the Ginkgo tables of a file, such as
+//
+//	var _ = Describe("Add", func() {
+//		DescribeTable("sums",
+//			func(a, b, want int) {
+//				Expect(Add(a, b)).To(Equal(want))
+//			},
+//			Entry("small numbers", 1, 2, 3),
+//		)
+//	})
+//
+// into map-based table tests named after the descriptions of the table and its containers
+// (TestAddSums), keyed by the entry descriptions, with a field per parameter of the table
+// function and its body run in t.Run subtests, with gomega assertions made on
+// NewWithT(t). Tables in containers with setup nodes such as BeforeEach, using variables
+// of their containers, or using Ginkgo functions other than GinkgoT, are left alone, as
+// are tables whose entries carry decorators. Containers left empty are removed, and the
+// generated tests are declared after the specs they came from. It returns the rewritten
+// source and the generated tables.
+func (c *Converter) migrateGinkgo(fset *token.FileSet, file *sourceFile, taken map[string]bool) ([]byte, []Table) {
+	m := &ginkgoMigration{
+		parsedSource: parsedSource{fset, file.parsed},
+		c:            c,
+		node:         file.node,
+		imports:      ginkgoImportsOf(file.node),
+		taken:        taken,
+	}
+	if !m.imports.dot && len(m.imports.names) == 0 {
+		return nil, nil
+	}
+
+	for _, decl := range file.node.Decls {
+		call := specsCall(decl)
+		if call == nil {
+			continue
+		}
+
+		m.decl = decl
+		m.funcs = nil
+		if m.spec(call, nil, nil) {
+			// Nothing is left of the specs, which the tests replace
+			start, end := lineRange(m.src, m.offset(decl.Pos()), m.offset(decl.End()))
+			m.edits = append(m.edits, sourceEdit{start: start, end: end, text: strings.Join(m.funcs, "\n\n") + "\n"})
+		} else if len(m.funcs) > 0 {
+			end := m.offset(decl.End())
+			m.edits = append(m.edits, sourceEdit{start: end, end: end, text: "\n\n" + strings.Join(m.funcs, "\n\n")})
+		}
+	}
+
+	if len(m.tables) > 0 {
+		m.edits = append(m.edits, testingImport(fset, file.node, m.src)...)
+	}
+
+	sort.Slice(m.edits, func(i, j int) bool { return m.edits[i].start < m.edits[j].start })
+	return applyEdits(m.src, m.edits), m.tables
+}
+
+// specsCall returns the call declaring the specs of a 'var _ = Describe(...)' declaration
+func specsCall(decl ast.Decl) *ast.CallExpr {
+	gen, ok := decl.(*ast.GenDecl)
+	if !ok || gen.Tok != token.VAR || len(gen.Specs) != 1 {
+		return nil
+	}
+	spec := gen.Specs[0].(*ast.ValueSpec)
+	if len(spec.Names) != 1 || spec.Names[0].Name != "_" || len(spec.Values) != 1 {
+		return nil
+	}
+	call, _ := spec.Values[0].(*ast.CallExpr)
+	return call
+}
+
+// spec migrates the tables below a Ginkgo call, given the descriptions of the containers
+// around it and its leading comment. It reports whether the call is a migrated table or a
+// container left without specs, which the caller removes.
+func (m *ginkgoMigration) spec(call *ast.CallExpr, descs []string, doc *ast.CommentGroup) bool {
+	name := m.imports.dsl(call.Fun)
+	if name == "DescribeTable" {
+		return m.table(call, descs, doc)
+	}
+	if !ginkgoContainers[name] || len(call.Args) < 2 {
+		return false
+	}
+	desc, ok := stringLit(call.Args[0])
+	body, isFunc := call.Args[len(call.Args)-1].(*ast.FuncLit)
+	if !ok || !isFunc || len(body.Body.List) == 0 {
+		return false
+	}
+
+	// Setup nodes run around every spec of the container, and nothing runs them for tests
+	for _, stmt := range body.Body.List {
+		if inner := stmtCall(stmt); inner != nil && ginkgoSetup[m.imports.dsl(inner.Fun)] {
+			m.c.logf("Skipping Ginkgo tables in %q: the container has a %s node\n", desc, m.imports.dsl(inner.Fun))
+			return false
+		}
+	}
+
+	var removed []sourceEdit
+	for i, stmt := range body.Body.List {
+		prevEnd := body.Body.Lbrace
+		if i > 0 {
+			prevEnd = body.Body.List[i-1].End()
+		}
+		inner := stmtCall(stmt)
+		comment := leadingComment(m.fset, m.node, prevEnd, stmt)
+		if inner == nil || !m.spec(inner, append(descs[:len(descs):len(descs)], desc), comment) {
+			continue
+		}
+
+		start := stmt.Pos()
+		if comment != nil {
+			start = comment.Pos()
+		}
+		from, to := lineRange(m.src, m.offset(start), m.offset(stmt.End()))
+		removed = append(removed, sourceEdit{start: from, end: to})
+	}
+
+	if len(removed) == len(body.Body.List) {
+		return true
+	}
+	m.edits = append(m.edits, removed...)
+	return false
+}
+
+// table generates the test replacing a DescribeTable call, reporting whether it did
+func (m *ginkgoMigration) table(call *ast.CallExpr, descs []string, doc *ast.CommentGroup) bool {
+	if len(call.Args) < 3 {
+		return false
+	}
+	desc, ok := stringLit(call.Args[0])
+	if !ok {
+		return false
+	}
+	skip := func(reason string) bool {
+		m.c.logf("Skipping Ginkgo table %q: %s\n", desc, reason)
+		return false
+	}
+
+	body, ok := call.Args[1].(*ast.FuncLit)
+	if !ok {
+		return skip("the table has decorators or a named function")
+	}
+	if body.Type.Results != nil && len(body.Type.Results.List) > 0 {
+		return skip("the table function returns values")
+	}
+	var params []*ast.Ident
+	for _, field := range body.Type.Params.List {
+		if _, variadic := field.Type.(*ast.Ellipsis); variadic || len(field.Names) == 0 {
+			return skip("the table function has variadic or unnamed parameters")
+		}
+		params = append(params, field.Names...)
+	}
+
+	var entries []*ast.CallExpr
+	for _, arg := range call.Args[2:] {
+		entry, ok := arg.(*ast.CallExpr)
+		if !ok || m.imports.dsl(entry.Fun) != "Entry" || entry.Ellipsis.IsValid() || len(entry.Args) != 1+len(params) {
+			return skip("an entry is focused, pending, decorated, or doesn't match the table function")
+		}
+		if _, ok := stringLit(entry.Args[0]); !ok {
+			return skip("an entry has no literal description")
+		}
+		entries = append(entries, entry)
+	}
+
+	if refersToOuter(call, m.decl) {
+		return skip("the table uses variables of its containers")
+	}
+	if usesIdents(body.Body, "t", "tc", "name", "tests", "g") {
+		return skip("the table function uses t, tc, name, tests, or g")
+	}
+	inline := false
+	ast.Inspect(body.Body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			if name := m.imports.dsl(call.Fun); name != "" && (name != "GinkgoT" || len(call.Args) > 0) {
+				inline = true
+			}
+		}
+		return !inline
+	})
+	if inline {
+		return skip("the table function calls Ginkgo functions other than GinkgoT")
+	}
+
+	keys, ok := m.entryKeys(entries)
+	if !ok {
+		return skip("entries share a description")
+	}
+
+	name := testFuncName(append(descs[:len(descs):len(descs)], desc), m.taken)
+	table := Table{Name: "tests", Func: name, Pos: m.fset.Position(call.Pos())}
+	if m.c.opts.TableFilter != nil && !m.c.opts.TableFilter(table) {
+		return false
+	}
+
+	m.c.logf("Migrated Ginkgo table %q to %s\n", desc, name)
+	m.funcs = append(m.funcs, m.testFunc(name, doc, body, params, entries, keys))
+	m.tables = append(m.tables, table)
+	return true
+}
+
+// entryKeys returns the map keys of the entries of a table: their descriptions, numbered
+// ("negative values #2") when entries share a description and SuffixDuplicates is set. It
+// reports false when entries share a description otherwise.
+func (m *ginkgoMigration) entryKeys(entries []*ast.CallExpr) ([]string, bool) {
+	keys := make([]string, len(entries))
+	seen := make(map[string]int)
+	for i, entry := range entries {
+		lit := entry.Args[0].(*ast.BasicLit)
+		desc, _ := strconv.Unquote(lit.Value)
+		keys[i] = lit.Value
+		if seen[desc]++; seen[desc] > 1 {
+			if !m.c.opts.SuffixDuplicates {
+				return nil, false
+			}
+			keys[i] = strconv.Quote(fmt.Sprintf("%s #%d", desc, seen[desc]))
+		}
+	}
+	return keys, true
+}
+
+// testFunc returns the source of the test replacing a table. Comments between entries
+// move along with the cases.
+func (m *ginkgoMigration) testFunc(name string, doc *ast.CommentGroup, body *ast.FuncLit, params []*ast.Ident, entries []*ast.CallExpr, keys []string) string {
+	var buf bytes.Buffer
+	if doc != nil {
+		fmt.Fprintf(&buf, "%s\n", m.text(doc))
+	}
+	fmt.Fprintf(&buf, "func %s(t *testing.T) {\n", name)
+	buf.WriteString("tests := map[string]struct {\n")
+	for _, field := range body.Type.Params.List {
+		fmt.Fprintf(&buf, "%s\n", m.text(field))
+	}
+	buf.WriteString("}{\n")
+
+	for i, entry := range entries {
+		prevEnd := body.End()
+		if i > 0 {
+			prevEnd = entries[i-1].End()
+		}
+		var leading, trailing []string
+		for _, group := range m.node.Comments {
+			switch {
+			case group.Pos() > prevEnd && group.End() < entry.Pos() && m.line(group.Pos()) > m.line(prevEnd):
+				leading = append(leading, m.text(group)+"\n")
+			case group.Pos() > entry.End() && m.line(group.Pos()) == m.line(entry.End()):
+				trailing = append(trailing, " "+m.text(group))
+			}
+		}
+
+		var values []string
+		for _, arg := range entry.Args[1:] {
+			values = append(values, m.text(arg))
+		}
+		fmt.Fprintf(&buf, "%s%s: {%s},%s\n", strings.Join(leading, ""), keys[i], strings.Join(values, ", "), strings.Join(trailing, ""))
+	}
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("for name, tc := range tests {\n")
+	buf.WriteString("t.Run(name, func(t *testing.T) {\n")
+
+	// Assertions are made on a Gomega failing the subtest
+	var edits []sourceEdit
+	base := m.offset(body.Body.Lbrace) + 1
+	replace := func(node ast.Node, text string) {
+		edits = append(edits, sourceEdit{start: m.offset(node.Pos()) - base, end: m.offset(node.End()) - base, text: text})
+	}
+	ast.Inspect(body.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if m.imports.dsl(call.Fun) == "GinkgoT" {
+			replace(call, "t")
+			return false
+		}
+		if name := m.imports.assertion(call.Fun); name != "" {
+			replace(call.Fun, "g."+name)
+		}
+		return true
+	})
+	if len(edits) > 0 && m.imports.gomega != "" {
+		newWithT := "NewWithT"
+		if m.imports.gomega != "." {
+			newWithT = m.imports.gomega + "." + newWithT
+		}
+		fmt.Fprintf(&buf, "g := %s(t)\n", newWithT)
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	// Parameters are read from the case, unless the function never used them
+	var names, values []string
+	for _, param := range params {
+		if param.Name != "_" && usesObject(body.Body, param.Obj) {
+			names = append(names, param.Name)
+			values = append(values, "tc."+param.Name)
+		}
+	}
+	if len(names) > 0 {
+		fmt.Fprintf(&buf, "%s := %s\n", strings.Join(names, ", "), strings.Join(values, ", "))
+	}
+
+	fmt.Fprintf(&buf, "%s\n", bytes.TrimSpace(applyEdits(m.src[base:m.offset(body.Body.Rbrace)], edits)))
+	buf.WriteString("})\n}\n}")
+	return buf.String()
+}
+
+// line returns the line of a position in the source
+func (m *ginkgoMigration) line(pos token.Pos) int {
+	return m.fset.Position(pos).Line
+}
+
+// stmtCall returns the call of an expression statement, or nil
+func stmtCall(stmt ast.Stmt) *ast.CallExpr {
+	exprStmt, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return nil
+	}
+	call, _ := exprStmt.X.(*ast.CallExpr)
+	return call
+}
+
+// stringLit returns the value of a string literal
+func stringLit(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	return value, err == nil
+}
+
+// leadingComment returns the comment on the lines right above a statement, after prevEnd
+func leadingComment(fset *token.FileSet, node *ast.File, prevEnd token.Pos, stmt ast.Stmt) *ast.CommentGroup {
+	line := fset.Position(stmt.Pos()).Line
+	for _, group := range node.Comments {
+		if group.Pos() > prevEnd && group.End() < stmt.Pos() && fset.Position(group.End()).Line == line-1 {
+			return group
+		}
+	}
+	return nil
+}
+
+// lineRange widens a range of the source to the whole lines holding it, with their line
+// break, when nothing else is on them
+func lineRange(src []byte, start, end int) (int, int) {
+	from, to := start, end
+	for from > 0 && (src[from-1] == ' ' || src[from-1] == '\t') {
+		from--
+	}
+	for to < len(src) && (src[to] == ' ' || src[to] == '\t') {
+		to++
+	}
+	if (from > 0 && src[from-1] != '\n') || (to < len(src) && src[to] != '\n') {
+		return start, end
+	}
+	if to < len(src) {
+		to++
+	}
+	return from, to
+}
+
+// refersToOuter reports whether a node refers to objects declared in the declaration
+// around it but outside the node itself, such as variables its containers share
+func refersToOuter(node ast.Node, decl ast.Decl) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok || ident.Obj == nil {
+			return !found
+		}
+		if declNode, ok := ident.Obj.Decl.(ast.Node); ok {
+			inDecl := declNode.Pos() >= decl.Pos() && declNode.End() <= decl.End()
+			inNode := declNode.Pos() >= node.Pos() && declNode.End() <= node.End()
+			if inDecl && !inNode {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// usesObject reports whether a node refers to an object
+func usesObject(node ast.Node, obj *ast.Object) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && obj != nil && ident.Obj == obj {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// testFuncName names a test after descriptions of what it tests ("Add", "sums small
+// numbers" is TestAddSumsSmallNumbers), numbering it when the package declares the name
+// already
+func testFuncName(descs []string, taken map[string]bool) string {
+	var b strings.Builder
+	b.WriteString("Test")
+	for _, desc := range descs {
+		words := strings.FieldsFunc(desc, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			r, size := utf8.DecodeRuneInString(word)
+			b.WriteRune(unicode.ToUpper(r))
+			b.WriteString(word[size:])
+		}
+	}
+
+	name := b.String()
+	for n := 2; taken[name]; n++ {
+		name = fmt.Sprintf("%s%d", b.String(), n)
+	}
+	taken[name] = true
+	return name
+}
+
+// testingImport returns the edit importing the testing package into a file that doesn't
+// import it yet, in a group of its own above the imports of other modules. A file without
+// imports gets it once printed.
+func testingImport(fset *token.FileSet, node *ast.File, src []byte) []sourceEdit {
+	var decl *ast.GenDecl
+	for _, d := range node.Decls {
+		if d, ok := d.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			decl = d
+			break
+		}
+	}
+	for _, spec := range node.Imports {
+		if spec.Path.Value == `"testing"` {
+			return nil
+		}
+	}
+	if decl == nil || len(decl.Specs) == 0 {
+		return nil
+	}
+
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+	text := "\t\"testing\"\n"
+	first := decl.Specs[0].(*ast.ImportSpec)
+	if path, _ := strconv.Unquote(first.Path.Value); !stdPath(path) {
+		text += "\n"
+	}
+	if !decl.Lparen.IsValid() {
+		start, end := offset(decl.Pos()), offset(decl.End())
+		return []sourceEdit{{start: start, end: end, text: "import (\n" + text + "\t" + string(src[offset(first.Pos()):end]) + "\n)"}}
+	}
+	start, _ := lineRange(src, offset(first.Pos()), offset(first.End()))
+	if first.Doc != nil {
+		start, _ = lineRange(src, offset(first.Doc.Pos()), offset(first.Doc.End()))
+	}
+	return []sourceEdit{{start: start, end: start, text: text}}
+}
@@ -0,0 +1,246 @@
+package tableconv
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// git runs a git command in dir and returns its trimmed standard output
+func git(dir string, args ...string) (string, error) {
+	out, err := gitOutput(dir, nil, args...)
+	return strings.TrimSpace(string(out)), err
+}
+
+// gitOutput runs a git command in dir with the given standard input, which may be nil,
+// and returns its standard output
+func gitOutput(dir string, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// gitNames runs a git subcommand listing paths in dir with -z and returns the paths, split
+// on the NUL bytes ending each, so that names with spaces or quotes come through unchanged
+func gitNames(dir string, args ...string) ([]string, error) {
+	out, err := gitOutput(dir, nil, slices.Insert(args, 1, "-z")...)
+	if err != nil {
+		return nil, err
+	}
+	names := strings.Split(string(out), "\x00")
+	return names[:len(names)-1], nil
+}
+
+// ChangedFiles returns the absolute paths of the files in the git repository containing
+// dir that changed since the merge base of ref and HEAD: files touched by commits on the
+// current branch, uncommitted changes, and untracked files. Deleted files are left out.
+func ChangedFiles(dir, ref string) ([]string, error) {
+	root, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := git(dir, "merge-base", ref, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := gitNames(root, "diff", "--name-only", "--diff-filter=ACMR", base)
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := gitNames(root, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, name := range append(changed, untracked...) {
+		files = append(files, filepath.Join(root, filepath.FromSlash(name)))
+	}
+
+	return files, nil
+}
+
+// FileSet returns a filter for Options.FileFilter that accepts exactly the given files
+func FileSet(paths []string) func(path string) bool {
+	set := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil {
+			set[abs] = true
+		}
+	}
+
+	return func(path string) bool {
+		abs, err := filepath.Abs(path)
+		return err == nil && set[abs]
+	}
+}
+
+// stagedFile is a file staged in the git index
+type stagedFile struct {
+	// path is the absolute path of the file in the working tree
+	path string
+	// root is the root of the repository holding the file
+	root string
+	// name is the path of the file relative to root, as git reports it
+	name string
+	// mode is the file mode recorded in the index
+	mode string
+	src  []byte
+}
+
+// ConvertStaged converts the versions of the Go files under dir that are staged in the git
+// index rather than the files in the working tree, so it works as a pre-commit hook even
+// when files are only partially staged. Unless DryRun is set, converted files are staged
+// again; their working tree copy is only rewritten when it matches the staged version, so
+// unstaged changes are never lost.
+func (c *Converter) ConvertStaged(dir string) (ConversionResult, error) {
+	result := ConversionResult{}
+
+	files, err := c.stagedFiles(dir)
+	if err != nil {
+		return result, err
+	}
+
+	// Convert without writing, since the working tree may not hold the staged versions
+	staged := *c
+	staged.opts.DryRun = true
+
+	var dirs []string
+	byDir := make(map[string][]*stagedFile)
+	for _, file := range files {
+		d := filepath.Dir(file.path)
+		if _, ok := byDir[d]; !ok {
+			dirs = append(dirs, d)
+		}
+		byDir[d] = append(byDir[d], file)
+	}
+
+	for _, d := range dirs {
+		var paths []string
+		var srcs [][]byte
+		for _, file := range byDir[d] {
+			paths = append(paths, file.path)
+			srcs = append(srcs, file.src)
+		}
+		staged.convertSources(paths, srcs, &result)
+	}
+
+	if c.opts.DryRun {
+		return result, nil
+	}
+
+	filesByPath := make(map[string]*stagedFile, len(files))
+	for _, file := range files {
+		filesByPath[file.path] = file
+	}
+	for _, fileResult := range result.Files {
+		if !fileResult.Modified {
+			continue
+		}
+		if err := c.restage(filesByPath[fileResult.Path], fileResult.out); err != nil {
+			result.Errors = append(result.Errors, FileError{fileResult.Path, err.Error()})
+		}
+	}
+
+	return result, nil
+}
+
+// stagedFiles reads the staged versions of the added or modified Go files under dir
+func (c *Converter) stagedFiles(dir string) ([]*stagedFile, error) {
+	root, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := gitNames(dir, "diff", "--cached", "--name-only", "--diff-filter=ACMR", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*stagedFile
+	for _, name := range names {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if !c.wantFile(path) || c.inSkippedDir(dir, path) {
+			continue
+		}
+
+		// Each line reads "<mode> <object> <stage>\t<path>"
+		entry, err := git(root, "--literal-pathspecs", "ls-files", "--stage", "--", name)
+		if err != nil {
+			return nil, err
+		}
+		mode, _, _ := strings.Cut(entry, " ")
+
+		src, err := gitOutput(root, nil, "show", ":"+name)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, &stagedFile{path: path, root: root, name: name, mode: mode, src: src})
+	}
+
+	return files, nil
+}
+
+// restage writes the converted source of a staged file to the git index, and to the
+// working tree when the working tree copy has no unstaged changes
+func (c *Converter) restage(file *stagedFile, out []byte) error {
+	object, err := gitOutput(file.root, out, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return fmt.Errorf("error storing converted file: %v", err)
+	}
+
+	info := fmt.Sprintf("%s,%s,%s", file.mode, strings.TrimSpace(string(object)), file.name)
+	if _, err := git(file.root, "update-index", "--cacheinfo", info); err != nil {
+		return fmt.Errorf("error staging converted file: %v", err)
+	}
+
+	worktree, err := os.ReadFile(file.path)
+	if err != nil || !bytes.Equal(worktree, file.src) {
+		c.logf("Staged converted file, leaving unstaged changes in the working tree: %s\n", file.path)
+		return nil
+	}
+
+	if err := writeFileAtomic(file.path, out); err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+	return nil
+}
+
+// inSkippedDir reports whether a file lies in a directory below dir that ConvertDir
+// would not descend into
+func (c *Converter) inSkippedDir(dir, path string) bool {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(abs, filepath.Dir(path))
+	if err != nil || rel == "." {
+		return false
+	}
+
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		if c.skipDir(name) {
+			return true
+		}
+	}
+	return false
+}
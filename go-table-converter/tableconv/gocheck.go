@@ -0,0 +1,596 @@
+package tableconv
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gocheckPackage is the import path of gocheck
+const gocheckPackage = "gopkg.in/check.v1"
+
+// gocheckNames are the names gocheck declares, which dot imports bring into scope
+var gocheckNames = map[string]bool{
+	"C": true, "Suite": true, "TestingT": true, "Run": true, "RunAll": true, "RunConf": true,
+	"Equals": true, "DeepEquals": true, "IsNil": true, "NotNil": true, "HasLen": true,
+	"ErrorMatches": true, "Matches": true, "Panics": true, "PanicMatches": true,
+	"FitsTypeOf": true, "Implements": true, "Not": true, "Commentf": true,
+	"Checker": true, "CheckerInfo": true, "CommentInterface": true,
+}
+
+// gocheckTMethods maps the methods of gocheck's C to the testing.T methods replacing them
+var gocheckTMethods = map[string]string{
+	"Log": "Log", "Logf": "Logf", "Error": "Error", "Errorf": "Errorf",
+	"Fatal": "Fatal", "Fatalf": "Fatalf", "Fail": "Fail", "FailNow": "FailNow",
+	"Failed": "Failed", "Skip": "Skip", "Skipf": "Skipf", "SkipNow": "SkipNow",
+	"MkDir": "TempDir",
+}
+
+// gocheckFixtures are the methods gocheck runs around the tests of a suite
+var gocheckFixtures = map[string]bool{
+	"SetUpSuite": true, "TearDownSuite": true, "SetUpTest": true, "TearDownTest": true,
+}
+
+// gocheckSuite is a gocheck suite of a package, registered with 'var _ = Suite(&S{})'
+type gocheckSuite struct {
+	name          string
+	registrations []gocheckDecl
+	methods       []gocheckDecl
+	// migrated counts the methods rewritten into test functions
+	migrated int
+}
+
+// gocheckDecl is a declaration of a file
+type gocheckDecl struct {
+	file *sourceFile
+	decl ast.Decl
+}
+
+// gocheckName returns the name a file refers to gocheck by, "." for a dot import and ""
+// when the file doesn't import it
+func gocheckName(node *ast.File) string {
+	for _, spec := range node.Imports {
+		if spec.Path.Value == strconv.Quote(gocheckPackage) {
+			return importName(spec)
+		}
+	}
+	return ""
+}
+
+// gocheckRef returns the name of the gocheck identifier an expression refers to, given the
+// name gocheck is imported by, or ""
+func gocheckRef(expr ast.Expr, pkg string) string {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		if pkg == "." && x.Obj == nil && gocheckNames[x.Name] {
+			return x.Name
+		}
+	case *ast.SelectorExpr:
+		if ident, ok := x.X.(*ast.Ident); ok && pkg != "" && pkg != "." && ident.Obj == nil && ident.Name == pkg {
+			return x.Sel.Name
+		}
+	case *ast.StarExpr:
+		return gocheckRef(x.X, pkg)
+	}
+	return ""
+}
+
+// migrateGocheckPackage rewrites the test methods of the gocheck suites of a package, such as
+//
+//	func (s *MathSuite) TestAdd(c *C) {
+//		c.Assert(Add(1, 2), Equals, 3)
+//	}
+//
+// into test functions named after the suite and the method:
+//
+//	func TestMathAdd(t *testing.T) {
+//		if got := Add(1, 2); got != 3 {
+//			t.Fatalf("got %v, want %v", got, 3)
+//		}
+//	}
+//
+// c.Assert becomes t.Fatalf and c.Check t.Errorf; the Equals, DeepEquals, IsNil, NotNil,
+// and HasLen checkers are supported, with a Commentf comment leading the message, and the
+// methods of C shared with testing.T are called on t. Methods using their suite, passing
+// c on, checking the untyped nil, or using other checkers are left alone and reported, as
+// are suites with fixtures such as SetUpTest. Suites left without methods are removed
+// along with their registration, and the 'func Test(t *testing.T) { TestingT(t) }' hook
+// once no suite is left. The loops of the migrated tests are converted afterwards like any
+// other.
+func (c *Converter) migrateGocheckPackage(fset *token.FileSet, files []*sourceFile) {
+	// Step 1: Find the registered suites and their methods
+	suites := make(map[string]*gocheckSuite)
+	var names []string
+	suite := func(name string) *gocheckSuite {
+		if suites[name] == nil {
+			suites[name] = &gocheckSuite{name: name}
+			names = append(names, name)
+		}
+		return suites[name]
+	}
+	var hooks []gocheckDecl
+	for _, file := range files {
+		// Suites can have methods in files that don't import gocheck
+		pkg := gocheckName(file.node)
+		if file.result.Generated {
+			continue
+		}
+		for _, decl := range file.node.Decls {
+			if call := specsCall(decl); call != nil && gocheckRef(call.Fun, pkg) == "Suite" && len(call.Args) == 1 {
+				if name := suiteType(call.Args[0]); name != "" {
+					s := suite(name)
+					s.registrations = append(s.registrations, gocheckDecl{file, decl})
+				}
+				continue
+			}
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			if fn.Recv == nil {
+				if gocheckHook(fn, pkg) {
+					hooks = append(hooks, gocheckDecl{file, fn})
+				}
+				continue
+			}
+			if name := receiverType(fn); name != "" {
+				s := suite(name)
+				s.methods = append(s.methods, gocheckDecl{file, fn})
+			}
+		}
+	}
+
+	// Step 2: Rewrite the test methods of the suites without fixtures
+	taken := packageNames(files)
+	edits := make(map[*sourceFile][]sourceEdit)
+	migrated := make(map[*sourceFile]bool)
+	for _, name := range names {
+		s := suites[name]
+		if len(s.registrations) == 0 {
+			continue
+		}
+		fixtures := false
+		for _, method := range s.methods {
+			if gocheckFixtures[method.decl.(*ast.FuncDecl).Name.Name] {
+				fixtures = true
+			}
+		}
+		if fixtures {
+			c.logf("Skipping gocheck suite %s: it has fixtures\n", name)
+			for _, registration := range s.registrations {
+				file := registration.file
+				file.skipTable(Table{Name: name, Pos: fset.Position(registration.decl.Pos())}, SkipGocheckFixtures)
+				file.result.TablesSkipped++
+			}
+			continue
+		}
+
+		for _, method := range s.methods {
+			fn := method.decl.(*ast.FuncDecl)
+			if !strings.HasPrefix(fn.Name.Name, "Test") {
+				continue
+			}
+			m := &gocheckMigration{parsedSource: parsedSource{fset, method.file.parsed}, pkg: gocheckName(method.file.node)}
+			prefix := strings.TrimSuffix(name, "Suite")
+			if len(prefix) <= 1 {
+				prefix = ""
+			}
+			testName := testFuncName([]string{prefix, strings.TrimPrefix(fn.Name.Name, "Test")}, taken)
+			methodEdits, err := m.method(fn, testName)
+			if err != nil {
+				delete(taken, testName)
+				c.logf("Skipping gocheck test %s.%s: %v\n", name, fn.Name.Name, err)
+				method.file.result.Diagnostics = append(method.file.result.Diagnostics, Diagnostic{
+					Table:   name,
+					Pos:     fset.Position(fn.Pos()),
+					Message: fmt.Sprintf("gocheck test %s.%s not migrated: %v", name, fn.Name.Name, err),
+				})
+				continue
+			}
+			c.logf("Migrated gocheck test %s.%s to %s\n", name, fn.Name.Name, testName)
+			edits[method.file] = append(edits[method.file], methodEdits...)
+			migrated[method.file] = true
+			s.migrated++
+		}
+	}
+
+	// Step 3: Remove the suites left without methods, and the hook once no suite is left
+	left, removed := 0, 0
+	for _, name := range names {
+		s := suites[name]
+		if len(s.registrations) == 0 {
+			continue
+		}
+		if s.migrated < len(s.methods) || typeRefs(files, name) > 0 {
+			left++
+			continue
+		}
+		for _, registration := range s.registrations {
+			edits[registration.file] = append(edits[registration.file], declRemoval(fset, registration))
+		}
+		if spec := suiteDecl(files, name); spec.file != nil {
+			edits[spec.file] = append(edits[spec.file], declRemoval(fset, spec))
+		}
+		c.logf("Removed gocheck suite %s\n", name)
+		removed++
+	}
+	if left == 0 && removed > 0 {
+		for _, hook := range hooks {
+			edits[hook.file] = append(edits[hook.file], declRemoval(fset, hook))
+		}
+	}
+
+	// Step 4: Reparse the rewritten files, dropping gocheck imports nothing uses anymore
+	for _, file := range files {
+		fileEdits := edits[file]
+		if len(fileEdits) == 0 {
+			continue
+		}
+		if migrated[file] {
+			fileEdits = append(fileEdits, testingImport(fset, file.node, file.parsed)...)
+		}
+		sort.Slice(fileEdits, func(i, j int) bool { return fileEdits[i].start < fileEdits[j].start })
+		src := applyEdits(file.parsed, fileEdits)
+
+		node, err := parser.ParseFile(fset, file.path, src, parser.ParseComments)
+		if err != nil {
+			c.logf("Skipping gocheck tests in %s: %v\n", file.path, err)
+			continue
+		}
+		pkg := gocheckName(node)
+		used := false
+		ast.Inspect(node, func(n ast.Node) bool {
+			if expr, ok := n.(ast.Expr); ok && gocheckRef(expr, pkg) != "" {
+				used = true
+			}
+			return !used
+		})
+		if !used {
+			removeImports(fset, node, func(spec *ast.ImportSpec) bool {
+				return spec.Path.Value == strconv.Quote(gocheckPackage)
+			})
+		}
+
+		file.node = node
+		file.parsed = src
+		file.comments = ast.NewCommentMap(fset, node, node.Comments)
+		file.migrated = true
+	}
+}
+
+// suiteType returns the type of a suite registration argument ('&S{}', 'new(S)'), or ""
+func suiteType(arg ast.Expr) string {
+	switch x := arg.(type) {
+	case *ast.UnaryExpr:
+		if lit, ok := x.X.(*ast.CompositeLit); ok && x.Op == token.AND {
+			if ident, ok := lit.Type.(*ast.Ident); ok {
+				return ident.Name
+			}
+		}
+	case *ast.CallExpr:
+		if fn, ok := x.Fun.(*ast.Ident); ok && fn.Name == "new" && len(x.Args) == 1 {
+			if ident, ok := x.Args[0].(*ast.Ident); ok {
+				return ident.Name
+			}
+		}
+	}
+	return ""
+}
+
+// receiverType returns the name of the type of a method's receiver ('s *S' is S), or ""
+func receiverType(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) != 1 {
+		return ""
+	}
+	typ := fn.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	ident, _ := typ.(*ast.Ident)
+	if ident == nil {
+		return ""
+	}
+	return ident.Name
+}
+
+// gocheckHook reports whether a function only hooks gocheck into go test
+// ('func Test(t *testing.T) { TestingT(t) }')
+func gocheckHook(fn *ast.FuncDecl, pkg string) bool {
+	if fn.Body == nil || len(fn.Body.List) != 1 || !isTestFunc(fn) {
+		return false
+	}
+	call := stmtCall(fn.Body.List[0])
+	return call != nil && gocheckRef(call.Fun, pkg) == "TestingT"
+}
+
+// typeRefs counts the references to a type name in the files of a package besides its
+// declaration, suite registrations, and method receivers
+func typeRefs(files []*sourceFile, name string) int {
+	refs := 0
+	for _, file := range files {
+		pkg := gocheckName(file.node)
+		skip := make(map[ast.Node]bool)
+		for _, decl := range file.node.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv != nil {
+					skip[decl.Recv] = true
+				}
+			case *ast.GenDecl:
+				if call := specsCall(decl); call != nil && gocheckRef(call.Fun, pkg) == "Suite" {
+					skip[decl] = true
+				}
+				for _, spec := range decl.Specs {
+					if spec, ok := spec.(*ast.TypeSpec); ok && spec.Name.Name == name {
+						skip[spec.Name] = true
+					}
+				}
+			}
+		}
+		ast.Inspect(file.node, func(n ast.Node) bool {
+			if skip[n] {
+				return false
+			}
+			if ident, ok := n.(*ast.Ident); ok && ident.Name == name {
+				refs++
+			}
+			return true
+		})
+	}
+	return refs
+}
+
+// suiteDecl returns the declaration of a suite type, when it declares nothing else
+func suiteDecl(files []*sourceFile, name string) gocheckDecl {
+	for _, file := range files {
+		for _, decl := range file.node.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE || len(gen.Specs) != 1 {
+				continue
+			}
+			if gen.Specs[0].(*ast.TypeSpec).Name.Name == name {
+				return gocheckDecl{file, gen}
+			}
+		}
+	}
+	return gocheckDecl{}
+}
+
+// declRemoval returns the edit removing a declaration and its doc comment, with the blank
+// line after it
+func declRemoval(fset *token.FileSet, d gocheckDecl) sourceEdit {
+	pos := d.decl.Pos()
+	switch decl := d.decl.(type) {
+	case *ast.FuncDecl:
+		if decl.Doc != nil {
+			pos = decl.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if decl.Doc != nil {
+			pos = decl.Doc.Pos()
+		}
+	}
+	src := d.file.parsed
+	start, end := lineRange(src, fset.Position(pos).Offset, fset.Position(d.decl.End()).Offset)
+	if end < len(src) && src[end] == '\n' {
+		end++
+	}
+	return sourceEdit{start: start, end: end}
+}
+
+// gocheckMigration holds the rewrite of a gocheck test method
+type gocheckMigration struct {
+	parsedSource
+	// pkg is the name the file refers to gocheck by
+	pkg string
+}
+
+// method returns the edits rewriting a test method into a test function with the given
+// name, or an error telling why it can't be rewritten
+func (m *gocheckMigration) method(fn *ast.FuncDecl, name string) ([]sourceEdit, error) {
+	params := fn.Type.Params.List
+	if len(params) != 1 || len(params[0].Names) != 1 || gocheckRef(params[0].Type, m.pkg) != "C" || fn.Type.Results != nil || fn.Body == nil {
+		return nil, fmt.Errorf("it isn't a gocheck test")
+	}
+	if recv := fn.Recv.List[0]; len(recv.Names) > 0 && recv.Names[0].Name != "_" && usesObject(fn.Body, recv.Names[0].Obj) {
+		return nil, fmt.Errorf("it uses its suite")
+	}
+	if usesIdents(fn.Body, "t") {
+		return nil, fmt.Errorf("it uses t")
+	}
+	c := params[0].Names[0]
+
+	var edits []sourceEdit
+	header := fmt.Sprintf("func %s(t *testing.T) ", name)
+	edits = append(edits, sourceEdit{start: m.offset(fn.Pos()), end: m.offset(fn.Body.Lbrace), text: header})
+	if fn.Doc != nil {
+		// The doc comment names the function after its method
+		if text := fn.Doc.List[0].Text; strings.HasPrefix(text, "// "+fn.Name.Name+" ") {
+			start := m.offset(fn.Doc.Pos()) + len("// ")
+			edits = append(edits, sourceEdit{start: start, end: start + len(fn.Name.Name), text: name})
+		}
+	}
+
+	// Every use of c is a call to one of its methods
+	parents := parentMap(fn.Body)
+	var err error
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok || ident.Obj != c.Obj || err != nil {
+			return err == nil
+		}
+		sel, ok := parents[ident].(*ast.SelectorExpr)
+		if !ok || sel.X != ident {
+			err = fmt.Errorf("it passes %s on", c.Name)
+			return false
+		}
+		call, ok := parents[sel].(*ast.CallExpr)
+		if !ok || call.Fun != sel {
+			err = fmt.Errorf("it uses %s.%s as a value", c.Name, sel.Sel.Name)
+			return false
+		}
+
+		switch method := sel.Sel.Name; {
+		case method == "Assert" || method == "Check":
+			stmt, ok := parents[call].(*ast.ExprStmt)
+			if !ok {
+				err = fmt.Errorf("it uses the result of %s.%s", c.Name, method)
+				return false
+			}
+			for _, arg := range call.Args {
+				if usesObject(arg, c.Obj) {
+					err = fmt.Errorf("it passes %s on", c.Name)
+					return false
+				}
+			}
+			text, assertErr := m.assertion(call, method == "Assert")
+			if assertErr != nil {
+				err = assertErr
+				return false
+			}
+			edits = append(edits, sourceEdit{start: m.offset(stmt.Pos()), end: m.offset(stmt.End()), text: text})
+			return false
+		case gocheckTMethods[method] != "":
+			edits = append(edits, sourceEdit{start: m.offset(sel.Pos()), end: m.offset(sel.End()), text: "t." + gocheckTMethods[method]})
+		default:
+			err = fmt.Errorf("testing.T has no %s method", method)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+// assertion returns the if statement replacing a c.Assert or c.Check call, which fails
+// with t.Fatalf or t.Errorf. Values that aren't plain expressions are evaluated once,
+// in the statement's init.
+func (m *gocheckMigration) assertion(call *ast.CallExpr, fatal bool) (string, error) {
+	if len(call.Args) < 2 || call.Ellipsis.IsValid() {
+		return "", fmt.Errorf("it passes c.Assert or c.Check a spread slice")
+	}
+	checker := gocheckRef(call.Args[1], m.pkg)
+	var expected int
+	switch checker {
+	case "Equals", "DeepEquals", "HasLen":
+		expected = 1
+	case "IsNil", "NotNil":
+	default:
+		return "", fmt.Errorf("the %s checker isn't supported", m.text(call.Args[1]))
+	}
+	if len(call.Args) < 2+expected {
+		return "", fmt.Errorf("the %s checker lacks its expected value", checker)
+	}
+	// The untyped nil has no type to compare it as ('nil != nil')
+	if ident, ok := call.Args[0].(*ast.Ident); ok && ident.Name == "nil" && ident.Obj == nil {
+		return "", fmt.Errorf("it checks the untyped nil")
+	}
+
+	// A Commentf comment leads the message
+	var format string
+	var args []string
+	switch extra := call.Args[2+expected:]; len(extra) {
+	case 0:
+	case 1:
+		comment, ok := extra[0].(*ast.CallExpr)
+		if !ok || gocheckRef(comment.Fun, m.pkg) != "Commentf" || len(comment.Args) == 0 || comment.Ellipsis.IsValid() {
+			return "", fmt.Errorf("only Commentf comments are supported")
+		}
+		commentFormat, ok := stringLit(comment.Args[0])
+		if !ok {
+			return "", fmt.Errorf("the Commentf format isn't a literal")
+		}
+		format = commentFormat + ": "
+		for _, arg := range comment.Args[1:] {
+			args = append(args, m.text(arg))
+		}
+	default:
+		return "", fmt.Errorf("too many arguments to c.Assert or c.Check")
+	}
+
+	// Values with calls are bound to names unused by the assertion
+	var inits, values []string
+	bind := func(expr ast.Expr, names ...string) (string, error) {
+		if plainExpr(expr) {
+			return m.text(expr), nil
+		}
+		for _, name := range names {
+			if !usesIdents(&ast.BlockStmt{List: []ast.Stmt{&ast.ExprStmt{X: call}}}, name) {
+				inits = append(inits, name)
+				values = append(values, m.text(expr))
+				return name, nil
+			}
+		}
+		return "", fmt.Errorf("the assertion uses %s", strings.Join(names, " and "))
+	}
+	got, err := bind(call.Args[0], "got", "obtained")
+	if err != nil {
+		return "", err
+	}
+	var want string
+	if expected > 0 {
+		if want, err = bind(call.Args[2], "want", "expected"); err != nil {
+			return "", err
+		}
+	}
+
+	var cond string
+	switch checker {
+	case "Equals":
+		cond = fmt.Sprintf("%s != %s", got, want)
+		format += "got %v, want %v"
+		args = append(args, got, want)
+	case "DeepEquals":
+		cond = fmt.Sprintf("!reflect.DeepEqual(%s, %s)", got, want)
+		format += "got %v, want %v"
+		args = append(args, got, want)
+	case "HasLen":
+		cond = fmt.Sprintf("len(%s) != %s", got, want)
+		format += "got length %d, want %d"
+		args = append(args, "len("+got+")", want)
+	case "IsNil":
+		cond = got + " != nil"
+		if errorValue(call.Args[0]) {
+			format += "unexpected error: %v"
+		} else {
+			format += "got %v, want nil"
+		}
+		args = append(args, got)
+	case "NotNil":
+		cond = got + " == nil"
+		format += "got nil"
+	}
+
+	init := ""
+	if len(inits) > 0 {
+		init = fmt.Sprintf("%s := %s; ", strings.Join(inits, ", "), strings.Join(values, ", "))
+	}
+	method := "Errorf"
+	if fatal {
+		method = "Fatalf"
+	}
+	return fmt.Sprintf("if %s%s {\n\tt.%s(%s)\n}", init, cond, method, strings.Join(append([]string{strconv.Quote(format)}, args...), ", ")), nil
+}
+
+// plainExpr reports whether evaluating an expression twice is the same as evaluating it
+// once: it makes no calls, receives from no channels, and builds no closures
+func plainExpr(expr ast.Expr) bool {
+	plain := true
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr, *ast.FuncLit:
+			plain = false
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW || n.Op == token.AND {
+				plain = false
+			}
+		}
+		return plain
+	})
+	return plain
+}
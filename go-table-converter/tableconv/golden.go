@@ -0,0 +1,641 @@
+package tableconv
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// goldenField is a string or []byte field of a table moved out to golden files
+type goldenField struct {
+	field *ast.Field
+	bytes bool
+	// values are the values of the field by case name, empty for cases leaving it out
+	values map[string]string
+	// elts are the elements of the cases setting the field
+	elts []*ast.KeyValueExpr
+}
+
+// externalizeGoldenPackage moves the large expected values of the table tests of a package
+// into golden files under testdata, read back by the tests instead
+func (c *Converter) externalizeGoldenPackage(fset *token.FileSet, files []*sourceFile) {
+	update, declare := updateFlag(files)
+	if update == "" {
+		c.logf("Skipping golden files: the package declares update as something other than a flag\n")
+		return
+	}
+
+	for _, file := range files {
+		if file.result.Generated || !strings.HasSuffix(file.path, "_test.go") {
+			continue
+		}
+
+		var edits []sourceEdit
+		golden := make(map[string][]byte)
+		for _, decl := range file.node.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil || !isTestFunc(fn) {
+				continue
+			}
+			// Loops in closures may run in subtests, which t.Name() would include
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.FuncLit:
+					return false
+				case *ast.RangeStmt:
+					tableEdits, files := c.externalizeGolden(fset, file, fn, n, update, golden)
+					edits = append(edits, tableEdits...)
+					for path, data := range files {
+						golden[path] = data
+					}
+				}
+				return true
+			})
+		}
+		if len(edits) == 0 {
+			continue
+		}
+
+		// The update flag is declared once, after the imports of the first file using it
+		if declare {
+			pos := file.node.Name.End()
+			for _, decl := range file.node.Decls {
+				if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.IMPORT {
+					pos = decl.End()
+				}
+			}
+			offset := fset.Position(pos).Offset
+			text := fmt.Sprintf("\n\nvar %s = flag.Bool(%q, false, \"update golden files\")", update, update)
+			edits = append(edits, sourceEdit{start: offset, end: offset, text: text})
+			declare = false
+		}
+
+		sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+		src := applyEdits(file.parsed, edits)
+		node, err := parser.ParseFile(fset, file.path, src, parser.ParseComments)
+		if err != nil {
+			c.logf("Skipping golden files in %s: %v\n", file.path, err)
+			continue
+		}
+		file.node = node
+		file.parsed = src
+		file.comments = ast.NewCommentMap(fset, node, node.Comments)
+		file.migrated = true
+		file.golden = golden
+	}
+}
+
+// updateFlag returns the name of the flag that makes tests rewrite their golden files,
+// and whether it still has to be declared: update, unless the package declares update as
+// something other than a flag.Bool, in which case it returns ""
+func updateFlag(files []*sourceFile) (string, bool) {
+	for _, file := range files {
+		for _, decl := range file.node.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok == token.IMPORT {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				spec, ok := spec.(*ast.ValueSpec)
+				if !ok {
+					continue
+				}
+				for i, name := range spec.Names {
+					if name.Name != "update" {
+						continue
+					}
+					if i < len(spec.Values) {
+						if call, ok := spec.Values[i].(*ast.CallExpr); ok && isSelector(call.Fun, "flag", "Bool") {
+							return "update", false
+						}
+					}
+					return "", false
+				}
+			}
+		}
+	}
+	if packageNames(files)["update"] {
+		return "", false
+	}
+	return "update", true
+}
+
+// externalizeGolden moves the first string or []byte field of a table with a value of at
+// least Golden bytes out to one golden file per case, named after the subtest of the case
+// ('testdata/TestRender/empty_page.golden'), and reads the file back where the subtest
+// compares the field, writing it first when the update flag is set. Tables qualify when
+// they are declared in the test for the loop alone and their loop runs each case in a
+// subtest named after it.
+// Change from: if got != tc.want {
+// To:          if got != string(want) {, with want read from the golden file
+// It returns the edits to the file and the golden files by path; paths in taken, which
+// other tables of the test use already, are left alone.
+func (c *Converter) externalizeGolden(fset *token.FileSet, file *sourceFile, fn *ast.FuncDecl, rangeStmt *ast.RangeStmt, update string, taken map[string][]byte) ([]sourceEdit, map[string][]byte) {
+	// Step 1: Check that the table is local to the test and its loop
+	lit, ok := tableValue(rangeStmt.X).(*ast.CompositeLit)
+	if !ok || lit.Pos() < fn.Pos() || lit.End() > fn.End() {
+		return nil, nil
+	}
+	if ident, ok := rangeStmt.X.(*ast.Ident); ok {
+		uses := 0
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			if other, ok := n.(*ast.Ident); ok && other.Obj == ident.Obj {
+				uses++
+			}
+			return true
+		})
+		if uses != 2 {
+			return nil, nil
+		}
+	}
+
+	var structType *ast.StructType
+	isMap := false
+	switch typ := lit.Type.(type) {
+	case *ast.MapType:
+		if key, ok := typ.Key.(*ast.Ident); !ok || key.Name != "string" {
+			return nil, nil
+		}
+		structType, _ = typ.Value.(*ast.StructType)
+		isMap = true
+	case *ast.ArrayType:
+		if typ.Len == nil {
+			structType, _ = typ.Elt.(*ast.StructType)
+		}
+	}
+	value, _ := rangeStmt.Value.(*ast.Ident)
+	if structType == nil || value == nil || value.Obj == nil {
+		return nil, nil
+	}
+	nameField := ""
+	if !isMap {
+		if nameField, _ = findNameField(structType); nameField == "" {
+			return nil, nil
+		}
+	}
+
+	// Step 2: Find the subtest the loop runs each case in, named after the case
+	var subtest *ast.FuncLit
+	for _, stmt := range rangeStmt.Body.List {
+		exprStmt, ok := stmt.(*ast.ExprStmt)
+		if !ok {
+			continue
+		}
+		call, ok := exprStmt.X.(*ast.CallExpr)
+		if !ok || !subtestCall(call) {
+			continue
+		}
+		switch arg := call.Args[0].(type) {
+		case *ast.Ident:
+			key, _ := rangeStmt.Key.(*ast.Ident)
+			if isMap && key != nil && arg.Obj != nil && arg.Obj == key.Obj {
+				subtest = call.Args[1].(*ast.FuncLit)
+			}
+		case *ast.SelectorExpr:
+			if x, ok := arg.X.(*ast.Ident); ok && !isMap && x.Obj == value.Obj && arg.Sel.Name == nameField {
+				subtest = call.Args[1].(*ast.FuncLit)
+			}
+		}
+	}
+	if subtest == nil || testingParam(subtest.Type, "T") == "" {
+		return nil, nil
+	}
+	t := testingParam(subtest.Type, "T")
+
+	// Step 3: Find a field with a large enough literal in some case
+	cases := make(map[string]*ast.CompositeLit)
+	var names []string
+	for _, elt := range lit.Elts {
+		var name string
+		var caseLit *ast.CompositeLit
+		if isMap {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				return nil, nil
+			}
+			caseLit, _ = kv.Value.(*ast.CompositeLit)
+			if name, ok = stringLit(kv.Key); !ok {
+				return nil, nil
+			}
+		} else {
+			caseLit, _ = elt.(*ast.CompositeLit)
+			if caseLit == nil {
+				return nil, nil
+			}
+			elt := keyedElt(caseLit, nameField)
+			if elt == nil {
+				return nil, nil
+			}
+			var ok bool
+			if name, ok = stringLit(elt.Value); !ok {
+				return nil, nil
+			}
+		}
+		if caseLit == nil || cases[name] != nil {
+			return nil, nil
+		}
+		for _, elt := range caseLit.Elts {
+			if _, ok := elt.(*ast.KeyValueExpr); !ok {
+				return nil, nil
+			}
+		}
+		cases[name] = caseLit
+		names = append(names, name)
+	}
+
+	var golden *goldenField
+	for _, field := range structType.Fields.List {
+		if len(field.Names) != 1 || field.Names[0].Name == nameField {
+			continue
+		}
+		candidate := &goldenField{field: field, values: make(map[string]string)}
+		switch typ := field.Type.(type) {
+		case *ast.Ident:
+			if typ.Name != "string" {
+				continue
+			}
+		case *ast.ArrayType:
+			if elt, ok := typ.Elt.(*ast.Ident); !ok || typ.Len != nil || elt.Name != "byte" {
+				continue
+			}
+			candidate.bytes = true
+		default:
+			continue
+		}
+
+		large := false
+		for _, name := range names {
+			elt := keyedElt(cases[name], field.Names[0].Name)
+			if elt == nil {
+				continue
+			}
+			text, ok := literalText(elt.Value)
+			if !ok {
+				candidate = nil
+				break
+			}
+			candidate.values[name] = text
+			candidate.elts = append(candidate.elts, elt)
+			large = large || len(text) >= c.opts.Golden
+		}
+		if candidate != nil && large {
+			golden = candidate
+			break
+		}
+	}
+	if golden == nil {
+		return nil, nil
+	}
+	fieldName := golden.field.Names[0].Name
+	tableName := fmt.Sprintf("%s:%d", fn.Name.Name, fset.Position(lit.Pos()).Line)
+
+	// Step 4: Find the statement of the subtest comparing the field, and what to
+	var uses []*ast.SelectorExpr
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel.Name == fieldName {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Obj == value.Obj {
+				uses = append(uses, sel)
+			}
+		}
+		return true
+	})
+	var stmt ast.Stmt
+	for _, s := range subtest.Body.List {
+		if len(uses) > 0 && uses[0].Pos() >= s.Pos() && uses[0].End() <= s.End() {
+			stmt = s
+		}
+	}
+	if stmt == nil {
+		c.logf("Skipping golden files for %s: %s isn't compared in its subtest\n", tableName, fieldName)
+		return nil, nil
+	}
+	for _, use := range uses {
+		if use.Pos() < stmt.Pos() || use.End() > stmt.End() {
+			c.logf("Skipping golden files for %s: %s is used outside of one comparison\n", tableName, fieldName)
+			return nil, nil
+		}
+	}
+	got := comparedWith(stmt, uses[0])
+	if got == nil {
+		c.logf("Skipping golden files for %s: %s isn't compared with a variable\n", tableName, fieldName)
+		return nil, nil
+	}
+
+	// A result declared by the comparison itself ('if got := f(tc.in); got != tc.want')
+	// is declared ahead of it instead, so that the update can write it
+	init := declaringInit(stmt, got)
+	if init != nil {
+		if reason := splitInitHazard(subtest.Body, stmt, init, uses); reason != "" {
+			c.logf("Skipping golden files for %s: %s\n", tableName, reason)
+			table := inlineTableName
+			if ident, ok := rangeStmt.X.(*ast.Ident); ok {
+				table = ident.Name
+			}
+			file.result.Diagnostics = append(file.result.Diagnostics, Diagnostic{
+				Pos:     fset.Position(init.Pos()),
+				Table:   table,
+				Message: fmt.Sprintf("%s isn't moved to golden files: %s", fieldName, reason),
+			})
+			return nil, nil
+		}
+	}
+
+	// Step 5: Name the golden files after the subtests
+	dir := filepath.Join(filepath.Dir(file.path), "testdata", fn.Name.Name)
+	files := make(map[string][]byte)
+	for _, name := range names {
+		base, ok := goldenName(name)
+		if !ok {
+			c.logf("Skipping golden files for %s: case %q doesn't make a file name\n", tableName, name)
+			return nil, nil
+		}
+		path := filepath.Join(dir, base+".golden")
+		if _, ok := taken[path]; ok {
+			c.logf("Skipping golden files for %s: another table of the test has a case %q\n", tableName, name)
+			return nil, nil
+		}
+		files[path] = []byte(golden.values[name])
+	}
+
+	// Step 6: Drop the field from the cases and the case struct and read the file instead
+	src := file.parsed
+	offset := func(pos token.Pos) int {
+		return fset.Position(pos).Offset
+	}
+	var edits []sourceEdit
+	for _, elt := range golden.elts {
+		start, end := offset(elt.Pos()), offset(elt.End())
+		if next := skipSpace(src, end); next < len(src) && src[next] == ',' {
+			end = next + 1
+		}
+		start, end = lineRange(src, start, trailingComment(src, end))
+		edits = append(edits, sourceEdit{start: start, end: end})
+	}
+	start, end := lineRange(src, offset(golden.field.Pos()), trailingComment(src, offset(golden.field.End())))
+	edits = append(edits, sourceEdit{start: start, end: end})
+
+	declared := usedNames(fn.Body)
+	used := func(name string) bool {
+		return declared[name] || name == update
+	}
+	goldenVar, wantVar := freshName("golden", used), freshName("want", used)
+	read := wantVar
+	gotBytes := nodeString(fset, got)
+	if !golden.bytes {
+		read = "string(" + wantVar + ")"
+		gotBytes = "[]byte(" + gotBytes + ")"
+	}
+	for _, use := range uses {
+		edits = append(edits, sourceEdit{start: offset(use.Pos()), end: offset(use.End()), text: read})
+	}
+
+	lineStart := offset(stmt.Pos())
+	for lineStart > 0 && src[lineStart-1] != '\n' {
+		lineStart--
+	}
+	indent := string(src[lineStart:offset(stmt.Pos())])
+	var block strings.Builder
+	if init != nil {
+		block.WriteString(indent + string(src[offset(init.Pos()):offset(init.End())]) + "\n")
+		ifStmt := stmt.(*ast.IfStmt)
+		edits = append(edits, sourceEdit{start: offset(init.Pos()), end: offset(ifStmt.Cond.Pos())})
+	}
+	for _, line := range []string{
+		fmt.Sprintf("%s := filepath.Join(\"testdata\", %s.Name()+\".golden\")", goldenVar, t),
+		fmt.Sprintf("if *%s {", update),
+		fmt.Sprintf("\tif err := os.WriteFile(%s, %s, 0o644); err != nil {", goldenVar, gotBytes),
+		fmt.Sprintf("\t\t%s.Fatalf(\"error updating golden file: %%v\", err)", t),
+		"\t}",
+		"}",
+		fmt.Sprintf("%s, err := os.ReadFile(%s)", wantVar, goldenVar),
+		"if err != nil {",
+		fmt.Sprintf("\t%s.Fatalf(\"error reading golden file: %%v\", err)", t),
+		"}",
+	} {
+		block.WriteString(indent + line + "\n")
+	}
+	edits = append(edits, sourceEdit{start: lineStart, end: lineStart, text: block.String()})
+
+	c.logf("Moved %s of %s to %d golden files in %s\n", fieldName, tableName, len(files), dir)
+	return edits, files
+}
+
+// declaringInit returns the init statement of an if statement when it declares a variable
+// the compared result refers to, or nil
+func declaringInit(stmt ast.Stmt, got ast.Expr) *ast.AssignStmt {
+	ifStmt, ok := stmt.(*ast.IfStmt)
+	if !ok || ifStmt.Init == nil {
+		return nil
+	}
+	init, ok := ifStmt.Init.(*ast.AssignStmt)
+	if !ok || init.Tok != token.DEFINE {
+		return nil
+	}
+	declares := false
+	ast.Inspect(got, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Obj != nil && ident.Obj.Decl == init {
+			declares = true
+		}
+		return !declares
+	})
+	if !declares {
+		return nil
+	}
+	return init
+}
+
+// splitInitHazard tells why the init statement of a comparison can't be moved ahead of it
+// in the body of a subtest, or returns "" when it can: the variables it declares must not
+// be used elsewhere in the body, nor be the err the golden file is read into, and it must
+// not use the field moved out
+func splitInitHazard(body *ast.BlockStmt, stmt ast.Stmt, init *ast.AssignStmt, uses []*ast.SelectorExpr) string {
+	for _, use := range uses {
+		if use.Pos() >= init.Pos() && use.End() <= init.End() {
+			return "the result is declared by the statement comparing it, along with the field"
+		}
+	}
+	var rest []ast.Stmt
+	for _, s := range body.List {
+		if s != stmt {
+			rest = append(rest, s)
+		}
+	}
+	used := usedNames(&ast.BlockStmt{List: rest})
+	for _, lhs := range init.Lhs {
+		ident, ok := lhs.(*ast.Ident)
+		if !ok || isBlankIdent(ident) {
+			continue
+		}
+		if ident.Name == "err" || used[ident.Name] {
+			return fmt.Sprintf("the result is declared by the statement comparing it, and %s can't be declared ahead of it", ident.Name)
+		}
+	}
+	return ""
+}
+
+// keyedElt returns the element of a keyed case literal setting a field, or nil
+func keyedElt(caseLit *ast.CompositeLit, name string) *ast.KeyValueExpr {
+	for _, elt := range caseLit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok && key.Name == name {
+				return kv
+			}
+		}
+	}
+	return nil
+}
+
+// literalText returns the value of a string literal, or of a []byte conversion of one
+// ('[]byte("...")')
+func literalText(expr ast.Expr) (string, bool) {
+	if call, ok := expr.(*ast.CallExpr); ok && len(call.Args) == 1 {
+		typ, ok := call.Fun.(*ast.ArrayType)
+		if !ok || typ.Len != nil {
+			return "", false
+		}
+		if elt, ok := typ.Elt.(*ast.Ident); !ok || elt.Name != "byte" {
+			return "", false
+		}
+		expr = call.Args[0]
+	}
+	return stringLit(expr)
+}
+
+// comparedWith returns the variable a statement compares a field with, as the other
+// operand of == or != or the neighboring argument of a call ('bytes.Equal(got, tc.want)',
+// 'assert.Equal(t, tc.want, got)'), or nil
+func comparedWith(stmt ast.Stmt, use *ast.SelectorExpr) ast.Expr {
+	var got ast.Expr
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		if got != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.BinaryExpr:
+			if n.Op != token.EQL && n.Op != token.NEQ {
+				break
+			}
+			if n.X == use {
+				got = n.Y
+			} else if n.Y == use {
+				got = n.X
+			}
+		case *ast.CallExpr:
+			for i, arg := range n.Args {
+				if arg != use || len(n.Args) < 2 {
+					continue
+				}
+				if i == len(n.Args)-1 {
+					got = n.Args[i-1]
+				} else {
+					got = n.Args[i+1]
+				}
+			}
+		}
+		return true
+	})
+
+	// The value is read again to update the golden file, so it has to be a variable
+	switch x := got.(type) {
+	case *ast.Ident:
+		return x
+	case *ast.SelectorExpr:
+		if _, ok := x.X.(*ast.Ident); ok {
+			return x
+		}
+	}
+	return nil
+}
+
+// goldenName returns the name t.Name() gives the subtest of a case, which names its
+// golden file: spaces become underscores and unprintable characters are escaped. Names
+// that can't be file names on every platform are rejected.
+func goldenName(name string) (string, bool) {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsSpace(r):
+			b.WriteByte('_')
+		case !strconv.IsPrint(r):
+			quoted := strconv.QuoteRune(r)
+			b.WriteString(quoted[1 : len(quoted)-1])
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	base := b.String()
+	if strings.ContainsAny(base, `\:*?"<>|`) {
+		return "", false
+	}
+	for _, part := range strings.Split(base, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", false
+		}
+	}
+	return base, true
+}
+
+// skipSpace returns the offset of the first character from offset on that isn't a space
+// or tab
+func skipSpace(src []byte, offset int) int {
+	for offset < len(src) && (src[offset] == ' ' || src[offset] == '\t') {
+		offset++
+	}
+	return offset
+}
+
+// trailingComment returns the end of a line comment following offset on its line, or
+// offset when there is none
+func trailingComment(src []byte, offset int) int {
+	next := skipSpace(src, offset)
+	if !bytes.HasPrefix(src[next:], []byte("//")) {
+		return offset
+	}
+	if end := bytes.IndexByte(src[next:], '\n'); end >= 0 {
+		return next + end
+	}
+	return len(src)
+}
+
+// usedNames returns the names a function body refers to or declares, leaving out the
+// names of fields, which can't clash with variables
+func usedNames(body *ast.BlockStmt) map[string]bool {
+	names := make(map[string]bool)
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			ast.Inspect(n.X, visit)
+			return false
+		case *ast.KeyValueExpr:
+			if _, ok := n.Key.(*ast.Ident); ok {
+				ast.Inspect(n.Value, visit)
+				return false
+			}
+		case *ast.StructType:
+			return false
+		case *ast.Ident:
+			names[n.Name] = true
+		}
+		return true
+	}
+	ast.Inspect(body, visit)
+	return names
+}
+
+// freshName returns name, or name numbered from 2 on, whichever isn't used yet
+func freshName(name string, used func(string) bool) string {
+	fresh := name
+	for n := 2; used(fresh); n++ {
+		fresh = fmt.Sprintf("%s%d", name, n)
+	}
+	return fresh
+}
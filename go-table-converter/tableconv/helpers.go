@@ -0,0 +1,184 @@
+package tableconv
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// helperTable is a function returning a slice-based table, ranged over by tests as
+// 'for _, tc := range makeCases()'
+type helperTable struct {
+	fn   *ast.FuncDecl
+	file *sourceFile
+	// result is the declared result type, the slice type
+	result *ast.Field
+	table  tableType
+	// returns are the table literals returned by the function
+	returns []*tableCandidate
+}
+
+// helperKey identifies the helper function a loop ranges over in tableVars
+type helperKey string
+
+// findHelperTables finds the functions of a package that build slice-based tables. Every
+// return statement must return a table literal, and every use of the function must be
+// a loop ranging over its result, so the loops can be updated along with the function.
+func findHelperTables(files []*sourceFile, types *namedTypes) []*helperTable {
+	// Count the uses of each function name and the uses that are loops over a call to it
+	uses := make(map[string]int)
+	rangedCalls := make(map[string]int)
+	for _, file := range files {
+		ast.Inspect(file.node, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				// The declared name is no use
+				if n.Body != nil {
+					ast.Inspect(n.Body, func(n ast.Node) bool {
+						countHelperUse(n, uses, rangedCalls)
+						return true
+					})
+				}
+				return false
+			default:
+				countHelperUse(n, uses, rangedCalls)
+			}
+			return true
+		})
+	}
+
+	var helpers []*helperTable
+	for _, file := range files {
+		for _, decl := range file.node.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || fn.Body == nil {
+				continue
+			}
+			name := fn.Name.Name
+			if uses[name] == 0 || uses[name] != rangedCalls[name] {
+				continue
+			}
+
+			if helper := helperTableOf(fn, types); helper != nil {
+				helper.file = file
+				helpers = append(helpers, helper)
+			}
+		}
+	}
+
+	return helpers
+}
+
+// countHelperUse counts a use of a function name, and whether it is a loop over a call to
+// the function without arguments
+func countHelperUse(n ast.Node, uses, rangedCalls map[string]int) {
+	switch n := n.(type) {
+	case *ast.Ident:
+		uses[n.Name]++
+	case *ast.RangeStmt:
+		if call, ok := n.X.(*ast.CallExpr); ok && len(call.Args) == 0 {
+			if name, _ := baseName(call.Fun); name != "" {
+				rangedCalls[name]++
+			}
+		}
+	}
+}
+
+// helperTableOf checks whether a function returns a slice-based table from each of its
+// return statements
+func helperTableOf(fn *ast.FuncDecl, types *namedTypes) *helperTable {
+	results := fn.Type.Results
+	if results == nil || len(results.List) != 1 || len(results.List[0].Names) > 1 {
+		return nil
+	}
+
+	result := results.List[0]
+	table, ok := tableMapType(result.Type, types)
+	if !ok {
+		return nil
+	}
+
+	helper := &helperTable{fn: fn, result: result, table: table}
+	valid := true
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// Returns of nested functions return something else
+			return false
+		case *ast.ReturnStmt:
+			if len(n.Results) != 1 {
+				valid = false
+				return false
+			}
+			lit, table, ok := literalTable(n.Results[0], types)
+			if !ok || table.named != helper.table.named || table.nameField != helper.table.nameField {
+				valid = false
+				return false
+			}
+			helper.returns = append(helper.returns, &tableCandidate{ident: fn.Name, lit: lit, table: table, funcName: fn.Name.Name})
+		}
+		return valid
+	})
+	if !valid || len(helper.returns) == 0 {
+		return nil
+	}
+
+	return helper
+}
+
+// info describes the helper's tables for reports
+func (helper *helperTable) info(fset *token.FileSet) Table {
+	return Table{
+		Name: helper.fn.Name.Name + "()",
+		Func: helper.fn.Name.Name,
+		Pos:  fset.Position(helper.fn.Name.Pos()),
+	}
+}
+
+// typeRefs counts the references to the helper's named case type made by its tables
+func (helper *helperTable) typeRefs() int {
+	if !helper.table.trimsType() {
+		return 0
+	}
+
+	// The result type and each returned table
+	refs := 1
+	for _, candidate := range helper.returns {
+		refs += candidate.typeRefs()
+	}
+	return refs
+}
+
+// convertHelper converts the tables returned by a helper function and its result type,
+// returning why it wasn't converted, or an empty reason when it was. The helper is left
+// alone unless every table it returns can be converted.
+func (c *Converter) convertHelper(fset *token.FileSet, helper *helperTable) (SkipReason, []Diagnostic) {
+	var diags []Diagnostic
+	var reason SkipReason
+	cases := make([][]*tableCase, len(helper.returns))
+	for i, candidate := range helper.returns {
+		var caseDiags []Diagnostic
+		cases[i], caseDiags = c.checkCases(fset, candidate)
+		diags = append(diags, caseDiags...)
+		if cases[i] == nil && reason == "" {
+			reason = candidate.skip
+		}
+	}
+	if reason != "" {
+		c.logf("Skipping table helper %s: cases that can't become map entries\n", helper.fn.Name.Name)
+		return reason, diags
+	}
+
+	for i, candidate := range helper.returns {
+		convertTableLiteral(fset, candidate.lit, candidate.table, cases[i])
+		if c.opts.KeyedFields {
+			keyCaseFields(candidate.table, cases[i])
+		}
+	}
+	if helper.table.named == nil {
+		collapseRemovedField(fset, helper.table.structType, helper.table.nameFieldIndex)
+	}
+	helper.result.Type = helper.table.declMapType(helper.result.Type)
+
+	c.logf("Found table helper: %s\n", helper.fn.Name.Name)
+	return "", diags
+}
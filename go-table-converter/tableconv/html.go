@@ -0,0 +1,195 @@
+package tableconv
+
+import (
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// htmlReport is the data of the HTML report template
+type htmlReport struct {
+	Generated   string
+	Result      ConversionResult
+	Added       int
+	Removed     int
+	Files       []htmlFile
+	Diagnostics []Diagnostic
+}
+
+// htmlFile is a modified file of the HTML report with its side-by-side diff
+type htmlFile struct {
+	Path    string
+	Tables  []Table
+	Added   int
+	Removed int
+	Rows    []diffRow
+}
+
+// diffRow is a row of a side-by-side diff. Line numbers are zero where a side has no
+// line; gap rows separate hunks that are apart.
+type diffRow struct {
+	Gap              bool
+	OldLine, NewLine int
+	Old, New         string
+	// OldKind and NewKind are "del", "add", or "" for unchanged lines
+	OldKind, NewKind string
+}
+
+// WriteHTML writes a standalone HTML page summarizing a run: its statistics, the tables
+// converted, warnings, errors, and a collapsible before/after diff of each modified file,
+// so proposed changes can be circulated for review before they are written
+func WriteHTML(w io.Writer, result ConversionResult) error {
+	report := htmlReport{
+		Generated:   time.Now().Format("2006-01-02 15:04:05"),
+		Result:      result,
+		Diagnostics: result.Diagnostics,
+	}
+	for _, file := range result.Files {
+		if !file.Modified {
+			continue
+		}
+		hf := htmlFile{Path: patchPath(file.Path), Tables: file.Tables, Rows: diffRows(file.src, file.out)}
+		for _, row := range hf.Rows {
+			if row.OldKind == "del" {
+				hf.Removed++
+			}
+			if row.NewKind == "add" {
+				hf.Added++
+			}
+		}
+		report.Added += hf.Added
+		report.Removed += hf.Removed
+		report.Files = append(report.Files, hf)
+	}
+	return htmlTemplate.Execute(w, report)
+}
+
+// diffRows returns the rows of the side-by-side diff turning a into b, with patchContext
+// unchanged lines around each change and a gap row wherever lines are left out. Removed
+// and added lines of a hunk are paired up row by row.
+func diffRows(a, b []byte) []diffRow {
+	aLines, bLines := splitLines(a), splitLines(b)
+	hunks := diffLines(aLines, bLines)
+	line := func(s string) string {
+		return strings.TrimRight(s, "\r\n")
+	}
+
+	var rows []diffRow
+	for i := 0; i < len(hunks); {
+		// Merge hunks whose context would overlap, as unifiedDiff does
+		j := i
+		for j+1 < len(hunks) && hunks[j+1].aStart-hunks[j].aEnd <= 2*patchContext {
+			j++
+		}
+		aStart := max(hunks[i].aStart-patchContext, 0)
+		aEnd := min(hunks[j].aEnd+patchContext, len(aLines))
+		if aStart > 0 {
+			rows = append(rows, diffRow{Gap: true})
+		}
+
+		pos := aStart
+		bPos := hunks[i].bStart - (hunks[i].aStart - aStart)
+		context := func(end int) {
+			for ; pos < end; pos, bPos = pos+1, bPos+1 {
+				rows = append(rows, diffRow{OldLine: pos + 1, NewLine: bPos + 1, Old: line(aLines[pos]), New: line(bLines[bPos])})
+			}
+		}
+		for k := i; k <= j; k++ {
+			h := hunks[k]
+			context(h.aStart)
+			for n := 0; n < max(h.aEnd-h.aStart, h.bEnd-h.bStart); n++ {
+				var row diffRow
+				if h.aStart+n < h.aEnd {
+					row.OldLine, row.Old, row.OldKind = h.aStart+n+1, line(aLines[h.aStart+n]), "del"
+				}
+				if h.bStart+n < h.bEnd {
+					row.NewLine, row.New, row.NewKind = h.bStart+n+1, line(bLines[h.bStart+n]), "add"
+				}
+				rows = append(rows, row)
+			}
+			pos, bPos = h.aEnd, h.bEnd
+		}
+		context(aEnd)
+		if j == len(hunks)-1 && aEnd < len(aLines) {
+			rows = append(rows, diffRow{Gap: true})
+		}
+		i = j + 1
+	}
+	return rows
+}
+
+// htmlTemplate renders the HTML report; it has no external resources so the page can be
+// attached to a review as a single file
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Table test conversion report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292f; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.2em; margin-top: 2em; }
+.stats { border-collapse: collapse; }
+.stats td { padding: 0.2em 1.5em 0.2em 0; }
+.stats td:last-child { text-align: right; font-weight: bold; }
+ul { padding-left: 1.2em; }
+code, .diff { font-family: SFMono-Regular, Consolas, "Liberation Mono", Menlo, monospace; font-size: 12px; }
+details { border: 1px solid #d0d7de; border-radius: 6px; margin: 0.8em 0; }
+summary { cursor: pointer; padding: 0.5em 0.8em; background: #f6f8fa; }
+summary .counts { float: right; }
+.added { color: #1a7f37; }
+.removed { color: #cf222e; }
+.diff { border-collapse: collapse; width: 100%; table-layout: fixed; }
+.diff td { padding: 0 0.5em; white-space: pre-wrap; word-break: break-all; vertical-align: top; }
+.diff td.num { width: 3.5em; text-align: right; color: #6e7781; user-select: none; }
+.diff td.del { background: #ffebe9; }
+.diff td.add { background: #e6ffec; }
+.diff tr.gap td { background: #ddf4ff; height: 1em; }
+.tables { margin: 0.5em 0.8em; }
+</style>
+</head>
+<body>
+<h1>Table test conversion report</h1>
+<p>Generated {{.Generated}}.</p>
+
+<h2>Statistics</h2>
+<table class="stats">
+<tr><td>Files processed</td><td>{{.Result.FilesProcessed}}</td></tr>
+<tr><td>Files modified</td><td>{{.Result.FilesModified}}</td></tr>
+<tr><td>Files unchanged since last run</td><td>{{.Result.FilesCached}}</td></tr>
+<tr><td>Generated files skipped</td><td>{{.Result.FilesGenerated}}</td></tr>
+<tr><td>Tables converted</td><td>{{.Result.TablesConverted}}</td></tr>
+<tr><td>Tables skipped</td><td>{{.Result.TablesSkipped}}</td></tr>
+<tr><td>Lines added</td><td class="added">+{{.Added}}</td></tr>
+<tr><td>Lines removed</td><td class="removed">-{{.Removed}}</td></tr>
+<tr><td>Warnings</td><td>{{len .Diagnostics}}</td></tr>
+<tr><td>Errors</td><td>{{len .Result.Errors}}</td></tr>
+</table>
+{{if .Diagnostics}}
+<h2>Warnings</h2>
+<ul>
+{{range .Diagnostics}}<li><code>{{.Pos}}</code>: {{.Table}}: {{.Message}}</li>
+{{end}}</ul>
+{{end}}{{if .Result.Errors}}
+<h2>Errors</h2>
+<ul>
+{{range .Result.Errors}}<li><code>{{.Path}}</code>: {{.Message}}</li>
+{{end}}</ul>
+{{end}}
+<h2>Changes</h2>
+{{if not .Files}}<p>No files would be modified.</p>{{end}}
+{{range .Files}}<details>
+<summary><code>{{.Path}}</code> <span class="counts"><span class="added">+{{.Added}}</span> <span class="removed">-{{.Removed}}</span></span></summary>
+{{if .Tables}}<ul class="tables">
+{{range .Tables}}<li>{{if .Func}}<code>{{.Func}}</code>: {{end}}<code>{{.Name}}</code> at line {{.Pos.Line}}</li>
+{{end}}</ul>{{end}}
+<table class="diff">
+{{range .Rows}}{{if .Gap}}<tr class="gap"><td class="num"></td><td></td><td class="num"></td><td></td></tr>
+{{else}}<tr><td class="num">{{if .OldLine}}{{.OldLine}}{{end}}</td><td class="{{.OldKind}}">{{.Old}}</td><td class="num">{{if .NewLine}}{{.NewLine}}{{end}}</td><td class="{{.NewKind}}">{{.New}}</td></tr>
+{{end}}{{end}}</table>
+</details>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,360 @@
+package tableconv
+
+import (
+	"bytes"
+	"go/ast"
+	"go/token"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// knownImports are the standard library packages that rewritten code may refer to without
+// the file importing them yet, by the name they are referred to with
+var knownImports = map[string]string{
+	"bytes":    "bytes",
+	"errors":   "errors",
+	"filepath": "path/filepath",
+	"flag":     "flag",
+	"fmt":      "fmt",
+	"maps":     "maps",
+	"os":       "os",
+	"reflect":  "reflect",
+	"regexp":   "regexp",
+	"slices":   "slices",
+	"sort":     "sort",
+	"strconv":  "strconv",
+	"strings":  "strings",
+	"testing":  "testing",
+	"time":     "time",
+}
+
+// packageRefs counts the selectors of a file whose operand is an identifier the file
+// doesn't declare ('fmt.Sprintf'), by name, which are the references to imported packages
+func packageRefs(node *ast.File) map[string]int {
+	refs := make(map[string]int)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil {
+				refs[x.Name]++
+			}
+		}
+		return true
+	})
+	return refs
+}
+
+// importName returns the name a file refers to an imported package by: its explicit name,
+// or else the name assumed from its path, as goimports does ('gopkg.in/yaml.v3' is yaml)
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+
+	path, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(path, "/")
+	name := parts[len(parts)-1]
+
+	// Major version suffixes ('example.com/mod/v2') aren't the name
+	if len(parts) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = parts[len(parts)-2]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexAny(name, ".-"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// fixImports removes the imports of a converted file that the conversion left unused and
+// adds the standard library imports that rewritten code refers to, so the file still
+// compiles. Imports the original file didn't use either are left alone, since their
+// names can't always be told from their paths.
+func (c *Converter) fixImports(fset *token.FileSet, file *sourceFile, declared map[string]bool) {
+	refs := packageRefs(file.node)
+
+	// Step 1: Remove the imports whose every reference went away
+	imported := make(map[string]bool)
+	for _, spec := range file.node.Imports {
+		imported[importName(spec)] = true
+	}
+	removeImports(fset, file.node, func(spec *ast.ImportSpec) bool {
+		name := importName(spec)
+		if name == "_" || name == "." || file.refs[name] == 0 || refs[name] > 0 {
+			return false
+		}
+		c.logf("Removed unused import %s from %s\n", spec.Path.Value, file.path)
+		return true
+	})
+
+	// Step 2: Add the known packages that are referred to but not imported
+	var missing []string
+	for name := range refs {
+		if _, known := knownImports[name]; known && !imported[name] && !declared[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		path := knownImports[name]
+		addImport(fset, file.node, path)
+		c.logf("Added import %q to %s\n", path, file.path)
+	}
+}
+
+// removeImports removes the imports of a file for which remove returns true, dropping
+// import declarations left empty
+func removeImports(fset *token.FileSet, node *ast.File, remove func(spec *ast.ImportSpec) bool) {
+	for i := 0; i < len(node.Decls); i++ {
+		decl, ok := node.Decls[i].(*ast.GenDecl)
+		if !ok || decl.Tok != token.IMPORT {
+			continue
+		}
+
+		var specs []ast.Spec
+		lastKept := decl.Lparen
+		for j, spec := range decl.Specs {
+			spec := spec.(*ast.ImportSpec)
+			if !remove(spec) {
+				specs = append(specs, spec)
+				lastKept = spec.End()
+				continue
+			}
+
+			// Removed imports leave no blank lines behind, not even the one before a
+			// trailing group they were all of
+			if j+1 < len(decl.Specs) {
+				collapseRemovedLines(fset, lastKept, spec, decl.Specs[j+1].Pos())
+			} else if tf := fset.File(spec.Pos()); tf != nil && decl.Rparen.IsValid() {
+				for merges := tf.Line(decl.Rparen) - tf.Line(lastKept) - 1; merges > 0; merges-- {
+					tf.MergeLine(tf.Line(lastKept) + 1)
+				}
+			}
+		}
+		decl.Specs = specs
+
+		if len(decl.Specs) == 0 {
+			node.Decls = append(node.Decls[:i], node.Decls[i+1:]...)
+			i--
+		}
+	}
+
+	node.Imports = nil
+	for _, decl := range node.Decls {
+		if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.IMPORT {
+			for _, spec := range decl.Specs {
+				node.Imports = append(node.Imports, spec.(*ast.ImportSpec))
+			}
+		}
+	}
+}
+
+// stdPath reports whether an import path is of the standard library, whose first element
+// has no dot unlike module paths
+func stdPath(path string) bool {
+	return !strings.Contains(strings.Split(path, "/")[0], ".")
+}
+
+// addImport adds an import of the given path to a file, to its first import declaration
+// if it has one
+func addImport(fset *token.FileSet, node *ast.File, path string) {
+	spec := &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(path)}}
+
+	for _, decl := range node.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.IMPORT {
+			continue
+		}
+
+		// A single import becomes a parenthesized list, with the new import last
+		single := !decl.Lparen.IsValid()
+		if single {
+			decl.Lparen = decl.TokPos + token.Pos(len(token.IMPORT.String()))
+			decl.Rparen = decl.End()
+		}
+		spec.Path.ValuePos = decl.Rparen
+		index := len(decl.Specs)
+
+		// Standard library packages join the group of the standard library imports, and
+		// other packages the group of the other imports, on the line of its last import so
+		// that imports added earlier open no gap
+		std, grouped := stdPath(path), false
+		for i, other := range decl.Specs {
+			if other, err := strconv.Unquote(other.(*ast.ImportSpec).Path.Value); err == nil && stdPath(other) == std {
+				spec.Path.ValuePos = decl.Specs[i].Pos()
+				index = i + 1
+				grouped = true
+			}
+		}
+
+		// Other packages start their group after the standard library imports otherwise,
+		// a blank line apart as goimports does
+		if !std && !grouped && len(decl.Specs) > 0 {
+			if pos, ok := openImportGroup(fset, decl, single); ok {
+				decl.Rparen = pos
+				spec.Path.ValuePos = pos
+			}
+		}
+		decl.Specs = append(decl.Specs[:index], append([]ast.Spec{spec}, decl.Specs[index:]...)...)
+		node.Imports = append(node.Imports, spec)
+		return
+	}
+
+	spec.Path.ValuePos = node.Name.End()
+	decl := &ast.GenDecl{TokPos: node.Name.End(), Tok: token.IMPORT, Specs: []ast.Spec{spec}}
+	node.Decls = append([]ast.Decl{decl}, node.Decls...)
+	node.Imports = append(node.Imports, spec)
+}
+
+// openImportGroup returns a position for an import to open a new group at the end of an
+// import declaration, and for its closing parenthesis to follow: one at least two lines
+// below the last import, so the printer leaves a blank line between them. The line of the
+// parenthesis, or the blank line after a declaration that was a single import, is moved
+// down a line to make room. It reports false when neither is at hand.
+func openImportGroup(fset *token.FileSet, decl *ast.GenDecl, single bool) (token.Pos, bool) {
+	tf := fset.File(decl.Rparen)
+	if tf == nil {
+		return token.NoPos, false
+	}
+	last := tf.Line(decl.Specs[len(decl.Specs)-1].Pos())
+
+	pos := decl.Rparen
+	if single {
+		// The line after the import has to be blank for nothing to come before the position
+		line := tf.Line(decl.Rparen) + 1
+		if line+1 > tf.LineCount() || tf.Offset(tf.LineStart(line+1)) != tf.Offset(tf.LineStart(line))+1 {
+			return token.NoPos, false
+		}
+		pos = tf.LineStart(line)
+	}
+	if tf.Line(pos) >= last+2 {
+		return pos, true
+	}
+
+	// The line of the position starts with it, so the newline ending the line before can
+	// become a line of its own
+	line := tf.Line(pos)
+	if tf.LineStart(line) != pos || tf.Offset(tf.LineStart(line-1)) >= tf.Offset(pos)-1 {
+		return token.NoPos, false
+	}
+	lines := slices.Insert(tf.Lines(), line-1, tf.Offset(pos)-1)
+	return pos, tf.SetLines(lines)
+}
+
+// importEdits returns the edits importing the given paths into a file that doesn't import
+// them yet: standard library packages join the group of the standard library imports and
+// other packages the group of the other imports, which is started when missing
+func importEdits(fset *token.FileSet, node *ast.File, src []byte, paths []string) []sourceEdit {
+	imported := make(map[string]bool)
+	for _, spec := range node.Imports {
+		imported[spec.Path.Value] = true
+	}
+	var std, other []string
+	for _, path := range paths {
+		if quoted := strconv.Quote(path); !imported[quoted] {
+			imported[quoted] = true
+			if stdPath(path) {
+				std = append(std, quoted)
+			} else {
+				other = append(other, quoted)
+			}
+		}
+	}
+	if len(std)+len(other) == 0 {
+		return nil
+	}
+
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+	lines := func(quoted []string) string {
+		var b strings.Builder
+		for _, q := range quoted {
+			b.WriteString("\t" + q + "\n")
+		}
+		return b.String()
+	}
+	block := func() string {
+		text := lines(std)
+		if len(std) > 0 && len(other) > 0 {
+			text += "\n"
+		}
+		return "import (\n" + text + lines(other) + ")"
+	}
+
+	var decl *ast.GenDecl
+	for _, d := range node.Decls {
+		if d, ok := d.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			decl = d
+			break
+		}
+	}
+	if decl == nil {
+		end := offset(node.Name.End())
+		return []sourceEdit{{start: end, end: end, text: "\n\n" + block()}}
+	}
+
+	// A single import becomes a parenthesized list holding it in its group
+	if !decl.Lparen.IsValid() {
+		spec := decl.Specs[0].(*ast.ImportSpec)
+		text := string(src[offset(spec.Pos()):offset(spec.End())])
+		if path, _ := strconv.Unquote(spec.Path.Value); stdPath(path) {
+			std = append([]string{text}, std...)
+		} else {
+			other = append([]string{text}, other...)
+		}
+		return []sourceEdit{{start: offset(decl.Pos()), end: offset(decl.End()), text: block()}}
+	}
+
+	var lastStd, lastOther *ast.ImportSpec
+	for _, spec := range decl.Specs {
+		spec := spec.(*ast.ImportSpec)
+		if path, _ := strconv.Unquote(spec.Path.Value); stdPath(path) {
+			lastStd = spec
+		} else {
+			lastOther = spec
+		}
+	}
+	afterLine := func(spec *ast.ImportSpec) int {
+		end := offset(spec.End())
+		if i := bytes.IndexByte(src[end:], '\n'); i >= 0 {
+			return end + i + 1
+		}
+		return len(src)
+	}
+
+	var edits []sourceEdit
+	if len(std) > 0 {
+		if lastStd != nil {
+			at := afterLine(lastStd)
+			edits = append(edits, sourceEdit{start: at, end: at, text: lines(std)})
+		} else {
+			first := decl.Specs[0].(*ast.ImportSpec)
+			at, _ := lineRange(src, offset(first.Pos()), offset(first.End()))
+			if first.Doc != nil {
+				at, _ = lineRange(src, offset(first.Doc.Pos()), offset(first.Doc.End()))
+			}
+			edits = append(edits, sourceEdit{start: at, end: at, text: lines(std) + "\n"})
+		}
+	}
+	if len(other) > 0 {
+		if lastOther != nil {
+			at := afterLine(lastOther)
+			edits = append(edits, sourceEdit{start: at, end: at, text: lines(other)})
+		} else {
+			at := offset(decl.Rparen)
+			for at > 0 && src[at-1] != '\n' {
+				at--
+			}
+			text := "\n" + lines(other)
+			if last := decl.Specs[len(decl.Specs)-1]; at <= offset(last.End()) {
+				at, text = offset(decl.Rparen), "\n\n"+lines(other)
+			}
+			edits = append(edits, sourceEdit{start: at, end: at, text: text})
+		}
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	return edits
+}
@@ -0,0 +1,295 @@
+package tableconv
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// indexedLoop is a loop over a table that uses its key as an index, which becomes the case
+// name once the table is a map. The key may index the table ('tests[i].a') or be formatted
+// ('t.Errorf("case %d: ...", i)'); other uses can't be rewritten.
+type indexedLoop struct {
+	rangeStmt *ast.RangeStmt
+	// indexes are the 'tests[i]' expressions in the body
+	indexes []*ast.IndexExpr
+	// formatted are the uses of the key as a formatting argument, and verbs are the
+	// format verbs printing them that need to become %s
+	formatted []*ast.Ident
+	verbs     []formatVerb
+	// caseDecl is a leading 'tc := tests[i]' statement, nil if there is none
+	caseDecl *ast.AssignStmt
+}
+
+// formatVerb is the position of a format verb in a format string literal
+type formatVerb struct {
+	lit        *ast.BasicLit
+	start, end int
+}
+
+// findIndexedLoop checks whether a loop over a table uses its key as an index. It returns
+// the loop when every use of the key can be rewritten, and a hazard describing the first
+// use that can't otherwise. Loops that don't use their key return neither.
+func findIndexedLoop(rangeStmt *ast.RangeStmt) (*indexedLoop, *loopHazard) {
+	table, ok := rangeStmt.X.(*ast.Ident)
+	if !ok || rangeStmt.Tok != token.DEFINE {
+		return nil, nil
+	}
+	key, ok := rangeStmt.Key.(*ast.Ident)
+	if !ok || isBlankIdent(key) || key.Obj == nil {
+		return nil, nil
+	}
+
+	loop := &indexedLoop{rangeStmt: rangeStmt}
+	isIndex := func(n ast.Node) (*ast.IndexExpr, bool) {
+		index, ok := n.(*ast.IndexExpr)
+		if !ok {
+			return nil, false
+		}
+		x, ok := index.X.(*ast.Ident)
+		i, ok2 := index.Index.(*ast.Ident)
+		return index, ok && ok2 && x.Name == table.Name && x.Obj == table.Obj && i.Obj == key.Obj
+	}
+
+	// Every use of the key must index the table or be formatted
+	parents := parentMap(rangeStmt.Body)
+	var hazard *loopHazard
+	ast.Inspect(rangeStmt.Body, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok || ident.Obj != key.Obj || hazard != nil {
+			return hazard == nil
+		}
+
+		if index, ok := isIndex(parents[ident]); ok && index.Index == ident {
+			loop.indexes = append(loop.indexes, index)
+			return true
+		}
+		if call, ok := parents[ident].(*ast.CallExpr); ok {
+			if verb, ok := formatVerbOf(call, ident); ok {
+				loop.formatted = append(loop.formatted, ident)
+				if verb.lit != nil {
+					loop.verbs = append(loop.verbs, verb)
+				}
+				return true
+			}
+		}
+
+		hazard = &loopHazard{pos: ident.Pos(), kind: hazardIndex, message: fmt.Sprintf("the loop uses the index %q of %s in a way that a case name can't stand in for once it is a map", key.Name, table.Name)}
+		return false
+	})
+	if hazard != nil {
+		return nil, hazard
+	}
+	if len(loop.indexes) == 0 && len(loop.formatted) == 0 {
+		return nil, nil
+	}
+
+	// Elements written through the index would only change a copy of the case
+	for _, index := range loop.indexes {
+		if writesThrough(index, parents) {
+			return nil, &loopHazard{pos: index.Pos(), kind: hazardIndex, message: fmt.Sprintf("the loop modifies the elements of %s, which a map can't do in place", table.Name)}
+		}
+	}
+
+	// A leading 'tc := tests[i]' already names the case
+	if len(rangeStmt.Body.List) > 0 && len(loop.indexes) > 0 && caseIdent(rangeStmt) == nil {
+		if assign, ok := rangeStmt.Body.List[0].(*ast.AssignStmt); ok && assign.Tok == token.DEFINE && len(assign.Lhs) == 1 && len(assign.Rhs) == 1 {
+			if _, ok := assign.Lhs[0].(*ast.Ident); ok {
+				if index, ok := isIndex(assign.Rhs[0]); ok && index == loop.indexes[0] {
+					loop.caseDecl = assign
+				}
+			}
+		}
+	}
+
+	return loop, nil
+}
+
+// caseIdent returns the value variable of a loop, nil if it has none or ignores it
+func caseIdent(rangeStmt *ast.RangeStmt) *ast.Ident {
+	ident, ok := rangeStmt.Value.(*ast.Ident)
+	if !ok || isBlankIdent(ident) {
+		return nil
+	}
+	return ident
+}
+
+// formatVerbOf finds the verb formatting an argument of a printf-style call, such as
+// t.Errorf or fmt.Sprintf, whose format is a string literal. The verb has no literal when
+// it already prints strings (%s, %q, %v). It reports whether the argument is formatted.
+func formatVerbOf(call *ast.CallExpr, arg ast.Expr) (formatVerb, bool) {
+	var name string
+	switch fun := call.Fun.(type) {
+	case *ast.SelectorExpr:
+		name = fun.Sel.Name
+	case *ast.Ident:
+		name = fun.Name
+	}
+	if !strings.HasSuffix(name, "f") {
+		return formatVerb{}, false
+	}
+
+	// The format is the first string literal argument, followed by its operands
+	for i, a := range call.Args {
+		lit, ok := a.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			continue
+		}
+
+		for j, operand := range call.Args[i+1:] {
+			if operand != arg {
+				continue
+			}
+			verbs := formatVerbs(lit.Value)
+			if j >= len(verbs) || verbs[j] == nil {
+				return formatVerb{}, false
+			}
+
+			start, end := verbs[j][0], verbs[j][1]
+			switch lit.Value[end-1] {
+			case 's', 'q', 'v':
+				return formatVerb{}, true
+			}
+			return formatVerb{lit: lit, start: start, end: end}, true
+		}
+		return formatVerb{}, false
+	}
+	return formatVerb{}, false
+}
+
+// writesThrough reports whether an element expression is assigned to, incremented, or has
+// its address taken, directly or through its fields and elements
+func writesThrough(expr ast.Expr, parents map[ast.Node]ast.Node) bool {
+	var node ast.Node = expr
+	for {
+		parent := parents[node]
+		switch p := parent.(type) {
+		case *ast.SelectorExpr:
+			if p.X != node {
+				return false
+			}
+		case *ast.IndexExpr:
+			if p.X != node {
+				return false
+			}
+		case *ast.ParenExpr, *ast.StarExpr:
+		case *ast.UnaryExpr:
+			return p.Op == token.AND
+		case *ast.AssignStmt:
+			for _, lhs := range p.Lhs {
+				if lhs == node {
+					return true
+				}
+			}
+			return false
+		case *ast.IncDecStmt:
+			return true
+		default:
+			return false
+		}
+		node = parent
+	}
+}
+
+// rewrite turns a loop using its key as an index into one whose key is the case name: the
+// key is renamed, each 'tests[i]' becomes the case variable, and format verbs printing the
+// key print strings instead
+// Change from: for i := range tests { ... tests[i].a ... }
+// To:          for name, tc := range tests { ... tc.a ... }
+// It reports whether the loop was rewritten.
+func (loop *indexedLoop) rewrite(fset *token.FileSet) bool {
+	rangeStmt := loop.rangeStmt
+	body := rangeStmt.Body
+
+	// Name the case after the value, a leading declaration, or else a name the body
+	// doesn't use
+	caseVar := caseIdent(rangeStmt)
+	switch {
+	case caseVar != nil:
+	case loop.caseDecl != nil:
+		caseVar = loop.caseDecl.Lhs[0].(*ast.Ident)
+		next := body.Rbrace
+		if len(body.List) > 1 {
+			next = body.List[1].Pos()
+		}
+		collapseRemovedLines(fset, body.Lbrace, loop.caseDecl, next)
+		body.List = body.List[1:]
+	case len(loop.indexes) > 0:
+		for _, name := range []string{"tc", "tt", "test"} {
+			if !usesName(body, name) {
+				caseVar = &ast.Ident{Name: name}
+				break
+			}
+		}
+		if caseVar == nil {
+			return false
+		}
+	}
+
+	// The key now holds the case name
+	keyName := freshKeyName(rangeStmt)
+	rangeStmt.Key = &ast.Ident{NamePos: rangeStmt.Key.Pos(), Name: keyName}
+
+	replaced := make(map[ast.Expr]bool)
+	for _, index := range loop.indexes {
+		replaced[index] = true
+	}
+	for _, ident := range loop.formatted {
+		replaced[ident] = true
+	}
+	replaceExprs(body, func(expr ast.Expr) ast.Expr {
+		if !replaced[expr] {
+			return nil
+		}
+		if _, ok := expr.(*ast.IndexExpr); ok {
+			return &ast.Ident{NamePos: expr.Pos(), Name: caseVar.Name}
+		}
+		return &ast.Ident{NamePos: expr.Pos(), Name: keyName}
+	})
+
+	// Replace verbs from the end so earlier spans stay valid
+	for i := len(loop.verbs) - 1; i >= 0; i-- {
+		verb := loop.verbs[i]
+		verb.lit.Value = verb.lit.Value[:verb.start] + "%s" + verb.lit.Value[verb.end:]
+	}
+
+	if caseVar != nil && rangeStmt.Value != caseVar {
+		caseVar.NamePos = rangeStmt.Key.End()
+		rangeStmt.Value = caseVar
+	}
+	return true
+}
+
+// usesName reports whether a block refers to a variable or other object by the given name;
+// field and method names don't count
+func usesName(body *ast.BlockStmt, name string) bool {
+	return usesNameExcept(body, name, nil)
+}
+
+// usesNameExcept is usesName ignoring the references to the given object, such as a
+// variable about to be renamed
+func usesNameExcept(body *ast.BlockStmt, name string, except *ast.Object) bool {
+	found := false
+	uses := func(ident *ast.Ident) bool {
+		return ident.Name == name && (except == nil || ident.Obj != except)
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			ast.Inspect(n.X, func(n ast.Node) bool {
+				if ident, ok := n.(*ast.Ident); ok && uses(ident) {
+					found = true
+				}
+				return !found
+			})
+			return false
+		case *ast.Ident:
+			if uses(n) {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,376 @@
+package tableconv
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// TableTest describes a table test found by Inventory: a slice or map literal of struct
+// cases a test function ranges over
+type TableTest struct {
+	// Test is the test function ranging over the table
+	Test string
+	// Table is the variable holding the table, "(inline)" for a table ranged over directly
+	Table string
+	// Pos is the position of the table literal
+	Pos token.Position
+	// Kind is "slice" or "map"
+	Kind string
+	// CaseType is the named case type, or "struct" for an anonymous case struct
+	CaseType string
+	// NameField is the field naming the cases of a slice-based table, empty when there is
+	// none and for map-based tables, which are keyed by name
+	NameField string
+	// Cases and Fields count the cases of the table and the fields of its case struct
+	Cases  int
+	Fields int
+	// Subtests is set when the loop runs each case in a t.Run subtest
+	Subtests bool
+	// Parallel is set when the subtests call t.Parallel
+	Parallel bool
+	// Flakiness lists the calls in the cases and the loop that make the test flaky
+	Flakiness []FlakySignal
+	// Bytes is the size of the table literal in bytes of source, and WidestCase and
+	// WidestBytes name the largest case and give its size
+	Bytes       int
+	WidestCase  string
+	WidestBytes int
+	// Large is set when the table exceeds the limits of Options.MaxCases or
+	// Options.MaxTableBytes
+	Large bool
+	// Unasserted holds the positions of the subtests of the loop, or of the loop without
+	// subtests, that never fail the test
+	Unasserted []token.Position
+}
+
+// Inventory finds the table tests of the test files under paths without modifying them,
+// in walk order. Tables whose case type isn't a struct declared in their package, such as
+// tables of strings or of imported types, are left out.
+func (c *Converter) Inventory(paths []string) ([]TableTest, error) {
+	dirs, err := c.packageDirs(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []TableTest
+	for _, dir := range dirs {
+		fset := token.NewFileSet()
+		files, err := packageFiles(fset, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			if !strings.HasSuffix(file.path, "_test.go") {
+				continue
+			}
+			for _, decl := range file.node.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Body == nil || !isTestFunc(fn) {
+					continue
+				}
+				ast.Inspect(fn.Body, func(n ast.Node) bool {
+					rangeStmt, ok := n.(*ast.RangeStmt)
+					if !ok || !tableExpr(rangeStmt.X) {
+						return true
+					}
+					if table, ok := inventoryTable(fset, files, file, fn, rangeStmt); ok {
+						table.Large = c.opts.MaxCases > 0 && table.Cases > c.opts.MaxCases ||
+							c.opts.MaxTableBytes > 0 && table.Bytes > c.opts.MaxTableBytes
+						tables = append(tables, table)
+					}
+					return true
+				})
+			}
+		}
+	}
+	return tables, nil
+}
+
+// inventoryTable describes the table a loop of a test ranges over
+func inventoryTable(fset *token.FileSet, files []*sourceFile, file *sourceFile, fn *ast.FuncDecl, rangeStmt *ast.RangeStmt) (TableTest, bool) {
+	lit := tableValue(rangeStmt.X).(*ast.CompositeLit)
+	table := TableTest{
+		Test:  fn.Name.Name,
+		Table: inlineTableName,
+		Pos:   fset.Position(lit.Pos()),
+		Cases: len(lit.Elts),
+	}
+	if ident, ok := rangeStmt.X.(*ast.Ident); ok {
+		table.Table = ident.Name
+	}
+
+	// Step 1: Resolve the case struct, anonymous or declared by the package
+	var elt ast.Expr
+	switch typ := lit.Type.(type) {
+	case *ast.ArrayType:
+		table.Kind, elt = "slice", typ.Elt
+	case *ast.MapType:
+		table.Kind, elt = "map", typ.Value
+	}
+	if star, ok := elt.(*ast.StarExpr); ok {
+		elt = star.X
+	}
+	structType, ok := elt.(*ast.StructType)
+	table.CaseType = "struct"
+	if !ok {
+		name, _ := baseName(elt)
+		if structType = namedStruct(files, name); structType == nil {
+			return TableTest{}, false
+		}
+		table.CaseType = name
+	}
+	for _, field := range structType.Fields.List {
+		table.Fields += max(len(field.Names), 1)
+	}
+	if table.Kind == "slice" {
+		table.NameField, _ = findNameField(structType)
+	}
+	table.Bytes = fset.Position(lit.End()).Offset - table.Pos.Offset
+	nameField, nameIndex := findNameField(structType)
+	for i, elt := range lit.Elts {
+		if size := fset.Position(elt.End()).Offset - fset.Position(elt.Pos()).Offset; size > table.WidestBytes {
+			table.WidestCase, table.WidestBytes = tableCaseName(elt, nameField, nameIndex, i), size
+		}
+	}
+
+	// Step 2: Look for subtests in the loop and t.Parallel calls in them, and for calls
+	// making the test flaky
+	ast.Inspect(rangeStmt.Body, func(n ast.Node) bool {
+		stmt, ok := n.(ast.Stmt)
+		if !ok {
+			return true
+		}
+		if lit := subtestFunc(stmt); lit != nil {
+			table.Subtests = true
+			if param := testingParam(lit.Type, "T"); param != "" && callsParallel(lit.Body, param) {
+				table.Parallel = true
+			}
+		}
+		return true
+	})
+	table.Flakiness = flakySignals(fset, file.node, fn, lit, rangeStmt, structType)
+	table.Unasserted = unassertedBodies(fset, file.node, fn, rangeStmt)
+	return table, true
+}
+
+// callsParallel reports whether a block calls t.Parallel() on the given *testing.T,
+// outside of nested subtests
+func callsParallel(body *ast.BlockStmt, param string) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok || found {
+			return false
+		}
+		if call, ok := n.(*ast.CallExpr); ok && len(call.Args) == 0 {
+			if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Parallel" {
+				if x, ok := sel.X.(*ast.Ident); ok && x.Name == param {
+					found = true
+				}
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// WriteInventory writes table tests as aligned columns, one table per line, followed by
+// totals
+func WriteInventory(w io.Writer, tables []TableTest) error {
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	orNone := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "POSITION\tTEST\tTABLE\tKIND\tCASE TYPE\tNAME FIELD\tCASES\tFIELDS\tSUBTESTS\tPARALLEL")
+	var slices, subtests, parallel int
+	for _, t := range tables {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n",
+			t.Pos, t.Test, t.Table, t.Kind, t.CaseType, orNone(t.NameField), t.Cases, t.Fields, yesNo(t.Subtests), yesNo(t.Parallel))
+		if t.Kind == "slice" {
+			slices++
+		}
+		if t.Subtests {
+			subtests++
+		}
+		if t.Parallel {
+			parallel++
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// Flakiness signals follow the table, since a test may have any number of them
+	flaky := 0
+	for _, t := range tables {
+		if len(t.Flakiness) == 0 {
+			continue
+		}
+		if flaky == 0 {
+			fmt.Fprintln(w, "\nFlakiness signals:")
+		}
+		flaky++
+		for _, signal := range t.Flakiness {
+			scope := "every case"
+			if signal.Case != "" {
+				scope = fmt.Sprintf("case %q", signal.Case)
+			}
+			fmt.Fprintf(w, "  %s: %s, %s: %s %s\n", signal.Pos, t.Test, scope, signal.Call, signal.Reason)
+		}
+	}
+
+	unasserted := 0
+	for _, t := range tables {
+		if len(t.Unasserted) == 0 {
+			continue
+		}
+		if unasserted == 0 {
+			fmt.Fprintln(w, "\nAsserting nothing:")
+		}
+		unasserted++
+		for _, pos := range t.Unasserted {
+			fmt.Fprintf(w, "  %s: %s, table %s: asserts nothing\n", pos, t.Test, t.Table)
+		}
+	}
+
+	// A table of one case is often a template nobody filled in, and reads better inline
+	single := 0
+	for _, t := range tables {
+		if t.Cases != 1 {
+			continue
+		}
+		if single == 0 {
+			fmt.Fprintln(w, "\nSingle-case tables:")
+		}
+		single++
+		fmt.Fprintf(w, "  %s: %s, table %s: has a single case, consider inlining it into the test\n", t.Pos, t.Test, t.Table)
+	}
+
+	large := 0
+	for _, t := range tables {
+		if !t.Large {
+			continue
+		}
+		if large == 0 {
+			fmt.Fprintln(w, "\nLarge tables:")
+		}
+		large++
+		fmt.Fprintf(w, "  %s: %s, table %s: %d cases in %d bytes, widest case %q of %d bytes; consider splitting it into several tests or moving its cases to testdata with export\n",
+			t.Pos, t.Test, t.Table, t.Cases, t.Bytes, t.WidestCase, t.WidestBytes)
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d table tests: %d slice-based, %d map-based, %d with subtests, %d parallel, %d possibly flaky, %d asserting nothing, %d single-case, %d large\n",
+		len(tables), slices, len(tables)-slices, subtests, parallel, flaky, unasserted, single, large)
+	return err
+}
+
+// jsonTableTest is the machine-readable form of a TableTest
+type jsonTableTest struct {
+	Test        string         `json:"test"`
+	Table       string         `json:"table"`
+	Position    jsonPosition   `json:"position"`
+	Kind        string         `json:"kind"`
+	CaseType    string         `json:"caseType"`
+	NameField   string         `json:"nameField,omitempty"`
+	Cases       int            `json:"cases"`
+	Fields      int            `json:"fields"`
+	Subtests    bool           `json:"subtests"`
+	Parallel    bool           `json:"parallel"`
+	Bytes       int            `json:"bytes"`
+	WidestCase  string         `json:"widestCase,omitempty"`
+	WidestBytes int            `json:"widestBytes"`
+	Large       bool           `json:"large"`
+	Flakiness   []jsonFlaky    `json:"flakiness"`
+	Unasserted  []jsonPosition `json:"unasserted"`
+}
+
+// jsonFlaky is the machine-readable form of a FlakySignal
+type jsonFlaky struct {
+	Position jsonPosition `json:"position"`
+	Case     string       `json:"case,omitempty"`
+	Call     string       `json:"call"`
+	Reason   string       `json:"reason"`
+}
+
+// WriteInventoryJSON writes table tests as an indented JSON array
+func WriteInventoryJSON(w io.Writer, tables []TableTest) error {
+	report := []jsonTableTest{}
+	for _, t := range tables {
+		flakiness := []jsonFlaky{}
+		for _, signal := range t.Flakiness {
+			flakiness = append(flakiness, jsonFlaky{Position: newJSONPosition(signal.Pos), Case: signal.Case, Call: signal.Call, Reason: signal.Reason})
+		}
+		unasserted := []jsonPosition{}
+		for _, pos := range t.Unasserted {
+			unasserted = append(unasserted, newJSONPosition(pos))
+		}
+		report = append(report, jsonTableTest{
+			Test:        t.Test,
+			Table:       t.Table,
+			Position:    newJSONPosition(t.Pos),
+			Kind:        t.Kind,
+			CaseType:    t.CaseType,
+			NameField:   t.NameField,
+			Cases:       t.Cases,
+			Fields:      t.Fields,
+			Subtests:    t.Subtests,
+			Parallel:    t.Parallel,
+			Bytes:       t.Bytes,
+			WidestCase:  t.WidestCase,
+			WidestBytes: t.WidestBytes,
+			Large:       t.Large,
+			Flakiness:   flakiness,
+			Unasserted:  unasserted,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// WriteInventoryCSV writes table tests as CSV with a header row, one table per row, for
+// slicing in a spreadsheet. Paths are relative to the working directory when below it,
+// and flags are written as true or false.
+func WriteInventoryCSV(w io.Writer, tables []TableTest) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"file", "line", "test", "table", "style", "case_type", "name_field", "cases", "fields", "subtests", "parallel", "flaky_signals", "unasserted", "bytes", "large"})
+	for _, t := range tables {
+		cw.Write([]string{
+			patchPath(t.Pos.Filename),
+			strconv.Itoa(t.Pos.Line),
+			t.Test,
+			t.Table,
+			t.Kind,
+			t.CaseType,
+			t.NameField,
+			strconv.Itoa(t.Cases),
+			strconv.Itoa(t.Fields),
+			strconv.FormatBool(t.Subtests),
+			strconv.FormatBool(t.Parallel),
+			strconv.Itoa(len(t.Flakiness)),
+			strconv.Itoa(len(t.Unasserted)),
+			strconv.Itoa(t.Bytes),
+			strconv.FormatBool(t.Large),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
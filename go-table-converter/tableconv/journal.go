@@ -0,0 +1,289 @@
+package tableconv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// JournalEntry records a file written by a conversion, with the edits turning its new
+// contents back into the old ones, so Revert can undo the run outside of version control
+type JournalEntry struct {
+	// Run identifies the conversion run, shared by the files a Converter writes
+	Run  string    `json:"run"`
+	Time time.Time `json:"time"`
+	// Path is absolute
+	Path string `json:"path"`
+	// Before and After are the hex-encoded SHA-256 hashes of the contents before and
+	// after the write; Before is empty when the write created the file
+	Before string `json:"before"`
+	After  string `json:"after"`
+	// Edits turn the contents after the write into the contents before it
+	Edits []PlanEdit `json:"edits"`
+}
+
+// JournalRun summarizes the entries of a run
+type JournalRun struct {
+	Run   string
+	Time  time.Time
+	Files int
+}
+
+// DefaultJournal returns the journal file the command-line tool records conversions in
+func DefaultJournal() (string, error) {
+	dir, err := DefaultCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "journal.jsonl"), nil
+}
+
+// newRunID returns an identifier for a conversion run, which sorts by the time it started
+func newRunID() string {
+	return time.Now().UTC().Format("20060102T150405.000000")
+}
+
+// newJournalEntry returns the entry of a write replacing before, nil for files that didn't
+// exist, by after
+func newJournalEntry(run, path string, before, after []byte) (JournalEntry, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	entry := JournalEntry{
+		Run:   run,
+		Time:  time.Now().UTC(),
+		Path:  abs,
+		After: contentHash(after),
+		Edits: []PlanEdit{},
+	}
+	if before != nil {
+		entry.Before = contentHash(before)
+		entry.Edits = byteEdits(after, before)
+	}
+	return entry, nil
+}
+
+// maxJournalBytes caps the size of a journal file: once an append takes it past the cap,
+// the oldest runs are dropped until it is back under half of it
+const maxJournalBytes = 32 << 20
+
+// appendJournal appends entries to a journal file, creating it if needed, and prunes it
+// once it grows past maxJournalBytes
+func appendJournal(path string, entries []JournalEntry) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating journal directory: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening journal: %v", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing journal: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing journal: %v", err)
+	}
+	return pruneJournal(path, maxJournalBytes)
+}
+
+// pruneJournal drops the oldest runs of a journal file larger than limit, keeping the
+// newest runs that fit in half of it, and always the last one
+func pruneJournal(path string, limit int64) error {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() <= limit {
+		return err
+	}
+	entries, err := LoadJournal(path)
+	if err != nil {
+		return err
+	}
+
+	lines := make([][]byte, len(entries))
+	for i, entry := range entries {
+		if lines[i], err = json.Marshal(entry); err != nil {
+			return err
+		}
+	}
+	start, size := len(entries), int64(0)
+	for start > 0 {
+		end, run := start, entries[start-1].Run
+		runSize := int64(0)
+		for start > 0 && entries[start-1].Run == run {
+			start--
+			runSize += int64(len(lines[start]) + 1)
+		}
+		if end < len(entries) && size+runSize > limit/2 {
+			start = end
+			break
+		}
+		size += runSize
+	}
+
+	var kept bytes.Buffer
+	for _, line := range lines[start:] {
+		kept.Write(line)
+		kept.WriteByte('\n')
+	}
+	if err := writeFileAtomic(path, kept.Bytes()); err != nil {
+		return fmt.Errorf("error pruning journal: %v", err)
+	}
+	return nil
+}
+
+// LoadJournal reads the entries of a journal file in the order they were recorded. A
+// missing journal has no entries.
+func LoadJournal(path string) ([]JournalEntry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading journal: %v", err)
+	}
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("error parsing journal %s:%d: %v", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// JournalRuns lists the runs of journal entries, oldest first
+func JournalRuns(entries []JournalEntry) []JournalRun {
+	var runs []JournalRun
+	for _, entry := range entries {
+		if n := len(runs); n > 0 && runs[n-1].Run == entry.Run {
+			runs[n-1].Files++
+			continue
+		}
+		runs = append(runs, JournalRun{Run: entry.Run, Time: entry.Time, Files: 1})
+	}
+	return runs
+}
+
+// Revert restores the files written by a run recorded in a journal, the last run when run
+// is empty, and returns the paths of the files it restored. Only the given files are
+// restored unless paths is empty. Files changed since the run are left alone, and so is
+// every other file: either the whole selection is reverted or nothing is, with the files
+// restored before a failed restore written back. The reverted entries are removed from
+// the journal.
+func Revert(journal, run string, paths []string) ([]string, error) {
+	entries, err := LoadJournal(journal)
+	if err != nil {
+		return nil, err
+	}
+	if run == "" {
+		if len(entries) == 0 {
+			return nil, fmt.Errorf("no conversions recorded in %s", journal)
+		}
+		run = entries[len(entries)-1].Run
+	}
+	var wanted []string
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		wanted = append(wanted, abs)
+	}
+
+	// Undo the writes newest first, in memory, so files written twice are restored
+	// step by step
+	selected := make([]bool, len(entries))
+	current := make(map[string][]byte)
+	contents := make(map[string][]byte)
+	var order []string
+	found := false
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Run != run {
+			continue
+		}
+		found = true
+		if len(wanted) > 0 && !slices.Contains(wanted, entry.Path) {
+			continue
+		}
+		selected[i] = true
+
+		cur, ok := contents[entry.Path]
+		if !ok {
+			if cur, err = os.ReadFile(entry.Path); err != nil {
+				return nil, fmt.Errorf("error reading file: %v", err)
+			}
+			current[entry.Path] = cur
+			order = append(order, entry.Path)
+		}
+		if contentHash(cur) != entry.After {
+			return nil, fmt.Errorf("%s changed since run %s, not reverting", entry.Path, run)
+		}
+		if entry.Before == "" {
+			contents[entry.Path] = nil
+			continue
+		}
+		edits, err := sourceEdits(entry.Edits, len(cur))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", entry.Path, err)
+		}
+		prior := applyEdits(cur, edits)
+		if contentHash(prior) != entry.Before {
+			return nil, fmt.Errorf("%s: journal edits don't restore the recorded contents", entry.Path)
+		}
+		contents[entry.Path] = prior
+	}
+	if !found {
+		return nil, fmt.Errorf("no run %s in %s", run, journal)
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("run %s wrote none of the given files", run)
+	}
+
+	var staged []stagedWrite
+	for _, path := range order {
+		w := stagedWrite{path, current[path], contents[path]}
+		if err := w.write(); err != nil {
+			return nil, rollbackWrites(staged, fmt.Errorf("error restoring file: %v", err))
+		}
+		staged = append(staged, w)
+	}
+	restored := order
+
+	var kept bytes.Buffer
+	enc := json.NewEncoder(&kept)
+	for i, entry := range entries {
+		if !selected[i] {
+			if err := enc.Encode(entry); err != nil {
+				return restored, err
+			}
+		}
+	}
+	if err := writeFileAtomic(journal, kept.Bytes()); err != nil {
+		return restored, fmt.Errorf("error writing journal: %v", err)
+	}
+	return restored, nil
+}
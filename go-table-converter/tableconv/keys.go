@@ -0,0 +1,265 @@
+package tableconv
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// KeyStrategy names the cases of tables without a name field
+type KeyStrategy string
+
+const (
+	// KeysNone leaves tables without a name field alone
+	KeysNone KeyStrategy = ""
+	// KeysIndex numbers the cases in table order ("case_01")
+	KeysIndex KeyStrategy = "index"
+	// KeysFields describes the cases by their field values ("a=2,b=3")
+	KeysFields KeyStrategy = "fields"
+)
+
+// maxKeyValueLen bounds the field values spelled out in generated keys; longer values,
+// such as nested literals, are left out
+const maxKeyValueLen = 20
+
+// nameCases generates names for the cases of a table that have none: every case of a
+// table without a name field, and the cases whose name is empty or blank, which would
+// make an unhelpful map key and subtest name. Blank names are reported as they are replaced.
+func (c *Converter) nameCases(fset *token.FileSet, candidate *tableCandidate, cases []*tableCase) []Diagnostic {
+	if candidate.table.nameField == "" {
+		for i, tc := range cases {
+			tc.name = generatedKey(fset, c.opts.Keys, candidate.table.structType, cases, i)
+		}
+		c.logf("Generated case names for table test variable %s\n", candidate.name())
+		return nil
+	}
+
+	// Blank names are described by the field values unless another strategy is chosen
+	strategy := c.opts.Keys
+	if strategy == KeysNone {
+		strategy = KeysFields
+	}
+
+	var diags []Diagnostic
+	for i, tc := range cases {
+		name := caseNameOf(fset, tc)
+		if !name.constant || strings.TrimSpace(name.text) != "" {
+			continue
+		}
+
+		key := generatedKey(fset, strategy, candidate.table.structType, cases, i)
+		diags = append(diags, Diagnostic{
+			Table:   candidate.name(),
+			Pos:     fset.Position(tc.lit.Pos()),
+			Message: fmt.Sprintf("blank case name %s replaced with %s", name, key.Value),
+		})
+		tc.name = key
+	}
+	return diags
+}
+
+// generatedKey generates a name for the i-th case of a table. Cases the field values can't
+// describe are numbered instead.
+func generatedKey(fset *token.FileSet, strategy KeyStrategy, structType *ast.StructType, cases []*tableCase, i int) *ast.BasicLit {
+	key := ""
+	if strategy == KeysFields {
+		key = fieldsKey(fset, fieldNames(structType), cases[i])
+	}
+	if key == "" {
+		width := max(2, len(strconv.Itoa(len(cases))))
+		key = fmt.Sprintf("case_%0*d", width, i+1)
+	}
+	return &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(key)}
+}
+
+// fieldsKey describes a case by its field values, leaving out its name field
+func fieldsKey(fset *token.FileSet, names []string, tc *tableCase) string {
+	var parts []string
+	for i, elt := range tc.lit.Elts {
+		if i == tc.nameIndex {
+			continue
+		}
+
+		name := ""
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok {
+				name = key.Name
+			}
+			elt = kv.Value
+		} else if i < len(names) {
+			name = names[i]
+		}
+
+		value := keyValue(fset, elt)
+		if name == "" || value == "" {
+			continue
+		}
+		parts = append(parts, name+"="+value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// keyValue returns the text of a field value for a generated key: the value of string
+// constants and the source of other short expressions, or empty for values too long to
+// spell out
+func keyValue(fset *token.FileSet, expr ast.Expr) string {
+	if value := constValue(expr); value != nil && value.Kind() == constant.String {
+		s := constant.StringVal(value)
+		if s == "" {
+			return `""`
+		}
+		if len(s) > maxKeyValueLen {
+			return ""
+		}
+		return s
+	}
+
+	text := nodeString(fset, expr)
+	if len(text) > maxKeyValueLen || strings.Contains(text, "\n") {
+		return ""
+	}
+	return text
+}
+
+// fieldNames lists the fields of a struct type in the order positional literals set them.
+// Embedded fields are named after their type.
+func fieldNames(structType *ast.StructType) []string {
+	var names []string
+	for _, field := range structType.Fields.List {
+		if len(field.Names) == 0 {
+			names = append(names, embeddedName(field.Type))
+			continue
+		}
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+	}
+	return names
+}
+
+// embeddedName returns the field name of an embedded type
+func embeddedName(typ ast.Expr) string {
+	switch t := typ.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	case *ast.IndexExpr:
+		return embeddedName(t.X)
+	case *ast.IndexListExpr:
+		return embeddedName(t.X)
+	}
+	return ""
+}
+
+// onlyRanged reports whether a table is only used by loops ranging over it. Slices of
+// structs without a name field are often plain test data rather than tables, so they are
+// left alone unless nothing but loops would see the change.
+func onlyRanged(node *ast.File, candidate *tableCandidate) bool {
+	if candidate.ident == nil {
+		return true
+	}
+
+	uses, ranged := 0, 0
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			if n != candidate.ident && n.Obj != nil && n.Obj == candidate.ident.Obj {
+				uses++
+			}
+		case *ast.RangeStmt:
+			if x, ok := n.X.(*ast.Ident); ok && x.Obj != nil && x.Obj == candidate.ident.Obj {
+				ranged++
+			}
+		}
+		return true
+	})
+	return uses > 0 && uses == ranged
+}
+
+// NameCheck checks case names for subtest names that are awkward to select with
+// 'go test -run'
+type NameCheck string
+
+const (
+	// NameCheckNone leaves case names alone
+	NameCheckNone NameCheck = ""
+	// NameCheckWarn reports awkward case names
+	NameCheckWarn NameCheck = "warn"
+	// NameCheckSanitize replaces spaces in case names with underscores and strips slashes,
+	// reporting the other awkward names
+	NameCheckSanitize NameCheck = "sanitize"
+)
+
+// runMetachars are the regular expression metacharacters that need escaping in -run patterns
+const runMetachars = `.*+?()[]{}|^$\`
+
+// checkCaseNames reports the case names of a table that are awkward to select with -run:
+// go test turns spaces into underscores, "/" separates subtest levels, and regular
+// expression metacharacters need escaping. With NameCheckSanitize, spaces and slashes are
+// fixed in place.
+func (c *Converter) checkCaseNames(fset *token.FileSet, candidate *tableCandidate, cases []*tableCase) []Diagnostic {
+	if c.opts.NameCheck == NameCheckNone {
+		return nil
+	}
+
+	var diags []Diagnostic
+	for _, tc := range cases {
+		name := caseNameOf(fset, tc)
+		if !name.constant {
+			continue
+		}
+
+		diag := Diagnostic{Table: candidate.name(), Pos: fset.Position(tc.lit.Pos())}
+		text := name.text
+		if c.opts.NameCheck == NameCheckSanitize {
+			text = sanitizeName(text)
+			if text != name.text {
+				tc.name = &ast.BasicLit{ValuePos: tc.name.Pos(), Kind: token.STRING, Value: strconv.Quote(text)}
+				diag.Message = fmt.Sprintf("case name %s sanitized to %q", name, text)
+				diags = append(diags, diag)
+			}
+		}
+
+		if problems := nameProblems(text); len(problems) > 0 {
+			diag.Message = fmt.Sprintf("case name %q is awkward to select with go test -run: it contains %s", text, strings.Join(problems, ", "))
+			diags = append(diags, diag)
+		}
+	}
+	return diags
+}
+
+// nameProblems describes what makes a subtest name awkward to select with -run
+func nameProblems(name string) []string {
+	var problems []string
+	if strings.ContainsFunc(name, unicode.IsSpace) {
+		problems = append(problems, "spaces")
+	}
+	if strings.Contains(name, "/") {
+		problems = append(problems, `"/"`)
+	}
+	if strings.ContainsAny(name, runMetachars) {
+		problems = append(problems, "regular expression metacharacters")
+	}
+	return problems
+}
+
+// sanitizeName replaces the spaces of a case name with underscores, as go test does for
+// subtest names, and strips slashes
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '/':
+			return -1
+		case unicode.IsSpace(r):
+			return '_'
+		}
+		return r
+	}, name)
+}
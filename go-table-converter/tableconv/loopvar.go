@@ -0,0 +1,142 @@
+package tableconv
+
+import (
+	"bufio"
+	"bytes"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// loopvarMinor is the minor version of Go 1.22, the first to give each loop iteration its
+// own loop variables
+const loopvarMinor = 22
+
+// sharesLoopVars reports whether the module holding a file declares a go version before
+// 1.22, whose loops share their variables between iterations. Files outside a module, and
+// standard input, are looked up from the working directory.
+func sharesLoopVars(path string) bool {
+	version := moduleGoVersion(filepath.Dir(path))
+	if version == "" {
+		return false
+	}
+
+	major, rest, _ := strings.Cut(version, ".")
+	minor, _, _ := strings.Cut(rest, ".")
+	majorNum, err := strconv.Atoi(major)
+	if err != nil {
+		return false
+	}
+	minorNum, err := strconv.Atoi(minor)
+	if err != nil {
+		return false
+	}
+	return majorNum == 1 && minorNum < loopvarMinor
+}
+
+// moduleGoVersion returns the go directive of the go.mod file governing a directory, or ""
+// when there is none
+func moduleGoVersion(dir string) string {
+	scanner := bufio.NewScanner(bytes.NewReader(readModFile(dir)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "go" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// readModFile returns the contents of the go.mod file governing a directory, or nil when
+// there is none
+func readModFile(dir string) []byte {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			return data
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// copyLoopVars rebinds the loop variables captured by the parallel subtests of loops over
+// converted tables in a function ('tc := tc'), since before Go 1.22 they would all see the
+// last case. It reports whether any were added.
+func copyLoopVars(fn *ast.FuncDecl, vars tableVars) bool {
+	modified := false
+	forRangedLoops(fn, vars, func(rangeStmt *ast.RangeStmt) {
+		// The key and value, in that order
+		var loopVars []string
+		for _, expr := range []ast.Expr{rangeStmt.Key, rangeStmt.Value} {
+			if ident, ok := expr.(*ast.Ident); ok && !isBlankIdent(ident) && rangeStmt.Tok == token.DEFINE {
+				loopVars = append(loopVars, ident.Name)
+			}
+		}
+
+		var captured []string
+		for _, name := range loopVars {
+			if rebinds(rangeStmt.Body, name) {
+				continue
+			}
+			for _, stmt := range rangeStmt.Body.List {
+				lit := subtestFunc(stmt)
+				if lit == nil {
+					continue
+				}
+				if param := testingParam(lit.Type, "T"); param != "" && startsWithParallel(lit.Body, param) && usesIdents(lit.Body, name) {
+					captured = append(captured, name)
+					break
+				}
+			}
+		}
+		if len(captured) == 0 {
+			return
+		}
+
+		// Place the copies right after the opening brace, like t.Parallel() calls
+		pos := rangeStmt.Body.Lbrace + 1
+		copies := make([]ast.Stmt, 0, len(captured)+len(rangeStmt.Body.List))
+		for _, name := range captured {
+			copies = append(copies, &ast.AssignStmt{
+				Lhs:    []ast.Expr{&ast.Ident{NamePos: pos, Name: name}},
+				TokPos: pos,
+				Tok:    token.DEFINE,
+				Rhs:    []ast.Expr{&ast.Ident{NamePos: pos, Name: name}},
+			})
+		}
+		rangeStmt.Body.List = append(copies, rangeStmt.Body.List...)
+		modified = true
+	})
+	return modified
+}
+
+// rebinds reports whether a loop body already copies a loop variable ('tc := tc')
+func rebinds(body *ast.BlockStmt, name string) bool {
+	for _, stmt := range body.List {
+		assign, ok := stmt.(*ast.AssignStmt)
+		if !ok || assign.Tok != token.DEFINE || len(assign.Lhs) != len(assign.Rhs) {
+			continue
+		}
+		for i, lhs := range assign.Lhs {
+			l, ok := lhs.(*ast.Ident)
+			r, ok2 := assign.Rhs[i].(*ast.Ident)
+			if ok && ok2 && l.Name == name && r.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
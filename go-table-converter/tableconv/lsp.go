@@ -0,0 +1,326 @@
+package tableconv
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspCodeAction struct {
+	Title string           `json:"title"`
+	Kind  string           `json:"kind"`
+	Edit  lspWorkspaceEdit `json:"edit"`
+}
+
+type lspWorkspaceEdit struct {
+	Changes map[string][]lspTextEdit `json:"changes"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+// lspParams holds the parameters of the requests and notifications the server handles
+type lspParams struct {
+	TextDocument   lspTextDocument `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+	Range lspRange `json:"range"`
+}
+
+// lspServer is the state of a language server: the documents open in the editor by path,
+// whose contents stand in for the files on disk, and the fixes last found in each directory
+type lspServer struct {
+	c     *Converter
+	out   *bufio.Writer
+	docs  map[string]lspDocument
+	fixes map[string]lspFixes
+}
+
+// lspDocument is a document open in the editor, under the URI the editor named it by
+type lspDocument struct {
+	uri  string
+	text []byte
+}
+
+// lspFixes are the fixes of the tables of a directory, along with a hash of the contents
+// of its Go files they were found in
+type lspFixes struct {
+	sum   [sha256.Size]byte
+	fixes []TableFix
+}
+
+// ServeLSP serves the Language Server Protocol on r and w, as the 'lsp' subcommand does
+// for editors over standard input and output, until the client asks it to exit. Code
+// actions at a position in a slice-based table test offer to convert the table, as
+// workspace edits of the documents left for the editor to apply; nothing is written.
+// Documents are kept in sync by full contents.
+func (c *Converter) ServeLSP(r io.Reader, w io.Writer) error {
+	// Imported packages are loaded once for the whole session
+	if c.importer == nil {
+		c.importer = newImporter()
+	}
+	s := &lspServer{c: c, out: bufio.NewWriter(w), docs: make(map[string]lspDocument), fixes: make(map[string]lspFixes)}
+
+	in := bufio.NewReader(r)
+	for {
+		msg, err := readLSPMessage(in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, rpcErr := s.handle(msg)
+		if msg.ID == nil {
+			continue
+		}
+		if err := s.write(rpcResponse(msg.ID, result, rpcErr)); err != nil {
+			return err
+		}
+	}
+}
+
+// readLSPMessage reads a message framed by a Content-Length header
+func readLSPMessage(r *bufio.Reader) (rpcMessage, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" && length < 0 {
+				return rpcMessage{}, io.EOF
+			}
+			return rpcMessage{}, fmt.Errorf("error reading message header: %v", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return rpcMessage{}, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return rpcMessage{}, fmt.Errorf("message without Content-Length")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return rpcMessage{}, fmt.Errorf("error reading message: %v", err)
+	}
+	var msg rpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return rpcMessage{}, fmt.Errorf("error parsing message: %v", err)
+	}
+	return msg, nil
+}
+
+// write sends a message framed by a Content-Length header
+func (s *lspServer) write(msg rpcMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n", len(body))
+	s.out.Write(body)
+	return s.out.Flush()
+}
+
+// handle handles a request or notification, returning the result of requests
+func (s *lspServer) handle(msg rpcMessage) (any, *rpcError) {
+	var params lspParams
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				// Documents are synced by their full contents
+				"textDocumentSync":   1,
+				"codeActionProvider": map[string]any{"codeActionKinds": []string{"refactor.rewrite"}},
+			},
+			"serverInfo": map[string]string{"name": "tabletests", "version": Version},
+		}, nil
+	case "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		if path, ok := uriPath(params.TextDocument.URI); ok {
+			s.docs[path] = lspDocument{params.TextDocument.URI, []byte(params.TextDocument.Text)}
+		}
+	case "textDocument/didChange":
+		if path, ok := uriPath(params.TextDocument.URI); ok && len(params.ContentChanges) > 0 {
+			s.docs[path] = lspDocument{params.TextDocument.URI, []byte(params.ContentChanges[len(params.ContentChanges)-1].Text)}
+		}
+	case "textDocument/didClose":
+		if path, ok := uriPath(params.TextDocument.URI); ok {
+			delete(s.docs, path)
+		}
+	case "textDocument/codeAction":
+		actions, err := s.codeActions(params.TextDocument.URI, params.Range)
+		if err != nil {
+			return nil, &rpcError{rpcInternalError, err.Error()}
+		}
+		return actions, nil
+	default:
+		if msg.ID != nil {
+			return nil, &rpcError{rpcMethodNotFound, fmt.Sprintf("method %s not supported", msg.Method)}
+		}
+	}
+	return nil, nil
+}
+
+// codeActions returns an action converting each slice-based table whose conversion
+// changes lines in the range of a document. The other files of its directory take part in
+// the conversion and its type check as they are open in the editor, or else as saved.
+func (s *lspServer) codeActions(uri string, rng lspRange) ([]lspCodeAction, error) {
+	path, ok := uriPath(uri)
+	if !ok || !strings.HasSuffix(path, ".go") {
+		return []lspCodeAction{}, nil
+	}
+	fixes, err := s.tableFixes(path)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []lspCodeAction{}
+	for _, fix := range fixes {
+		if fix.Table.Pos.Filename != path {
+			continue
+		}
+		edit := lspWorkspaceEdit{Changes: make(map[string][]lspTextEdit)}
+		touches := false
+		for _, e := range fix.Edits {
+			// Edits count lines from 1 and the protocol from 0
+			start, end := e.StartLine-1, e.EndLine-1
+			if e.Path == path && start <= rng.End.Line && rng.Start.Line < max(end, start+1) {
+				touches = true
+			}
+			uri := s.uri(e.Path)
+			edit.Changes[uri] = append(edit.Changes[uri], lspTextEdit{
+				Range:   lspRange{Start: lspPosition{Line: start}, End: lspPosition{Line: end}},
+				NewText: e.NewText,
+			})
+		}
+		if touches {
+			actions = append(actions, lspCodeAction{
+				Title: fmt.Sprintf("Convert %s to a map-based table test", fix.Table.Name),
+				Kind:  "refactor.rewrite",
+				Edit:  edit,
+			})
+		}
+	}
+	return actions, nil
+}
+
+// tableFixes returns the fixes of the tables of a document and the other files the
+// converter takes in its directory. Code actions are asked for on every move of the
+// cursor, so the fixes are only found again once a Go file of the directory changes.
+func (s *lspServer) tableFixes(path string) ([]TableFix, error) {
+	dir := filepath.Dir(path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".go") {
+			names = append(names, filepath.Join(dir, entry.Name()))
+		}
+	}
+	// Documents may be open before they are first saved
+	for path := range s.docs {
+		if filepath.Dir(path) == dir && !slices.Contains(names, path) && strings.HasSuffix(path, ".go") {
+			names = append(names, path)
+		}
+	}
+	slices.Sort(names)
+
+	h := sha256.New()
+	overlay := make(map[string][]byte)
+	var paths []string
+	var srcs [][]byte
+	for _, name := range names {
+		doc, open := s.docs[name]
+		src := doc.text
+		if open {
+			overlay[name] = src
+		} else if src, err = os.ReadFile(name); err != nil {
+			return nil, err
+		}
+		converting := name == path || s.c.wantFile(name)
+		fmt.Fprintf(h, "%s\x00%t\x00%d\x00", name, converting, len(src))
+		h.Write(src)
+		if converting {
+			paths = append(paths, name)
+			srcs = append(srcs, src)
+		}
+	}
+
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	if cached, ok := s.fixes[dir]; ok && cached.sum == sum {
+		return cached.fixes, nil
+	}
+	conv := *s.c
+	conv.overlay = overlay
+	_, fixes := conv.FixTables(paths, srcs)
+	s.fixes[dir] = lspFixes{sum, fixes}
+	return fixes, nil
+}
+
+// uri returns the URI of a file, as the editor named it if it is open
+func (s *lspServer) uri(path string) string {
+	if doc, ok := s.docs[filepath.Clean(path)]; ok {
+		return doc.uri
+	}
+	return pathURI(path)
+}
+
+// uriPath returns the clean path of a file URI, which documents are known by
+func uriPath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	return filepath.Clean(filepath.FromSlash(u.Path)), true
+}
+
+// pathURI returns the file URI of an absolute path
+func pathURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
@@ -0,0 +1,110 @@
+package tableconv
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// markdownListLimit is the number of entries listed under each heading of the markdown
+// summary before the rest are only counted, keeping it short enough for a pull request
+const markdownListLimit = 20
+
+// WriteMarkdown writes a compact markdown summary of a run for a pull request description
+// or $GITHUB_STEP_SUMMARY: the totals, a table of counts per package, the tables left
+// unconverted because of a warning (notable skips), and the tables converted despite one
+// (risky conversions), which are worth a closer look in review
+func WriteMarkdown(w io.Writer, result ConversionResult) error {
+	var b strings.Builder
+	b.WriteString("## Table test conversion\n\n")
+	count := func(n int, noun string) string {
+		if n == 1 {
+			return "1 " + noun
+		}
+		return fmt.Sprintf("%d %ss", n, noun)
+	}
+	fmt.Fprintf(&b, "%s converted in %d of %s", count(result.TablesConverted, "table"), result.FilesModified, count(result.FilesProcessed, "file"))
+	if result.TablesSkipped > 0 {
+		fmt.Fprintf(&b, ", %d skipped", result.TablesSkipped)
+	}
+	if result.TablesSuppressed > 0 {
+		fmt.Fprintf(&b, ", %d suppressed", result.TablesSuppressed)
+	}
+	if result.FilesCached > 0 {
+		fmt.Fprintf(&b, " (%d unchanged since the last run)", result.FilesCached)
+	}
+	fmt.Fprintf(&b, ", %s, %s.\n", count(len(result.Diagnostics), "warning"), count(len(result.Errors), "error"))
+
+	// Step 1: Count each package directory, and sort the warnings by whether their table
+	// was converted
+	packages := result.Packages()
+	if len(packages) > 0 {
+		b.WriteString("\n| Package | Files | Modified | Tables converted | Tables skipped | Warnings | Errors |\n")
+		b.WriteString("| --- | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+		for _, p := range packages {
+			fmt.Fprintf(&b, "| `%s` | %d | %d | %d | %d | %d | %d |\n",
+				filepath.ToSlash(patchPath(p.Dir)), p.Files, p.FilesModified, p.TablesConverted, p.TablesSkipped, p.Warnings, p.Errors)
+		}
+	}
+
+	var skips, risky []Diagnostic
+	for _, file := range result.Files {
+		converted := make(map[string]bool)
+		for _, table := range file.Tables {
+			converted[table.Name] = true
+		}
+		for _, diag := range file.Diagnostics {
+			if converted[diag.Table] {
+				risky = append(risky, diag)
+			} else {
+				skips = append(skips, diag)
+			}
+		}
+	}
+
+	// Step 2: List the skips, risky conversions, and errors
+	list := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", title)
+		for i, item := range items {
+			if i == markdownListLimit {
+				fmt.Fprintf(&b, "- …and %d more\n", len(items)-markdownListLimit)
+				break
+			}
+			fmt.Fprintf(&b, "- %s\n", item)
+		}
+	}
+	diagItems := func(diags []Diagnostic) []string {
+		var items []string
+		for _, diag := range diags {
+			items = append(items, fmt.Sprintf("`%s:%d` `%s`: %s", patchPath(diag.Pos.Filename), diag.Pos.Line, diag.Table, markdownEscape(diag.Message)))
+		}
+		return items
+	}
+	list("Notable skips", diagItems(skips))
+	list("Risky conversions", diagItems(risky))
+	var errs []string
+	for _, err := range result.Errors {
+		errs = append(errs, fmt.Sprintf("`%s`: %s", patchPath(err.Path), markdownEscape(err.Message)))
+	}
+	list("Errors", errs)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownEscape keeps a message from being read as markdown, which messages quoting code
+// ('case {"a", 1}') or paths with underscores would otherwise be
+func markdownEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("\\`*_[]<>|#", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
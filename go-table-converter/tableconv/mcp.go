@@ -0,0 +1,176 @@
+package tableconv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// mcpProtocolVersion is the version of the Model Context Protocol the server speaks
+const mcpProtocolVersion = "2024-11-05"
+
+// mcpTool describes a tool the MCP server offers
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// mcpPathSchema is the input schema of the tools, which all take a path
+var mcpPathSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"path": map[string]any{
+			"type":        "string",
+			"description": "Go file or directory, walked recursively",
+		},
+	},
+	"required": []string{"path"},
+}
+
+// mcpTools are the tools of the MCP server
+var mcpTools = []mcpTool{
+	{
+		Name:        "detect_tables",
+		Description: "List the slice-based table tests under a path that would be converted to map-based ones, with warnings and the tables left alone and why, as the JSON report of tabletests. Nothing is modified.",
+		InputSchema: mcpPathSchema,
+	},
+	{
+		Name:        "diff_preview",
+		Description: "Show the conversion of the slice-based table tests under a path as a unified diff, without modifying files.",
+		InputSchema: mcpPathSchema,
+	},
+	{
+		Name:        "convert_file",
+		Description: "Convert the slice-based table tests under a path to map-based ones, writing the files that still compile once converted, and return the JSON report of the run.",
+		InputSchema: mcpPathSchema,
+	},
+}
+
+// mcpContent is a piece of the content of a tool result
+type mcpContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// mcpToolResult is the result of a tool call; failures are reported in the result rather
+// than as protocol errors, so that the assistant sees them
+type mcpToolResult struct {
+	Content []mcpContent `json:"content"`
+	IsError bool         `json:"isError,omitempty"`
+}
+
+// mcpCallParams are the parameters of a tools/call request
+type mcpCallParams struct {
+	Name      string `json:"name"`
+	Arguments struct {
+		Path string `json:"path"`
+	} `json:"arguments"`
+}
+
+// ServeMCP serves the Model Context Protocol on r and w, as the 'mcp' subcommand does for
+// AI assistants over standard input and output, until r ends. Messages are JSON-RPC
+// messages on lines of their own. The tools detect_tables and diff_preview never modify
+// files; convert_file converts with the options of the converter, so a dry-run converter
+// leaves files alone there too.
+func (c *Converter) ServeMCP(r io.Reader, w io.Writer) error {
+	out := bufio.NewWriter(w)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg rpcMessage
+		var result any
+		var rpcErr *rpcError
+		if err := json.Unmarshal(line, &msg); err != nil {
+			// Messages that can't be parsed are answered with a null ID
+			msg.ID = json.RawMessage("null")
+			rpcErr = &rpcError{rpcParseError, fmt.Sprintf("error parsing message: %v", err)}
+		} else {
+			result, rpcErr = c.handleMCP(msg)
+			if msg.ID == nil {
+				continue
+			}
+		}
+
+		body, err := json.Marshal(rpcResponse(msg.ID, result, rpcErr))
+		if err != nil {
+			return err
+		}
+		out.Write(body)
+		out.WriteByte('\n')
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// handleMCP handles a request or notification, returning the result of requests
+func (c *Converter) handleMCP(msg rpcMessage) (any, *rpcError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": mcpProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": "tabletests", "version": Version},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": mcpTools}, nil
+	case "tools/call":
+		var params mcpCallParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+		if params.Arguments.Path == "" {
+			return nil, &rpcError{rpcInvalidParams, "missing path argument"}
+		}
+		return c.callMCPTool(params.Name, params.Arguments.Path)
+	}
+	if msg.ID != nil {
+		return nil, &rpcError{rpcMethodNotFound, fmt.Sprintf("method %s not supported", msg.Method)}
+	}
+	return nil, nil
+}
+
+// callMCPTool runs a tool on a path
+func (c *Converter) callMCPTool(name, path string) (any, *rpcError) {
+	conv := *c
+	switch name {
+	case "detect_tables", "diff_preview":
+		conv.opts.DryRun = true
+	case "convert_file":
+	default:
+		return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("unknown tool %q", name)}
+	}
+	if _, err := os.Stat(path); err != nil {
+		return mcpToolResult{Content: []mcpContent{{"text", err.Error()}}, IsError: true}, nil
+	}
+
+	result, err := conv.ConvertPaths([]string{path})
+	if err != nil {
+		return mcpToolResult{Content: []mcpContent{{"text", err.Error()}}, IsError: true}, nil
+	}
+	var buf bytes.Buffer
+	if name == "diff_preview" {
+		err = WritePatch(&buf, result)
+	} else {
+		err = WriteJSON(&buf, result)
+	}
+	if err != nil {
+		return nil, &rpcError{rpcInternalError, err.Error()}
+	}
+	if buf.Len() == 0 {
+		buf.WriteString("No slice-based table tests to convert.\n")
+	}
+	return mcpToolResult{Content: []mcpContent{{"text", buf.String()}}, IsError: len(result.Errors) > 0}, nil
+}
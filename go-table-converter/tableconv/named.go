@@ -0,0 +1,86 @@
+package tableconv
+
+import (
+	"go/ast"
+)
+
+// namedType is a named struct type that tables can use as their test case type
+type namedType struct {
+	// spec is the type declaration
+	spec *ast.TypeSpec
+	// structType is the declared struct
+	structType *ast.StructType
+	// file is the file declaring the type
+	file *sourceFile
+}
+
+// namedTypes holds the named struct types declared across the files of a package
+type namedTypes struct {
+	// structs maps type names to their declaration; names declared more than once map to nil
+	structs map[string]*namedType
+	// refs counts the references to each type name outside of its declaration
+	refs map[string]int
+}
+
+// collectNamedTypes finds the named struct types declared in the files of a package,
+// including types declared inside test functions
+func collectNamedTypes(files []*sourceFile) *namedTypes {
+	types := &namedTypes{
+		structs: make(map[string]*namedType),
+		refs:    make(map[string]int),
+	}
+
+	declared := make(map[*ast.Ident]bool)
+	for _, file := range files {
+		ast.Inspect(file.node, func(n ast.Node) bool {
+			spec, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+
+			declared[spec.Name] = true
+
+			// The same name declared in several scopes can't be resolved without type information
+			if _, seen := types.structs[spec.Name.Name]; seen {
+				types.structs[spec.Name.Name] = nil
+				return true
+			}
+
+			structType, ok := spec.Type.(*ast.StructType)
+			if !ok || spec.Assign.IsValid() {
+				types.structs[spec.Name.Name] = nil
+				return true
+			}
+
+			types.structs[spec.Name.Name] = &namedType{spec: spec, structType: structType, file: file}
+			return true
+		})
+	}
+
+	// Count every use of the type names so types used outside of tables can be left alone
+	for _, file := range files {
+		ast.Inspect(file.node, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && !declared[ident] {
+				if _, ok := types.structs[ident.Name]; ok {
+					types.refs[ident.Name]++
+				}
+			}
+			return true
+		})
+	}
+
+	return types
+}
+
+// lookup returns the named struct type with the given name, or nil if there is none
+func (types *namedTypes) lookup(name string) *namedType {
+	if types == nil {
+		return nil
+	}
+	return types.structs[name]
+}
+
+// generic reports whether the type has type parameters, so tables use it instantiated
+func (named *namedType) generic() bool {
+	return named.spec.TypeParams != nil
+}
@@ -0,0 +1,407 @@
+package tableconv
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"slices"
+)
+
+// checkTableOrder checks that the loops over a table don't depend on the order of its
+// cases, reporting them in the file declaring the table otherwise. It returns why the
+// table can't be converted, or an empty reason when it can.
+func (c *Converter) checkTableOrder(fset *token.FileSet, files []*sourceFile, file *sourceFile, candidate *tableCandidate) SkipReason {
+	vars := make(tableVars)
+	vars.add(candidate)
+	if candidate.funcName == "" && candidate.ident != nil {
+		vars.addShared(candidate)
+	}
+	return c.checkOrder(fset, files, file, vars, candidate.name())
+}
+
+// checkOrder checks the loops over the tables in vars for order dependence, shared state,
+// and indexing the table, and the rest of the files for uses of the tables as slices. All
+// are reported, but shared state doesn't keep the table from being converted, and order
+// dependence only does without IgnoreOrder. It returns why the tables can't be converted,
+// or an empty reason when they can.
+func (c *Converter) checkOrder(fset *token.FileSet, files []*sourceFile, file *sourceFile, vars tableVars, table string) SkipReason {
+	var diags []Diagnostic
+	ordered, indexed, sliced := false, false, false
+	for _, file := range files {
+		for _, pos := range sliceUses(file.node, vars) {
+			sliced = true
+			diags = append(diags, Diagnostic{
+				Table:   table,
+				Pos:     fset.Position(pos),
+				Message: fmt.Sprintf("%s is used as a slice outside of the loops ranging over it, which a map can't stand in for", table),
+			})
+		}
+		for _, fn := range funcDecls(file.node) {
+			forRangedLoops(fn, vars, func(rangeStmt *ast.RangeStmt) {
+				for _, hazard := range loopHazards(fn, rangeStmt) {
+					ordered = ordered || hazard.kind == hazardOrder
+					indexed = indexed || hazard.kind == hazardIndex
+					diags = append(diags, Diagnostic{
+						Table:   table,
+						Pos:     fset.Position(hazard.pos),
+						Message: hazard.message,
+					})
+				}
+			})
+		}
+	}
+	file.result.Diagnostics = append(file.result.Diagnostics, diags...)
+	if indexed {
+		c.logf("Skipping table test variable %s: its loops index it\n", table)
+		return SkipIndexed
+	}
+	if sliced {
+		c.logf("Skipping table test variable %s: it is used as a slice outside of loops over it\n", table)
+		return SkipUsedAsSlice
+	}
+	if !ordered || c.opts.IgnoreOrder {
+		return ""
+	}
+
+	c.logf("Skipping table test variable %s: its loops depend on the order of its cases\n", table)
+	return SkipOrderDependent
+}
+
+// sliceUses finds the uses of the table variables in vars that only a slice supports, such
+// as 'tests[0]', 'tests[i]' in a three-clause loop, or passing the table on. Ranging over
+// a table, taking its length, and assigning it a literal work as well for a map, and the
+// indexes of loops ranging over the table are left to findIndexedLoop.
+func sliceUses(node *ast.File, vars tableVars) []token.Pos {
+	// The keys of the loops ranging over the tables
+	keys := make(map[*ast.Object]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if rangeStmt, ok := n.(*ast.RangeStmt); ok {
+			if _, ok := vars.ranged(rangeStmt); ok {
+				if key, ok := rangeStmt.Key.(*ast.Ident); ok && key.Obj != nil {
+					keys[key.Obj] = true
+				}
+			}
+		}
+		return true
+	})
+	usesKey := func(expr ast.Expr) bool {
+		found := false
+		ast.Inspect(expr, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && ident.Obj != nil && keys[ident.Obj] {
+				found = true
+			}
+			return !found
+		})
+		return found
+	}
+
+	var uses []token.Pos
+	parents := parentMap(node)
+	ast.Inspect(node, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if _, ok := vars.lookup(ident); !ok {
+			return true
+		}
+		if ident.Obj != nil && ident.Obj.Decl != nil && declaresIdent(ident.Obj.Decl, ident) {
+			return true
+		}
+
+		switch parent := parents[ident].(type) {
+		case *ast.RangeStmt:
+			if parent.X == ident {
+				return true
+			}
+		case *ast.IndexExpr:
+			if parent.X == ident && usesKey(parent.Index) {
+				return true
+			}
+		case *ast.CallExpr:
+			if fun, ok := parent.Fun.(*ast.Ident); ok && fun.Name == "len" && fun.Obj == nil {
+				return true
+			}
+		case *ast.AssignStmt:
+			for i, lhs := range parent.Lhs {
+				if lhs == ident && len(parent.Rhs) == len(parent.Lhs) {
+					if _, ok := parent.Rhs[i].(*ast.CompositeLit); ok {
+						return true
+					}
+				}
+			}
+		case *ast.SelectorExpr:
+			// A field or method of the same name as a table shared by name
+			if parent.Sel == ident {
+				return true
+			}
+		case *ast.KeyValueExpr:
+			if parent.Key == ident && ident.Obj == nil {
+				return true
+			}
+		case *ast.FuncDecl, *ast.Field, *ast.LabeledStmt, *ast.BranchStmt:
+			return true
+		}
+		uses = append(uses, ident.Pos())
+		return true
+	})
+	return uses
+}
+
+// declaresIdent reports whether an identifier is the one a declaration declares
+func declaresIdent(decl any, ident *ast.Ident) bool {
+	switch decl := decl.(type) {
+	case *ast.ValueSpec:
+		return slices.Contains(decl.Names, ident)
+	case *ast.AssignStmt:
+		return decl.Tok == token.DEFINE && slices.ContainsFunc(decl.Lhs, func(lhs ast.Expr) bool { return lhs == ident })
+	}
+	return false
+}
+
+// funcDecls returns the functions declared in a file
+func funcDecls(node *ast.File) []*ast.FuncDecl {
+	var fns []*ast.FuncDecl
+	for _, decl := range node.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+			fns = append(fns, fn)
+		}
+	}
+	return fns
+}
+
+// forRangedLoops calls f for each loop of a function over a table in vars
+func forRangedLoops(fn *ast.FuncDecl, vars tableVars, f func(rangeStmt *ast.RangeStmt)) {
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if rangeStmt, ok := n.(*ast.RangeStmt); ok {
+			if _, ok := vars.ranged(rangeStmt); ok {
+				f(rangeStmt)
+			}
+		}
+		return true
+	})
+}
+
+// hazardKind classifies loop hazards
+type hazardKind int
+
+const (
+	// hazardShared is state the cases share, which parallel cases would race on
+	hazardShared hazardKind = iota
+	// hazardOrder is state left by the previous case, so the cases depend on their order
+	hazardOrder
+	// hazardIndex is a use of the loop's index that a map has no equivalent for
+	hazardIndex
+)
+
+// loopHazard is a use of state that outlives a case of a table loop, or of its index
+type loopHazard struct {
+	pos     token.Pos
+	kind    hazardKind
+	message string
+}
+
+// loopHazards finds the state a loop in a function carries from one case to the next, the
+// state its cases share, and uses of its index that can't be rewritten
+func loopHazards(fn *ast.FuncDecl, rangeStmt *ast.RangeStmt) []loopHazard {
+	var hazards []loopHazard
+	if _, hazard := findIndexedLoop(rangeStmt); hazard != nil {
+		hazards = append(hazards, *hazard)
+	}
+	hazards = append(hazards, orderHazards(rangeStmt)...)
+	seen := make(map[token.Pos]bool)
+	for _, hazard := range hazards {
+		seen[hazard.pos] = true
+	}
+	for _, hazard := range sharedStateHazards(fn, rangeStmt) {
+		if !seen[hazard.pos] {
+			hazards = append(hazards, hazard)
+		}
+	}
+	return hazards
+}
+
+// declaredOutside reports whether an identifier refers to a variable declared outside the
+// given node, or to a package-level variable of another file
+func declaredOutside(ident *ast.Ident, node ast.Node) bool {
+	if ident.Obj == nil {
+		return ident.Name != "_"
+	}
+	if ident.Obj.Kind != ast.Var {
+		return false
+	}
+	decl, ok := ident.Obj.Decl.(ast.Node)
+	return ok && (decl.Pos() < node.Pos() || decl.Pos() >= node.End())
+}
+
+// orderHazards finds the variables declared outside a loop that its body accumulates
+// ('total += tc.n', 'got = append(got, ...)') or reads before assigning them, and so sees
+// the value left by the previous case
+func orderHazards(rangeStmt *ast.RangeStmt) []loopHazard {
+	outer := func(ident *ast.Ident) bool {
+		return ident.Obj != nil && declaredOutside(ident, rangeStmt)
+	}
+
+	// Collect the assignments to outer variables, and which of them accumulate
+	type variable struct {
+		firstWrite, firstRead token.Pos
+		accumulated           token.Pos
+	}
+	vars := make(map[*ast.Object]*variable)
+	var order []*ast.Object
+	written := make(map[*ast.Ident]bool)
+	lookup := func(ident *ast.Ident) *variable {
+		v, ok := vars[ident.Obj]
+		if !ok {
+			v = &variable{}
+			vars[ident.Obj] = v
+			order = append(order, ident.Obj)
+		}
+		return v
+	}
+	ast.Inspect(rangeStmt.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				ident, ok := lhs.(*ast.Ident)
+				if !ok || !outer(ident) {
+					continue
+				}
+
+				written[ident] = true
+				v := lookup(ident)
+				if !v.firstWrite.IsValid() {
+					// The right-hand side is evaluated before the assignment
+					v.firstWrite = n.End()
+				}
+				if n.Tok != token.ASSIGN && n.Tok != token.DEFINE || usesObj(n.Rhs, ident.Obj) {
+					if !v.accumulated.IsValid() {
+						v.accumulated = ident.Pos()
+					}
+				}
+			}
+		case *ast.IncDecStmt:
+			if ident, ok := n.X.(*ast.Ident); ok && outer(ident) {
+				written[ident] = true
+				v := lookup(ident)
+				if !v.accumulated.IsValid() {
+					v.accumulated = ident.Pos()
+				}
+			}
+		}
+		return true
+	})
+	if len(vars) == 0 {
+		return nil
+	}
+
+	// Find where each of them is first read
+	ast.Inspect(rangeStmt.Body, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && !written[ident] {
+			if v, ok := vars[ident.Obj]; ok && !v.firstRead.IsValid() {
+				v.firstRead = ident.Pos()
+			}
+		}
+		return true
+	})
+
+	var hazards []loopHazard
+	for _, obj := range order {
+		v := vars[obj]
+		switch {
+		case v.accumulated.IsValid():
+			hazards = append(hazards, loopHazard{v.accumulated, hazardOrder, fmt.Sprintf("the loop accumulates %q across cases, so it depends on their order", obj.Name)})
+		case v.firstRead.IsValid() && v.firstWrite.IsValid() && v.firstRead < v.firstWrite:
+			hazards = append(hazards, loopHazard{v.firstRead, hazardOrder, fmt.Sprintf("the loop reads %q before assigning it, so each case sees the value left by the one before", obj.Name)})
+		}
+	}
+	return hazards
+}
+
+// sharedStateHazards finds the writes of a loop body to state every case shares:
+// package-level variables, elements and fields of variables declared outside the loop
+// (such as a shared map), and variables captured and modified by subtest closures
+func sharedStateHazards(fn *ast.FuncDecl, rangeStmt *ast.RangeStmt) []loopHazard {
+	var hazards []loopHazard
+	write := func(lhs ast.Expr, inClosure bool) {
+		switch x := lhs.(type) {
+		case *ast.Ident:
+			switch {
+			case !declaredOutside(x, rangeStmt):
+			case declaredOutside(x, fn):
+				hazards = append(hazards, loopHazard{pos: x.Pos(), kind: hazardShared, message: fmt.Sprintf("the loop writes the package-level variable %q", x.Name)})
+			case inClosure:
+				hazards = append(hazards, loopHazard{pos: x.Pos(), kind: hazardShared, message: fmt.Sprintf("a closure in the loop modifies the captured variable %q", x.Name)})
+			}
+		case *ast.IndexExpr, *ast.SelectorExpr, *ast.StarExpr:
+			if root := rootIdent(x); root != nil && declaredOutside(root, rangeStmt) {
+				hazards = append(hazards, loopHazard{pos: x.Pos(), kind: hazardShared, message: fmt.Sprintf("the loop writes to %q, which all cases share", root.Name)})
+			}
+		}
+	}
+
+	var inspect func(n ast.Node, inClosure bool)
+	inspect = func(n ast.Node, inClosure bool) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				if !inClosure {
+					inspect(n.Body, true)
+					return false
+				}
+			case *ast.AssignStmt:
+				if n.Tok != token.DEFINE {
+					for _, lhs := range n.Lhs {
+						write(lhs, inClosure)
+					}
+				}
+			case *ast.IncDecStmt:
+				write(n.X, inClosure)
+			case *ast.CallExpr:
+				if fun, ok := n.Fun.(*ast.Ident); ok && fun.Name == "delete" && fun.Obj == nil && len(n.Args) == 2 {
+					if root := rootIdent(n.Args[0]); root != nil && declaredOutside(root, rangeStmt) {
+						hazards = append(hazards, loopHazard{pos: n.Pos(), kind: hazardShared, message: fmt.Sprintf("the loop deletes from %q, which all cases share", root.Name)})
+					}
+				}
+			}
+			return true
+		})
+	}
+	inspect(rangeStmt.Body, false)
+
+	return hazards
+}
+
+// rootIdent returns the variable an element, field, or dereference expression starts from
+func rootIdent(expr ast.Expr) *ast.Ident {
+	for {
+		switch x := expr.(type) {
+		case *ast.Ident:
+			return x
+		case *ast.IndexExpr:
+			expr = x.X
+		case *ast.SelectorExpr:
+			expr = x.X
+		case *ast.StarExpr:
+			expr = x.X
+		case *ast.ParenExpr:
+			expr = x.X
+		default:
+			return nil
+		}
+	}
+}
+
+// usesObj reports whether any of the expressions refers to the object
+func usesObj(exprs []ast.Expr, obj *ast.Object) bool {
+	found := false
+	for _, expr := range exprs {
+		ast.Inspect(expr, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && ident.Obj == obj {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
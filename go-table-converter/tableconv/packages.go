@@ -0,0 +1,49 @@
+package tableconv
+
+import (
+	"path/filepath"
+)
+
+// PackageResult holds the statistics of a run for the files of one package directory
+type PackageResult struct {
+	// Dir is the directory of the package, as the files of the run are named
+	Dir             string
+	Files           int
+	FilesModified   int
+	TablesConverted int
+	TablesSkipped   int
+	Warnings        int
+	Errors          int
+}
+
+// Packages breaks a conversion result down by package directory, in the order the
+// directories were processed, so the owners of each package can see their share
+func (r ConversionResult) Packages() []PackageResult {
+	var packages []PackageResult
+	index := make(map[string]int)
+	pkg := func(path string) *PackageResult {
+		dir := filepath.Dir(path)
+		i, ok := index[dir]
+		if !ok {
+			i = len(packages)
+			index[dir] = i
+			packages = append(packages, PackageResult{Dir: dir})
+		}
+		return &packages[i]
+	}
+
+	for _, file := range r.Files {
+		p := pkg(file.Path)
+		p.Files++
+		p.TablesSkipped += file.TablesSkipped
+		p.Warnings += len(file.Diagnostics)
+		if file.Modified {
+			p.FilesModified++
+			p.TablesConverted += file.TablesConverted
+		}
+	}
+	for _, err := range r.Errors {
+		pkg(err.Path).Errors++
+	}
+	return packages
+}
@@ -0,0 +1,190 @@
+package tableconv
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// addParallel marks the subtests run by loops over converted tables in a function as
+// parallel, since map iteration already makes their order random, and the test function
+// itself when it runs any. Functions using Setenv or Chdir are left alone because those
+// panic in parallel tests, and subtests are not made parallel when the function defers
+// cleanup, which would then run before the subtests do, or when the cases carry or share
+// state they would race on.
+func addParallel(fn *ast.FuncDecl, vars tableVars) bool {
+	if fn.Body == nil || !parallelSafe(fn.Body) {
+		return false
+	}
+
+	hazards := false
+	forRangedLoops(fn, vars, func(rangeStmt *ast.RangeStmt) {
+		hazards = hazards || len(loopHazards(fn, rangeStmt)) > 0
+	})
+	if hazards {
+		return false
+	}
+
+	modified := false
+	parallelSubtests := false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		rangeStmt, ok := n.(*ast.RangeStmt)
+		if !ok {
+			return true
+		}
+		if _, ok := vars.ranged(rangeStmt); !ok {
+			return true
+		}
+
+		for _, stmt := range rangeStmt.Body.List {
+			lit := subtestFunc(stmt)
+			if lit == nil {
+				continue
+			}
+
+			param := testingParam(lit.Type, "T")
+			if param == "" {
+				continue
+			}
+
+			parallelSubtests = true
+			if !startsWithParallel(lit.Body, param) {
+				insertParallel(lit.Body, param)
+				modified = true
+			}
+		}
+
+		return true
+	})
+
+	// The test function can only run in parallel with other tests once its subtests do
+	if parallelSubtests && isTestFunc(fn) {
+		param := testingParam(fn.Type, "T")
+		if !startsWithParallel(fn.Body, param) {
+			insertParallel(fn.Body, param)
+			modified = true
+		}
+	}
+
+	return modified
+}
+
+// parallelSafe reports whether a test function body allows parallel subtests: it must
+// not call Setenv or Chdir, and must not defer anything outside its subtests
+func parallelSafe(body *ast.BlockStmt) bool {
+	safe := true
+	var inspect func(n ast.Node, inClosure bool)
+	inspect = func(n ast.Node, inClosure bool) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			if !safe {
+				return false
+			}
+
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				if !inClosure {
+					inspect(n.Body, true)
+					return false
+				}
+			case *ast.DeferStmt:
+				if !inClosure {
+					safe = false
+				}
+			case *ast.SelectorExpr:
+				if n.Sel.Name == "Setenv" || n.Sel.Name == "Chdir" {
+					safe = false
+				}
+			}
+			return true
+		})
+	}
+	inspect(body, false)
+
+	return safe
+}
+
+// subtestFunc returns the function literal run by a statement of the form
+// t.Run(name, func(t *testing.T) { ... }), or nil for any other statement
+func subtestFunc(stmt ast.Stmt) *ast.FuncLit {
+	exprStmt, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return nil
+	}
+
+	call, ok := exprStmt.X.(*ast.CallExpr)
+	if !ok || len(call.Args) != 2 {
+		return nil
+	}
+
+	if sel, ok := call.Fun.(*ast.SelectorExpr); !ok || sel.Sel.Name != "Run" {
+		return nil
+	}
+
+	lit, _ := call.Args[1].(*ast.FuncLit)
+	return lit
+}
+
+// testingParam returns the name of the parameter of a function taking only a pointer to
+// the given testing type (T or B), or "" for any other function
+func testingParam(typ *ast.FuncType, testingType string) string {
+	if typ.Params == nil || len(typ.Params.List) != 1 || len(typ.Params.List[0].Names) != 1 {
+		return ""
+	}
+
+	field := typ.Params.List[0]
+	star, ok := field.Type.(*ast.StarExpr)
+	if !ok {
+		return ""
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != testingType {
+		return ""
+	}
+	if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "testing" {
+		return ""
+	}
+
+	if isBlankIdent(field.Names[0]) {
+		return ""
+	}
+	return field.Names[0].Name
+}
+
+// isTestFunc reports whether a function is a top-level test run by go test
+func isTestFunc(fn *ast.FuncDecl) bool {
+	return fn.Recv == nil && strings.HasPrefix(fn.Name.Name, "Test") && testingParam(fn.Type, "T") != ""
+}
+
+// startsWithParallel reports whether a block already calls t.Parallel() before
+// anything else
+func startsWithParallel(body *ast.BlockStmt, param string) bool {
+	if len(body.List) == 0 {
+		return false
+	}
+
+	exprStmt, ok := body.List[0].(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := exprStmt.X.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Parallel" {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && x.Name == param
+}
+
+// insertParallel inserts a call to t.Parallel() at the top of a block. The call is placed
+// right after the opening brace so comments on the first statement stay with it.
+func insertParallel(body *ast.BlockStmt, param string) {
+	pos := body.Lbrace + 1
+	call := &ast.ExprStmt{X: &ast.CallExpr{
+		Fun:    &ast.SelectorExpr{X: &ast.Ident{NamePos: pos, Name: param}, Sel: &ast.Ident{NamePos: pos, Name: "Parallel"}},
+		Lparen: pos,
+		Rparen: pos,
+	}}
+	body.List = append([]ast.Stmt{call}, body.List...)
+}
@@ -0,0 +1,121 @@
+package tableconv
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// patchContext is the number of unchanged lines shown around each change
+const patchContext = 3
+
+// WritePatch writes the changes of every modified file as a unified patch that can be
+// applied with git apply or patch -p1, so conversions can be reviewed before they touch
+// the working tree
+func WritePatch(w io.Writer, result ConversionResult) error {
+	for _, file := range result.Files {
+		if !file.Modified {
+			continue
+		}
+		notes := explanationNotes(file.src, file.explanations)
+		if _, err := io.WriteString(w, unifiedDiff(patchPath(file.Path), file.src, file.out, notes)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchPath returns the path a file is named by in a patch: relative to the working
+// directory when the file lies below it, with forward slashes
+func patchPath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if abs, err := filepath.Abs(path); err == nil {
+			if rel, err := filepath.Rel(wd, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				path = rel
+			}
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// unifiedDiff returns the git-style unified diff turning a into b. The notes on the lines
+// of a shown in a hunk follow its header, where git apply and patch ignore them.
+func unifiedDiff(path string, a, b []byte, notes map[int][]string) string {
+	aLines, bLines := splitLines(a), splitLines(b)
+	hunks := diffLines(aLines, bLines)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "diff --git a/%s b/%s\n", path, path)
+	fmt.Fprintf(&sb, "--- a/%s\n", path)
+	fmt.Fprintf(&sb, "+++ b/%s\n", path)
+
+	for i := 0; i < len(hunks); {
+		// Merge hunks whose context would overlap
+		j := i
+		for j+1 < len(hunks) && hunks[j+1].aStart-hunks[j].aEnd <= 2*patchContext {
+			j++
+		}
+
+		aStart := max(hunks[i].aStart-patchContext, 0)
+		aEnd := min(hunks[j].aEnd+patchContext, len(aLines))
+		bStart := hunks[i].bStart - (hunks[i].aStart - aStart)
+		bEnd := hunks[j].bEnd + (aEnd - hunks[j].aEnd)
+
+		var hunkNotes []string
+		for line := aStart; line < aEnd; line++ {
+			hunkNotes = append(hunkNotes, notes[line]...)
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@", hunkRange(aStart, aEnd-aStart), hunkRange(bStart, bEnd-bStart))
+		if len(hunkNotes) > 0 {
+			fmt.Fprintf(&sb, " %s", strings.Join(hunkNotes, "; "))
+		}
+		sb.WriteByte('\n')
+
+		pos := aStart
+		for k := i; k <= j; k++ {
+			h := hunks[k]
+			for ; pos < h.aStart; pos++ {
+				writePatchLine(&sb, ' ', aLines[pos])
+			}
+			for _, line := range aLines[h.aStart:h.aEnd] {
+				writePatchLine(&sb, '-', line)
+			}
+			for _, line := range bLines[h.bStart:h.bEnd] {
+				writePatchLine(&sb, '+', line)
+			}
+			pos = h.aEnd
+		}
+		for ; pos < aEnd; pos++ {
+			writePatchLine(&sb, ' ', aLines[pos])
+		}
+
+		i = j + 1
+	}
+
+	return sb.String()
+}
+
+// hunkRange formats the start and length of a hunk side, counting lines from 1
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// writePatchLine writes a patch line, marking a last line without a trailing newline
+func writePatchLine(sb *strings.Builder, prefix byte, line string) {
+	sb.WriteByte(prefix)
+	sb.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
@@ -0,0 +1,104 @@
+package tableconv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// listedPackage is a package as 'go list -json' describes it
+type listedPackage struct {
+	Dir          string
+	ImportPath   string
+	GoFiles      []string
+	CgoFiles     []string
+	TestGoFiles  []string
+	XTestGoFiles []string
+	Error        *struct {
+		Err string
+	}
+}
+
+// isPackagePattern reports whether a path given to ConvertPaths is a Go package pattern
+// (./internal/..., example.com/mod/pkg) rather than a file or directory to walk. Existing
+// files and directories are walked as before, and other missing paths are left to fail
+// like them, so that a mistyped file name isn't handed to the go tool as an import path.
+func isPackagePattern(path string) bool {
+	if strings.Contains(path, "...") {
+		return true
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+	return isImportPath(path)
+}
+
+// isImportPath reports whether a path reads as the import path of a package outside the
+// standard library: slash-separated, not relative or absolute, not naming a Go file, and
+// starting with a domain (example.com/mod/pkg)
+func isImportPath(path string) bool {
+	if filepath.IsAbs(path) || strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".go") || strings.ContainsRune(path, '\\') {
+		return false
+	}
+	first, _, _ := strings.Cut(path, "/")
+	return strings.Contains(first, ".")
+}
+
+// resolvePattern lists the Go files of the packages matching a pattern, resolved by the
+// go tool like 'go test' would, so that module boundaries and build constraints are
+// respected. Packages that can't be loaded are reported as errors of the result.
+func (c *Converter) resolvePattern(pattern string, result *ConversionResult) ([]string, error) {
+	fields := "-json=Dir,ImportPath,GoFiles,CgoFiles,TestGoFiles,XTestGoFiles,Error"
+	cmd := exec.Command("go", "list", "-e", fields, "--", pattern)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error resolving %s: %v: %s", pattern, err, strings.TrimSpace(stderr.String()))
+	}
+
+	wd, _ := os.Getwd()
+	var files []string
+	packages := 0
+	dec := json.NewDecoder(&stdout)
+	for {
+		var pkg listedPackage
+		if err := dec.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading go list output: %v", err)
+		}
+		packages++
+		if pkg.Error != nil {
+			result.Errors = append(result.Errors, FileError{pkg.ImportPath, pkg.Error.Err})
+			continue
+		}
+
+		// Name files like walking the working directory would
+		dir := pkg.Dir
+		if rel, err := filepath.Rel(wd, dir); wd != "" && err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			dir = rel
+		}
+
+		// Test files build along with the package; the others only count with AllFiles,
+		// which wantFile leaves to decide
+		for _, names := range [][]string{pkg.GoFiles, pkg.CgoFiles, pkg.TestGoFiles, pkg.XTestGoFiles} {
+			for _, name := range names {
+				path := filepath.Join(dir, name)
+				if c.wantFile(path) {
+					files = append(files, path)
+				}
+			}
+		}
+	}
+	if packages == 0 {
+		return nil, fmt.Errorf("no packages match %s: %s", pattern, strings.TrimSpace(stderr.String()))
+	}
+	return files, nil
+}
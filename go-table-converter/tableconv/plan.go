@@ -0,0 +1,184 @@
+package tableconv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// planVersion is the version of the plan format, bumped on incompatible changes
+const planVersion = 1
+
+// Plan records the edits of a conversion so it can be reviewed and applied later by
+// ApplyPlan, which refuses to touch files that changed in between
+type Plan struct {
+	Version int        `json:"version"`
+	Files   []PlanFile `json:"files"`
+}
+
+// PlanFile holds the edits of a single file
+type PlanFile struct {
+	// Path is relative to the working directory the plan was made in when the file lies
+	// below it, with forward slashes
+	Path string `json:"path"`
+	// SHA256 is the hex-encoded SHA-256 hash of the contents the edits apply to
+	SHA256 string `json:"sha256"`
+	// Tables names the tables the edits convert
+	Tables []string   `json:"tables"`
+	Edits  []PlanEdit `json:"edits"`
+}
+
+// PlanEdit replaces the bytes from Start up to but not including End, offsets into the
+// original contents of the file, by NewText
+type PlanEdit struct {
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	NewText string `json:"newText"`
+}
+
+// NewPlan returns the plan of the modified files of a dry-run conversion
+func NewPlan(result ConversionResult) Plan {
+	plan := Plan{Version: planVersion, Files: []PlanFile{}}
+	for _, file := range result.Files {
+		if !file.Modified {
+			continue
+		}
+		pf := PlanFile{
+			Path:   patchPath(file.Path),
+			SHA256: contentHash(file.src),
+			Tables: make([]string, 0, len(file.Tables)),
+		}
+		for _, table := range file.Tables {
+			pf.Tables = append(pf.Tables, table.Name)
+		}
+		pf.Edits = byteEdits(file.src, file.out)
+		plan.Files = append(plan.Files, pf)
+	}
+	return plan
+}
+
+// WritePlan writes the plan of a dry-run conversion as indented JSON
+func WritePlan(w io.Writer, result ConversionResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(NewPlan(result))
+}
+
+// LoadPlan reads a plan written by WritePlan
+func LoadPlan(path string) (Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Plan{}, fmt.Errorf("error reading plan: %v", err)
+	}
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return Plan{}, fmt.Errorf("error parsing plan %s: %v", path, err)
+	}
+	if plan.Version != planVersion {
+		return Plan{}, fmt.Errorf("plan %s has version %d, expected %d", path, plan.Version, planVersion)
+	}
+	return plan, nil
+}
+
+// ApplyPlan applies the edits of a plan and returns the paths of the files it wrote.
+// Every file is checked against its hash before any is written, so a plan made stale by
+// later changes is rejected as a whole. The writes are recorded as a run in the journal
+// file unless it is empty.
+func ApplyPlan(plan Plan, journal string) ([]string, error) {
+	srcs := make([][]byte, len(plan.Files))
+	outs := make([][]byte, len(plan.Files))
+	var stale []string
+	for i, pf := range plan.Files {
+		path := filepath.FromSlash(pf.Path)
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading file: %v", err)
+		}
+		srcs[i] = src
+		if contentHash(src) != pf.SHA256 {
+			stale = append(stale, pf.Path)
+			continue
+		}
+		edits, err := sourceEdits(pf.Edits, len(src))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", pf.Path, err)
+		}
+		outs[i] = applyEdits(src, edits)
+	}
+	if len(stale) > 0 {
+		return nil, fmt.Errorf("files changed since the plan was made: %v", stale)
+	}
+
+	run := newRunID()
+	var written []string
+	for i, pf := range plan.Files {
+		path := filepath.FromSlash(pf.Path)
+		if err := writeFileAtomic(path, outs[i]); err != nil {
+			return written, fmt.Errorf("error writing to file: %v", err)
+		}
+		written = append(written, path)
+		if journal != "" {
+			entry, err := newJournalEntry(run, path, srcs[i], outs[i])
+			if err == nil {
+				err = appendJournal(journal, []JournalEntry{entry})
+			}
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// contentHash returns the hex-encoded SHA-256 hash of the contents of a file
+func contentHash(src []byte) string {
+	sum := sha256.Sum256(src)
+	return hex.EncodeToString(sum[:])
+}
+
+// byteEdits returns the edits turning a into b, as the byte ranges of the changed lines
+func byteEdits(a, b []byte) []PlanEdit {
+	aLines, bLines := splitLines(a), splitLines(b)
+
+	// offsets[i] is the offset of line i of a, and the last one the length of a
+	offsets := make([]int, len(aLines)+1)
+	for i, line := range aLines {
+		offsets[i+1] = offsets[i] + len(line)
+	}
+
+	edits := []PlanEdit{}
+	for _, hunk := range diffLines(aLines, bLines) {
+		var text []byte
+		for _, line := range bLines[hunk.bStart:hunk.bEnd] {
+			text = append(text, line...)
+		}
+		edits = append(edits, PlanEdit{Start: offsets[hunk.aStart], End: offsets[hunk.aEnd], NewText: string(text)})
+	}
+	return edits
+}
+
+// sourceEdits returns edits in source order, checking that they lie within size bytes
+// and don't overlap
+func sourceEdits(planned []PlanEdit, size int) ([]sourceEdit, error) {
+	edits := make([]sourceEdit, 0, len(planned))
+	for _, edit := range planned {
+		edits = append(edits, sourceEdit{edit.Start, edit.End, edit.NewText})
+	}
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+
+	pos := 0
+	for _, edit := range edits {
+		if edit.start < pos || edit.end < edit.start || edit.end > size {
+			return nil, fmt.Errorf("invalid edit of bytes %d to %d", edit.start, edit.end)
+		}
+		pos = edit.end
+	}
+	return edits, nil
+}
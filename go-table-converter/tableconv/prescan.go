@@ -0,0 +1,61 @@
+package tableconv
+
+import "bytes"
+
+// prescan reports whether directories whose files can't hold slice-based tables may be
+// skipped without parsing them. The options and rules rewriting other code turn it off.
+func (c *Converter) prescan() bool {
+	return !c.opts.Tabulate && !c.opts.FromGinkgo && !c.opts.FromGocheck && c.opts.Golden == 0 && len(c.rules) == 0
+}
+
+// mayHoldTables reports whether a source may hold a slice-based table, judging by its
+// bytes alone: every table is a slice literal of anonymous structs ('[]struct{...}{...}')
+// or of a named case type ('[]testCase{...}', '[]*testCase{...}'). Comments and strings
+// can make it report sources holding none, but never the reverse.
+func mayHoldTables(src []byte) bool {
+	for {
+		i := bytes.Index(src, []byte("[]"))
+		if i < 0 {
+			return false
+		}
+		src = src[i+2:]
+
+		rest := bytes.TrimLeft(src, " \t\r\n*")
+		if bytes.HasPrefix(rest, []byte("struct")) {
+			return true
+		}
+
+		// A type name, maybe qualified and instantiated, followed by the literal's brace
+		n := 0
+		for n < len(rest) && isNameByte(rest[n]) {
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		rest = bytes.TrimLeft(rest[n:], " \t")
+		if len(rest) > 0 && rest[0] == '[' {
+			depth, end := 0, 0
+			for end = range rest {
+				if rest[end] == '[' {
+					depth++
+				} else if rest[end] == ']' {
+					if depth--; depth == 0 {
+						break
+					}
+				}
+			}
+			rest = bytes.TrimLeft(rest[end+1:], " \t")
+		}
+		if len(rest) > 0 && rest[0] == '{' {
+			return true
+		}
+	}
+}
+
+// isNameByte reports whether a byte can be part of a possibly qualified type name. Bytes
+// of multi-byte characters count, since identifiers can hold any letter.
+func isNameByte(b byte) bool {
+	return b == '_' || b == '.' || b >= 0x80 ||
+		'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
@@ -0,0 +1,39 @@
+package tableconv
+
+import "time"
+
+// Progress is how far ConvertPaths has come through the files it found
+type Progress struct {
+	// FilesTotal is the number of files found under the paths
+	FilesTotal int
+	// FilesScanned is the number of files converted, checked, or skipped so far
+	FilesScanned int
+	// FilesModified is the number of scanned files with tables to convert
+	FilesModified int
+	// Elapsed is the time spent converting since the paths were walked
+	Elapsed time.Duration
+
+	start time.Time
+}
+
+// Remaining returns the number of files left to scan
+func (p Progress) Remaining() int {
+	return p.FilesTotal - p.FilesScanned
+}
+
+// ETA estimates the time left from the pace so far, zero before any file is scanned
+func (p Progress) ETA() time.Duration {
+	if p.FilesScanned == 0 {
+		return 0
+	}
+	return p.Elapsed * time.Duration(p.Remaining()) / time.Duration(p.FilesScanned)
+}
+
+// reportProgress passes the progress of a run to the Progress option, if set
+func (c *Converter) reportProgress(progress Progress) {
+	if c.opts.Progress == nil {
+		return
+	}
+	progress.Elapsed = time.Since(progress.start)
+	c.opts.Progress(progress)
+}
@@ -0,0 +1,153 @@
+package tableconv
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// rdjsonResult is a reviewdog Diagnostic Format (rdjson) result
+type rdjsonResult struct {
+	Source      rdjsonSource       `json:"source"`
+	Severity    string             `json:"severity"`
+	Diagnostics []rdjsonDiagnostic `json:"diagnostics"`
+}
+
+type rdjsonSource struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type rdjsonDiagnostic struct {
+	Message     string             `json:"message"`
+	Location    rdjsonLocation     `json:"location"`
+	Severity    string             `json:"severity"`
+	Source      *rdjsonSource      `json:"source,omitempty"`
+	Code        rdjsonCode         `json:"code"`
+	Suggestions []rdjsonSuggestion `json:"suggestions,omitempty"`
+}
+
+type rdjsonLocation struct {
+	Path  string       `json:"path"`
+	Range *rdjsonRange `json:"range,omitempty"`
+}
+
+type rdjsonRange struct {
+	Start rdjsonPosition  `json:"start"`
+	End   *rdjsonPosition `json:"end,omitempty"`
+}
+
+type rdjsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column,omitempty"`
+}
+
+type rdjsonCode struct {
+	Value string `json:"value"`
+}
+
+type rdjsonSuggestion struct {
+	Range rdjsonRange `json:"range"`
+	Text  string      `json:"text"`
+}
+
+// WriteRDJSON writes the findings of a conversion result in reviewdog's rdjson format.
+// Each slice-based table carries the conversion of its part of the file as suggested
+// fixes, so reviewdog can post them as inline review suggestions.
+func WriteRDJSON(w io.Writer, result ConversionResult) error {
+	out := rdjsonResult{
+		Source:      rdjsonSource{Name: "tabletests"},
+		Severity:    "WARNING",
+		Diagnostics: rdjsonDiagnostics(result),
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// WriteRDJSONL writes the findings of a conversion result in reviewdog's rdjsonl format,
+// one diagnostic per line
+func WriteRDJSONL(w io.Writer, result ConversionResult) error {
+	enc := json.NewEncoder(w)
+	for _, diag := range rdjsonDiagnostics(result) {
+		diag.Source = &rdjsonSource{Name: "tabletests"}
+		if err := enc.Encode(diag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rdjsonDiagnostics converts the findings of a conversion result to rdjson diagnostics
+func rdjsonDiagnostics(result ConversionResult) []rdjsonDiagnostic {
+	diags := []rdjsonDiagnostic{}
+
+	for _, file := range result.Files {
+		first := len(diags)
+		for _, table := range file.Tables {
+			diags = append(diags, rdjsonDiagnostic{
+				Message:  fmt.Sprintf("slice-based table test %q should be map-based", table.Name),
+				Location: newRDJSONLocation(table.Pos),
+				Severity: "WARNING",
+				Code:     rdjsonCode{ruleSliceTable},
+			})
+		}
+
+		// Attach each changed region to the closest table above it
+		for _, hunk := range diffLines(splitLines(file.src), splitLines(file.out)) {
+			target := first
+			for i := first; i < len(diags); i++ {
+				if diags[i].Location.Range.Start.Line <= hunk.aStart+1 {
+					target = i
+				}
+			}
+			if target < len(diags) {
+				diags[target].Suggestions = append(diags[target].Suggestions, newRDJSONSuggestion(file, hunk))
+			}
+		}
+
+		for _, diag := range file.Diagnostics {
+			diags = append(diags, rdjsonDiagnostic{
+				Message:  fmt.Sprintf("%s: %s", diag.Table, diag.Message),
+				Location: newRDJSONLocation(diag.Pos),
+				Severity: "WARNING",
+				Code:     rdjsonCode{ruleTableDiagnostic},
+			})
+		}
+	}
+
+	for _, err := range result.Errors {
+		diags = append(diags, rdjsonDiagnostic{
+			Message:  err.Message,
+			Location: rdjsonLocation{Path: filepath.ToSlash(err.Path)},
+			Severity: "ERROR",
+			Code:     rdjsonCode{ruleConversionError},
+		})
+	}
+
+	return diags
+}
+
+// newRDJSONLocation converts a token position to an rdjson location
+func newRDJSONLocation(pos token.Position) rdjsonLocation {
+	return rdjsonLocation{
+		Path:  filepath.ToSlash(pos.Filename),
+		Range: &rdjsonRange{Start: rdjsonPosition{Line: pos.Line, Column: pos.Column}},
+	}
+}
+
+// newRDJSONSuggestion replaces the original lines of a hunk with its converted lines
+func newRDJSONSuggestion(file FileResult, hunk diffHunk) rdjsonSuggestion {
+	lines := splitLines(file.out)
+	return rdjsonSuggestion{
+		Range: rdjsonRange{
+			Start: rdjsonPosition{Line: hunk.aStart + 1, Column: 1},
+			End:   &rdjsonPosition{Line: hunk.aEnd + 1, Column: 1},
+		},
+		Text: strings.Join(lines[hunk.bStart:hunk.bEnd], ""),
+	}
+}
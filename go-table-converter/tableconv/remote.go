@@ -0,0 +1,329 @@
+package tableconv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Hosting services pull requests can be opened on
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+)
+
+// apiClient makes the API requests opening pull requests, which would otherwise wait on
+// an unresponsive host for good
+var apiClient = &http.Client{Timeout: 30 * time.Second}
+
+// DefaultRemoteBranch is the branch conversions of remote repositories are pushed to by
+// default
+const DefaultRemoteBranch = "tabletests/map-tables"
+
+// RemoteOptions describes the repository ConvertRemote converts and the pull request it
+// opens
+type RemoteOptions struct {
+	// URL is the git URL of the repository, cloned with the credentials git has for it
+	URL string
+	// Dir is the directory to clone into; an empty Dir clones into a temporary directory,
+	// removed afterwards unless NoPush is set
+	Dir string
+	// Branch is the branch the conversion is committed to, DefaultRemoteBranch if empty
+	Branch string
+	// Base is the branch the pull request merges into, the default branch if empty
+	Base string
+	// Title is the subject of the commit and the title of the pull request
+	Title string
+	// Provider is ProviderGitHub or ProviderGitLab, told by the host of URL if empty
+	Provider string
+	// APIURL is the root of the API of the provider, such as https://api.github.com or
+	// https://gitlab.example.com/api/v4, told by the host of URL if empty
+	APIURL string
+	// Token authenticates the API request opening the pull request
+	Token string
+	// NoPush stops once the conversion is committed, leaving the clone for inspection
+	NoPush bool
+}
+
+// RemoteResult is the outcome of converting a remote repository
+type RemoteResult struct {
+	// Result is the conversion, with paths relative to the root of the repository
+	Result ConversionResult
+	// Dir is the clone, which is gone by the time ConvertRemote returns unless it was given
+	// or NoPush was set
+	Dir string
+	// Summary is the markdown summary of the conversion, the description of the pull request
+	Summary string
+	// PullRequest is the web URL of the pull request, empty when nothing was converted
+	// or nothing was pushed
+	PullRequest string
+}
+
+// ConvertRemote clones a repository, converts its table tests on a new branch, commits and
+// pushes the branch, and opens a pull request (a merge request on GitLab) with the
+// markdown summary of the conversion as its description. Files are written in the clone
+// regardless of DryRun. Nothing is pushed when no table was converted.
+func (c *Converter) ConvertRemote(remote RemoteOptions) (RemoteResult, error) {
+	// Git would take arguments starting with a dash for options
+	for _, arg := range []struct{ what, value string }{{"URL", remote.URL}, {"branch", remote.Branch}, {"base branch", remote.Base}} {
+		if strings.HasPrefix(arg.value, "-") {
+			return RemoteResult{}, fmt.Errorf("invalid repository %s %q: it starts with a dash", arg.what, arg.value)
+		}
+	}
+
+	// Find out where the pull request goes before cloning anything
+	var repo, provider, apiURL string
+	if !remote.NoPush {
+		host, path, err := parseRepoURL(remote.URL)
+		if err != nil {
+			return RemoteResult{}, err
+		}
+		if provider, apiURL, err = remoteAPI(host, remote.Provider, remote.APIURL); err != nil {
+			return RemoteResult{}, err
+		}
+		if remote.Token == "" {
+			return RemoteResult{}, fmt.Errorf("a token is needed to open a pull request on %s", host)
+		}
+		repo = path
+	}
+	if remote.Branch == "" {
+		remote.Branch = DefaultRemoteBranch
+	}
+	if remote.Title == "" {
+		remote.Title = "Convert slice-based table tests to map-based tables"
+	}
+
+	dir := remote.Dir
+	if dir == "" {
+		var err error
+		if dir, err = os.MkdirTemp("", "tabletests-remote-"); err != nil {
+			return RemoteResult{}, err
+		}
+		if !remote.NoPush {
+			defer os.RemoveAll(dir)
+		}
+	}
+	res := RemoteResult{Dir: dir}
+
+	// Step 1: Clone and branch off the base
+	c.logf("Cloning %s into %s\n", remote.URL, dir)
+	if _, err := git(".", "clone", "--quiet", "--", remote.URL, dir); err != nil {
+		return res, err
+	}
+	if remote.Base == "" {
+		var err error
+		if remote.Base, err = git(dir, "rev-parse", "--abbrev-ref", "HEAD"); err != nil {
+			return res, err
+		}
+	}
+	if _, err := git(dir, "checkout", "--quiet", "-b", remote.Branch, "origin/"+remote.Base); err != nil {
+		return res, err
+	}
+
+	// Step 2: Convert the clone in place
+	conv := *c
+	conv.opts.DryRun = false
+	conv.opts.FileFilter = nil
+	conv.opts.Journal = ""
+	result, err := conv.ConvertPaths([]string{dir})
+	if err != nil {
+		return res, err
+	}
+	res.Result = result.relativeTo(dir)
+	var summary strings.Builder
+	if err := WriteMarkdown(&summary, res.Result); err != nil {
+		return res, err
+	}
+	res.Summary = summary.String()
+	if result.FilesModified == 0 {
+		c.logf("Nothing to convert in %s\n", remote.URL)
+		return res, nil
+	}
+
+	// Step 3: Commit, push, and open the pull request
+	if _, err := git(dir, "add", "--all"); err != nil {
+		return res, err
+	}
+	if _, err := git(dir, "commit", "--quiet", "--message", remote.Title); err != nil {
+		return res, err
+	}
+	if remote.NoPush {
+		return res, nil
+	}
+	c.logf("Pushing %s to %s\n", remote.Branch, remote.URL)
+	if _, err := git(dir, "push", "--quiet", "origin", remote.Branch); err != nil {
+		return res, err
+	}
+	res.PullRequest, err = openPullRequest(provider, apiURL, remote.Token, repo, remote.Branch, remote.Base, remote.Title, res.Summary)
+	return res, err
+}
+
+// parseRepoURL returns the host and the repository path ('owner/repo') of a git URL, in
+// URL form ('https://github.com/owner/repo.git') or scp-like form
+// ('git@github.com:owner/repo.git')
+func parseRepoURL(raw string) (string, string, error) {
+	var host, path string
+	if u, err := url.Parse(raw); err == nil && u.Scheme != "" && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+	} else if at, rest, ok := strings.Cut(raw, ":"); ok && !strings.Contains(at, "/") {
+		host, path = at[strings.LastIndex(at, "@")+1:], rest
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || !strings.Contains(path, "/") {
+		return "", "", fmt.Errorf("can't tell the host and repository of %q", raw)
+	}
+	return host, path, nil
+}
+
+// RemoteProvider returns the provider ConvertRemote opens the pull request on a repository
+// with: the given one if set, or else the one told by the host of its URL or by apiURL
+func RemoteProvider(repoURL, provider, apiURL string) (string, error) {
+	host, _, err := parseRepoURL(repoURL)
+	if err != nil {
+		return "", err
+	}
+	provider, _, err = remoteAPI(host, provider, apiURL)
+	return provider, err
+}
+
+// remoteAPI returns the provider and API root for a host, from the given ones if set
+func remoteAPI(host, provider, apiURL string) (string, string, error) {
+	if provider == "" {
+		switch {
+		case host == "github.com" || strings.Contains(apiURL, "github"):
+			provider = ProviderGitHub
+		case strings.Contains(host, "gitlab") || strings.HasSuffix(apiURL, "/api/v4"):
+			provider = ProviderGitLab
+		default:
+			return "", "", fmt.Errorf("can't tell whether %s is GitHub or GitLab: set the provider", host)
+		}
+	}
+	if apiURL == "" {
+		switch {
+		case provider == ProviderGitHub && host == "github.com":
+			apiURL = "https://api.github.com"
+		case provider == ProviderGitHub:
+			// GitHub Enterprise Server
+			apiURL = "https://" + host + "/api/v3"
+		case provider == ProviderGitLab:
+			apiURL = "https://" + host + "/api/v4"
+		}
+	}
+	if provider != ProviderGitHub && provider != ProviderGitLab {
+		return "", "", fmt.Errorf("unknown provider %q: use %s or %s", provider, ProviderGitHub, ProviderGitLab)
+	}
+	return provider, strings.TrimSuffix(apiURL, "/"), nil
+}
+
+// openPullRequest opens a pull request of head into base and returns its web URL
+func openPullRequest(provider, apiURL, token, repo, head, base, title, body string) (string, error) {
+	var endpoint string
+	var payload any
+	header := http.Header{"Content-Type": {"application/json"}}
+	switch provider {
+	case ProviderGitHub:
+		endpoint = apiURL + "/repos/" + repo + "/pulls"
+		payload = map[string]string{"title": title, "head": head, "base": base, "body": body}
+		header.Set("Accept", "application/vnd.github+json")
+		header.Set("Authorization", "Bearer "+token)
+	case ProviderGitLab:
+		endpoint = apiURL + "/projects/" + url.PathEscape(repo) + "/merge_requests"
+		payload = map[string]string{"title": title, "source_branch": head, "target_branch": base, "description": body}
+		header.Set("PRIVATE-TOKEN", token)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header = header
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error opening pull request: %v", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error opening pull request: %v", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("error opening pull request: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	var created struct {
+		HTMLURL string `json:"html_url"`
+		WebURL  string `json:"web_url"`
+	}
+	if err := json.Unmarshal(respBody, &created); err != nil {
+		return "", fmt.Errorf("error reading pull request: %v", err)
+	}
+	if created.HTMLURL != "" {
+		return created.HTMLURL, nil
+	}
+	return created.WebURL, nil
+}
+
+// relativeTo returns the result with the paths of files under root made relative to it
+func (r ConversionResult) relativeTo(root string) ConversionResult {
+	rel := func(path string) string {
+		if relPath, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(relPath, "..") {
+			return relPath
+		}
+		return path
+	}
+	relTables := func(tables []Table) []Table {
+		out := make([]Table, len(tables))
+		for i, table := range tables {
+			table.Pos.Filename = rel(table.Pos.Filename)
+			out[i] = table
+		}
+		return out
+	}
+	relDiags := func(diags []Diagnostic) []Diagnostic {
+		out := make([]Diagnostic, len(diags))
+		for i, diag := range diags {
+			diag.Pos.Filename = rel(diag.Pos.Filename)
+			out[i] = diag
+		}
+		return out
+	}
+	relSkipped := func(skipped []SkippedTable) []SkippedTable {
+		out := make([]SkippedTable, len(skipped))
+		for i, s := range skipped {
+			s.Table.Pos.Filename = rel(s.Table.Pos.Filename)
+			out[i] = s
+		}
+		return out
+	}
+
+	files := make([]FileResult, len(r.Files))
+	for i, file := range r.Files {
+		file.Path = rel(file.Path)
+		file.Tables = relTables(file.Tables)
+		file.Diagnostics = relDiags(file.Diagnostics)
+		file.Skipped = relSkipped(file.Skipped)
+		files[i] = file
+	}
+	r.Files = files
+	r.Tables = relTables(r.Tables)
+	r.Diagnostics = relDiags(r.Diagnostics)
+	r.Skipped = relSkipped(r.Skipped)
+	errs := make([]FileError, len(r.Errors))
+	for i, e := range r.Errors {
+		e.Path = rel(e.Path)
+		errs[i] = e
+	}
+	r.Errors = errs
+	return r
+}
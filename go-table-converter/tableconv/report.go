@@ -0,0 +1,129 @@
+package tableconv
+
+import (
+	"encoding/json"
+	"go/token"
+	"io"
+)
+
+// jsonReport is the machine-readable form of a ConversionResult
+type jsonReport struct {
+	FilesProcessed   int             `json:"filesProcessed"`
+	FilesModified    int             `json:"filesModified"`
+	FilesCached      int             `json:"filesCached"`
+	FilesGenerated   int             `json:"filesGenerated"`
+	TablesConverted  int             `json:"tablesConverted"`
+	TablesSkipped    int             `json:"tablesSkipped"`
+	TablesSuppressed int             `json:"tablesSuppressed"`
+	Packages         []jsonPackage   `json:"packages"`
+	Files            []jsonFile      `json:"files"`
+	Errors           []jsonFileError `json:"errors"`
+}
+
+// jsonPackage reports the statistics of a package directory
+type jsonPackage struct {
+	Dir             string `json:"dir"`
+	Files           int    `json:"files"`
+	FilesModified   int    `json:"filesModified"`
+	TablesConverted int    `json:"tablesConverted"`
+	TablesSkipped   int    `json:"tablesSkipped"`
+	Warnings        int    `json:"warnings"`
+	Errors          int    `json:"errors"`
+}
+
+// jsonFile reports a processed file
+type jsonFile struct {
+	Path        string           `json:"path"`
+	Modified    bool             `json:"modified"`
+	Cached      bool             `json:"cached"`
+	Generated   bool             `json:"generated"`
+	Tables      []jsonTable      `json:"tables"`
+	Diagnostics []jsonDiagnostic `json:"diagnostics"`
+}
+
+// jsonTable reports a converted table
+type jsonTable struct {
+	Name     string       `json:"name"`
+	Func     string       `json:"func,omitempty"`
+	Position jsonPosition `json:"position"`
+}
+
+// jsonDiagnostic reports a problem found with a table
+type jsonDiagnostic struct {
+	Table    string       `json:"table"`
+	Message  string       `json:"message"`
+	Position jsonPosition `json:"position"`
+}
+
+// jsonFileError reports a file that could not be converted
+type jsonFileError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// jsonPosition is a position in a source file
+type jsonPosition struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+}
+
+// WriteJSON writes a conversion result as an indented JSON report
+func WriteJSON(w io.Writer, result ConversionResult) error {
+	report := jsonReport{
+		FilesProcessed:   result.FilesProcessed,
+		FilesModified:    result.FilesModified,
+		FilesCached:      result.FilesCached,
+		FilesGenerated:   result.FilesGenerated,
+		TablesConverted:  result.TablesConverted,
+		TablesSkipped:    result.TablesSkipped,
+		TablesSuppressed: result.TablesSuppressed,
+		Packages:         []jsonPackage{},
+		Files:            make([]jsonFile, 0, len(result.Files)),
+		Errors:           make([]jsonFileError, 0, len(result.Errors)),
+	}
+
+	for _, p := range result.Packages() {
+		report.Packages = append(report.Packages, jsonPackage{
+			Dir:             p.Dir,
+			Files:           p.Files,
+			FilesModified:   p.FilesModified,
+			TablesConverted: p.TablesConverted,
+			TablesSkipped:   p.TablesSkipped,
+			Warnings:        p.Warnings,
+			Errors:          p.Errors,
+		})
+	}
+
+	for _, file := range result.Files {
+		jf := jsonFile{
+			Path:        file.Path,
+			Modified:    file.Modified,
+			Cached:      file.Cached,
+			Generated:   file.Generated,
+			Tables:      make([]jsonTable, 0, len(file.Tables)),
+			Diagnostics: make([]jsonDiagnostic, 0, len(file.Diagnostics)),
+		}
+		for _, table := range file.Tables {
+			jf.Tables = append(jf.Tables, jsonTable{Name: table.Name, Func: table.Func, Position: newJSONPosition(table.Pos)})
+		}
+		for _, diag := range file.Diagnostics {
+			jf.Diagnostics = append(jf.Diagnostics, jsonDiagnostic{Table: diag.Table, Message: diag.Message, Position: newJSONPosition(diag.Pos)})
+		}
+		report.Files = append(report.Files, jf)
+	}
+
+	for _, err := range result.Errors {
+		report.Errors = append(report.Errors, jsonFileError{Path: err.Path, Message: err.Message})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// newJSONPosition converts a token position for the JSON report
+func newJSONPosition(pos token.Position) jsonPosition {
+	return jsonPosition{Path: pos.Filename, Line: pos.Line, Column: pos.Column, Offset: pos.Offset}
+}
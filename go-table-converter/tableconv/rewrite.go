@@ -0,0 +1,78 @@
+package tableconv
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"reflect"
+)
+
+var (
+	exprType      = reflect.TypeOf((*ast.Expr)(nil)).Elem()
+	exprSliceType = reflect.TypeOf([]ast.Expr(nil))
+)
+
+// replaceExprs walks the tree rooted at root and replaces every expression for which
+// replace returns a non-nil result. Replacement expressions are not walked again.
+func replaceExprs(root ast.Node, replace func(ast.Expr) ast.Expr) {
+	ast.Inspect(root, func(n ast.Node) bool {
+		if n == nil {
+			return true
+		}
+
+		v := reflect.ValueOf(n)
+		if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+			return true
+		}
+
+		// Expressions can only be swapped through the fields that hold them
+		v = v.Elem()
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			switch field.Type() {
+			case exprType:
+				if field.IsNil() {
+					continue
+				}
+				if r := replace(field.Interface().(ast.Expr)); r != nil {
+					field.Set(reflect.ValueOf(r))
+				}
+			case exprSliceType:
+				for j := 0; j < field.Len(); j++ {
+					if r := replace(field.Index(j).Interface().(ast.Expr)); r != nil {
+						field.Index(j).Set(reflect.ValueOf(r))
+					}
+				}
+			}
+		}
+
+		return true
+	})
+}
+
+// parsedSource is a source along with the file set it was parsed into, for rewrites that
+// splice the original text of its nodes
+type parsedSource struct {
+	fset *token.FileSet
+	src  []byte
+}
+
+// offset returns the offset of a position in the source
+func (s parsedSource) offset(pos token.Pos) int {
+	return s.fset.Position(pos).Offset
+}
+
+// text returns the source of a node
+func (s parsedSource) text(node ast.Node) string {
+	return string(s.src[s.offset(node.Pos()):s.offset(node.End())])
+}
+
+// nodeString returns the source of a node
+func nodeString(fset *token.FileSet, node ast.Node) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
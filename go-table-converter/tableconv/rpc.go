@@ -0,0 +1,38 @@
+package tableconv
+
+import "encoding/json"
+
+// rpcMessage is a JSON-RPC 2.0 request, response, or notification, as the language and
+// MCP servers exchange them. Notifications have no ID.
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is the error of a failed request
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidParams  = -32602
+	rpcMethodNotFound = -32601
+	rpcInternalError  = -32603
+)
+
+// rpcResponse answers the request with the given ID with a result or an error
+func rpcResponse(id json.RawMessage, result any, err *rpcError) rpcMessage {
+	resp := rpcMessage{JSONRPC: "2.0", ID: id, Result: result, Error: err}
+	if result == nil && err == nil {
+		// Requests answered with null still need a result member
+		resp.Result = json.RawMessage("null")
+	}
+	return resp
+}
@@ -0,0 +1,215 @@
+package tableconv
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// Kinds of rules a config file can declare
+const (
+	// ruleRenameField renames a field of the case structs of table tests
+	ruleRenameField = "rename-field"
+	// ruleReplaceCall replaces the function called by calls of a helper
+	ruleReplaceCall = "replace-call"
+)
+
+// ruleFromConfig returns the rule of a kind read from a config file, rewriting from to to
+func ruleFromConfig(kind, from, to string) (Rule, error) {
+	switch kind {
+	case ruleRenameField:
+		return renameFieldRule(from, to)
+	case ruleReplaceCall:
+		return replaceCallRule(from, to)
+	}
+	return Rule{}, fmt.Errorf("unknown section %q: use %s, %s, or %s", kind, configPipeline, ruleRenameField, ruleReplaceCall)
+}
+
+// renameFieldRule returns the rule renaming the field from of anonymous case structs to to
+func renameFieldRule(from, to string) (Rule, error) {
+	if !token.IsIdentifier(from) || !token.IsIdentifier(to) {
+		return Rule{}, fmt.Errorf("%s %s: %s isn't a field name", ruleRenameField, from, to)
+	}
+
+	rewrite := func(fset *token.FileSet, file *ast.File) (bool, error) {
+		// Tables ranged over by several loops are renamed once, or left alone for all of them
+		renamed := make(map[*ast.CompositeLit]bool)
+		changed := false
+		ast.Inspect(file, func(n ast.Node) bool {
+			rangeStmt, ok := n.(*ast.RangeStmt)
+			if !ok || !tableExpr(rangeStmt.X) {
+				return true
+			}
+			lit := tableValue(rangeStmt.X).(*ast.CompositeLit)
+			done, seen := renamed[lit]
+			if !seen {
+				done = renameCaseField(lit, from, to)
+				renamed[lit] = done
+				changed = changed || done
+			}
+
+			tc := caseIdent(rangeStmt)
+			if !done || tc == nil {
+				return true
+			}
+			ast.Inspect(rangeStmt.Body, func(n ast.Node) bool {
+				if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel.Name == from {
+					if x, ok := sel.X.(*ast.Ident); ok && x.Name == tc.Name {
+						sel.Sel.Name = to
+					}
+				}
+				return true
+			})
+			return true
+		})
+		return changed, nil
+	}
+	name := fmt.Sprintf("%s %s to %s", ruleRenameField, from, to)
+	return Rule{Name: name, Rewrite: rewrite, key: name}, nil
+}
+
+// renameCaseField renames a field of the anonymous case struct of a table literal and the
+// keys of its keyed cases, reporting whether the struct has the field and not yet one
+// named to
+func renameCaseField(lit *ast.CompositeLit, from, to string) bool {
+	var elt ast.Expr
+	switch typ := lit.Type.(type) {
+	case *ast.ArrayType:
+		elt = typ.Elt
+	case *ast.MapType:
+		elt = typ.Value
+	}
+	if star, ok := elt.(*ast.StarExpr); ok {
+		elt = star.X
+	}
+	structType, ok := elt.(*ast.StructType)
+	if !ok {
+		return false
+	}
+
+	var field *ast.Ident
+	for _, f := range structType.Fields.List {
+		for _, name := range f.Names {
+			switch name.Name {
+			case from:
+				field = name
+			case to:
+				return false
+			}
+		}
+	}
+	if field == nil {
+		return false
+	}
+
+	field.Name = to
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			elt = kv.Value
+		}
+		caseLit := caseLiteral(elt)
+		if caseLit == nil {
+			continue
+		}
+		for _, value := range caseLit.Elts {
+			if kv, ok := value.(*ast.KeyValueExpr); ok {
+				if key, ok := kv.Key.(*ast.Ident); ok && key.Name == from {
+					key.Name = to
+				}
+			}
+		}
+	}
+	return true
+}
+
+// replaceCallRule returns the rule making calls of the function from call to instead. Both
+// are a function name or a package name and function name ('require.Equal'); to may
+// instead qualify the function with an import path ('github.com/acme/check.Diff').
+func replaceCallRule(from, to string) (Rule, error) {
+	fromPkg, fromName, ok := splitFuncName(from)
+	if !ok || strings.Contains(fromPkg, "/") {
+		return Rule{}, fmt.Errorf("%s %s: not a function name", ruleReplaceCall, from)
+	}
+	toPath, toName, ok := splitFuncName(to)
+	if !ok {
+		return Rule{}, fmt.Errorf("%s %s: %s isn't a function name", ruleReplaceCall, from, to)
+	}
+	toPkg := toPath
+	if strings.Contains(toPath, "/") {
+		toPkg = importName(&ast.ImportSpec{Path: &ast.BasicLit{Value: strconv.Quote(toPath)}})
+	} else if path, ok := testifyPackages[toPkg]; ok {
+		toPath = path
+	} else {
+		// Standard library packages are imported along with the conversion's own
+		toPath = ""
+	}
+
+	rewrite := func(fset *token.FileSet, file *ast.File) (bool, error) {
+		paths := importPaths(file)
+		changed := false
+		var err error
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || err != nil || !callsFunc(call, fromPkg, fromName) {
+				return true
+			}
+			if toPkg != "" && paths[toPkg] == "" {
+				if _, known := knownImports[toPkg]; !known && toPath == "" {
+					err = fmt.Errorf("%s: can't tell the import path of %s", fset.Position(call.Pos()), toPkg)
+					return false
+				}
+				if toPath != "" {
+					addImport(fset, file, toPath)
+					paths[toPkg] = toPath
+				}
+			} else if toPath != "" && paths[toPkg] != toPath {
+				err = fmt.Errorf("%s: %s already names the import of %q", fset.Position(call.Pos()), toPkg, paths[toPkg])
+				return false
+			}
+
+			pos := call.Fun.Pos()
+			if toPkg == "" {
+				call.Fun = &ast.Ident{NamePos: pos, Name: toName}
+			} else {
+				call.Fun = &ast.SelectorExpr{X: &ast.Ident{NamePos: pos, Name: toPkg}, Sel: &ast.Ident{NamePos: pos, Name: toName}}
+			}
+			changed = true
+			return true
+		})
+		return changed, err
+	}
+	name := fmt.Sprintf("%s %s with %s", ruleReplaceCall, from, to)
+	return Rule{Name: name, Rewrite: rewrite, key: name}, nil
+}
+
+// splitFuncName splits a function name qualified by a package name or import path at its
+// last dot, reporting whether the name is a valid function name
+func splitFuncName(s string) (string, string, bool) {
+	i := strings.LastIndex(s, ".")
+	if i < strings.LastIndex(s, "/") {
+		return "", "", false
+	}
+	pkg, name := "", s
+	if i >= 0 {
+		pkg, name = s[:i], s[i+1:]
+		if pkg == "" || (!strings.Contains(pkg, "/") && !token.IsIdentifier(pkg)) {
+			return "", "", false
+		}
+	}
+	return pkg, name, token.IsIdentifier(name)
+}
+
+// callsFunc reports whether a call calls the function name, qualified by the package
+// name pkg unless it is empty
+func callsFunc(call *ast.CallExpr, pkg, name string) bool {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return pkg == "" && fun.Name == name
+	case *ast.SelectorExpr:
+		x, ok := fun.X.(*ast.Ident)
+		return ok && pkg != "" && x.Obj == nil && x.Name == pkg && fun.Sel.Name == name
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package tableconv
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+)
+
+// Rule is a rewrite of test files bundled into the conversion, such as a migration to a
+// company's assertion helpers. Rules run on every file after its tables are converted,
+// so files they change are diffed, type-checked, and written like converted ones.
+type Rule struct {
+	// Name identifies the rule in progress messages and errors
+	Name string
+	// Rewrite changes a parsed file in place and reports whether it changed anything.
+	// Comments of nodes it removes are dropped; an error leaves the file unwritten.
+	Rewrite func(fset *token.FileSet, file *ast.File) (changed bool, err error)
+
+	// key spells out what rules read from a config file do, for the cache of clean files
+	key string
+}
+
+// RegisterRule adds a rule to run on the files the converter processes, after the
+// rules registered before it. Registering a rule turns off the cache of clean files,
+// since the cache can't tell what a rule would change; the rules of Options.Rules that
+// LoadConfig read keep it, keyed by what they do.
+func (c *Converter) RegisterRule(rule Rule) {
+	c.rules = append(c.rules, rule)
+	c.cache = nil
+}
+
+// applyRules runs the registered rules on a file that isn't generated, reporting
+// whether any of them changed it
+func (c *Converter) applyRules(fset *token.FileSet, file *sourceFile) bool {
+	if file.result.Generated {
+		return false
+	}
+
+	changed := false
+	for _, rule := range c.rules {
+		ok, err := rule.Rewrite(fset, file.node)
+		if err != nil {
+			file.err = fmt.Errorf("rule %s: %v", rule.Name, err)
+			return false
+		}
+		if ok {
+			c.logf("Applied rule %s to %s\n", rule.Name, file.path)
+			changed = true
+		}
+	}
+	return changed
+}
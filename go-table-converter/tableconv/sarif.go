@@ -0,0 +1,162 @@
+package tableconv
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"path/filepath"
+)
+
+// SARIF rule identifiers for the findings of a check run
+const (
+	ruleSliceTable      = "slice-table"
+	ruleTableDiagnostic = "table-diagnostic"
+	ruleConversionError = "conversion-error"
+)
+
+// sarifLog is the root of a SARIF 2.1.0 log
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	DefaultConfig    sarifConfig  `json:"defaultConfiguration"`
+}
+
+type sarifConfig struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// WriteSARIF writes the findings of a conversion result as a SARIF 2.1.0 log, so they
+// show up in GitHub code scanning and other SARIF viewers: slice-based tables, problems
+// that kept tables from being converted, and files whose conversion failed
+func WriteSARIF(w io.Writer, result ConversionResult) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "tabletests",
+			Version:        Version,
+			InformationURI: "https://github.com/khalilchatoo/claude-playground/tree/main/go-table-converter",
+			Rules: []sarifRule{
+				{
+					ID:               ruleSliceTable,
+					Name:             "SliceBasedTableTest",
+					ShortDescription: sarifMessage{"Table test should be map-based"},
+					DefaultConfig:    sarifConfig{"warning"},
+				},
+				{
+					ID:               ruleTableDiagnostic,
+					Name:             "TableTestDiagnostic",
+					ShortDescription: sarifMessage{"Table test can't be converted safely"},
+					DefaultConfig:    sarifConfig{"warning"},
+				},
+				{
+					ID:               ruleConversionError,
+					Name:             "ConversionError",
+					ShortDescription: sarifMessage{"File could not be converted"},
+					DefaultConfig:    sarifConfig{"error"},
+				},
+			},
+		}},
+		Results: []sarifResult{},
+	}
+
+	for _, table := range result.Tables {
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    ruleSliceTable,
+			Level:     "warning",
+			Message:   sarifMessage{fmt.Sprintf("slice-based table test %q should be map-based", table.Name)},
+			Locations: []sarifLocation{newSARIFLocation(table.Pos)},
+		})
+	}
+
+	for _, diag := range result.Diagnostics {
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    ruleTableDiagnostic,
+			Level:     "warning",
+			Message:   sarifMessage{fmt.Sprintf("%s: %s", diag.Table, diag.Message)},
+			Locations: []sarifLocation{newSARIFLocation(diag.Pos)},
+		})
+	}
+
+	for _, err := range result.Errors {
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    ruleConversionError,
+			Level:     "error",
+			Message:   sarifMessage{err.Message},
+			Locations: []sarifLocation{newSARIFLocation(token.Position{Filename: err.Path})},
+		})
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+// newSARIFLocation converts a token position to a SARIF location. The region is omitted
+// for positions that only name a file.
+func newSARIFLocation(pos token.Position) sarifLocation {
+	loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(pos.Filename)},
+	}}
+	if pos.Line > 0 {
+		loc.PhysicalLocation.Region = &sarifRegion{StartLine: pos.Line, StartColumn: pos.Column}
+	}
+	return loc
+}
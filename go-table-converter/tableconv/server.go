@@ -0,0 +1,111 @@
+package tableconv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxRequestBytes limits the size of the sources the HTTP API accepts
+const maxRequestBytes = 4 << 20
+
+// jsonConversion is the response of the HTTP API to a source: the converted source for
+// /convert, the tables it converts or would convert, and the problems found
+type jsonConversion struct {
+	Source      *string          `json:"source,omitempty"`
+	Modified    bool             `json:"modified"`
+	Tables      []jsonTable      `json:"tables"`
+	Diagnostics []jsonDiagnostic `json:"diagnostics"`
+	Skipped     []jsonSkipped    `json:"skipped"`
+}
+
+// jsonError is the response of the HTTP API to a request it couldn't serve
+type jsonError struct {
+	Error string `json:"error"`
+}
+
+// Handler returns the HTTP API of the converter, as the 'serve' subcommand serves it. Both
+// endpoints take Go source as the body of a POST request and convert it as a file of its
+// own, with the options of the converter, returning JSON: POST /convert returns the
+// converted source along with the tables converted and the diagnostics, and POST /analyze
+// the same without the source. Sources that don't parse get a 422 response with the error.
+// Packages the sources import are loaded once and shared by the requests.
+func (c *Converter) Handler() http.Handler {
+	if c.importer == nil {
+		c.importer = &sharedImporter{importer: newImporter()}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/convert", func(w http.ResponseWriter, r *http.Request) {
+		c.serveSource(w, r, true)
+	})
+	mux.HandleFunc("/analyze", func(w http.ResponseWriter, r *http.Request) {
+		c.serveSource(w, r, false)
+	})
+	return mux
+}
+
+// serveSource converts the source in the body of a request, including the converted
+// source in the response when asked to
+func (c *Converter) serveSource(w http.ResponseWriter, r *http.Request, withSource bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONResponse(w, http.StatusMethodNotAllowed, jsonError{fmt.Sprintf("method %s not allowed", r.Method)})
+		return
+	}
+	src, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeJSONResponse(w, status, jsonError{fmt.Sprintf("error reading source: %v", err)})
+		return
+	}
+
+	// Requests are served concurrently, each by a converter of its own sharing the importer
+	conv := *c
+	file, err := conv.convertSource(src)
+	if err != nil {
+		writeJSONResponse(w, http.StatusUnprocessableEntity, jsonError{err.Error()})
+		return
+	}
+
+	resp := jsonConversion{
+		Modified:    file.result.Modified,
+		Tables:      []jsonTable{},
+		Diagnostics: []jsonDiagnostic{},
+		Skipped:     []jsonSkipped{},
+	}
+	if withSource {
+		out := string(file.out)
+		resp.Source = &out
+	}
+	for _, table := range file.result.Tables {
+		resp.Tables = append(resp.Tables, jsonTable{Name: table.Name, Func: table.Func, Position: newJSONPosition(table.Pos)})
+	}
+	for _, diag := range file.result.Diagnostics {
+		resp.Diagnostics = append(resp.Diagnostics, jsonDiagnostic{Table: diag.Table, Message: diag.Message, Position: newJSONPosition(diag.Pos)})
+	}
+	for _, skipped := range file.result.Skipped {
+		resp.Skipped = append(resp.Skipped, jsonSkipped{
+			Name:     skipped.Table.Name,
+			Func:     skipped.Table.Func,
+			Position: newJSONPosition(skipped.Table.Pos),
+			Reason:   skipped.Reason,
+			Message:  skipped.Message,
+		})
+	}
+	writeJSONResponse(w, http.StatusOK, resp)
+}
+
+// writeJSONResponse writes a value as the indented JSON body of a response
+func writeJSONResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
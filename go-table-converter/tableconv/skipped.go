@@ -0,0 +1,105 @@
+package tableconv
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// SkipReason tells why a slice-based table was left unconverted, as a stable identifier
+// for tools reading the reports
+type SkipReason string
+
+const (
+	// SkipNoNameField marks tables whose case struct has no name field to key them by
+	SkipNoNameField SkipReason = "no-name-field"
+	// SkipPromotedNameField marks tables whose case struct only has a name field through a
+	// struct it embeds
+	SkipPromotedNameField SkipReason = "promoted-name-field"
+	// SkipUsedOutsideLoops marks tables without a name field that keys were to be
+	// generated for, but that are used by more than loops over them
+	SkipUsedOutsideLoops SkipReason = "used-outside-loops"
+	// SkipIndexed marks tables whose loops index them ('tests[i]')
+	SkipIndexed SkipReason = "indexed"
+	// SkipUsedAsSlice marks tables used as slices outside of the loops ranging over them,
+	// such as 'tests[0]' or a three-clause loop indexing them
+	SkipUsedAsSlice SkipReason = "used-as-slice"
+	// SkipOrderDependent marks tables whose loops depend on the order of their cases
+	SkipOrderDependent SkipReason = "order-dependent"
+	// SkipNonLiteralCases marks tables with cases that aren't struct literals
+	SkipNonLiteralCases SkipReason = "non-literal-cases"
+	// SkipDuplicateNames marks tables whose cases share a name
+	SkipDuplicateNames SkipReason = "duplicate-names"
+	// SkipSharedVariable marks tables assigned to a variable another table assigned to
+	// it can't be converted with
+	SkipSharedVariable SkipReason = "shared-variable"
+	// SkipCaseTypeShared marks tables whose named case type is used outside of the
+	// tables being converted
+	SkipCaseTypeShared SkipReason = "case-type-shared"
+	// SkipCaseTypeGenerated marks tables whose named case type is declared in a
+	// generated file
+	SkipCaseTypeGenerated SkipReason = "case-type-generated"
+	// SkipInvalidDirective marks tables with a //tabletests: directive that isn't
+	// understood
+	SkipInvalidDirective SkipReason = "invalid-directive"
+	// SkipGocheckFixtures marks gocheck suites with fixtures such as SetUpTest, whose test
+	// methods aren't migrated to test functions
+	SkipGocheckFixtures SkipReason = "gocheck-fixtures"
+)
+
+// skipMessages explain the reasons tables are skipped
+var skipMessages = map[SkipReason]string{
+	SkipNoNameField:       "the case struct has no name field to key the cases by; pass -keys to generate names",
+	SkipPromotedNameField: "the name field is promoted from an embedded struct, which would have to lose it too",
+	SkipUsedOutsideLoops:  "the table has no name field and is used by more than the loops over it, so it may be plain test data",
+	SkipIndexed:           "its loops index the table, which a map can't be",
+	SkipUsedAsSlice:       "the table is used as a slice outside of the loops ranging over it, which a map can't stand in for",
+	SkipOrderDependent:    "its loops depend on the order of the cases, which a map doesn't keep; pass -ignore-order to convert it anyway",
+	SkipNonLiteralCases:   "some cases aren't struct literals, so their names can't become map keys",
+	SkipDuplicateNames:    "some cases share a name, which would make duplicate map keys; pass -suffix-duplicates to number them",
+	SkipSharedVariable:    "another table assigned to the same variable can't be converted",
+	SkipCaseTypeShared:    "its named case type is used outside of the table tests being converted",
+	SkipCaseTypeGenerated: "its named case type is declared in a generated file",
+	SkipInvalidDirective:  "a " + directivePrefix + " directive of the table isn't understood",
+	SkipGocheckFixtures:   "the gocheck suite has fixtures such as SetUpTest, which test functions would have to call themselves",
+}
+
+// SkippedTable describes a slice-based table test that was found but left unconverted
+type SkippedTable struct {
+	Table Table
+	// Reason identifies why the table was skipped
+	Reason SkipReason
+	// Message explains the reason
+	Message string
+}
+
+// skipTable records a table of the file left unconverted for the given reason
+func (file *sourceFile) skipTable(table Table, reason SkipReason) {
+	file.result.Skipped = append(file.result.Skipped, SkippedTable{Table: table, Reason: reason, Message: skipMessages[reason]})
+}
+
+// jsonSkipped is the machine-readable form of a SkippedTable
+type jsonSkipped struct {
+	Name     string       `json:"name"`
+	Func     string       `json:"func,omitempty"`
+	Position jsonPosition `json:"position"`
+	Reason   SkipReason   `json:"reason"`
+	Message  string       `json:"message"`
+}
+
+// WriteSkippedJSON writes the tables a run left unconverted as an indented JSON array
+func WriteSkippedJSON(w io.Writer, result ConversionResult) error {
+	report := []jsonSkipped{}
+	for _, skipped := range result.Skipped {
+		report = append(report, jsonSkipped{
+			Name:     skipped.Table.Name,
+			Func:     skipped.Table.Func,
+			Position: newJSONPosition(skipped.Table.Pos),
+			Reason:   skipped.Reason,
+			Message:  skipped.Message,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
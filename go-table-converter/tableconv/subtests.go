@@ -0,0 +1,147 @@
+package tableconv
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// wrapSubtests wraps the bodies of loops over converted tables in a test function in
+// t.Run subtests named by the map key, so each case can fail on its own and be selected
+// with -run; benchmarks get b.Run sub-benchmarks the same way. Loops that already run
+// subtests are left alone, as are bodies whose meaning would change inside a closure
+// because they return or jump out of the loop.
+func wrapSubtests(fn *ast.FuncDecl, vars tableVars) bool {
+	testingType := "T"
+	param := testingParam(fn.Type, testingType)
+	if param == "" {
+		testingType = "B"
+		param = testingParam(fn.Type, testingType)
+	}
+	if fn.Body == nil || param == "" {
+		return false
+	}
+
+	modified := false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		rangeStmt, ok := n.(*ast.RangeStmt)
+		if !ok {
+			return true
+		}
+		if _, ok := vars.ranged(rangeStmt); !ok {
+			return true
+		}
+
+		if !wrappable(rangeStmt.Body) {
+			return true
+		}
+		key := rangeKey(rangeStmt)
+		if key == nil {
+			return true
+		}
+
+		wrapSubtest(rangeStmt, key.Name, param, testingType)
+		modified = true
+		return false
+	})
+
+	return modified
+}
+
+// wrappable reports whether a loop body can move into a subtest closure: it must not
+// already run subtests, and must not return or branch out of the loop
+func wrappable(body *ast.BlockStmt) bool {
+	if len(body.List) == 0 {
+		return false
+	}
+	for _, stmt := range body.List {
+		if subtestFunc(stmt) != nil {
+			return false
+		}
+	}
+
+	safe := true
+	// loops and switches count the statements enclosing a node that an unlabeled break
+	// (switches and loops) or continue (loops only) would apply to instead of our loop
+	var inspect func(n ast.Node, loops, switches int)
+	inspect = func(n ast.Node, loops, switches int) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			if !safe || n == nil {
+				return false
+			}
+
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.ReturnStmt:
+				safe = false
+			case *ast.BranchStmt:
+				switch {
+				case n.Label != nil || n.Tok == token.GOTO:
+					safe = false
+				case n.Tok == token.BREAK:
+					safe = loops+switches > 0
+				case n.Tok == token.CONTINUE:
+					safe = loops > 0
+				}
+			case *ast.ForStmt:
+				inspect(n.Body, loops+1, switches)
+				return false
+			case *ast.RangeStmt:
+				inspect(n.Body, loops+1, switches)
+				return false
+			case *ast.SwitchStmt:
+				inspect(n.Body, loops, switches+1)
+				return false
+			case *ast.TypeSwitchStmt:
+				inspect(n.Body, loops, switches+1)
+				return false
+			case *ast.SelectStmt:
+				inspect(n.Body, loops, switches+1)
+				return false
+			}
+			return true
+		})
+	}
+	for _, stmt := range body.List {
+		inspect(stmt, 0, 0)
+	}
+
+	return safe
+}
+
+// wrapSubtest replaces the body of a loop with t.Run(key, func(t *testing.T) { body }),
+// where testingType is T for tests and B for benchmarks
+func wrapSubtest(rangeStmt *ast.RangeStmt, key, param, testingType string) {
+	body := rangeStmt.Body
+	pos := body.Lbrace
+
+	lit := &ast.FuncLit{
+		Type: &ast.FuncType{
+			Func: pos,
+			Params: &ast.FieldList{List: []*ast.Field{{
+				Names: []*ast.Ident{{NamePos: pos, Name: param}},
+				Type: &ast.StarExpr{Star: pos, X: &ast.SelectorExpr{
+					X:   &ast.Ident{NamePos: pos, Name: "testing"},
+					Sel: &ast.Ident{NamePos: pos, Name: testingType},
+				}},
+			}}},
+		},
+		Body: body,
+	}
+
+	call := &ast.CallExpr{
+		Fun: &ast.SelectorExpr{
+			X:   &ast.Ident{NamePos: pos, Name: param},
+			Sel: &ast.Ident{NamePos: pos, Name: "Run"},
+		},
+		Lparen: pos,
+		Args:   []ast.Expr{&ast.Ident{NamePos: pos, Name: key}, lit},
+		Rparen: body.Rbrace,
+	}
+
+	rangeStmt.Body = &ast.BlockStmt{
+		Lbrace: body.Lbrace,
+		List:   []ast.Stmt{&ast.ExprStmt{X: call}},
+		Rbrace: body.Rbrace,
+	}
+}
@@ -0,0 +1,706 @@
+package tableconv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// assertionRun is a run of structurally identical assertions in a test function that
+// only differ in their literals
+type assertionRun struct {
+	// start is the index of the first assertion in the function body
+	start int
+	stmts []ast.Stmt
+	// lits holds the literals of each assertion, in the same order for every assertion
+	lits [][]*ast.BasicLit
+}
+
+// tableField is a field of a table generated from repeated assertions
+type tableField struct {
+	name string
+	typ  types.Type
+	// lit is the index of the literal the field replaces
+	lit int
+}
+
+// tabulate rewrites runs of repeated assertions in the test functions of a file, such as
+//
+//	if Divide(6, 2) != 3 {
+//		t.Error("6 / 2 should equal 3")
+//	}
+//	if Divide(5, 0) != 0 {
+//		t.Error("Division by zero should return 0")
+//	}
+//
+// into a map-based table test whose fields hold the literals that differ between the
+// assertions. Differing assertion messages become the case names; otherwise cases are
+// named after the call being checked. Only the first run of each function is rewritten.
+// info holds the types of the type-checked package, which name and type the fields; runs
+// whose fields can't be typed are left alone and reported. The rewrite is done on the
+// source so the generated table is laid out like handwritten code; it returns the
+// rewritten source and the generated tables.
+func (c *Converter) tabulate(fset *token.FileSet, file *sourceFile, info *types.Info, pkg *types.Package) ([]byte, []Table) {
+	node, src := file.node, file.parsed
+	var tables []Table
+	var edits []sourceEdit
+	for _, decl := range node.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil || !isTestFunc(fn) {
+			continue
+		}
+
+		param := testingParam(fn.Type, "T")
+		run := findAssertionRun(fset, fn.Body, param)
+		if run == nil || usesIdents(fn.Body, "tests", "tc", "name") {
+			continue
+		}
+
+		table := Table{Name: "tests", Func: fn.Name.Name, Pos: fset.Position(run.stmts[0].Pos())}
+		if c.opts.TableFilter != nil && !c.opts.TableFilter(table) {
+			continue
+		}
+
+		c.logf("Found repeated assertions in %s\n", fn.Name.Name)
+		text, err := tabulateRun(fset, node, src, run, param, info, pkg)
+		if err != nil {
+			c.logf("Skipping repeated assertions in %s: %v\n", fn.Name.Name, err)
+			file.result.Diagnostics = append(file.result.Diagnostics, Diagnostic{
+				Table:   table.Name,
+				Pos:     table.Pos,
+				Message: fmt.Sprintf("repeated assertions in %s not tabulated: %v", fn.Name.Name, err),
+			})
+			continue
+		}
+		edits = append(edits, sourceEdit{
+			start: fset.Position(run.stmts[0].Pos()).Offset,
+			end:   fset.Position(run.stmts[len(run.stmts)-1].End()).Offset,
+			text:  text,
+		})
+		tables = append(tables, table)
+	}
+
+	return applyEdits(src, edits), tables
+}
+
+// sourceEdit replaces the source between two offsets
+type sourceEdit struct {
+	start, end int
+	text       string
+}
+
+// applyEdits applies non-overlapping edits, given in source order, to a copy of src
+func applyEdits(src []byte, edits []sourceEdit) []byte {
+	out := append([]byte{}, src...)
+	for i := len(edits) - 1; i >= 0; i-- {
+		edit := edits[i]
+		out = append(out[:edit.start], append([]byte(edit.text), out[edit.end:]...)...)
+	}
+	return out
+}
+
+// findAssertionRun finds the first run of at least two consecutive assertions of the same
+// shape that differ in at least one literal. An assertion is an if statement without else
+// whose body reports a failure through the test's *testing.T.
+func findAssertionRun(fset *token.FileSet, body *ast.BlockStmt, param string) *assertionRun {
+	var run *assertionRun
+	var runShape string
+	flush := func() *assertionRun {
+		if run != nil && len(run.stmts) >= 2 && len(varyingLits(run)) > 0 {
+			return run
+		}
+		run = nil
+		return nil
+	}
+
+	for i, stmt := range body.List {
+		ifStmt, ok := stmt.(*ast.IfStmt)
+		if !ok || ifStmt.Else != nil || !reportsFailure(ifStmt.Body, param) {
+			if found := flush(); found != nil {
+				return found
+			}
+			continue
+		}
+
+		shape, lits := stmtShape(fset, stmt)
+		if run != nil && shape == runShape {
+			run.stmts = append(run.stmts, stmt)
+			run.lits = append(run.lits, lits)
+			continue
+		}
+
+		if found := flush(); found != nil {
+			return found
+		}
+		run = &assertionRun{start: i, stmts: []ast.Stmt{stmt}, lits: [][]*ast.BasicLit{lits}}
+		runShape = shape
+	}
+
+	return flush()
+}
+
+// reportsFailure reports whether a block calls t.Error, t.Errorf, t.Fatal, or t.Fatalf
+func reportsFailure(body *ast.BlockStmt, param string) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && failureCall(call, param) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// failureCall reports whether a call reports a test failure through the *testing.T param
+func failureCall(call *ast.CallExpr, param string) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	if x, ok := sel.X.(*ast.Ident); !ok || x.Name != param {
+		return false
+	}
+
+	switch sel.Sel.Name {
+	case "Error", "Errorf", "Fatal", "Fatalf":
+		return true
+	}
+	return false
+}
+
+// stmtShape returns the source of a statement with its literals blanked out, which is the
+// same for statements differing only in their literals, along with the literals
+func stmtShape(fset *token.FileSet, stmt ast.Stmt) (string, []*ast.BasicLit) {
+	var lits []*ast.BasicLit
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.BasicLit); ok {
+			lits = append(lits, lit)
+		}
+		return true
+	})
+
+	values := make([]string, len(lits))
+	for i, lit := range lits {
+		values[i] = lit.Value
+		lit.Value = "_" + lit.Kind.String()
+	}
+	shape := nodeString(fset, stmt)
+	for i, lit := range lits {
+		lit.Value = values[i]
+	}
+
+	return shape, lits
+}
+
+// varyingLits returns the indexes of the literals that differ between the assertions
+func varyingLits(run *assertionRun) []int {
+	var varying []int
+	for i, lit := range run.lits[0] {
+		for _, lits := range run.lits[1:] {
+			if lits[i].Value != lit.Value {
+				varying = append(varying, i)
+				break
+			}
+		}
+	}
+	return varying
+}
+
+// usesIdents reports whether any of the given identifiers appear in a block
+func usesIdents(body *ast.BlockStmt, names ...string) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			for _, name := range names {
+				if ident.Name == name {
+					found = true
+				}
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// tabulateRun returns the source of the table test replacing a run of assertions. The
+// first assertion becomes the loop body, with its differing literals read from the case,
+// and comments between the assertions move to the cases they preceded. Differing format
+// strings of t.Errorf and t.Fatalf don't name the cases but keep formatting the message,
+// reading the values they spell out from the case. It fails when they spell out more than
+// that, or when a field can't be typed.
+func tabulateRun(fset *token.FileSet, node *ast.File, src []byte, run *assertionRun, param string, info *types.Info, pkg *types.Package) (string, error) {
+	template := run.stmts[0]
+	parents := parentMap(template)
+
+	// A differing assertion message names the cases, the other differing literals
+	// become fields
+	messageLit := -1
+	var fields []tableField
+	used := map[string]bool{"name": true}
+	for _, i := range varyingLits(run) {
+		lit := run.lits[0][i]
+		if messageLit < 0 && lit.Kind == token.STRING && isMessageArg(lit, parents, param) {
+			messageLit = i
+			continue
+		}
+
+		name, typ := litField(lit, parents, info)
+		if typ == nil {
+			return "", fmt.Errorf("the type of %s can't be resolved", lit.Value)
+		}
+		fieldName := name
+		for n := 2; used[fieldName]; n++ {
+			fieldName = fmt.Sprintf("%s%d", name, n)
+		}
+		used[fieldName] = true
+		fields = append(fields, tableField{name: fieldName, typ: typ, lit: i})
+	}
+
+	// A format string can't name the cases, which are named after the checked call instead
+	var format *caseFormat
+	if messageLit >= 0 {
+		call := parents[run.lits[0][messageLit]].(*ast.CallExpr)
+		if strings.HasSuffix(call.Fun.(*ast.SelectorExpr).Sel.Name, "f") {
+			var ok bool
+			if format, ok = tabulateFormat(run, messageLit, fields, info); !ok || format.own != len(call.Args)-1 {
+				return "", errMessagesDiffer
+			}
+			format.call = call
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("tests := map[string]struct {\n")
+	for _, field := range fields {
+		typ, ok := typeText(field.typ, node, pkg)
+		if !ok {
+			return "", fmt.Errorf("%s isn't imported by the file", typ)
+		}
+		fmt.Fprintf(&buf, "%s %s\n", field.name, typ)
+	}
+	buf.WriteString("}{\n")
+
+	names := make(map[string]int)
+	for i, stmt := range run.stmts {
+		if i > 0 {
+			for _, group := range node.Comments {
+				if group.Pos() > run.stmts[i-1].End() && group.End() < stmt.Pos() {
+					fmt.Fprintf(&buf, "%s\n", src[fset.Position(group.Pos()).Offset:fset.Position(group.End()).Offset])
+				}
+			}
+		}
+
+		var name string
+		if messageLit >= 0 && format == nil {
+			name, _ = strconv.Unquote(run.lits[i][messageLit].Value)
+		} else {
+			name = checkedCall(fset, stmt, param)
+		}
+		if names[name]++; names[name] > 1 {
+			name = fmt.Sprintf("%s #%d", name, names[name])
+		}
+
+		var values []string
+		for _, field := range fields {
+			values = append(values, run.lits[i][field.lit].Value)
+		}
+		fmt.Fprintf(&buf, "%s: {%s},\n", strconv.Quote(name), strings.Join(values, ", "))
+	}
+	buf.WriteString("}\n\n")
+
+	// Read the differing literals of the loop body from the case
+	var edits []sourceEdit
+	base := fset.Position(template.Pos()).Offset
+	replace := func(lit *ast.BasicLit, text string) {
+		start := fset.Position(lit.Pos()).Offset - base
+		edits = append(edits, sourceEdit{start: start, end: start + len(lit.Value), text: text})
+	}
+	for i, lit := range run.lits[0] {
+		if i == messageLit {
+			if format == nil {
+				replace(lit, "name")
+				continue
+			}
+			replace(lit, strconv.Quote(format.text))
+			edits = append(edits, format.argEdits(fset, fields, base)...)
+		}
+		for _, field := range fields {
+			if field.lit == i {
+				replace(lit, "tc."+field.name)
+			}
+		}
+	}
+	// Arguments inserted before a literal argument go in before it is replaced
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].end < edits[j].end
+	})
+	body := applyEdits(src[base:fset.Position(template.End()).Offset], edits)
+
+	fmt.Fprintf(&buf, "for name, tc := range tests {\n")
+	fmt.Fprintf(&buf, "%s.Run(name, func(%s *testing.T) {\n%s\n})\n", param, param, body)
+	buf.WriteString("}")
+
+	return buf.String(), nil
+}
+
+// errMessagesDiffer is why runs of assertions whose format strings differ in more than
+// the values they check aren't tabulated
+var errMessagesDiffer = errors.New("their messages differ in more than the values they check")
+
+// caseFormat is the format string of a printf-style message of a table test generated from
+// assertions whose format strings differ
+type caseFormat struct {
+	text string
+	// fields holds, for each verb of the format in order, the index of the field it reads,
+	// or -1 for the next argument of the message itself
+	fields []int
+	// own counts the verbs reading the arguments of the message itself
+	own int
+	// call is the call of the first assertion formatting the message
+	call *ast.CallExpr
+}
+
+// tabulateFormat turns the differing format strings of the messages of a run of assertions
+// into a single one: the values of the fields that the format of the first assertion spells
+// out become verbs reading the fields. It reports false unless the format spells out the
+// format of every assertion once their values are filled in.
+// Change from: t.Errorf("Divide(6, 3) = %d, want 2", got)
+// To:          t.Errorf("Divide(%v, %v) = %d, want %v", tc.a, tc.b, got, tc.want)
+func tabulateFormat(run *assertionRun, messageLit int, fields []tableField, info *types.Info) (*caseFormat, bool) {
+	formats := make([]string, len(run.lits))
+	for i, lits := range run.lits {
+		var err error
+		if formats[i], err = strconv.Unquote(lits[messageLit].Value); err != nil {
+			return nil, false
+		}
+	}
+	spans := formatVerbs(formats[0])
+	if slices.ContainsFunc(spans, func(span []int) bool { return span == nil }) || spans == nil && strings.Count(formats[0], "%") != 2*strings.Count(formats[0], "%%") {
+		return nil, false
+	}
+
+	// Values are spelled out the way the verbs reading the fields print them
+	texts := make([][]string, len(run.lits))
+	verbs := make([]string, len(fields))
+	for i, lits := range run.lits {
+		texts[i] = make([]string, len(fields))
+		for f, field := range fields {
+			if text, verb := spelledValue(info.Types[lits[field.lit]].Value, field.typ); verb != "" {
+				texts[i][f], verbs[f] = text, verb
+			}
+		}
+	}
+
+	// Match the values of the first assertion in the text between verbs, longest first and
+	// not within a longer word
+	byLength := make([]int, len(fields))
+	for f := range fields {
+		byLength[f] = f
+	}
+	sort.SliceStable(byLength, func(i, j int) bool { return len(texts[0][byLength[i]]) > len(texts[0][byLength[j]]) })
+	isWord := func(b byte) bool { return b == '_' || unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b)) }
+
+	format := &caseFormat{}
+	// pieces are the text of the format, split at the values filled in
+	var pieces []string
+	var filled []int
+	var text strings.Builder
+	s := formats[0]
+	for pos, verb := 0, 0; pos < len(s); {
+		if verb < len(spans) && pos == spans[verb][0] {
+			text.WriteString(s[pos:spans[verb][1]])
+			format.fields = append(format.fields, -1)
+			format.own++
+			pos = spans[verb][1]
+			verb++
+			continue
+		}
+		end := len(s)
+		if verb < len(spans) {
+			end = spans[verb][0]
+		}
+		matched := -1
+		for _, f := range byLength {
+			value := texts[0][f]
+			if value == "" || !strings.HasPrefix(s[pos:end], value) {
+				continue
+			}
+			if pos > 0 && isWord(s[pos-1]) && isWord(value[0]) || pos+len(value) < len(s) && isWord(s[pos+len(value)]) && isWord(value[len(value)-1]) {
+				continue
+			}
+			matched = f
+			break
+		}
+		if matched < 0 {
+			text.WriteByte(s[pos])
+			pos++
+			continue
+		}
+		pieces = append(pieces, text.String())
+		filled = append(filled, matched)
+		format.text += text.String() + verbs[matched]
+		format.fields = append(format.fields, matched)
+		text.Reset()
+		pos += len(texts[0][matched])
+	}
+	pieces = append(pieces, text.String())
+	format.text += text.String()
+
+	// Every assertion's format has to come out of filling in its values
+	for i := range formats {
+		var out strings.Builder
+		for j, piece := range pieces {
+			out.WriteString(piece)
+			if j < len(filled) {
+				out.WriteString(texts[i][filled[j]])
+			}
+		}
+		if out.String() != formats[i] {
+			return nil, false
+		}
+	}
+	return format, true
+}
+
+// formatVerbs returns the span of the verb formatting each operand of a format string.
+// Operands consumed by a '*' width or precision have no span, and formats with explicit
+// argument indexes give none at all.
+func formatVerbs(format string) [][]int {
+	var verbs [][]int
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		start := i
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+		for i < len(format) && (format[i] >= '0' && format[i] <= '9' || format[i] == '.' || format[i] == '*') {
+			if format[i] == '*' {
+				verbs = append(verbs, nil)
+			}
+			i++
+		}
+		if i >= len(format) || format[i] == '[' {
+			return nil
+		}
+		if format[i] != '%' {
+			verbs = append(verbs, []int{start, i + 1})
+		}
+	}
+	return verbs
+}
+
+// spelledValue returns the text a value of a field prints as, and the verb printing it: %s
+// for strings and %v for numbers. It returns no verb for values of other types, and for
+// strings holding verbs themselves.
+func spelledValue(value constant.Value, typ types.Type) (string, string) {
+	basic, ok := typ.(*types.Basic)
+	if !ok || value == nil {
+		return "", ""
+	}
+
+	switch info := basic.Info(); {
+	case info&types.IsString != 0:
+		if text := constant.StringVal(value); !strings.Contains(text, "%") {
+			return text, "%s"
+		}
+	case info&types.IsInteger != 0:
+		return constant.ToInt(value).ExactString(), "%v"
+	case info&types.IsFloat != 0:
+		f, _ := constant.Float64Val(constant.ToFloat(value))
+		bits := 64
+		if basic.Kind() == types.Float32 {
+			bits = 32
+		}
+		return strconv.FormatFloat(f, 'g', -1, bits), "%v"
+	}
+	return "", ""
+}
+
+// argEdits returns the edits to the arguments of the call formatting a message that pass
+// the fields its format reads, in the order of its verbs. Offsets are relative to base.
+func (format *caseFormat) argEdits(fset *token.FileSet, fields []tableField, base int) []sourceEdit {
+	args := format.call.Args[1:]
+	var edits []sourceEdit
+	var pending []string
+	own := 0
+	for _, f := range format.fields {
+		if f >= 0 {
+			pending = append(pending, "tc."+fields[f].name)
+			continue
+		}
+		if len(pending) > 0 {
+			start := fset.Position(args[own].Pos()).Offset - base
+			edits = append(edits, sourceEdit{start: start, end: start, text: strings.Join(pending, ", ") + ", "})
+			pending = nil
+		}
+		own++
+	}
+	if len(pending) > 0 {
+		end := fset.Position(format.call.Args[len(format.call.Args)-1].End()).Offset - base
+		edits = append(edits, sourceEdit{start: end, end: end, text: ", " + strings.Join(pending, ", ")})
+	}
+	return edits
+}
+
+// parentMap maps the nodes below root to their parents
+func parentMap(root ast.Node) map[ast.Node]ast.Node {
+	parents := make(map[ast.Node]ast.Node)
+	var stack []ast.Node
+	ast.Inspect(root, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		if len(stack) > 0 {
+			parents[n] = stack[len(stack)-1]
+		}
+		stack = append(stack, n)
+		return true
+	})
+	return parents
+}
+
+// isMessageArg reports whether a literal is the message passed to t.Error and friends
+func isMessageArg(lit *ast.BasicLit, parents map[ast.Node]ast.Node, param string) bool {
+	call, ok := parents[lit].(*ast.CallExpr)
+	return ok && failureCall(call, param) && len(call.Args) > 0 && call.Args[0] == lit
+}
+
+// litField names the field holding a literal after its use: the parameter it is passed
+// to, "want" when it is compared against, and "value" otherwise. The field has the type
+// the literal takes there, or none when the type check didn't resolve it.
+func litField(lit *ast.BasicLit, parents map[ast.Node]ast.Node, info *types.Info) (string, types.Type) {
+	typ := info.Types[lit].Type
+	if !resolved(typ) {
+		return "", nil
+	}
+	typ = types.Default(typ)
+
+	switch parent := parents[lit].(type) {
+	case *ast.CallExpr:
+		fun := info.Types[parent.Fun]
+		if fun.IsType() {
+			break
+		}
+		sig, ok := fun.Type.(*types.Signature)
+		if !ok {
+			return "", nil
+		}
+		for i, arg := range parent.Args {
+			if arg == lit {
+				if name := paramName(sig, i); name != "" {
+					return name, typ
+				}
+				return fmt.Sprintf("arg%d", i+1), typ
+			}
+		}
+	case *ast.BinaryExpr:
+		switch parent.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			other := parent.X
+			if other == lit {
+				other = parent.Y
+			}
+			if !resolved(info.Types[other].Type) {
+				return "want", nil
+			}
+			return "want", typ
+		}
+	}
+
+	return "value", typ
+}
+
+// resolved reports whether the type check found the type of an expression
+func resolved(typ types.Type) bool {
+	basic, ok := typ.(*types.Basic)
+	return typ != nil && !(ok && basic.Kind() == types.Invalid)
+}
+
+// paramName returns the name of the parameter of a signature taking its i-th argument, or
+// "" when the parameter is unnamed
+func paramName(sig *types.Signature, i int) string {
+	params := sig.Params()
+	if sig.Variadic() && i >= params.Len()-1 {
+		i = params.Len() - 1
+	}
+	if i >= params.Len() {
+		return ""
+	}
+	if name := params.At(i).Name(); name != "_" {
+		return name
+	}
+	return ""
+}
+
+// typeText returns how a file of a package refers to a type, qualifying the types of other
+// packages by the names the file imports them under. It reports false when the file
+// doesn't import a package the type needs.
+func typeText(typ types.Type, node *ast.File, pkg *types.Package) (string, bool) {
+	imported := true
+	text := types.TypeString(typ, func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		for _, spec := range node.Imports {
+			if path, _ := strconv.Unquote(spec.Path.Value); path != other.Path() {
+				continue
+			}
+			if spec.Name == nil {
+				return other.Name()
+			}
+			if spec.Name.Name == "." {
+				return ""
+			}
+			return spec.Name.Name
+		}
+		imported = false
+		return other.Name()
+	})
+	return text, imported
+}
+
+// checkedCall names a case after the first call in its assertion's if statement header that
+// is not made on the test itself, such as "Divide(6, 2)"; it falls back to the condition
+func checkedCall(fset *token.FileSet, stmt ast.Stmt, param string) string {
+	ifStmt := stmt.(*ast.IfStmt)
+	name := nodeString(fset, ifStmt.Cond)
+	found := false
+	inspect := func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Name == param {
+				return true
+			}
+		}
+		name = nodeString(fset, call)
+		found = true
+		return false
+	}
+	if ifStmt.Init != nil {
+		ast.Inspect(ifStmt.Init, inspect)
+	}
+	ast.Inspect(ifStmt.Cond, inspect)
+	return name
+}
@@ -0,0 +1,26 @@
+package geo
+
+// Point is a point on the plane
+type Point struct {
+	X, Y int
+}
+
+// Path is a sequence of points
+type Path struct {
+	Points []Point
+	length int
+}
+
+// Mirror reflects points across the y axis
+func Mirror(points []Point) []Point {
+	mirrored := make([]Point, len(points))
+	for i, p := range points {
+		mirrored[i] = Point{-p.X, p.Y}
+	}
+	return mirrored
+}
+
+// NewPath returns a path through the given points
+func NewPath(points ...Point) Path {
+	return Path{Points: points, length: len(points)}
+}
@@ -0,0 +1,46 @@
+package geo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMirror(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []Point
+		want   []Point
+	}{
+		{"origin", []Point{{0, 0}}, []Point{{0, 0}}},
+		{"two points", []Point{{1, 2}, {-3, 4}}, []Point{{-1, 2}, {3, 4}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Mirror(tc.points)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Mirror() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []Point
+		want   Path
+	}{
+		{"empty", nil, Path{}},
+		{"one point", []Point{{1, 1}}, Path{Points: []Point{{1, 1}}, length: 1}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := NewPath(tc.points...)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("NewPath() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
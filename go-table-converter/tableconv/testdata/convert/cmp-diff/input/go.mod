@@ -0,0 +1,5 @@
+module example.com/geo
+
+go 1.24
+
+require github.com/google/go-cmp v0.6.0
@@ -0,0 +1,46 @@
+package geo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMirror(t *testing.T) {
+	tests := map[string]struct {
+		points []Point
+		want   []Point
+	}{
+		"origin":     {[]Point{{0, 0}}, []Point{{0, 0}}},
+		"two points": {[]Point{{1, 2}, {-3, 4}}, []Point{{-1, 2}, {3, 4}}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := Mirror(tc.points)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewPath(t *testing.T) {
+	tests := map[string]struct {
+		points []Point
+		want   Path
+	}{
+		"empty":     {nil, Path{}},
+		"one point": {[]Point{{1, 1}}, Path{Points: []Point{{1, 1}}, length: 1}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := NewPath(tc.points...)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("NewPath() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package strs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpper(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		comment string
+	}{
+		{"empty", "", "", "nothing to do"},
+		{"word", "go", "GO", "letters only"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if got := strings.ToUpper(tc.in); got != tc.want {
+				t.Errorf("ToUpper(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		count int
+		want  string
+	}{
+		{"once", "ab", 1, "ab"},
+		{"twice", "ab", 2, "abab"},
+	}
+
+	for _, tc := range tests {
+		check(t, tc)
+	}
+}
+
+func check(t *testing.T, tc any) {
+	t.Helper()
+}
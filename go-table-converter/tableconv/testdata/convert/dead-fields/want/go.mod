@@ -0,0 +1,3 @@
+module example.com/strs
+
+go 1.24
@@ -0,0 +1,46 @@
+package strs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpper(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		in   string
+		want string
+	}{
+		"empty": {"", ""},
+		"word":  {"go", "GO"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if got := strings.ToUpper(tc.in); got != tc.want {
+				t.Errorf("ToUpper(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	tests := map[string]struct {
+		in    string
+		count int
+		want  string
+	}{
+		"once":  {"ab", 1, "ab"},
+		"twice": {"ab", 2, "abab"},
+	}
+
+	for _, tc := range tests {
+		check(t, tc)
+	}
+}
+
+func check(t *testing.T, tc any) {
+	t.Helper()
+}
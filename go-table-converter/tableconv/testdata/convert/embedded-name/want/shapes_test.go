@@ -0,0 +1,54 @@
+package shapes
+
+import "testing"
+
+type common struct {
+	name string
+	skip bool
+}
+
+type sized struct {
+	width, height int
+}
+
+func area(width, height int) int { return width * height }
+
+func TestAreaPromoted(t *testing.T) {
+	tests := []struct {
+		common
+		width, height int
+		want          int
+	}{
+		{common{name: "square"}, 2, 2, 4},
+		{common{name: "line"}, 3, 0, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.skip {
+				t.Skip()
+			}
+			if got := area(tc.width, tc.height); got != tc.want {
+				t.Errorf("area() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAreaEmbedded(t *testing.T) {
+	tests := map[string]struct {
+		sized
+		want int
+	}{
+		"square": {sized{2, 2}, 4},
+		"line":   {sized{3, 0}, 0},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := area(tc.width, tc.height); got != tc.want {
+				t.Errorf("area() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package mathx
+
+// Add returns the sum of a and b
+func Add(a, b int) int { return a + b }
+
+// Lookup returns the value of a key, or nil when there is none
+func Lookup(m map[string]*int, key string) *int { return m[key] }
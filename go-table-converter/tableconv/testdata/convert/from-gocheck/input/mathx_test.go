@@ -0,0 +1,40 @@
+package mathx
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MathSuite struct{}
+
+var _ = Suite(&MathSuite{})
+
+func (s *MathSuite) TestAdd(c *C) {
+	c.Assert(Add(1, 2), Equals, 3)
+	c.Check(Add(-1, 1), Equals, 0, Commentf("opposites"))
+}
+
+func (s *MathSuite) TestLookup(c *C) {
+	c.Assert(Lookup(nil, "a"), IsNil)
+}
+
+func (s *MathSuite) TestNil(c *C) {
+	c.Assert(nil, IsNil)
+}
+
+type StoreSuite struct {
+	values map[string]*int
+}
+
+var _ = Suite(&StoreSuite{})
+
+func (s *StoreSuite) SetUpTest(c *C) {
+	s.values = map[string]*int{}
+}
+
+func (s *StoreSuite) TestEmpty(c *C) {
+	c.Assert(Lookup(s.values, "a"), IsNil)
+}
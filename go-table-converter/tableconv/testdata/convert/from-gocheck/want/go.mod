@@ -0,0 +1,5 @@
+module example.com/mathx
+
+go 1.24
+
+require gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c
@@ -0,0 +1,46 @@
+package mathx
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MathSuite struct{}
+
+var _ = Suite(&MathSuite{})
+
+func TestMathAdd(t *testing.T) {
+	if got := Add(1, 2); got != 3 {
+		t.Fatalf("got %v, want %v", got, 3)
+	}
+	if got := Add(-1, 1); got != 0 {
+		t.Errorf("opposites: got %v, want %v", got, 0)
+	}
+}
+
+func TestMathLookup(t *testing.T) {
+	if got := Lookup(nil, "a"); got != nil {
+		t.Fatalf("got %v, want nil", got)
+	}
+}
+
+func (s *MathSuite) TestNil(c *C) {
+	c.Assert(nil, IsNil)
+}
+
+type StoreSuite struct {
+	values map[string]*int
+}
+
+var _ = Suite(&StoreSuite{})
+
+func (s *StoreSuite) SetUpTest(c *C) {
+	s.values = map[string]*int{}
+}
+
+func (s *StoreSuite) TestEmpty(c *C) {
+	c.Assert(Lookup(s.values, "a"), IsNil)
+}
@@ -0,0 +1,28 @@
+package report
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		items []string
+		want  string
+	}{
+		{name: "empty", title: "Nothing", want: "Nothing\n"},
+		{
+			name:  "groceries",
+			title: "Groceries",
+			items: []string{"apples", "bread", "cheese"},
+			want:  "Groceries\n  - apples\n  - bread\n  - cheese\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Render(tc.title, tc.items); got != tc.want {
+				t.Errorf("Render() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package report
+
+import "strings"
+
+// Render lays out a list of items as a bulleted report
+func Render(title string, items []string) string {
+	var b strings.Builder
+	b.WriteString(title + "\n")
+	for _, item := range items {
+		b.WriteString("  - " + item + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,42 @@
+package report
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestRender(t *testing.T) {
+	tests := map[string]struct {
+		title string
+		items []string
+	}{
+		"empty": {title: "Nothing"},
+		"groceries": {
+			title: "Groceries",
+			items: []string{"apples", "bread", "cheese"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := Render(tc.title, tc.items)
+			golden := filepath.Join("testdata", t.Name()+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatalf("error updating golden file: %v", err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("error reading golden file: %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("Render() = %q, want %q", got, string(want))
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package calc
+
+import "testing"
+
+func add(a, b int) int { return a + b }
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b int
+		want int
+	}{
+		{"zero", 0, 0, 0},
+		{"positive", 2, 3, 5},
+	}
+
+	for i := range tests {
+		t.Run(tests[i].name, func(t *testing.T) {
+			if got := add(tests[i].a, tests[i].b); got != tests[i].want {
+				t.Errorf("add(%d, %d) = %d, want %d", tests[i].a, tests[i].b, got, tests[i].want)
+			}
+		})
+	}
+}
+
+func TestAddFirst(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b int
+		want int
+	}{
+		{"zero", 0, 0, 0},
+		{"negative", -2, -3, -5},
+	}
+
+	if got := add(tests[0].a, tests[0].b); got != tests[0].want {
+		t.Errorf("first case: got %d", got)
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := add(tc.a, tc.b); got != tc.want {
+				t.Errorf("add(%d, %d) = %d, want %d", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAddCounted(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b int
+		want int
+	}{
+		{"zero", 0, 0, 0},
+		{"positive", 2, 3, 5},
+	}
+
+	for i := 0; i < len(tests); i++ {
+		t.Run(tests[i].name, func(t *testing.T) {
+			if got := add(tests[i].a, tests[i].b); got != tests[i].want {
+				t.Errorf("add(%d, %d) = %d", tests[i].a, tests[i].b, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package temp
+
+import "testing"
+
+func celsius(f float64) float64 { return (f - 32) * 5 / 9 }
+
+func TestCelsius(t *testing.T) {
+	tests := []struct {
+		name string
+		f    float64
+		exp  float64
+	}{
+		{"freezing", 32, 0},
+		{"boiling", 212, 100},
+	}
+
+	for _, tc := range tests {
+		if got := celsius(tc.f); got != tc.exp {
+			t.Errorf("celsius(%v) = %v, want %v", tc.f, got, tc.exp)
+		}
+	}
+}
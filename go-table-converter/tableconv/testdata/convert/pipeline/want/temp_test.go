@@ -0,0 +1,25 @@
+package temp
+
+import "testing"
+
+func celsius(f float64) float64 { return (f - 32) * 5 / 9 }
+
+func TestCelsius(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		f        float64
+		expected float64
+	}{
+		"freezing": {f: 32, expected: 0},
+		"boiling":  {f: 212, expected: 100},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if got := celsius(tc.f); got != tc.expected {
+				t.Errorf("celsius(%v) = %v, want %v", tc.f, got, tc.expected)
+			}
+		})
+	}
+}
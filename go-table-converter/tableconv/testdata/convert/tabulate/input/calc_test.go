@@ -0,0 +1,42 @@
+package calc
+
+import "testing"
+
+func TestDivide(t *testing.T) {
+	if got := Divide(6, 3); got != 2 {
+		t.Errorf("Divide(6, 3) = %d, want 2", got)
+	}
+	if got := Divide(9, 3); got != 3 {
+		t.Errorf("Divide(9, 3) = %d, want 3", got)
+	}
+	if got := Divide(5, 0); got != 0 {
+		t.Errorf("Divide(5, 0) = %d, want 0", got)
+	}
+}
+
+func TestDivideMessages(t *testing.T) {
+	if Divide(6, 2) != 3 {
+		t.Error("six halves failed")
+	}
+	if Divide(8, 2) != 4 {
+		t.Error("dividing eight went wrong")
+	}
+}
+
+func TestScale(t *testing.T) {
+	if got := Scale(1.5, 2); got != 3 {
+		t.Errorf("Scale(1.5, 2) = %v, want 3", got)
+	}
+	if got := Scale(2.5, 4); got != 10 {
+		t.Errorf("Scale(2.5, 4) = %v, want 10", got)
+	}
+}
+
+func TestRound(t *testing.T) {
+	if round(1.4) != 1 {
+		t.Error("rounds down")
+	}
+	if round(1.6) != 2 {
+		t.Error("rounds up")
+	}
+}
@@ -0,0 +1,14 @@
+package calc
+
+// Divide divides a by b, returning 0 when b is 0
+func Divide(a, b int) int {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+// Scale multiplies x by n
+func Scale(x float64, n int) float64 {
+	return x * float64(n)
+}
@@ -0,0 +1,69 @@
+package calc
+
+import "testing"
+
+func TestDivide(t *testing.T) {
+	tests := map[string]struct {
+		a    int
+		b    int
+		want int
+	}{
+		"Divide(6, 3)": {6, 3, 2},
+		"Divide(9, 3)": {9, 3, 3},
+		"Divide(5, 0)": {5, 0, 0},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Divide(tc.a, tc.b); got != tc.want {
+				t.Errorf("Divide(%v, %v) = %d, want %v", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDivideMessages(t *testing.T) {
+	tests := map[string]struct {
+		a    int
+		want int
+	}{
+		"six halves failed":         {6, 3},
+		"dividing eight went wrong": {8, 4},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if Divide(tc.a, 2) != tc.want {
+				t.Error(name)
+			}
+		})
+	}
+}
+
+func TestScale(t *testing.T) {
+	tests := map[string]struct {
+		x    float64
+		n    int
+		want float64
+	}{
+		"Scale(1.5, 2)": {1.5, 2, 3},
+		"Scale(2.5, 4)": {2.5, 4, 10},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Scale(tc.x, tc.n); got != tc.want {
+				t.Errorf("Scale(%v, %v) = %v, want %v", tc.x, tc.n, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRound(t *testing.T) {
+	if round(1.4) != 1 {
+		t.Error("rounds down")
+	}
+	if round(1.6) != 2 {
+		t.Error("rounds up")
+	}
+}
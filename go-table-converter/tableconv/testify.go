@@ -0,0 +1,336 @@
+package tableconv
+
+import (
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// AssertionStyle is the style assertions in loops over converted tables are migrated to
+type AssertionStyle string
+
+const (
+	// AssertionsUnchanged leaves assertions alone
+	AssertionsUnchanged AssertionStyle = ""
+	// AssertionsTestify turns 'if got != want { t.Errorf(...) }' blocks into testify's
+	// assert.Equal, or require.Equal for blocks calling t.Fatalf
+	AssertionsTestify AssertionStyle = "testify"
+	// AssertionsStd turns testify's Equal and NoError assertions back into if blocks
+	AssertionsStd AssertionStyle = "std"
+)
+
+// testifyModule is the module providing the assert and require packages
+const testifyModule = "github.com/stretchr/testify"
+
+// testifyPackages are the testify packages by the name assertions refer to them with
+var testifyPackages = map[string]string{
+	"assert":  testifyModule + "/assert",
+	"require": testifyModule + "/require",
+}
+
+// migrateAssertions migrates the assertions in the loops over converted tables of a file to
+// the configured style, importing the testify packages they use. Migrating to testify
+// leaves files alone whose module doesn't require it, or that refer to something else as
+// assert or require. It reports whether any assertion was migrated.
+func (c *Converter) migrateAssertions(fset *token.FileSet, file *sourceFile, vars tableVars) bool {
+	if len(vars) == 0 {
+		return false
+	}
+
+	// Only assertions referring to the testify packages themselves are migrated back
+	imported := make(map[string]bool)
+	for _, spec := range file.node.Imports {
+		name := importName(spec)
+		path, ok := testifyPackages[name]
+		if !ok {
+			continue
+		}
+		if spec.Path.Value != strconv.Quote(path) {
+			c.logf("Skipping assertions in %s: %s refers to %s\n", file.path, name, spec.Path.Value)
+			return false
+		}
+		imported[name] = true
+	}
+
+	if c.opts.Assertions == AssertionsTestify && !moduleRequires(filepath.Dir(file.path), testifyModule) {
+		c.logf("Skipping assertions in %s: the module doesn't require %s\n", file.path, testifyModule)
+		return false
+	}
+
+	used := make(map[string]bool)
+	modified := false
+	for _, decl := range file.node.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+
+		migrated := false
+		switch c.opts.Assertions {
+		case AssertionsTestify:
+			if (usesName(fn.Body, "assert") && !imported["assert"]) || (usesName(fn.Body, "require") && !imported["require"]) {
+				continue
+			}
+			migrated = toTestify(fset, fn, vars, used)
+		case AssertionsStd:
+			migrated = fromTestify(fn, vars, imported)
+		}
+		if migrated {
+			c.logf("Migrated assertions in %s to %s\n", fn.Name.Name, c.opts.Assertions)
+			modified = true
+		}
+	}
+
+	for _, name := range []string{"assert", "require"} {
+		if used[name] && !imported[name] {
+			addImport(fset, file.node, testifyPackages[name])
+		}
+	}
+	return modified
+}
+
+// toTestify turns the assertions in the loops over converted tables of a function into
+// testify assertions, recording the testify packages used. Outside subtests the case
+// name becomes the message of the assertion.
+// Change from: if got != tc.want { t.Errorf("got %d, want %d", got, tc.want) }
+// To:          assert.Equal(t, tc.want, got)
+// Blocks calling t.Fatal or t.Fatalf use require instead, and 'if err != nil' blocks
+// become assert.NoError(t, err). It reports whether any assertion was migrated.
+func toTestify(fset *token.FileSet, fn *ast.FuncDecl, vars tableVars, used map[string]bool) bool {
+	modified := false
+	forRangedLoops(fn, vars, func(rangeStmt *ast.RangeStmt) {
+		inSubtest := subtestStmts(rangeStmt.Body)
+		ast.Inspect(rangeStmt.Body, func(n ast.Node) bool {
+			block, ok := n.(*ast.BlockStmt)
+			if !ok {
+				return true
+			}
+
+			for i, stmt := range block.List {
+				ifStmt, ok := stmt.(*ast.IfStmt)
+				if !ok || ifStmt.Init != nil || ifStmt.Else != nil {
+					continue
+				}
+				call, sel, ok := failureBlock(ifStmt.Body)
+				if !ok {
+					continue
+				}
+
+				// The assertion and its arguments after the testing.T
+				var assertion string
+				var args []ast.Expr
+				if want, got, ok := deepEqualArgs(ifStmt.Cond); ok {
+					assertion, args = "Equal", []ast.Expr{want, got}
+				} else if cond, ok := ifStmt.Cond.(*ast.BinaryExpr); ok && cond.Op == token.NEQ {
+					switch {
+					case isNil(cond.Y) && errorValue(cond.X):
+						assertion, args = "NoError", []ast.Expr{cond.X}
+					case isNil(cond.X) || isNil(cond.Y):
+						continue
+					case expectedValue(cond.X) && !expectedValue(cond.Y):
+						assertion, args = "Equal", []ast.Expr{cond.X, cond.Y}
+					default:
+						assertion, args = "Equal", []ast.Expr{cond.Y, cond.X}
+					}
+				} else {
+					continue
+				}
+
+				pkg := "assert"
+				if strings.HasPrefix(sel.Sel.Name, "Fatal") {
+					pkg = "require"
+				}
+				used[pkg] = true
+
+				pos := ifStmt.Pos()
+				args = append([]ast.Expr{&ast.Ident{NamePos: pos, Name: sel.X.(*ast.Ident).Name}}, args...)
+				if !inSubtest[ifStmt] {
+					if key := rangeKey(rangeStmt); key != nil {
+						args = append(args, &ast.Ident{NamePos: pos, Name: key.Name})
+					}
+				}
+
+				// The block shrinks to a single line
+				if file := fset.File(pos); file != nil {
+					for merges := file.Line(ifStmt.End()) - file.Line(pos); merges > 0; merges-- {
+						file.MergeLine(file.Line(pos))
+					}
+				}
+				block.List[i] = &ast.ExprStmt{X: &ast.CallExpr{
+					Fun:    &ast.SelectorExpr{X: &ast.Ident{NamePos: pos, Name: pkg}, Sel: &ast.Ident{NamePos: pos, Name: assertion}},
+					Lparen: pos,
+					Args:   args,
+					Rparen: call.Rparen,
+				}}
+				modified = true
+			}
+			return true
+		})
+	})
+	return modified
+}
+
+// fromTestify turns the testify Equal and NoError assertions in the loops over converted
+// tables of a function into if blocks reporting with the testing.T, which fail with
+// t.Errorf for assert and t.Fatalf for require. Values compared with Equal are compared
+// with != when one of them is a literal, and with reflect.DeepEqual otherwise.
+// Change from: assert.Equal(t, tc.want, got)
+// To:          if !reflect.DeepEqual(got, tc.want) { t.Errorf("got %v, want %v", got, tc.want) }
+// It reports whether any assertion was migrated.
+func fromTestify(fn *ast.FuncDecl, vars tableVars, imported map[string]bool) bool {
+	modified := false
+	forRangedLoops(fn, vars, func(rangeStmt *ast.RangeStmt) {
+		ast.Inspect(rangeStmt.Body, func(n ast.Node) bool {
+			block, ok := n.(*ast.BlockStmt)
+			if !ok {
+				return true
+			}
+
+			for i, stmt := range block.List {
+				if ifStmt := stdAssertion(stmt, imported); ifStmt != nil {
+					block.List[i] = ifStmt
+					modified = true
+				}
+			}
+			return true
+		})
+	})
+	return modified
+}
+
+// stdAssertion returns the if block replacing a testify assertion statement, or nil if the
+// statement is no Equal or NoError assertion with a literal message, if any
+func stdAssertion(stmt ast.Stmt, imported map[string]bool) *ast.IfStmt {
+	exprStmt, ok := stmt.(*ast.ExprStmt)
+	if !ok {
+		return nil
+	}
+	call, ok := exprStmt.X.(*ast.CallExpr)
+	if !ok || call.Ellipsis.IsValid() {
+		return nil
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok || pkg.Obj != nil || !imported[pkg.Name] {
+		return nil
+	}
+
+	// The values checked follow the testing.T
+	values := 2
+	if sel.Sel.Name == "NoError" {
+		values = 1
+	} else if sel.Sel.Name != "Equal" {
+		return nil
+	}
+	if len(call.Args) < 1+values {
+		return nil
+	}
+	t, ok := call.Args[0].(*ast.Ident)
+	if !ok {
+		return nil
+	}
+
+	// A message leads the failure report: a format and its arguments
+	format, extra := "", call.Args[1+values:]
+	if len(extra) > 0 {
+		lit, ok := extra[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return nil
+		}
+		msg, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			return nil
+		}
+		format, extra = msg+": ", extra[1:]
+	}
+
+	pos := stmt.Pos()
+	var cond ast.Expr
+	var args []ast.Expr
+	if values == 1 {
+		err := call.Args[1]
+		cond = &ast.BinaryExpr{X: err, OpPos: pos, Op: token.NEQ, Y: &ast.Ident{NamePos: pos, Name: "nil"}}
+		format += "unexpected error: %v"
+		args = []ast.Expr{err}
+	} else {
+		expected, got := call.Args[1], call.Args[2]
+		_, literal := expected.(*ast.BasicLit)
+		if _, ok := got.(*ast.BasicLit); ok {
+			literal = true
+		}
+		if literal {
+			cond = &ast.BinaryExpr{X: got, OpPos: pos, Op: token.NEQ, Y: expected}
+		} else {
+			cond = &ast.UnaryExpr{OpPos: pos, Op: token.NOT, X: &ast.CallExpr{
+				Fun:    &ast.SelectorExpr{X: &ast.Ident{NamePos: pos, Name: "reflect"}, Sel: &ast.Ident{NamePos: pos, Name: "DeepEqual"}},
+				Lparen: pos,
+				Args:   []ast.Expr{got, expected},
+				Rparen: pos,
+			}}
+		}
+		format += "got %v, want %v"
+		args = []ast.Expr{got, expected}
+	}
+
+	method := "Errorf"
+	if pkg.Name == "require" {
+		method = "Fatalf"
+	}
+	report := &ast.CallExpr{
+		Fun:    &ast.SelectorExpr{X: &ast.Ident{NamePos: pos, Name: t.Name}, Sel: &ast.Ident{NamePos: pos, Name: method}},
+		Lparen: pos,
+		Args:   append(append([]ast.Expr{&ast.BasicLit{ValuePos: pos, Kind: token.STRING, Value: strconv.Quote(format)}}, extra...), args...),
+		Rparen: call.Rparen,
+	}
+	return &ast.IfStmt{
+		If:   pos,
+		Cond: cond,
+		Body: &ast.BlockStmt{Lbrace: pos, List: []ast.Stmt{&ast.ExprStmt{X: report}}, Rbrace: call.Rparen},
+	}
+}
+
+// failureBlock returns the call of a block that does nothing but report a failure with
+// t.Error, t.Errorf, t.Fatal, or t.Fatalf, and its selector
+func failureBlock(body *ast.BlockStmt) (*ast.CallExpr, *ast.SelectorExpr, bool) {
+	if len(body.List) != 1 {
+		return nil, nil, false
+	}
+	stmt, ok := body.List[0].(*ast.ExprStmt)
+	if !ok {
+		return nil, nil, false
+	}
+	call, ok := stmt.X.(*ast.CallExpr)
+	if !ok {
+		return nil, nil, false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil, nil, false
+	}
+	if _, ok := sel.X.(*ast.Ident); !ok {
+		return nil, nil, false
+	}
+	switch sel.Sel.Name {
+	case "Error", "Errorf", "Fatal", "Fatalf":
+		return call, sel, true
+	}
+	return nil, nil, false
+}
+
+// isNil reports whether an expression is the identifier nil
+func isNil(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == "nil"
+}
+
+// errorValue reports whether an expression is a variable named like an error ('err',
+// 'parseErr')
+func errorValue(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && (ident.Name == "err" || strings.HasSuffix(ident.Name, "Err"))
+}
@@ -0,0 +1,34 @@
+package tableconv
+
+import "time"
+
+// Timings breaks down the time a conversion spent in each of its phases
+type Timings struct {
+	// Parse covers reading and parsing files
+	Parse time.Duration
+	// Transform covers finding and rewriting tables, and running rules
+	Transform time.Duration
+	// Format covers printing the rewritten files
+	Format time.Duration
+	// TypeCheck covers checking that converted packages still compile
+	TypeCheck time.Duration
+	// Write covers writing files and recording them in the journal
+	Write time.Duration
+	// Tests covers the test runs of Options.VerifyTests
+	Tests time.Duration
+}
+
+// Total returns the time spent in all phases
+func (t Timings) Total() time.Duration {
+	return t.Parse + t.Transform + t.Format + t.TypeCheck + t.Write + t.Tests
+}
+
+// add adds the times of other to t
+func (t *Timings) add(other Timings) {
+	t.Parse += other.Parse
+	t.Transform += other.Transform
+	t.Format += other.Format
+	t.TypeCheck += other.TypeCheck
+	t.Write += other.Write
+	t.Tests += other.Tests
+}
@@ -0,0 +1,249 @@
+package tableconv
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// checkPackage type-checks the converted files of a package as a whole before anything
+// is written. Errors that the original source already had (such as imports that can't be
+// resolved here) are ignored. Once the conversion of a file introduces new errors, or a
+// file fails to convert at all, none of the files of the package is written: the file at
+// fault reports the table and diagnostic responsible, and the other modified files the
+// file and table that kept them from being written.
+func (c *Converter) checkPackage(files []*sourceFile) {
+	modified := false
+	for _, file := range files {
+		if file.result.Modified {
+			modified = true
+		}
+	}
+	if !modified {
+		return
+	}
+
+	// culprits describes the failures that keep the package from being written
+	var culprits []string
+	for _, file := range files {
+		if file.err != nil {
+			culprits = append(culprits, filepath.Base(file.path))
+		}
+	}
+
+	if !c.opts.SkipTypeCheck {
+		// Files of the package that aren't being converted still declare identifiers the tests use
+		siblings := c.siblingSources(files)
+
+		before := make([]namedSource, 0, len(files)+len(siblings))
+		after := make([]namedSource, 0, len(files)+len(siblings))
+		for _, file := range files {
+			before = append(before, namedSource{file.path, file.src})
+			after = append(after, namedSource{file.path, file.out})
+		}
+		before = append(before, siblings...)
+		after = append(after, siblings...)
+
+		known := make(map[string]int)
+		for _, err := range c.typeCheck(token.NewFileSet(), before, nil) {
+			known[err.Msg]++
+		}
+
+		for _, err := range c.typeCheck(token.NewFileSet(), after, nil) {
+			if known[err.Msg] > 0 {
+				known[err.Msg]--
+				continue
+			}
+
+			pos := err.Fset.Position(err.Pos)
+			for _, file := range files {
+				if file.path != pos.Filename || !file.result.Modified || file.err != nil {
+					continue
+				}
+
+				tables := culpritTables(file, err)
+				file.err = fmt.Errorf("converted file does not compile: %s%s: %s", tables, pos, err.Msg)
+				file.result = FileResult{Path: file.path}
+				culprit := filepath.Base(file.path)
+				if tables != "" {
+					culprit = strings.TrimSuffix(tables, ": ") + " in " + culprit
+				}
+				culprits = append(culprits, culprit)
+			}
+		}
+	}
+	if len(culprits) == 0 {
+		return
+	}
+
+	for _, file := range files {
+		if file.result.Modified && file.err == nil {
+			file.err = fmt.Errorf("not converted: the conversion of %s, in the same package, failed", strings.Join(culprits, "; "))
+			file.result = FileResult{Path: file.path}
+		}
+	}
+}
+
+// namedSource is the content of a Go file and the name it is checked under
+type namedSource struct {
+	path string
+	src  []byte
+}
+
+// typeCheck type-checks a package from source and returns every error found, recording
+// the types of its expressions in info unless it is nil
+func (c *Converter) typeCheck(fset *token.FileSet, srcs []namedSource, info *types.Info) []types.Error {
+	var errs []types.Error
+	var nodes []*ast.File
+	for _, src := range srcs {
+		node, err := parser.ParseFile(fset, src.path, src.src, 0)
+		if err != nil {
+			errs = append(errs, types.Error{Fset: fset, Msg: err.Error()})
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 0 {
+		return errs
+	}
+
+	_, checkErrs := c.checkFiles(fset, nodes, info)
+	return append(errs, checkErrs...)
+}
+
+// checkFiles type-checks the parsed files of a package, recording the types of their
+// expressions in info unless it is nil, and returns the package along with every error
+// found
+func (c *Converter) checkFiles(fset *token.FileSet, nodes []*ast.File, info *types.Info) (*types.Package, []types.Error) {
+	if c.importer == nil {
+		c.importer = newImporter()
+	}
+
+	var errs []types.Error
+	conf := types.Config{
+		Importer: c.importer,
+		Error: func(err error) {
+			if typeErr, ok := err.(types.Error); ok {
+				errs = append(errs, typeErr)
+			}
+		},
+	}
+	pkg, _ := conf.Check(nodes[0].Name.Name, fset, nodes, info)
+
+	return pkg, errs
+}
+
+// newImporter returns an importer resolving imports from source, the way the go tool
+// would for the package being checked
+func newImporter() types.ImporterFrom {
+	return importer.ForCompiler(token.NewFileSet(), "source", nil).(types.ImporterFrom)
+}
+
+// sharedImporter lets converters checking packages concurrently, such as the ones serving
+// the requests of Handler, load each imported package once. The source importer isn't
+// safe for concurrent use, so imports take turns.
+type sharedImporter struct {
+	mu       sync.Mutex
+	importer types.ImporterFrom
+}
+
+func (si *sharedImporter) Import(path string) (*types.Package, error) {
+	return si.ImportFrom(path, ".", 0)
+}
+
+func (si *sharedImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	return si.importer.ImportFrom(path, dir, mode)
+}
+
+// culpritTables describes the converted tables responsible for a type error: the table
+// ranged over by the loop containing the error, or else the tables of its function
+func culpritTables(file *sourceFile, err types.Error) string {
+	funcName, rangedTable := "", ""
+	node, parseErr := parser.ParseFile(token.NewFileSet(), file.path, file.out, 0)
+	if parseErr == nil {
+		// The file is alone in its file set, so positions map directly to offsets
+		offset := token.Pos(err.Fset.Position(err.Pos).Offset + 1)
+		for _, decl := range node.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok || offset < funcDecl.Pos() || offset >= funcDecl.End() {
+				continue
+			}
+
+			funcName = funcDecl.Name.Name
+			ast.Inspect(funcDecl, func(n ast.Node) bool {
+				if rangeStmt, ok := n.(*ast.RangeStmt); ok && rangeStmt.Pos() <= offset && offset < rangeStmt.End() {
+					if ident, ok := rangeStmt.X.(*ast.Ident); ok {
+						rangedTable = ident.Name
+					}
+				}
+				return true
+			})
+		}
+	}
+
+	var names []string
+	for _, table := range file.result.Tables {
+		if table.Func == funcName && (rangedTable == "" || table.Name == rangedTable) {
+			names = append(names, table.Name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("table %s: ", strings.Join(names, ", "))
+}
+
+// siblingSources reads the other Go files of the package in the directory of the given
+// files, as the overlay has them if it holds them
+func (c *Converter) siblingSources(files []*sourceFile) []namedSource {
+	if len(files) == 0 || files[0].path == "" {
+		return nil
+	}
+
+	converting := make(map[string]bool)
+	for _, file := range files {
+		converting[filepath.Clean(file.path)] = true
+	}
+
+	dir := filepath.Dir(files[0].path)
+	pkgName := files[0].node.Name.Name
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var siblings []namedSource
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() || !strings.HasSuffix(path, ".go") || converting[path] {
+			continue
+		}
+
+		src, ok := c.overlay[path]
+		if !ok {
+			if src, err = os.ReadFile(path); err != nil {
+				continue
+			}
+		}
+
+		node, err := parser.ParseFile(token.NewFileSet(), path, src, parser.PackageClauseOnly)
+		if err != nil || node.Name.Name != pkgName {
+			continue
+		}
+
+		siblings = append(siblings, namedSource{path, src})
+	}
+
+	return siblings
+}
@@ -0,0 +1,133 @@
+package tableconv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// packageFailure stands for failures of a package outside of its tests, such as build
+// failures or a failing TestMain
+const packageFailure = "(package)"
+
+// testRun is the outcome of running the tests of a package
+type testRun struct {
+	// failed holds the top-level tests that failed, and packageFailure when the package
+	// failed without a test failing
+	failed map[string]bool
+	// output holds the output of each top-level test, and of the package under
+	// packageFailure
+	output map[string]*strings.Builder
+}
+
+// testEvent is an event of 'go test -json'
+type testEvent struct {
+	Action string
+	Test   string
+	Output string
+}
+
+// runPackageTests runs the tests of the package in a directory. Failing tests are part
+// of the outcome; the error is for tests that couldn't be run at all.
+func runPackageTests(dir string) (testRun, error) {
+	cmd := exec.Command("go", "test", "-json", "-count=1", ".")
+	cmd.Dir = dir
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return testRun{}, fmt.Errorf("error running go test: %v", err)
+		}
+	}
+
+	run := testRun{failed: make(map[string]bool), output: make(map[string]*strings.Builder)}
+	write := func(test, text string) {
+		if run.output[test] == nil {
+			run.output[test] = &strings.Builder{}
+		}
+		run.output[test].WriteString(text)
+	}
+	packageFailed := false
+	scanner := bufio.NewScanner(&out)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		var event testEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// Build errors are printed as they are
+			write(packageFailure, scanner.Text()+"\n")
+			continue
+		}
+
+		// Subtests are attributed to their test, since conversion may rename cases
+		test, _, _ := strings.Cut(event.Test, "/")
+		if test == "" {
+			test = packageFailure
+		}
+		switch event.Action {
+		case "output", "build-output":
+			write(test, event.Output)
+		case "fail":
+			if test == packageFailure {
+				packageFailed = true
+			} else {
+				run.failed[test] = true
+			}
+		}
+	}
+	if packageFailed && len(run.failed) == 0 {
+		run.failed[packageFailure] = true
+	}
+	return run, scanner.Err()
+}
+
+// runTests runs the tests of the package in a directory, timing them
+func (c *Converter) runTests(dir string) (testRun, error) {
+	start := time.Now()
+	defer func() {
+		c.timings.Tests += time.Since(start)
+	}()
+	return runPackageTests(dir)
+}
+
+// newFailures returns the tests failing in run that passed in baseline, sorted
+func (run testRun) newFailures(baseline testRun) []string {
+	var tests []string
+	for test := range run.failed {
+		if !baseline.failed[test] {
+			tests = append(tests, test)
+		}
+	}
+	slices.Sort(tests)
+	return tests
+}
+
+// failureOutput returns the output of the given tests
+func (run testRun) failureOutput(tests []string) string {
+	var sb strings.Builder
+	for _, test := range tests {
+		if out := run.output[test]; out != nil {
+			sb.WriteString(out.String())
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// declaresTest reports whether a file declares a test function of the given name
+func (file *sourceFile) declaresTest(name string) bool {
+	if file.node == nil {
+		return false
+	}
+	for _, decl := range file.node.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == name {
+			return true
+		}
+	}
+	return false
+}
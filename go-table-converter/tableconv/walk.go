@@ -0,0 +1,65 @@
+package tableconv
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// walkFiles calls fn for every file under root, and for every error accessing the tree,
+// skipping the directories the options leave out. It stops at the first error fn returns.
+//
+// Symbolic links to directories are skipped unless Options.FollowSymlinks is set, like
+// the go tool does; root itself is always followed. When following links, every directory
+// is walked once, under the first path it is reached by, so links pointing back up the
+// tree or at a directory walked already neither loop nor convert files twice.
+func (c *Converter) walkFiles(root string, fn func(path string, err error) error) error {
+	// walked holds the real paths of the directories walked so far when following links,
+	// the only case in which a directory can be reached twice
+	walked := make(map[string]bool)
+	var walk func(root string) error
+	walk = func(root string) error {
+		return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return fn(path, err)
+			}
+
+			if entry.IsDir() {
+				if path != root && c.skipDir(entry.Name()) {
+					c.logf("Skipping directory: %s\n", path)
+					return filepath.SkipDir
+				}
+				if c.opts.FollowSymlinks {
+					real, err := filepath.EvalSymlinks(path)
+					if err != nil {
+						return fn(path, err)
+					}
+					if walked[real] {
+						c.logf("Skipping directory walked already: %s\n", path)
+						return filepath.SkipDir
+					}
+					walked[real] = true
+				}
+				return nil
+			}
+
+			// Links to files are converted like files, through the link
+			if entry.Type()&fs.ModeSymlink != 0 {
+				if info, err := os.Stat(path); err == nil && info.IsDir() {
+					if !c.opts.FollowSymlinks || c.skipDir(entry.Name()) {
+						c.logf("Skipping symlinked directory: %s\n", path)
+						return nil
+					}
+					return walk(path + string(filepath.Separator))
+				}
+			}
+			return fn(path, nil)
+		})
+	}
+
+	// WalkDir doesn't descend into links, unless they are named as directories
+	if info, err := os.Lstat(root); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		root += string(filepath.Separator)
+	}
+	return walk(root)
+}
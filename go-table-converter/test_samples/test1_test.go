@@ -5,13 +5,13 @@ import "testing"
 // TestAddition tests the Add function
 func TestAddition(t *testing.T) {
 	tests := map[string]struct {
-		a		int
-		b		int
-		expected	int
+		a        int
+		b        int
+		expected int
 	}{
-		"simple sum":		{1, 2, 3},
-		"zero value":		{0, 5, 5},
-		"negative numbers":	{-2, -3, -5},
+		"simple sum":       {1, 2, 3},
+		"zero value":       {0, 5, 5},
+		"negative numbers": {-2, -3, -5},
 	}
 
 	for name, tc := range tests {
@@ -23,4 +23,3 @@ func TestAddition(t *testing.T) {
 		})
 	}
 }
-
@@ -5,13 +5,13 @@ import "testing"
 // TestMultiplication tests the Multiply function
 func TestMultiplication(t *testing.T) {
 	testCases := map[string]struct {
-		a		int
-		b		int
-		expected	int
+		a        int
+		b        int
+		expected int
 	}{
-		"simple multiply":	{2, 3, 6},
-		"multiply by zero":	{5, 0, 0},
-		"negative values":	{-2, 4, -8},
+		"simple multiply":  {2, 3, 6},
+		"multiply by zero": {5, 0, 0},
+		"negative values":  {-2, 4, -8},
 	}
 
 	for name, tc := range testCases {
@@ -21,4 +21,3 @@ func TestMultiplication(t *testing.T) {
 		}
 	}
 }
-
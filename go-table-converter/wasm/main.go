@@ -0,0 +1,52 @@
+//go:build js && wasm
+
+// Command wasm is the converter built for browsers, for playgrounds converting the table
+// tests pasted into a web page. Build it with GOOS=js GOARCH=wasm and load it with the
+// wasm_exec.js of the Go distribution; it defines a global function
+//
+//	tabletestsConvert(source, options) -> {source, error}
+//
+// converting the Go source as a file of its own. options is optional and may set
+// subtests, parallel, keyedFields, ignoreOrder, suffixDuplicates, and dedupe. Converted
+// sources aren't type-checked, since the browser has no packages to check them against.
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/khalilchatoo/claude-playground/go-table-converter/tableconv"
+)
+
+func main() {
+	js.Global().Set("tabletestsConvert", js.FuncOf(convert))
+
+	// The function stays callable for as long as the page is open
+	select {}
+}
+
+// convert converts the source given as the first argument with the options of the second
+func convert(this js.Value, args []js.Value) any {
+	if len(args) == 0 || args[0].Type() != js.TypeString {
+		return map[string]any{"error": "tabletestsConvert takes the Go source as a string"}
+	}
+
+	opts := tableconv.Options{SkipTypeCheck: true}
+	if len(args) > 1 && args[1].Type() == js.TypeObject {
+		flag := func(name string) bool {
+			v := args[1].Get(name)
+			return v.Type() == js.TypeBoolean && v.Bool()
+		}
+		opts.Subtests = flag("subtests")
+		opts.Parallel = flag("parallel")
+		opts.KeyedFields = flag("keyedFields")
+		opts.IgnoreOrder = flag("ignoreOrder")
+		opts.SuffixDuplicates = flag("suffixDuplicates")
+		opts.Dedupe = flag("dedupe")
+	}
+
+	out, err := tableconv.NewConverter(opts).ConvertSource([]byte(args[0].String()))
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	return map[string]any{"source": string(out)}
+}